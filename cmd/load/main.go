@@ -11,8 +11,12 @@ import (
 	"net/http"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/binproto"
+	binclient "github.com/2019UGEC100/order-matching-engine-go/pkg/binproto/client"
 )
 
 type Req struct {
@@ -50,6 +54,8 @@ func main() {
 		symbol    = flag.String("sym", "LOAD", "symbol")
 		sleepMs   = flag.Int("sleep", 0, "ms sleep between requests per goroutine")
 		statsMode = flag.Bool("stats", false, "record per-request latency and print p50/p90/p99")
+		binAddr   = flag.String("bin", "", "use the binary order entry protocol at this address (e.g. 127.0.0.1:9100) instead of HTTP")
+		apiKey    = flag.String("key", "", "API key (id:secret) sent as X-API-Key, or in the binary Logon")
 	)
 	flag.Parse()
 
@@ -81,6 +87,26 @@ func main() {
 		Timeout:   30 * time.Second,
 	}
 
+	// Binary protocol: one connection per worker, so -c is also the number
+	// of connections as with HTTP keep-alive
+	var binClients []*binclient.Client
+	if *binAddr != "" {
+		for i := 0; i < *conns; i++ {
+			bc, err := binclient.Dial(*binAddr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "dial %s: %v\n", *binAddr, err)
+				os.Exit(1)
+			}
+			defer bc.Close()
+			id, secret, _ := strings.Cut(*apiKey, ":")
+			if err := bc.Logon(id, secret); err != nil {
+				fmt.Fprintf(os.Stderr, "logon: %v\n", err)
+				os.Exit(1)
+			}
+			binClients = append(binClients, bc)
+		}
+	}
+
 	// Prepare workload distribution
 	reqsPerWorker := (*total + *conns - 1) / *conns
 	var wg sync.WaitGroup
@@ -124,11 +150,25 @@ func main() {
 			var err error
 			maxRetries := 5
 			baseDelay := 50 * time.Millisecond
+			if binClients != nil {
+				// no retries: a binary connection either works or is gone
+				_, err = binClients[id].Submit(binproto.NewOrder{
+					Symbol:   r.Symbol,
+					Side:     binproto.SideBuy,
+					OrdType:  binproto.OrdLimit,
+					Price:    r.Price,
+					Quantity: r.Quantity,
+				})
+				maxRetries = -1
+			}
 
 			for attempt := 0; attempt <= maxRetries; attempt++ {
 				// create a fresh request for this attempt (new Body reader)
 				req, _ := http.NewRequest("POST", *urlFlag, bytes.NewReader(b))
 				req.Header.Set("Content-Type", "application/json")
+				if *apiKey != "" {
+					req.Header.Set("X-API-Key", *apiKey)
+				}
 
 				resp, err = client.Do(req)
 				if err == nil {
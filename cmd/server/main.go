@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -10,21 +12,229 @@ import (
 	"runtime"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/accounts"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/api"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	binserver "github.com/2019UGEC100/order-matching-engine-go/pkg/binproto/server"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/execfeed"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/fees"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/fix"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/grpcapi"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/marketdata"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/metrics"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/positions"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/risk"
 )
 
 func main() {
+	fixAddr := flag.String("fix", ":9878", "FIX 4.4 order entry listen address, logons need an API key (empty disables)")
+	fixCompID := flag.String("fix-compid", "EXCH", "FIX SenderCompID of the gateway")
+	fixStore := flag.String("fix-store", "fixstore", "directory for FIX session sequence numbers and messages")
+	grpcAddr := flag.String("grpc", ":9090", "gRPC listen address, order calls need an API key (empty disables)")
+	binAddr := flag.String("bin", ":9100", "binary order entry listen address, connections log on with an API key (empty disables)")
+	keysFile := flag.String("keys", "apikeys.json", "API key file, managed through /admin/v1/keys")
+	rlAccount := flag.String("rl-account", "order=50:100,cancel=100:200,query=100:200", "per-account rate limits, class=rate[:burst] per second (empty disables)")
+	spot := flag.Bool("spot", false, "enforce account balances: orders must be funded (deposits via /admin/v1/accounts)")
+	sessionRoll := flag.String("session-roll", "00:00", "daily start of the ticker session (open, high, low) at HH:MM UTC")
+	eod := flag.String("eod", "", "daily mark-to-market of open positions at HH:MM UTC (empty disables)")
+	matching := flag.String("matching", "", "per-symbol matching algorithm, e.g. ZN=pro_rata:lot=5:min=10,ZB=hybrid:top=20 (others FIFO)")
+	feesFile := flag.String("fees", "", "JSON file with maker/taker fee schedules and account tiers (empty: no fees)")
+	riskFile := flag.String("risk", "", "JSON file with pre-trade risk limits (empty: no limits)")
+	spreadsFlag := flag.String("spreads", "", "spread instruments, e.g. ESZ4-ESH5=ESZ4:1,ESH5:-1;... (empty: none)")
+	rlIP := flag.String("rl-ip", "order=200:400,cancel=400:800,query=500:1000", "per-IP rate limits, class=rate[:burst] per second (empty disables)")
+	flag.Parse()
+
 	// use all available CPUs
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	// Event bus: every shard publishes order events, trades and level
+	// changes here; market data, persistence and audit consumers subscribe.
+	bus := events.NewBus()
+
+	// L2 market data hub must subscribe before the first order arrives
+	l2 := marketdata.NewHub(bus)
+	defer l2.Close()
+	l3 := marketdata.NewL3Hub(bus)
+	defer l3.Close()
+	candles := marketdata.NewCandleStore(bus, marketdata.DefaultCandleHistory)
+	defer candles.Close()
+	executions := execfeed.NewJournal(bus, execfeed.DefaultJournalSize)
+	defer executions.Close()
+
+	// Positions and P&L per account, marked to market at end of day
+	tracker := positions.NewTracker(bus)
+	defer tracker.Close()
+	if *eod != "" {
+		at, err := positions.ParseEOD(*eod)
+		if err != nil {
+			log.Fatalf("-eod: %v", err)
+		}
+		tracker.RunEOD(at, func(s []positions.Settlement) {
+			for _, st := range s {
+				log.Printf("mark-to-market %s %s net %d at %d: pnl %d\n", st.Account, st.Symbol, st.Net, st.MarkPrice, st.PnL)
+			}
+		})
+	}
+
+	// Pre-trade risk checks run before an order reaches its shard
+	var riskCfg risk.Config
+	if *riskFile != "" {
+		cfg, err := risk.LoadConfig(*riskFile)
+		if err != nil {
+			log.Fatalf("risk limits: %v", err)
+		}
+		riskCfg = cfg
+	}
+	riskChecker := risk.NewChecker(bus, riskCfg)
+	defer riskChecker.Close()
+	metrics.Register("risk_rejections", func() interface{} { return riskChecker.Rejections() })
+
+	opts := []engine.Option{engine.WithEventBus(bus), engine.WithPreTrade(riskChecker)}
+
+	// Ticker sessions: open, high and low restart daily
+	roll, err := positions.ParseEOD(*sessionRoll)
+	if err != nil {
+		log.Fatalf("-session-roll: %v", err)
+	}
+	opts = append(opts, engine.WithSessionRoll(roll))
+
+	// Matching algorithm per instrument; FIFO unless configured
+	matchers, err := engine.ParseMatching(*matching)
+	if err != nil {
+		log.Fatalf("-matching: %v", err)
+	}
+	opts = append(opts, engine.WithMatching(func(symbol string) engine.Matcher { return matchers[symbol] }))
+
+	// Spreads and their legs, matched with implied liquidity
+	spreads, err := engine.ParseSpreads(*spreadsFlag)
+	if err != nil {
+		log.Fatalf("-spreads: %v", err)
+	}
+	if *spot && len(spreads) > 0 {
+		log.Fatal("-spreads: account balances do not support spread instruments")
+	}
+	opts = append(opts, engine.WithSpreads(spreads...))
+
+	// Maker-taker fees, charged as each trade executes
+	if *feesFile != "" {
+		cfg, err := fees.LoadConfig(*feesFile)
+		if err != nil {
+			log.Fatalf("fees: %v", err)
+		}
+		feeEngine := fees.NewEngine(bus, cfg, fees.DefaultHistoryDays)
+		defer feeEngine.Close()
+		api.InitFees(feeEngine)
+		opts = append(opts, engine.WithFees(feeEngine))
+	}
+
+	// Spot balances: funds are held before the order reaches its shard
+	if *spot {
+		ledger := accounts.NewLedger(bus)
+		defer ledger.Close()
+		api.InitAccounts(ledger)
+		opts = append(opts, engine.WithPreTrade(ledger))
+	}
+
 	// Create router with N shards and buffer size 1024
-	router := engine.NewRouter(runtime.NumCPU(), 1024)
+	router := engine.NewRouter(runtime.NumCPU(), 1024, opts...)
 	// Ensure graceful stop on exit
 	defer router.Stop()
 
+	// API keys, for HTTP and every order entry gateway; the first start
+	// creates an admin key to issue the others. Its secret is only written
+	// to the keys file, never to the log.
+	keys, err := auth.OpenKeyStore(*keysFile)
+	if err != nil {
+		log.Fatalf("open keys: %v", err)
+	}
+	if len(keys.List()) == 0 {
+		k, err := keys.Create("admin", true)
+		if err != nil {
+			log.Fatalf("create admin key: %v", err)
+		}
+		log.Printf("created admin API key %s; its secret is in %s\n", k.ID, *keysFile)
+	}
+
+	// Rate limits: per account once authenticated, per IP for everyone, on
+	// every gateway
+	accountBudgets, err := ratelimit.ParseBudgets(*rlAccount)
+	if err != nil {
+		log.Fatalf("-rl-account: %v", err)
+	}
+	ipBudgets, err := ratelimit.ParseBudgets(*rlIP)
+	if err != nil {
+		log.Fatalf("-rl-ip: %v", err)
+	}
+	limiter := ratelimit.New(accountBudgets, ipBudgets)
+	metrics.Register("rate_limits", func() interface{} { return limiter.Usage() })
+
+	// FIX order entry gateway
+	if *fixAddr != "" {
+		gw := fix.NewAcceptor(fix.Config{CompID: *fixCompID, StoreDir: *fixStore, Keys: keys, Limits: limiter}, router, bus)
+		defer gw.Close()
+		ln, err := net.Listen("tcp", *fixAddr)
+		if err != nil {
+			log.Fatalf("fix listen: %v", err)
+		}
+		go func() {
+			log.Printf("FIX gateway on %s\n", ln.Addr())
+			if err := gw.Serve(ln); err != nil {
+				log.Println("fix serve error:", err)
+			}
+		}()
+	}
+
+	// gRPC API
+	if *grpcAddr != "" {
+		gs := grpc.NewServer()
+		grpcapi.New(router, bus, keys, limiter).Register(gs)
+		defer gs.Stop() // not GracefulStop: streaming RPCs never finish on their own
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		go func() {
+			log.Printf("gRPC server on %s\n", ln.Addr())
+			if err := gs.Serve(ln); err != nil {
+				log.Println("grpc serve error:", err)
+			}
+		}()
+	}
+
+	// Binary order entry
+	if *binAddr != "" {
+		bs := binserver.New(router, keys, limiter)
+		defer bs.Close()
+		ln, err := net.Listen("tcp", *binAddr)
+		if err != nil {
+			log.Fatalf("binary listen: %v", err)
+		}
+		go func() {
+			log.Printf("binary order entry on %s\n", ln.Addr())
+			if err := bs.Serve(ln); err != nil {
+				log.Println("binary serve error:", err)
+			}
+		}()
+	}
+
 	// Initialize API package with router
 	api.Init(router)
+	api.InitEventBus(bus)
+	api.InitCandles(candles)
+	api.InitExecutions(executions)
+	api.InitPositions(tracker)
+
+	accountOf := func(r *http.Request) string { return auth.Account(r.Context()) }
+
+	// the address is charged before the key is checked, so guessing keys
+	// is throttled too
+	private := func(h http.HandlerFunc) http.Handler { return limiter.Guard(keys.Authenticate, accountOf, h) }
+	public := func(h http.HandlerFunc) http.Handler { return limiter.Middleware(accountOf, h) }
 
 	// Start pprof server on :6060
 	go func() {
@@ -40,9 +250,33 @@ func main() {
 	mux.HandleFunc("/metrics", api.MetricsHandler)
 
 	// Orders API
-	mux.HandleFunc("/api/v1/orders", api.CreateOrderHandler) // POST
-	mux.HandleFunc("/api/v1/orders/", api.OrderByIDHandler)  // GET/DELETE by id
-	mux.HandleFunc("/api/v1/orderbook/", api.GetOrderBookHandler)
+	mux.Handle("/api/v1/orders", private(api.CreateOrderHandler))             // POST
+	mux.Handle("/api/v1/orders/", private(api.OrderByIDHandler))              // GET/DELETE/PATCH by id, own orders only
+	mux.Handle("/api/v1/orders/batch", private(api.BatchOrdersHandler))       // POST/DELETE many at once
+	mux.Handle("/api/v1/orders/client/", private(api.OrderByClientIDHandler)) // GET/DELETE/PATCH by client_order_id
+	mux.Handle("/api/v1/orderbook/", public(api.GetOrderBookHandler))
+	mux.Handle("/api/v1/trades/", public(api.TradesHandler)) // GET, GET .../stream (SSE)
+	mux.Handle("/api/v1/ticker", public(api.TickerHandler))  // all symbols
+	mux.Handle("/api/v1/ticker/", public(api.TickerHandler))
+	mux.Handle("/api/v1/candles/", public(api.CandlesHandler)) // GET, GET .../stream (SSE)
+
+	// Execution reports (SSE, resumable)
+	mux.Handle("/api/v1/executions/stream", private(api.ExecutionsStreamHandler))
+	mux.Handle("/api/v1/dropcopy/stream", keys.AdminOnly(http.HandlerFunc(api.DropCopyStreamHandler)))
+
+	// Accounts
+	mux.Handle("/api/v1/accounts/", private(api.AccountsHandler)) // GET .../{id}/balances, .../{id}/positions, .../{id}/fees
+	mux.Handle("/admin/v1/accounts/", keys.AdminOnly(http.HandlerFunc(api.AdminAccountsHandler)))
+	mux.Handle("/admin/v1/fees", keys.AdminOnly(http.HandlerFunc(api.AdminFeesHandler))) // daily totals for invoicing
+
+	// Key management
+	mux.Handle("/admin/v1/keys", keys.AdminOnly(keys.AdminHandler("/admin/v1/keys")))
+	mux.Handle("/admin/v1/keys/", keys.AdminOnly(keys.AdminHandler("/admin/v1/keys")))
+
+	// Market data streams
+	mux.Handle("/ws/v1/marketdata", l2)
+	mux.Handle("/ws/v1/marketdata/l3", l3)
+	mux.Handle("/ws/v1/positions", keys.Authenticate(tracker)) // own account's positions
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -0,0 +1,336 @@
+// Package accounts keeps per-asset balances for a spot venue and refuses
+// orders an account cannot fund.
+//
+// A symbol trades its base asset against its quote asset ("BTC-USD": base
+// BTC, quote USD). Quote amounts are in price units (cents), base amounts in
+// quantity units. An accepted limit buy holds price*quantity of quote, a
+// sell holds quantity of base. A pegged buy holds at its peg_limit, the
+// most it can ever pay; trailing stop buys have no such bound and are
+// refused.
+//
+// The Ledger is a single mutex-guarded book across all shards: holds are
+// taken synchronously while the router vets the order, before any shard
+// sees it, so two orders on different shards can never spend the same
+// funds. Fills coming out of the matching loop reach the Ledger as bus
+// events and move held funds to the counterparty; cancels and rejects
+// release what is left of the hold. Released and received funds become
+// available a moment after the engine acts, never before.
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// DefaultQuote is the quote asset of a symbol without a separator.
+const DefaultQuote = "USD"
+
+// Assets splits a symbol into base and quote asset at the first '-', '/'
+// or '_'; without one the symbol is the base and DefaultQuote the quote.
+func Assets(symbol string) (base, quote string) {
+	if i := strings.IndexAny(symbol, "-/_"); i > 0 && i < len(symbol)-1 {
+		return symbol[:i], symbol[i+1:]
+	}
+	return symbol, DefaultQuote
+}
+
+var (
+	// ErrNoAccount refuses orders that carry no account.
+	ErrNoAccount = errors.New("order has no account to fund it")
+	// ErrMarketBuy refuses market buys, whose cost is unknown until they
+	// have matched; send a marketable limit order instead.
+	ErrMarketBuy = errors.New("market buy orders cannot be funded in advance; use a limit price")
+	// ErrUncappedPegBuy refuses pegged buys without a peg_limit, for the
+	// same reason.
+	ErrUncappedPegBuy = errors.New("pegged buy orders cannot be funded in advance without a peg_limit")
+	// ErrStopBuy refuses trailing stop buys, whose trigger price is not
+	// known until trades print.
+	ErrStopBuy = errors.New("trailing stop buy orders cannot be funded in advance")
+	// ErrAmount refuses non-positive deposits and withdrawals.
+	ErrAmount = errors.New("amount must be > 0")
+)
+
+// InsufficientFunds refuses an order, amend or withdrawal.
+type InsufficientFunds struct {
+	Account   string `json:"account"`
+	Asset     string `json:"asset"`
+	Needed    int64  `json:"needed"`
+	Available int64  `json:"available"`
+}
+
+func (e *InsufficientFunds) Error() string {
+	return fmt.Sprintf("insufficient %s: need %d, available %d", e.Asset, e.Needed, e.Available)
+}
+
+// Balance of one asset. Total is Available + Held.
+type Balance struct {
+	Asset     string `json:"asset"`
+	Available int64  `json:"available"`
+	Held      int64  `json:"held"`
+}
+
+// hold is what an open order has reserved.
+type hold struct {
+	account     string
+	side        model.Side
+	base, quote string
+	price       int64
+	quantity    int64 // total, so filled = quantity - remaining
+	remaining   int64
+	amount      int64 // held, in quote for a buy and base for a sell
+	pegged      bool  // price is the peg's cap and does not follow the order
+}
+
+// asset returns what h holds.
+func (h *hold) asset() string {
+	if h.side == model.BUY {
+		return h.quote
+	}
+	return h.base
+}
+
+// need is what the order's remaining quantity at price requires.
+func need(side model.Side, price, remaining int64) int64 {
+	if side == model.BUY {
+		return price * remaining
+	}
+	return remaining
+}
+
+// Ledger implements engine.PreTrade and engine.Releaser.
+type Ledger struct {
+	sub *events.Subscription
+
+	mu       sync.Mutex
+	balances map[string]map[string]*Balance // account -> asset
+	holds    map[string]*hold               // by order ID
+	done     chan struct{}
+}
+
+// NewLedger creates an empty ledger that settles from bus. Pass it to
+// engine.WithPreTrade.
+func NewLedger(bus *events.Bus) *Ledger {
+	l := &Ledger{
+		balances: make(map[string]map[string]*Balance),
+		holds:    make(map[string]*hold),
+		done:     make(chan struct{}),
+	}
+	l.sub = bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.OrderFilled, events.OrderAmended, events.OrderCancelled, events.OrderRejected:
+			return true
+		}
+		return false
+	})
+	go l.run()
+	return l
+}
+
+// Close stops settling.
+func (l *Ledger) Close() {
+	l.sub.Close()
+	<-l.done
+}
+
+func (l *Ledger) balanceLocked(account, asset string) *Balance {
+	w := l.balances[account]
+	if w == nil {
+		w = make(map[string]*Balance)
+		l.balances[account] = w
+	}
+	b := w[asset]
+	if b == nil {
+		b = &Balance{Asset: asset}
+		w[asset] = b
+	}
+	return b
+}
+
+// Deposit credits amount of asset to account.
+func (l *Ledger) Deposit(account, asset string, amount int64) (Balance, error) {
+	if amount <= 0 {
+		return Balance{}, ErrAmount
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.balanceLocked(account, asset)
+	b.Available += amount
+	return *b, nil
+}
+
+// Withdraw debits amount of asset from account's available balance.
+func (l *Ledger) Withdraw(account, asset string, amount int64) (Balance, error) {
+	if amount <= 0 {
+		return Balance{}, ErrAmount
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.balanceLocked(account, asset)
+	if b.Available < amount {
+		return *b, &InsufficientFunds{Account: account, Asset: asset, Needed: amount, Available: b.Available}
+	}
+	b.Available -= amount
+	return *b, nil
+}
+
+// Balances returns account's balances sorted by asset.
+func (l *Ledger) Balances(account string) []Balance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Balance, 0, len(l.balances[account]))
+	for _, b := range l.balances[account] {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Asset < out[j].Asset })
+	return out
+}
+
+// CheckOrder holds the funds o needs, or refuses it.
+func (l *Ledger) CheckOrder(o *model.Order) error {
+	if o.Account == "" {
+		return ErrNoAccount
+	}
+	if o.Type == model.MARKET && o.Side == model.BUY {
+		return ErrMarketBuy
+	}
+	if o.Type == model.TRAILING_STOP && o.Side == model.BUY {
+		return ErrStopBuy
+	}
+	price := o.Price
+	if o.Type == model.PEGGED {
+		if o.Side == model.BUY && o.PegLimit == 0 {
+			return ErrUncappedPegBuy
+		}
+		price = o.PegLimit
+	}
+	base, quote := Assets(o.Symbol)
+	h := &hold{
+		account: o.Account, side: o.Side, base: base, quote: quote,
+		price: price, quantity: o.Quantity, remaining: o.Quantity,
+		amount: need(o.Side, price, o.Quantity), pegged: o.Type == model.PEGGED,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.balanceLocked(o.Account, h.asset())
+	if b.Available < h.amount {
+		return &InsufficientFunds{Account: o.Account, Asset: b.Asset, Needed: h.amount, Available: b.Available}
+	}
+	b.Available -= h.amount
+	b.Held += h.amount
+	l.holds[o.ID] = h
+	return nil
+}
+
+// Release returns the hold CheckOrder took for o.
+func (l *Ledger) Release(o *model.Order) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.holds[o.ID]; ok {
+		l.releaseLocked(o.ID, h)
+	}
+}
+
+// CheckAmend holds the extra funds a larger or (for a buy) higher-priced
+// order needs. A smaller need is released once the amend has applied.
+func (l *Ledger) CheckAmend(symbol, orderID string, price, qty int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.holds[orderID]
+	if !ok {
+		return nil
+	}
+	remaining := qty - (h.quantity - h.remaining)
+	if remaining <= 0 {
+		return nil // the shard rejects it
+	}
+	if h.pegged {
+		price = h.price
+	}
+	extra := need(h.side, price, remaining) - h.amount
+	if extra <= 0 {
+		return nil
+	}
+	b := l.balanceLocked(h.account, h.asset())
+	if b.Available < extra {
+		return &InsufficientFunds{Account: h.account, Asset: b.Asset, Needed: extra, Available: b.Available}
+	}
+	// held now; if the shard then refuses the amend it stays held until
+	// the order is done
+	b.Available -= extra
+	b.Held += extra
+	h.amount += extra
+	return nil
+}
+
+func (l *Ledger) run() {
+	defer close(l.done)
+	for ev := range l.sub.C() {
+		l.apply(&ev)
+	}
+}
+
+func (l *Ledger) apply(ev *events.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.holds[ev.Order.ID]
+	if !ok {
+		return
+	}
+
+	switch ev.Type {
+	case events.OrderFilled:
+		l.settleLocked(h, ev.FillPrice, ev.FillQty)
+		h.quantity, h.remaining = ev.Order.Quantity, ev.Order.Remaining()
+	case events.OrderAmended:
+		if !h.pegged {
+			h.price = ev.Order.Price
+		}
+		h.quantity, h.remaining = ev.Order.Quantity, ev.Order.Remaining()
+		// give back what the new terms no longer need, e.g. a lower price
+		// or a buy that filled below its limit
+		if excess := h.amount - need(h.side, h.price, h.remaining); excess > 0 {
+			b := l.balanceLocked(h.account, h.asset())
+			b.Held -= excess
+			b.Available += excess
+			h.amount -= excess
+		}
+	default: // cancelled or rejected
+		h.remaining = 0
+	}
+
+	if h.remaining == 0 {
+		l.releaseLocked(ev.Order.ID, h)
+	}
+}
+
+// settleLocked books one fill: the held side leaves the account, the other
+// side arrives available.
+func (l *Ledger) settleLocked(h *hold, price, qty int64) {
+	base := l.balanceLocked(h.account, h.base)
+	quote := l.balanceLocked(h.account, h.quote)
+	if h.side == model.BUY {
+		cost := price * qty
+		h.amount -= cost
+		quote.Held -= cost
+		base.Available += qty
+		return
+	}
+	h.amount -= qty
+	base.Held -= qty
+	quote.Available += price * qty
+}
+
+// releaseLocked returns what is left of h and forgets it.
+func (l *Ledger) releaseLocked(id string, h *hold) {
+	b := l.balanceLocked(h.account, h.asset())
+	b.Held -= h.amount
+	b.Available += h.amount
+	delete(l.holds, id)
+}
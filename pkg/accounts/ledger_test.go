@@ -0,0 +1,178 @@
+package accounts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func setup(t *testing.T) (*engine.Router, *Ledger) {
+	t.Helper()
+	b := events.NewBus()
+	l := NewLedger(b)
+	r := engine.NewRouter(4, 16, engine.WithEventBus(b), engine.WithPreTrade(l))
+	t.Cleanup(func() {
+		r.Stop()
+		l.Close()
+	})
+	return r, l
+}
+
+func order(id, account, symbol string, side model.Side, price, qty int64) *model.Order {
+	return &model.Order{ID: id, Account: account, Symbol: symbol, Side: side, Type: model.LIMIT, Price: price, Quantity: qty}
+}
+
+func balance(l *Ledger, account, asset string) Balance {
+	for _, b := range l.Balances(account) {
+		if b.Asset == asset {
+			return b
+		}
+	}
+	return Balance{Asset: asset}
+}
+
+// waitBalance waits for settlement to bring account's asset to want.
+func waitBalance(t *testing.T, l *Ledger, account, asset string, want Balance) {
+	t.Helper()
+	want.Asset = asset
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := balance(l, account, asset)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s %s: expected %+v, got %+v", account, asset, want, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAssets(t *testing.T) {
+	for sym, want := range map[string][2]string{
+		"BTC-USD": {"BTC", "USD"}, "ETH/BTC": {"ETH", "BTC"}, "AAPL": {"AAPL", "USD"}, "X-": {"X-", "USD"},
+	} {
+		if b, q := Assets(sym); b != want[0] || q != want[1] {
+			t.Fatalf("%s: got %s/%s", sym, b, q)
+		}
+	}
+}
+
+func TestHoldAndSettle(t *testing.T) {
+	r, l := setup(t)
+	l.Deposit("buyer", "USD", 10_000)
+	l.Deposit("seller", "BTC", 10)
+
+	if res := r.SubmitOrder(order("s", "seller", "BTC-USD", model.SELL, 100, 6)); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if b := balance(l, "seller", "BTC"); b.Available != 4 || b.Held != 6 {
+		t.Fatalf("expected 6 BTC held, got %+v", b)
+	}
+
+	// buys 4 at 100 with a 110 limit: pays 400, the 40 of price
+	// improvement comes back once the order is done
+	if res := r.SubmitOrder(order("b", "buyer", "BTC-USD", model.BUY, 110, 4)); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	waitBalance(t, l, "buyer", "USD", Balance{Available: 9_600})
+	waitBalance(t, l, "buyer", "BTC", Balance{Available: 4})
+	waitBalance(t, l, "seller", "USD", Balance{Available: 400})
+	waitBalance(t, l, "seller", "BTC", Balance{Available: 4, Held: 2})
+
+	r.CancelOrder("BTC-USD", "s")
+	waitBalance(t, l, "seller", "BTC", Balance{Available: 6})
+}
+
+func TestHoldsSpanShards(t *testing.T) {
+	r, l := setup(t)
+	l.Deposit("a", "USD", 1_000)
+
+	// four symbols over four shards, one USD balance
+	for i, sym := range []string{"AAA-USD", "BBB-USD", "CCC-USD", "DDD-USD"} {
+		if res := r.SubmitOrder(order(sym, "a", sym, model.BUY, 100, 2)); res.Err != "" {
+			t.Fatalf("order %d: %s", i, res.Err)
+		}
+	}
+	res := r.SubmitOrder(order("x", "a", "EEE-USD", model.BUY, 100, 3))
+	var funds *InsufficientFunds
+	if !errors.As(res.Reject, &funds) || funds.Needed != 300 || funds.Available != 200 {
+		t.Fatalf("expected refusal for 300 USD with 200 left, got %v", res.Reject)
+	}
+	if b := balance(l, "a", "USD"); b.Available != 200 || b.Held != 800 {
+		t.Fatalf("unexpected USD %+v", b)
+	}
+}
+
+func TestAmendMovesHold(t *testing.T) {
+	r, l := setup(t)
+	l.Deposit("a", "USD", 1_000)
+	r.SubmitOrder(order("o", "a", "X-USD", model.BUY, 100, 5))
+
+	if res := r.AmendOrder("X-USD", "o", 300, 5); !errors.As(res.Reject, new(*InsufficientFunds)) {
+		t.Fatalf("expected 1500 USD to be refused, got %+v", res)
+	}
+	if res := r.AmendOrder("X-USD", "o", 200, 5); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if b := balance(l, "a", "USD"); b.Held != 1_000 {
+		t.Fatalf("expected the extra held before the shard applies it, got %+v", b)
+	}
+	r.AmendOrder("X-USD", "o", 50, 5)
+	waitBalance(t, l, "a", "USD", Balance{Available: 750, Held: 250})
+}
+
+func TestPeggedBuyHoldsAtItsCap(t *testing.T) {
+	r, l := setup(t)
+	l.Deposit("a", "USD", 1_000)
+	l.Deposit("s", "X", 10)
+	r.SubmitOrder(order("ask", "s", "X-USD", model.SELL, 60, 10))
+
+	peg := &model.Order{ID: "p", Account: "a", Symbol: "X-USD", Side: model.BUY, Type: model.PEGGED, PegType: model.PegPrimary, PegLimit: 100, Quantity: 5}
+	if res := r.SubmitOrder(peg); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	// the peg prices at nothing yet (no bid), but may climb to 100
+	waitBalance(t, l, "a", "USD", Balance{Available: 500, Held: 500})
+
+	// a market peg lifts the ask at 60 and gets back what it did not pay
+	mkt := &model.Order{ID: "m", Account: "a", Symbol: "X-USD", Side: model.BUY, Type: model.PEGGED, PegType: model.PegMarket, PegLimit: 100, Quantity: 5}
+	if res := r.SubmitOrder(mkt); len(res.Trades) != 1 || res.Trades[0].Price != 60 {
+		t.Fatalf("expected the market peg to lift 5 at 60, got %+v (%s)", res.Trades, res.Err)
+	}
+	waitBalance(t, l, "a", "USD", Balance{Available: 200, Held: 500})
+
+	if res := r.SubmitOrder(&model.Order{ID: "u", Account: "a", Symbol: "X-USD", Side: model.BUY, Type: model.PEGGED, PegType: model.PegPrimary, Quantity: 1}); res.Reject != ErrUncappedPegBuy {
+		t.Fatalf("expected ErrUncappedPegBuy, got %v", res.Reject)
+	}
+}
+
+func TestRefusals(t *testing.T) {
+	r, l := setup(t)
+	l.Deposit("a", "BTC", 1)
+
+	if res := r.SubmitOrder(&model.Order{ID: "m", Account: "a", Symbol: "BTC-USD", Side: model.BUY, Type: model.MARKET, Quantity: 1}); res.Reject != ErrMarketBuy {
+		t.Fatalf("expected ErrMarketBuy, got %v", res.Reject)
+	}
+	if res := r.SubmitOrder(&model.Order{ID: "ts", Account: "a", Symbol: "BTC-USD", Side: model.BUY, Type: model.TRAILING_STOP, TrailAmount: 1, Quantity: 1}); res.Reject != ErrStopBuy {
+		t.Fatalf("expected ErrStopBuy, got %v", res.Reject)
+	}
+	if res := r.SubmitOrder(order("n", "", "BTC-USD", model.SELL, 1, 1)); res.Reject != ErrNoAccount {
+		t.Fatalf("expected ErrNoAccount, got %v", res.Reject)
+	}
+
+	// a market sell with nothing to hit gives its hold back
+	r.SubmitOrder(&model.Order{ID: "ms", Account: "a", Symbol: "BTC-USD", Side: model.SELL, Type: model.MARKET, Quantity: 1})
+	waitBalance(t, l, "a", "BTC", Balance{Available: 1})
+
+	if _, err := l.Withdraw("a", "BTC", 2); !errors.As(err, new(*InsufficientFunds)) {
+		t.Fatalf("expected overdraw to fail, got %v", err)
+	}
+	if _, err := l.Deposit("a", "BTC", 0); err != ErrAmount {
+		t.Fatalf("expected ErrAmount, got %v", err)
+	}
+}
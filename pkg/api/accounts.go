@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/accounts"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/fees"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/positions"
+)
+
+// ledger is set by InitAccounts; nil when balances are not enforced.
+var ledger *accounts.Ledger
+
+// InitAccounts wires the account endpoints to a ledger.
+func InitAccounts(l *accounts.Ledger) {
+	ledger = l
+}
+
+// tracker is set by InitPositions; nil when positions are not tracked.
+var tracker *positions.Tracker
+
+// InitPositions wires the positions endpoint to a tracker.
+func InitPositions(t *positions.Tracker) {
+	tracker = t
+}
+
+// feeEngine is set by InitFees; nil when trades are not charged.
+var feeEngine *fees.Engine
+
+// InitFees wires the fee endpoints to a fee engine.
+func InitFees(e *fees.Engine) {
+	feeEngine = e
+}
+
+// feeDay returns the ?day= of r, today (UTC) by default.
+func feeDay(r *http.Request) (string, bool) {
+	day := r.URL.Query().Get("day")
+	if day == "" {
+		return time.Now().UTC().Format(fees.DayLayout), true
+	}
+	_, err := time.Parse(fees.DayLayout, day)
+	return day, err == nil
+}
+
+// accountPath splits /prefix/{id}/{action}.
+func accountPath(path, prefix string) (id, action string) {
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	id, action, _ = strings.Cut(rest, "/")
+	return id, action
+}
+
+// mayView reports whether the request may read account id: its own, or
+// any with an admin key.
+func mayView(r *http.Request, id string) bool {
+	k, _ := auth.FromContext(r.Context())
+	return k.Account == id || k.Admin
+}
+
+// -------------------------------
+// GET /api/v1/accounts/{id}/balances
+// GET /api/v1/accounts/{id}/positions
+// GET /api/v1/accounts/{id}/fees?day=YYYY-MM-DD
+// -------------------------------
+func AccountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, action := accountPath(r.URL.Path, "/api/v1/accounts")
+	if id == "" || !mayView(r, id) {
+		writeError(w, http.StatusForbidden, "not your account")
+		return
+	}
+	switch action {
+	case "balances":
+		if ledger == nil {
+			writeError(w, http.StatusNotFound, "balances are not enabled")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"account":  id,
+			"balances": ledger.Balances(id),
+		})
+	case "positions":
+		if tracker == nil {
+			writeError(w, http.StatusNotFound, "positions are not enabled")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"account":   id,
+			"positions": tracker.Positions(id),
+		})
+	case "fees":
+		if feeEngine == nil {
+			writeError(w, http.StatusNotFound, "fees are not enabled")
+			return
+		}
+		day, ok := feeDay(r)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "day must be YYYY-MM-DD")
+			return
+		}
+		writeJSON(w, http.StatusOK, feeEngine.Total(id, day))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// -------------------------------
+// POST /admin/v1/accounts/{id}/deposit   {"asset": "USD", "amount": N}
+// POST /admin/v1/accounts/{id}/withdraw  {"asset": "USD", "amount": N}
+// -------------------------------
+// Serve behind auth.AdminOnly.
+func AdminAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if ledger == nil {
+		writeError(w, http.StatusNotFound, "balances are not enabled")
+		return
+	}
+	id, action := accountPath(r.URL.Path, "/admin/v1/accounts")
+	if id == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var req struct {
+		Asset  string `json:"asset"`
+		Amount int64  `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Asset == "" {
+		writeError(w, http.StatusBadRequest, "asset and amount are required")
+		return
+	}
+
+	var (
+		bal accounts.Balance
+		err error
+	)
+	switch action {
+	case "deposit":
+		bal, err = ledger.Deposit(id, req.Asset, req.Amount)
+	case "withdraw":
+		bal, err = ledger.Withdraw(id, req.Asset, req.Amount)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var funds *accounts.InsufficientFunds
+	switch {
+	case errors.As(err, &funds):
+		writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "funds": funds})
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"account": id, "balance": bal})
+	}
+}
+
+// -------------------------------
+// GET /admin/v1/fees?day=YYYY-MM-DD
+// -------------------------------
+// Every account's fees for the day, for invoicing. Serve behind
+// auth.AdminOnly.
+func AdminFeesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if feeEngine == nil {
+		writeError(w, http.StatusNotFound, "fees are not enabled")
+		return
+	}
+	day, ok := feeDay(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "day must be YYYY-MM-DD")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"day":    day,
+		"totals": feeEngine.Totals(day),
+	})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/accounts"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/fees"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/positions"
+)
+
+func TestAccountBalances(t *testing.T) {
+	l := accounts.NewLedger(events.NewBus())
+	defer l.Close()
+	InitAccounts(l)
+	defer InitAccounts(nil)
+
+	as := func(k auth.Key, req *http.Request) *http.Request {
+		return req.WithContext(auth.WithKey(req.Context(), k))
+	}
+	admin := auth.Key{Account: "ops", Admin: true}
+
+	w := httptest.NewRecorder()
+	AdminAccountsHandler(w, as(admin, httptest.NewRequest("POST", "/admin/v1/accounts/alice/deposit", bytes.NewBufferString(`{"asset":"USD","amount":500}`))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("deposit: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	AdminAccountsHandler(w, as(admin, httptest.NewRequest("POST", "/admin/v1/accounts/alice/withdraw", bytes.NewBufferString(`{"asset":"USD","amount":900}`))))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("overdraw: expected 409, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	AccountsHandler(w, as(auth.Key{Account: "alice"}, httptest.NewRequest("GET", "/api/v1/accounts/alice/balances", nil)))
+	var resp struct {
+		Balances []accounts.Balance `json:"balances"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Balances) != 1 || resp.Balances[0].Available != 500 {
+		t.Fatalf("expected 500 USD available, got %d %+v", w.Code, resp.Balances)
+	}
+
+	w = httptest.NewRecorder()
+	AccountsHandler(w, as(auth.Key{Account: "bob"}, httptest.NewRequest("GET", "/api/v1/accounts/alice/balances", nil)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("another account's balances: expected 403, got %d", w.Code)
+	}
+}
+
+func TestAccountPositions(t *testing.T) {
+	b := events.NewBus()
+	tr := positions.NewTracker(b)
+	defer tr.Close()
+	r := engine.NewRouter(2, 16, engine.WithEventBus(b))
+	defer r.Stop()
+	InitPositions(tr)
+	defer InitPositions(nil)
+
+	r.SubmitOrder(&model.Order{ID: "s", Account: "bob", Symbol: "ES", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 2})
+	r.SubmitOrder(&model.Order{ID: "b", Account: "alice", Symbol: "ES", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 2})
+
+	var resp struct {
+		Positions []positions.Position `json:"positions"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(resp.Positions) == 0 && time.Now().Before(deadline) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/accounts/alice/positions", nil)
+		AccountsHandler(w, req.WithContext(auth.WithKey(req.Context(), auth.Key{Account: "alice"})))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		time.Sleep(time.Millisecond)
+	}
+	if len(resp.Positions) != 1 || resp.Positions[0].Net != 2 || resp.Positions[0].AvgPrice != 100 {
+		t.Fatalf("expected long 2 at 100, got %+v", resp.Positions)
+	}
+}
+
+func TestAccountFees(t *testing.T) {
+	b := events.NewBus()
+	e := fees.NewEngine(b, fees.Config{Table: fees.Table{Default: &fees.Schedule{Taker: fees.Fee{PerUnit: 3}}}}, 0)
+	defer e.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b), engine.WithFees(e))
+	defer r.Stop()
+	InitFees(e)
+	defer InitFees(nil)
+
+	r.SubmitOrder(&model.Order{ID: "s", Account: "bob", Symbol: "ES", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 2})
+	r.SubmitOrder(&model.Order{ID: "b", Account: "alice", Symbol: "ES", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 2})
+
+	as := func(k auth.Key, req *http.Request) *http.Request {
+		return req.WithContext(auth.WithKey(req.Context(), k))
+	}
+	var total fees.Total
+	deadline := time.Now().Add(2 * time.Second)
+	for total.Fills == 0 && time.Now().Before(deadline) {
+		w := httptest.NewRecorder()
+		AccountsHandler(w, as(auth.Key{Account: "alice"}, httptest.NewRequest("GET", "/api/v1/accounts/alice/fees", nil)))
+		json.NewDecoder(w.Body).Decode(&total)
+		time.Sleep(time.Millisecond)
+	}
+	if total.TakerFees != 6 || total.Fees != 6 {
+		t.Fatalf("expected 6 in taker fees today, got %+v", total)
+	}
+
+	w := httptest.NewRecorder()
+	AdminFeesHandler(w, as(auth.Key{Account: "ops", Admin: true}, httptest.NewRequest("GET", "/admin/v1/fees?day="+total.Day, nil)))
+	var run struct {
+		Totals []fees.Total `json:"totals"`
+	}
+	json.NewDecoder(w.Body).Decode(&run)
+	if w.Code != http.StatusOK || len(run.Totals) != 2 {
+		t.Fatalf("expected both accounts in the day's totals, got %d %+v", w.Code, run.Totals)
+	}
+
+	w = httptest.NewRecorder()
+	AdminFeesHandler(w, as(auth.Key{Account: "ops", Admin: true}, httptest.NewRequest("GET", "/admin/v1/fees?day=yesterday", nil)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad day, got %d", w.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+// maxBatch caps the items of one batch request.
+const maxBatch = 100
+
+// -------------------------------
+// POST   /api/v1/orders/batch  {"orders": [...], "atomic": false}
+// DELETE /api/v1/orders/batch  {"order_ids": [...], "atomic": false}
+// -------------------------------
+// Each item gets its own result, in the order given, under "results". With
+// "atomic": true every item must be for one symbol and they all succeed or
+// none does. Every item costs one token of the rate limits, so a batch is
+// refused whole when the account or address has too few left.
+func BatchOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if router == nil {
+		writeError(w, http.StatusInternalServerError, "router not initialized")
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		batchSubmit(w, r)
+	case http.MethodDelete:
+		batchCancel(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func batchSubmit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Orders []*model.Order `json:"orders"`
+		Atomic bool           `json:"atomic"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if len(req.Orders) == 0 || len(req.Orders) > maxBatch {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("orders must hold 1 to %d orders", maxBatch))
+		return
+	}
+	if !ratelimit.Charge(w, r, len(req.Orders)-1) {
+		return
+	}
+
+	// invalid orders are answered here; an atomic batch with one is refused
+	results := make([]map[string]interface{}, len(req.Orders))
+	seen := make(map[string]bool)
+	for i, o := range req.Orders {
+		err := prepareOrder(r, o)
+		if err == nil && o.ClientOrderID != "" && seen[o.ClientOrderID] {
+			err = errors.New("client_order_id repeated in the batch")
+		}
+		seen[o.ClientOrderID] = true
+		if err != nil {
+			if req.Atomic {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("orders[%d]: %v", i, err))
+				return
+			}
+			results[i] = map[string]interface{}{"status": http.StatusBadRequest, "error": err.Error()}
+		}
+	}
+
+	var valid []*model.Order
+	var at []int
+	for i, o := range req.Orders {
+		if results[i] == nil {
+			valid = append(valid, o)
+			at = append(at, i)
+		}
+	}
+	if len(valid) > 0 {
+		for j, res := range router.SubmitBatch(valid, req.Atomic) {
+			i := at[j]
+			status, body := outcome(res)
+			results[i] = withStatus(body, status)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// withStatus returns a copy of body with its status, for a batch result.
+func withStatus(body map[string]interface{}, status int) map[string]interface{} {
+	out := make(map[string]interface{}, len(body)+1)
+	for k, v := range body {
+		out[k] = v
+	}
+	out["status"] = status
+	return out
+}
+
+func batchCancel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrderIDs []string `json:"order_ids"`
+		Atomic   bool     `json:"atomic"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if len(req.OrderIDs) == 0 || len(req.OrderIDs) > maxBatch {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("order_ids must hold 1 to %d ids", maxBatch))
+		return
+	}
+	if !ratelimit.Charge(w, r, len(req.OrderIDs)-1) {
+		return
+	}
+
+	items := make([]engine.CancelItem, len(req.OrderIDs))
+	for i, id := range req.OrderIDs {
+		items[i] = engine.CancelItem{OrderID: id}
+	}
+
+	results := make([]map[string]interface{}, len(items))
+	for i, res := range router.CancelBatch(accountOf(r), items, req.Atomic) {
+		id := req.OrderIDs[i]
+		if !res.OK {
+			results[i] = map[string]interface{}{"order_id": id, "error": res.Err}
+			continue
+		}
+		results[i] = map[string]interface{}{"order_id": id, "status": "cancelled"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+)
+
+func TestBatchSubmitAndCancel(t *testing.T) {
+	r := engine.NewRouter(4, 16)
+	defer r.Stop()
+	Init(r)
+	as := func(account string, req *http.Request) *http.Request {
+		return req.WithContext(auth.WithKey(req.Context(), auth.Key{Account: account}))
+	}
+	var out struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+
+	body := `{"orders":[
+		{"symbol":"BA","side":"SELL","type":"LIMIT","price":100,"quantity":5},
+		{"symbol":"BB","side":"SELL","type":"LIMIT","price":0,"quantity":5},
+		{"symbol":"BA","side":"BUY","type":"LIMIT","price":100,"quantity":2}]}`
+	w := httptest.NewRecorder()
+	BatchOrdersHandler(w, as("alice", httptest.NewRequest("POST", "/api/v1/orders/batch", bytes.NewBufferString(body))))
+	out.Results = nil
+	json.NewDecoder(w.Body).Decode(&out)
+	if w.Code != http.StatusOK || len(out.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d: %s", w.Code, w.Body)
+	}
+	if out.Results[0]["status"] != 201.0 || out.Results[1]["status"] != 400.0 || out.Results[2]["status"] != 200.0 {
+		t.Fatalf("expected resting, invalid, filled: %+v", out.Results)
+	}
+	id := out.Results[0]["order_id"].(string)
+
+	// an atomic cancel with an unknown ID cancels nothing; bob cannot cancel
+	// alice's order
+	w = httptest.NewRecorder()
+	BatchOrdersHandler(w, as("alice", httptest.NewRequest("DELETE", "/api/v1/orders/batch", bytes.NewBufferString(`{"order_ids":["`+id+`","nope"],"atomic":true}`))))
+	out.Results = nil
+	json.NewDecoder(w.Body).Decode(&out)
+	if out.Results[0]["error"] == nil || out.Results[1]["error"] != "order not found" {
+		t.Fatalf("expected the atomic cancel refused, got %+v", out.Results)
+	}
+	w = httptest.NewRecorder()
+	BatchOrdersHandler(w, as("bob", httptest.NewRequest("DELETE", "/api/v1/orders/batch", bytes.NewBufferString(`{"order_ids":["`+id+`"]}`))))
+	out.Results = nil
+	json.NewDecoder(w.Body).Decode(&out)
+	if out.Results[0]["error"] != "order not found" {
+		t.Fatalf("expected another account's order not found, got %+v", out.Results)
+	}
+
+	w = httptest.NewRecorder()
+	BatchOrdersHandler(w, as("alice", httptest.NewRequest("DELETE", "/api/v1/orders/batch", bytes.NewBufferString(`{"order_ids":["`+id+`"]}`))))
+	out.Results = nil
+	json.NewDecoder(w.Body).Decode(&out)
+	if out.Results[0]["status"] != "cancelled" {
+		t.Fatalf("expected the order cancelled, got %+v", out.Results)
+	}
+}
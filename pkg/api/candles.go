@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/marketdata"
+)
+
+// candles is set by InitCandles.
+var candles *marketdata.CandleStore
+
+// InitCandles wires the candle endpoints to a candle store.
+func InitCandles(cs *marketdata.CandleStore) {
+	candles = cs
+}
+
+// -------------------------------
+// GET /api/v1/candles/{symbol}?interval=1m&from=&to=   (from/to unix ms)
+// GET /api/v1/candles/{symbol}/stream?interval=1m      (SSE, forming bar)
+// -------------------------------
+func CandlesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if candles == nil {
+		writeError(w, http.StatusInternalServerError, "candle store not initialized")
+		return
+	}
+
+	q := r.URL.Query()
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "1m"
+	}
+
+	if strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/stream") {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		streamCandles(w, r, parts[len(parts)-2], interval)
+		return
+	}
+
+	var from, to int64
+	for name, dst := range map[string]*int64{"from": &from, "to": &to} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, name+" must be unix milliseconds")
+				return
+			}
+			*dst = n
+		}
+	}
+
+	symbol := pathParam(r.URL.Path)
+	bars, err := candles.Query(symbol, interval, from, to)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"symbol":   symbol,
+		"interval": interval,
+		"candles":  bars,
+	})
+}
+
+func streamCandles(w http.ResponseWriter, r *http.Request, symbol, interval string) {
+	updates, cancel, err := candles.Watch(symbol, interval)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	stream := startSSE(w)
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case c := <-updates:
+			if err := stream.send("candle", "", c); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.ping(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
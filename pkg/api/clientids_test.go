@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+)
+
+func TestClientOrderIDIsIdempotent(t *testing.T) {
+	r := engine.NewRouter(1, 16)
+	defer r.Stop()
+	Init(r)
+	as := func(account string, req *http.Request) *http.Request {
+		return req.WithContext(auth.WithKey(req.Context(), auth.Key{Account: account}))
+	}
+	post := func(account string, price int) (*httptest.ResponseRecorder, string) {
+		w := httptest.NewRecorder()
+		body := `{"symbol":"CID","side":"BUY","type":"LIMIT","price":` + strconv.Itoa(price) + `,"quantity":5,"client_order_id":"quote-1"}`
+		CreateOrderHandler(w, as(account, httptest.NewRequest("POST", "/api/v1/orders", bytes.NewBufferString(body))))
+		var created struct {
+			OrderID string `json:"order_id"`
+		}
+		json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&created)
+		return w, created.OrderID
+	}
+
+	w1, id := post("alice", 100)
+	w2, again := post("alice", 100)
+	if w1.Code != http.StatusCreated || w2.Code != http.StatusCreated || again != id || w2.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the retry to replay %s, got %d %s", id, w2.Code, again)
+	}
+	if w, _ := post("alice", 101); w.Code != http.StatusConflict {
+		t.Fatalf("expected quote-1 at another price to conflict, got %d: %s", w.Code, w.Body)
+	}
+	if _, other := post("bob", 100); other == id || other == "" {
+		t.Fatal("another account's client_order_id is its own")
+	}
+	if bk := r.GetOrderBook("CID", 5); len(bk.Bids) != 1 || bk.Bids[0]["quantity"] != int64(10) {
+		t.Fatalf("expected one order each for alice and bob, got %v", bk.Bids)
+	}
+
+	path := "/api/v1/orders/client/quote-1"
+	w := httptest.NewRecorder()
+	OrderByClientIDHandler(w, as("alice", httptest.NewRequest("GET", path, nil)))
+	var got map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got["order_id"] != id || got["client_order_id"] != "quote-1" {
+		t.Fatalf("expected alice's order by client ID, got %d %v", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	OrderByClientIDHandler(w, as("alice", httptest.NewRequest("DELETE", path, nil)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the cancel by client ID, got %d: %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	OrderByClientIDHandler(w, as("carol", httptest.NewRequest("GET", path, nil)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown client ID not found, got %d", w.Code)
+	}
+
+	// a retried batch replays the orders it placed and places the rest
+	batch := func(body string) []map[string]interface{} {
+		w := httptest.NewRecorder()
+		BatchOrdersHandler(w, as("alice", httptest.NewRequest("POST", "/api/v1/orders/batch", bytes.NewBufferString(body))))
+		var out struct {
+			Results []map[string]interface{} `json:"results"`
+		}
+		json.NewDecoder(w.Body).Decode(&out)
+		return out.Results
+	}
+	first := batch(`{"orders":[{"symbol":"CID","side":"SELL","type":"LIMIT","price":200,"quantity":1,"client_order_id":"q2"}]}`)
+	retry := batch(`{"orders":[{"symbol":"CID","side":"SELL","type":"LIMIT","price":200,"quantity":1,"client_order_id":"q2"},
+		{"symbol":"CID","side":"SELL","type":"LIMIT","price":201,"quantity":1,"client_order_id":"q3"},
+		{"symbol":"CID","side":"SELL","type":"LIMIT","price":202,"quantity":1,"client_order_id":"q3"}]}`)
+	if len(retry) != 3 || retry[0]["order_id"] != first[0]["order_id"] || retry[1]["status"] != 201.0 || retry[2]["status"] != 400.0 {
+		t.Fatalf("expected q2 replayed, q3 placed once, got %+v", retry)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/execfeed"
+)
+
+// journal is set by InitExecutions.
+var journal *execfeed.Journal
+
+// InitExecutions wires the execution report streams to a journal.
+func InitExecutions(j *execfeed.Journal) {
+	journal = j
+}
+
+// accountOf returns the account a request acts for, as bound by the auth
+// middleware.
+func accountOf(r *http.Request) string {
+	return auth.Account(r.Context())
+}
+
+// -------------------------------
+// GET /api/v1/executions/stream?after=SEQ   (SSE, own account)
+// -------------------------------
+func ExecutionsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	account := accountOf(r)
+	if account == "" {
+		writeError(w, http.StatusUnauthorized, "account required")
+		return
+	}
+	streamExecutions(w, r, account)
+}
+
+// -------------------------------
+// GET /api/v1/dropcopy/stream?after=SEQ   (SSE, every account)
+// -------------------------------
+// Serve behind auth.AdminOnly: it shows every account's orders.
+func DropCopyStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	streamExecutions(w, r, "")
+}
+
+// streamExecutions sends account's reports ("" for all) as SSE "execution"
+// events with the report seq as id. The stream resumes after the seq in
+// ?after= or, on an EventSource reconnect, Last-Event-ID; with neither it
+// starts live. A seq that has left the journal gets 410 and the client has
+// to resync from GET /api/v1/orders/{id}.
+func streamExecutions(w http.ResponseWriter, r *http.Request, account string) {
+	if journal == nil {
+		writeError(w, http.StatusInternalServerError, "execution journal not initialized")
+		return
+	}
+
+	after := execfeed.Live
+	from := r.URL.Query().Get("after")
+	if from == "" {
+		from = r.Header.Get("Last-Event-ID")
+	}
+	if from != "" {
+		n, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid sequence")
+			return
+		}
+		after = n
+	}
+
+	feed, err := journal.Follow(account, after)
+	switch {
+	case errors.Is(err, execfeed.ErrTooOld):
+		writeError(w, http.StatusGone, err.Error())
+		return
+	case errors.Is(err, execfeed.ErrAhead):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer feed.Close()
+
+	stream := startSSE(w)
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), sseKeepAlive)
+		rep, err := feed.Next(ctx)
+		cancel()
+		switch {
+		case err == nil:
+			if err := stream.send("execution", strconv.FormatUint(rep.Seq, 10), rep); err != nil {
+				return
+			}
+		case errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil:
+			if err := stream.ping(); err != nil {
+				return
+			}
+		default:
+			// slow consumer or shutdown: the client resumes from its last id
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/execfeed"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// readExecutions returns the next n reports and their SSE ids.
+func readExecutions(t *testing.T, sc *bufio.Scanner, n int) ([]execfeed.Report, []string) {
+	t.Helper()
+	var reps []execfeed.Report
+	var ids []string
+	for len(reps) < n && sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "data: "):
+			var rep execfeed.Report
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &rep)
+			reps = append(reps, rep)
+		}
+	}
+	if len(reps) < n {
+		t.Fatalf("stream ended after %d reports", len(reps))
+	}
+	return reps, ids
+}
+
+func TestExecutionsStream(t *testing.T) {
+	b := events.NewBus()
+	j := execfeed.NewJournal(b, 0)
+	defer j.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+	Init(r)
+	InitExecutions(j)
+
+	ks, _ := auth.OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	alice, _ := ks.Create("alice", false)
+	firm, _ := ks.Create("firm", true)
+	aliceKey := alice.ID + ":" + alice.Secret
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/executions/stream", ks.Authenticate(http.HandlerFunc(ExecutionsStreamHandler)))
+	mux.Handle("/api/v1/dropcopy/stream", ks.AdminOnly(http.HandlerFunc(DropCopyStreamHandler)))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r.SubmitOrder(&model.Order{ID: "s", Account: "alice", Symbol: "EX", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 3})
+	r.SubmitOrder(&model.Order{ID: "b", Account: "bob", Symbol: "EX", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 1})
+
+	get := func(path string, h map[string]string) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("/api/v1/executions/stream?after=0", map[string]string{"X-API-Key": aliceKey})
+	defer resp.Body.Close()
+	reps, ids := readExecutions(t, bufio.NewScanner(resp.Body), 2)
+	if reps[0].Type != execfeed.ReportAck || reps[1].Type != execfeed.ReportPartialFill || reps[1].Account != "alice" {
+		t.Fatalf("expected alice's ack and partial fill, got %+v", reps)
+	}
+
+	// reconnecting with Last-Event-ID resumes after it
+	resp2 := get("/api/v1/executions/stream", map[string]string{"X-API-Key": aliceKey, "Last-Event-ID": ids[0]})
+	defer resp2.Body.Close()
+	reps, _ = readExecutions(t, bufio.NewScanner(resp2.Body), 1)
+	if reps[0].Type != execfeed.ReportPartialFill {
+		t.Fatalf("expected resume at the partial fill, got %+v", reps[0])
+	}
+
+	if resp := get("/api/v1/dropcopy/stream", map[string]string{"X-API-Key": aliceKey}); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin key, got %d", resp.StatusCode)
+	}
+	if resp := get("/api/v1/executions/stream?after=99", map[string]string{"X-API-Key": aliceKey}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a seq ahead of the journal, got %d", resp.StatusCode)
+	}
+
+	dc := get("/api/v1/dropcopy/stream?after=0", map[string]string{"X-API-Key": firm.ID + ":" + firm.Secret})
+	defer dc.Body.Close()
+	reps, _ = readExecutions(t, bufio.NewScanner(dc.Body), 4)
+	seen := map[string]bool{}
+	for _, rep := range reps {
+		seen[rep.Account] = true
+	}
+	if !seen["alice"] || !seen["bob"] {
+		t.Fatalf("expected both accounts in the drop copy, got %+v", reps)
+	}
+}
@@ -25,11 +25,11 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-// MetricsHandler returns simple metrics (JSON)
+// MetricsHandler returns simple metrics (JSON), plus the sections other
+// components registered with metrics.Register.
 func MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	resp := map[string]interface{}{
-		"orders_processed": metrics.GetOrdersProcessed(),
-	}
+	resp := metrics.Sections()
+	resp["orders_processed"] = metrics.GetOrdersProcessed()
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(resp)
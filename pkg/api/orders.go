@@ -2,26 +2,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/google/uuid"
-
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/accounts"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/risk"
 )
 
 // router is set by Init
-var (
-	router     *engine.Router
-	idToSymbol = struct {
-		mu sync.RWMutex
-		m  map[string]string
-	}{m: make(map[string]string)}
-)
+var router *engine.Router
 
 // Init wires the API package to the engine Router.
 // Call this once at server startup.
@@ -39,15 +33,11 @@ func CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := req.Validate(); err != nil {
+	if err := prepareOrder(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Assign ID and timestamp
-	req.ID = uuid.NewString()
-	req.Timestamp = time.Now().UnixMilli()
-
 	// Submit to router (which routes to correct shard)
 	if router == nil {
 		writeError(w, http.StatusInternalServerError, "router not initialized")
@@ -55,22 +45,51 @@ func CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	res := router.SubmitOrder(&req)
+	if res.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	status, body := outcome(res)
+	writeJSON(w, status, body)
+}
+
+// outcome is the HTTP status and body answering a submit. A client_order_id
+// repeated with other terms is a conflict.
+func outcome(res engine.SubmitResult) (int, map[string]interface{}) {
+	if res.Err == engine.ErrClientOrderIDReused.Error() {
+		return http.StatusConflict, rejection(res.Err, res.Reject)
+	}
 	if res.Err != "" {
-		writeError(w, http.StatusBadRequest, res.Err)
-		return
+		return http.StatusBadRequest, rejection(res.Err, res.Reject)
 	}
+	return res.StatusCode, submitted(res)
+}
 
-	// If order is now resting in book (limit with remaining), record id->symbol mapping
-	if req.Type == model.LIMIT && req.Quantity > 0 {
-		idToSymbol.mu.Lock()
-		idToSymbol.m[req.ID] = req.Symbol
-		idToSymbol.mu.Unlock()
+// prepareOrder validates a new order from r and stamps it with r's account
+// and the time. The router gives it its ID when it is submitted.
+func prepareOrder(r *http.Request, o *model.Order) error {
+	validate := o.Validate
+	if router != nil && router.IsSpread(o.Symbol) {
+		validate = o.ValidateSpread
+	}
+	if err := validate(); err != nil {
+		return err
 	}
 
+	// the account comes from the API key, never from the body
+	o.Account = accountOf(r)
+
+	// IDs are the router's, so they tell it where the order lives
+	o.ID = ""
+	o.Timestamp = time.Now().UnixMilli()
+	return nil
+}
+
+// submitted builds the response to an accepted order.
+func submitted(res engine.SubmitResult) map[string]interface{} {
 	// ensure trades_executed is [] not null
-	var tradesResp []model.Order
+	var tradesResp []model.Trade
 	if res.Trades == nil {
-		tradesResp = []model.Order{}
+		tradesResp = []model.Trade{}
 	} else {
 		tradesResp = res.Trades
 	}
@@ -81,24 +100,44 @@ func CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"side":            res.Order.Side,
 		"type":            res.Order.Type,
 		"price":           res.Order.Price,
-		"quantity":        res.Order.Quantity + res.Order.Filled,
+		"quantity":        res.Order.Quantity,
 		"filled_quantity": res.Order.Filled,
-		"remaining":       res.Order.Quantity,
+		"remaining":       res.Order.Remaining(),
 		"trades_executed": tradesResp,
 	}
-
-	writeJSON(w, res.StatusCode, resp)
+	addTerms(resp, res.Order)
+	return resp
 }
 
 // -------------------------------
 // GET/DELETE dispatcher
 // -------------------------------
 func OrderByIDHandler(w http.ResponseWriter, r *http.Request) {
+	orderByID(w, r, pathParam(r.URL.Path))
+}
+
+// -------------------------------
+// GET/DELETE/PATCH /api/v1/orders/client/{client_order_id}
+// -------------------------------
+// The same as by order ID, for an order submitted with a client_order_id
+// within the last day.
+func OrderByClientIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := router.ClientOrder(accountOf(r), pathParam(r.URL.Path))
+	if !ok {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	orderByID(w, r, id)
+}
+
+func orderByID(w http.ResponseWriter, r *http.Request, id string) {
 	switch r.Method {
 	case http.MethodGet:
-		GetOrderHandler(w, r)
+		getOrder(w, r, id)
 	case http.MethodDelete:
-		CancelOrderHandler(w, r)
+		cancelOrder(w, r, id)
+	case http.MethodPatch:
+		amendOrder(w, r, id)
 	default:
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 	}
@@ -108,65 +147,147 @@ func OrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/orders/{id}
 // -------------------------------
 func GetOrderHandler(w http.ResponseWriter, r *http.Request) {
-	id := pathParam(r.URL.Path)
+	getOrder(w, r, pathParam(r.URL.Path))
+}
 
-	// lookup symbol
-	idToSymbol.mu.RLock()
-	sym, ok := idToSymbol.m[id]
-	idToSymbol.mu.RUnlock()
+func getOrder(w http.ResponseWriter, r *http.Request, id string) {
+	o, ok := ownOrder(r, id)
 	if !ok {
 		writeError(w, http.StatusNotFound, "order not found")
 		return
 	}
 
-	res := router.GetOrder(sym, id)
-	if res.Err != "" || res.Order == nil {
-		writeError(w, http.StatusNotFound, "order not found")
-		return
-	}
-
-	o := res.Order
 	resp := map[string]interface{}{
 		"order_id":        o.ID,
 		"symbol":          o.Symbol,
 		"side":            o.Side,
 		"type":            o.Type,
 		"price":           o.Price,
-		"quantity":        o.Quantity + o.Filled,
+		"quantity":        o.Quantity,
 		"filled_quantity": o.Filled,
-		"remaining":       o.Quantity - o.Filled,
+		"remaining":       o.Remaining(),
 	}
+	addTerms(resp, o)
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// addTerms adds an order's client_order_id, a hidden order's flag, its
+// execution conditions, a pegged order's peg or a trailing stop's trail and
+// current trigger to its response. A pegged order's price is the one the peg
+// gives it now; a triggered stop shows the type it became.
+func addTerms(resp map[string]interface{}, o *model.Order) {
+	if o.ClientOrderID != "" {
+		resp["client_order_id"] = o.ClientOrderID
+	}
+	if o.Hidden {
+		resp["hidden"] = true
+	}
+	if o.MinQty > 0 {
+		scope := o.MinQtyScope
+		if scope == "" {
+			scope = model.MinQtyEach
+		}
+		resp["min_qty"] = o.MinQty
+		resp["min_qty_scope"] = scope
+	}
+	if o.AllOrNone {
+		resp["all_or_none"] = true
+	}
+	switch {
+	case o.Type == model.PEGGED:
+		resp["peg_type"] = o.PegType
+		resp["peg_offset"] = o.PegOffset
+		resp["peg_limit"] = o.PegLimit
+	case o.Type == model.TRAILING_STOP || o.Triggered:
+		resp["trail_amount"] = o.TrailAmount
+		resp["trail_percent"] = o.TrailPercent
+		trigger := o.TriggerType
+		if trigger == "" {
+			trigger = model.MARKET
+		}
+		resp["trigger_type"] = trigger
+		resp["limit_offset"] = o.LimitOffset
+		resp["stop_price"] = o.StopPrice
+		resp["triggered"] = o.Triggered
+	}
+}
+
 // -------------------------------
 // DELETE /api/v1/orders/{id}
 // -------------------------------
 func CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
-	id := pathParam(r.URL.Path)
+	cancelOrder(w, r, pathParam(r.URL.Path))
+}
 
-	// lookup symbol
-	idToSymbol.mu.RLock()
-	sym, ok := idToSymbol.m[id]
-	idToSymbol.mu.RUnlock()
+func cancelOrder(w http.ResponseWriter, r *http.Request, id string) {
+	o, ok := ownOrder(r, id)
 	if !ok {
 		writeError(w, http.StatusNotFound, "order not found")
 		return
 	}
 
-	res := router.CancelOrder(sym, id)
+	res := router.CancelOrder(o.Symbol, id)
 	if !res.OK {
 		writeError(w, http.StatusBadRequest, res.Err)
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
 
-	// remove mapping
-	idToSymbol.mu.Lock()
-	delete(idToSymbol.m, id)
-	idToSymbol.mu.Unlock()
+// -------------------------------
+// PATCH /api/v1/orders/{id}  {"price": ..., "quantity": ...}
+// -------------------------------
+// Either field may be omitted to keep its current value. quantity is the new
+// total size including what has already filled.
+func AmendOrderHandler(w http.ResponseWriter, r *http.Request) {
+	amendOrder(w, r, pathParam(r.URL.Path))
+}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+func amendOrder(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Price    int64 `json:"price"`
+		Quantity int64 `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	cur, ok := ownOrder(r, id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if req.Price == 0 {
+		req.Price = cur.Price
+	}
+	if req.Quantity == 0 {
+		req.Quantity = cur.Quantity
+	}
+
+	res := router.AmendOrder(cur.Symbol, id, req.Price, req.Quantity)
+	if res.Err != "" {
+		writeReject(w, res.Err, res.Reject)
+		return
+	}
+
+	tradesResp := res.Trades
+	if tradesResp == nil {
+		tradesResp = []model.Trade{}
+	}
+	o := res.Order
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"order_id":        o.ID,
+		"symbol":          o.Symbol,
+		"side":            o.Side,
+		"type":            o.Type,
+		"price":           o.Price,
+		"quantity":        o.Quantity,
+		"filled_quantity": o.Filled,
+		"remaining":       o.Remaining(),
+		"trades_executed": tradesResp,
+	})
 }
 
 // -------------------------------
@@ -190,15 +311,54 @@ func GetOrderBookHandler(w http.ResponseWriter, r *http.Request) {
 
 	resp := map[string]interface{}{
 		"symbol": symbol,
+		"seq":    snap.Seq,
 		"bids":   snap.Bids,
 		"asks":   snap.Asks,
 	}
+	if len(snap.ImpliedBids) > 0 {
+		resp["implied_bids"] = snap.ImpliedBids
+	}
+	if len(snap.ImpliedAsks) > 0 {
+		resp["implied_asks"] = snap.ImpliedAsks
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
 // ----------------- helpers -----------------
 
+// ownOrder looks up order id on behalf of the request's account. Orders of
+// other accounts are reported as not found, so their IDs cannot be probed.
+func ownOrder(r *http.Request, id string) (*model.Order, bool) {
+	res := router.GetOrder("", id)
+	if res.Err != "" || res.Order == nil || res.Order.Account != accountOf(r) {
+		return nil, false
+	}
+	return res.Order, true
+}
+
+// writeReject answers a refused submit or amend with 400. A pre-trade
+// refusal also says which risk limit was hit or which funds were missing.
+func writeReject(w http.ResponseWriter, msg string, reject error) {
+	writeJSON(w, http.StatusBadRequest, rejection(msg, reject))
+}
+
+// rejection is the body of a refusal: the error and, for a pre-trade
+// refusal, the risk limit hit or the funds missing.
+func rejection(msg string, reject error) map[string]interface{} {
+	var (
+		v     *risk.Violation
+		funds *accounts.InsufficientFunds
+	)
+	switch {
+	case errors.As(reject, &v):
+		return map[string]interface{}{"error": msg, "risk": v}
+	case errors.As(reject, &funds):
+		return map[string]interface{}{"error": msg, "funds": funds}
+	}
+	return map[string]interface{}{"error": msg}
+}
+
 func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
@@ -2,9 +2,13 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
 )
 
 func TestCreateOrderInvalidJSON(t *testing.T) {
@@ -17,3 +21,76 @@ func TestCreateOrderInvalidJSON(t *testing.T) {
 		t.Fatalf("expected 400 for invalid json; got %d", w.Code)
 	}
 }
+
+func TestOrdersAreScopedToAccount(t *testing.T) {
+	r := engine.NewRouter(1, 16)
+	defer r.Stop()
+	Init(r)
+
+	as := func(account string, req *http.Request) *http.Request {
+		return req.WithContext(auth.WithKey(req.Context(), auth.Key{Account: account}))
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"symbol":"OWN","side":"BUY","type":"LIMIT","price":100,"quantity":5,"account":"bob"}`
+	CreateOrderHandler(w, as("alice", httptest.NewRequest("POST", "/api/v1/orders", bytes.NewBufferString(body))))
+	var created struct {
+		OrderID string `json:"order_id"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	res := r.GetOrder("OWN", created.OrderID)
+	if res.Order == nil || res.Order.Account != "alice" {
+		t.Fatalf("expected the order to belong to the key's account, got %+v", res.Order)
+	}
+
+	path := "/api/v1/orders/" + created.OrderID
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		w := httptest.NewRecorder()
+		OrderByIDHandler(w, as("bob", httptest.NewRequest(method, path, bytes.NewBufferString(`{"price":101}`))))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%s by another account: expected 404, got %d", method, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	OrderByIDHandler(w, as("alice", httptest.NewRequest("DELETE", path, nil)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the owner to cancel, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestGetTrailingStopShowsTrigger(t *testing.T) {
+	r := engine.NewRouter(1, 16)
+	defer r.Stop()
+	Init(r)
+
+	post := func(body string) string {
+		w := httptest.NewRecorder()
+		CreateOrderHandler(w, httptest.NewRequest("POST", "/api/v1/orders", bytes.NewBufferString(body)))
+		if w.Code >= 300 {
+			t.Fatalf("create %s: %d %s", body, w.Code, w.Body)
+		}
+		var created struct {
+			OrderID string `json:"order_id"`
+		}
+		json.NewDecoder(w.Body).Decode(&created)
+		return created.OrderID
+	}
+	post(`{"symbol":"TS","side":"SELL","type":"LIMIT","price":200,"quantity":1}`)
+	post(`{"symbol":"TS","side":"BUY","type":"LIMIT","price":200,"quantity":1}`)
+	id := post(`{"symbol":"TS","side":"SELL","type":"TRAILING_STOP","trail_amount":15,"quantity":2}`)
+
+	w := httptest.NewRecorder()
+	OrderByIDHandler(w, httptest.NewRequest("GET", "/api/v1/orders/"+id, nil))
+	var got struct {
+		Type        string `json:"type"`
+		StopPrice   int64  `json:"stop_price"`
+		TriggerType string `json:"trigger_type"`
+		Triggered   bool   `json:"triggered"`
+	}
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.Type != "TRAILING_STOP" || got.StopPrice != 185 || got.TriggerType != "MARKET" || got.Triggered {
+		t.Fatalf("expected a waiting stop at 185, got %+v", got)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is how often an idle stream sends a comment line so proxies
+// do not close it.
+const sseKeepAlive = 15 * time.Second
+
+// sseStream is an open Server-Sent Events response.
+type sseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// startSSE writes the event-stream headers and lifts the server's write
+// timeout for this response, which would otherwise cut long streams.
+func startSSE(w http.ResponseWriter) *sseStream {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+	return &sseStream{w: w, rc: rc}
+}
+
+// send writes one event with a JSON payload. id may be empty.
+func (s *sseStream) send(event, id string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// ping writes a comment line.
+func (s *sseStream) ping() error {
+	if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// -------------------------------
+// GET /api/v1/ticker           (all symbols)
+// GET /api/v1/ticker/{symbol}
+// -------------------------------
+func TickerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if router == nil {
+		writeError(w, http.StatusInternalServerError, "router not initialized")
+		return
+	}
+
+	symbol := strings.TrimPrefix(strings.Trim(r.URL.Path, "/"), "api/v1/ticker")
+	symbol = strings.Trim(symbol, "/")
+	if symbol == "" {
+		writeJSON(w, http.StatusOK, router.GetTickers())
+		return
+	}
+
+	t, ok := router.GetTicker(symbol)
+	if !ok {
+		writeError(w, http.StatusNotFound, "symbol not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// bus is set by InitEventBus; streaming endpoints need it.
+var bus *events.Bus
+
+// InitEventBus wires the streaming endpoints to the engine event bus.
+// Call this once at server startup, next to Init.
+func InitEventBus(b *events.Bus) {
+	bus = b
+}
+
+// -------------------------------
+// GET /api/v1/trades/{symbol}?limit=N
+// GET /api/v1/trades/{symbol}/stream  (SSE)
+// -------------------------------
+func TradesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/stream") {
+		streamTrades(w, r)
+		return
+	}
+	if router == nil {
+		writeError(w, http.StatusInternalServerError, "router not initialized")
+		return
+	}
+
+	symbol := pathParam(r.URL.Path)
+	limit := 50
+	if ls := r.URL.Query().Get("limit"); ls != "" {
+		if l, err := strconv.Atoi(ls); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	trades := router.GetTrades(symbol, limit)
+	if trades == nil {
+		trades = []model.Trade{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"symbol": symbol,
+		"trades": trades,
+	})
+}
+
+// streamTrades pushes every new trade on symbol as an SSE "trade" event.
+// A client that cannot keep up is disconnected rather than slowing the
+// engine; it can reconnect and backfill from the REST endpoint.
+func streamTrades(w http.ResponseWriter, r *http.Request) {
+	if bus == nil {
+		writeError(w, http.StatusInternalServerError, "event bus not initialized")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	symbol := parts[len(parts)-2]
+
+	sub := bus.Subscribe(256, events.Disconnect, func(ev *events.Event) bool {
+		return ev.Type == events.TradeExecuted && ev.Symbol == symbol
+	})
+	defer sub.Close()
+
+	stream := startSSE(w)
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			t := *ev.Trade
+			t.MakerOrderID, t.TakerOrderID = "", ""
+			if err := stream.send("trade", strconv.FormatUint(ev.Seq, 10), t); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.ping(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
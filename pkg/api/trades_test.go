@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func cross(r *engine.Router, symbol string, price, qty int64) {
+	r.SubmitOrder(&model.Order{ID: symbol + "-s", Symbol: symbol, Side: model.SELL, Type: model.LIMIT, Price: price, Quantity: qty})
+	r.SubmitOrder(&model.Order{ID: symbol + "-b", Symbol: symbol, Side: model.BUY, Type: model.LIMIT, Price: price, Quantity: qty})
+}
+
+func TestTradesHandlerREST(t *testing.T) {
+	r := engine.NewRouter(1, 16)
+	defer r.Stop()
+	Init(r)
+
+	cross(r, "TT", 100, 2)
+	cross(r, "TT", 101, 3)
+
+	w := httptest.NewRecorder()
+	TradesHandler(w, httptest.NewRequest("GET", "/api/v1/trades/TT?limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Trades []model.Trade `json:"trades"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Trades) != 1 || resp.Trades[0].Price != 101 || resp.Trades[0].AggressorSide != model.BUY {
+		t.Fatalf("expected latest trade at 101 bought, got %+v", resp.Trades)
+	}
+	if resp.Trades[0].ID == "" || resp.Trades[0].Timestamp == 0 {
+		t.Fatalf("expected trade id and timestamp, got %+v", resp.Trades[0])
+	}
+}
+
+func TestTradesHandlerSSE(t *testing.T) {
+	b := events.NewBus()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+	Init(r)
+	InitEventBus(b)
+
+	srv := httptest.NewServer(http.HandlerFunc(TradesHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/trades/SS/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event-stream, got %q", ct)
+	}
+
+	cross(r, "OTHER", 50, 1) // filtered out
+	cross(r, "SS", 100, 4)
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var tr model.Trade
+		json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &tr)
+		if tr.Symbol != "SS" || tr.Quantity != 4 || tr.MakerOrderID != "" {
+			t.Fatalf("unexpected streamed trade %+v", tr)
+		}
+		return
+	}
+	t.Fatalf("stream ended without a trade: %v", sc.Err())
+}
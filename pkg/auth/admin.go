@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AdminHandler serves key management under prefix; wrap it in AdminOnly.
+//
+//	GET    {prefix}       list keys (no secrets)
+//	POST   {prefix}       {"account": "...", "admin": false} -> the new key with its secret
+//	DELETE {prefix}/{id}  revoke
+func (ks *KeyStore) AdminHandler(prefix string) http.Handler {
+	prefix = strings.TrimRight(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"keys": ks.List()})
+		case id == "" && r.Method == http.MethodPost:
+			var req struct {
+				Account string `json:"account"`
+				Admin   bool   `json:"admin"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid json")
+				return
+			}
+			if req.Account == "" {
+				writeError(w, http.StatusBadRequest, "account is required")
+				return
+			}
+			k, err := ks.Create(req.Account, req.Admin)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusCreated, k)
+		case id != "" && r.Method == http.MethodDelete:
+			err := ks.Revoke(id)
+			switch {
+			case errors.Is(err, ErrKeyNotFound):
+				writeError(w, http.StatusNotFound, err.Error())
+			case err != nil:
+				writeError(w, http.StatusInternalServerError, err.Error())
+			default:
+				writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+			}
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
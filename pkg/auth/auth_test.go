@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func echoAccount() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Account(r.Context())))
+	})
+}
+
+func TestKeyStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := ks.Create("alice", false)
+	b, _ := ks.Create("bob", true)
+	if err := ks.Revoke(a.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ks2, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := ks2.List()
+	if len(keys) != 1 || keys[0].ID != b.ID || !keys[0].Admin || keys[0].Secret != "" {
+		t.Fatalf("expected only bob's admin key without secret, got %+v", keys)
+	}
+	if k, ok := ks2.lookup(b.ID); !ok || k.Secret != b.Secret {
+		t.Fatal("expected the secret to survive a reload")
+	}
+	if err := ks2.Revoke(a.ID); err != ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ks, _ := OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	k, _ := ks.Create("alice", false)
+	h := ks.Authenticate(echoAccount())
+
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	signed := func(ts time.Time, body, sigBody string) *http.Request {
+		req := httptest.NewRequest("POST", "/api/v1/orders?x=1", bytes.NewBufferString(body))
+		stamp := strconv.FormatInt(ts.UnixMilli(), 10)
+		req.Header.Set("X-API-Key", k.ID)
+		req.Header.Set("X-API-Timestamp", stamp)
+		req.Header.Set("X-API-Signature", Sign(k.Secret, stamp, "POST", "/api/v1/orders?x=1", []byte(sigBody)))
+		return req
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", k.ID+":"+k.Secret)
+	if w := do(req); w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("plain key: got %d %q", w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", k.ID+":wrong")
+	if w := do(req); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: expected 401, got %d", w.Code)
+	}
+	if w := do(httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("no key: expected 401, got %d", w.Code)
+	}
+
+	if w := do(signed(time.Now(), `{"a":1}`, `{"a":1}`)); w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("signed: got %d %q", w.Code, w.Body)
+	}
+	if w := do(signed(time.Now(), `{"a":2}`, `{"a":1}`)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("tampered body: expected 401, got %d", w.Code)
+	}
+	if w := do(signed(time.Now().Add(-2*MaxSkew), `{}`, `{}`)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("stale timestamp: expected 401, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	ks, _ := OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	admin, _ := ks.Create("ops", true)
+	user, _ := ks.Create("alice", false)
+	h := ks.AdminOnly(ks.AdminHandler("/admin/v1/keys"))
+
+	do := func(key Key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("X-API-Key", key.ID+":"+key.Secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(user, "GET", "/admin/v1/keys", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("non-admin: expected 403, got %d", w.Code)
+	}
+
+	w := do(admin, "POST", "/admin/v1/keys", `{"account":"bob"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", w.Code)
+	}
+	var bob Key
+	json.NewDecoder(w.Body).Decode(&bob)
+	if bob.Account != "bob" || bob.Secret == "" {
+		t.Fatalf("expected bob's key with its secret, got %+v", bob)
+	}
+
+	if w := do(admin, "DELETE", "/admin/v1/keys/"+bob.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d", w.Code)
+	}
+	if w := do(bob, "GET", "/admin/v1/keys", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: expected 401, got %d", w.Code)
+	}
+	if w := do(admin, "DELETE", "/admin/v1/keys/"+bob.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("revoke twice: expected 404, got %d", w.Code)
+	}
+}
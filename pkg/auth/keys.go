@@ -0,0 +1,177 @@
+// Package auth authenticates HTTP requests with API keys and binds each one
+// to the account that owns the key.
+//
+// A request presents its key either directly,
+//
+//	X-API-Key: <id>:<secret>
+//
+// or signs itself with the secret, which then never crosses the wire:
+//
+//	X-API-Key:       <id>
+//	X-API-Timestamp: <unix ms>
+//	X-API-Signature: hex(HMAC-SHA256(secret, timestamp "\n" method "\n" request URI "\n" body))
+//
+// Keys live in a JSON file managed through the admin API (AdminHandler).
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Key is one API key.
+type Key struct {
+	ID      string `json:"id"`
+	Secret  string `json:"secret,omitempty"`
+	Account string `json:"account"`
+	Admin   bool   `json:"admin,omitempty"` // may manage keys and read the drop copy
+	Created int64  `json:"created"`         // unix ms
+}
+
+// ErrKeyNotFound is returned for an unknown key ID.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyStore holds the keys and persists every change to its file.
+type KeyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// OpenKeyStore loads the keys in path; a missing file is an empty store and
+// is created on the first change.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	ks := &KeyStore{path: path, keys: make(map[string]Key)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	for _, k := range file.Keys {
+		ks.keys[k.ID] = k
+	}
+	return ks, nil
+}
+
+// Create issues a new key for account. The returned Key is the only place
+// the caller gets to see the secret through the API.
+func (ks *KeyStore) Create(account string, admin bool) (Key, error) {
+	if account == "" {
+		return Key{}, errors.New("account is required")
+	}
+	k := Key{
+		ID:      randomHex(8),
+		Secret:  randomHex(32),
+		Account: account,
+		Admin:   admin,
+		Created: time.Now().UnixMilli(),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[k.ID] = k
+	if err := ks.saveLocked(); err != nil {
+		delete(ks.keys, k.ID)
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// Revoke deletes a key.
+func (ks *KeyStore) Revoke(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	delete(ks.keys, id)
+	if err := ks.saveLocked(); err != nil {
+		ks.keys[id] = k
+		return err
+	}
+	return nil
+}
+
+// List returns every key without its secret, oldest first.
+func (ks *KeyStore) List() []Key {
+	ks.mu.RLock()
+	out := make([]Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		k.Secret = ""
+		out = append(out, k)
+	}
+	ks.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Created != out[j].Created {
+			return out[i].Created < out[j].Created
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (ks *KeyStore) lookup(id string) (Key, bool) {
+	ks.mu.RLock()
+	k, ok := ks.keys[id]
+	ks.mu.RUnlock()
+	return k, ok
+}
+
+// saveLocked writes the file through a temp file and rename so a crash
+// never leaves it half written.
+func (ks *KeyStore) saveLocked() error {
+	file := struct {
+		Keys []Key `json:"keys"`
+	}{Keys: make([]Key, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		file.Keys = append(file.Keys, k)
+	}
+	sort.Slice(file.Keys, func(i, j int) bool { return file.Keys[i].ID < file.Keys[j].ID })
+	b, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), filepath.Base(ks.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ks.path)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxSkew is how far a signed request's timestamp may be from the
+	// server clock.
+	MaxSkew = 30 * time.Second
+	// maxSignedBody caps the body read into memory to check a signature.
+	maxSignedBody = 1 << 20
+)
+
+type ctxKey struct{}
+
+// WithKey returns ctx carrying the authenticated key.
+func WithKey(ctx context.Context, k Key) context.Context {
+	k.Secret = ""
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// FromContext returns the key the request was authenticated with.
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(Key)
+	return k, ok
+}
+
+// Account returns the account of the authenticated request, or "".
+func Account(ctx context.Context) string {
+	k, _ := FromContext(ctx)
+	return k.Account
+}
+
+// Authenticate passes on requests carrying a valid key, with the key in
+// their context, and answers everything else with 401.
+func (ks *KeyStore) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, msg := ks.verify(r)
+		if msg != "" {
+			writeError(w, http.StatusUnauthorized, msg)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), k)))
+	})
+}
+
+// AdminOnly is Authenticate that also requires an admin key.
+func (ks *KeyStore) AdminOnly(next http.Handler) http.Handler {
+	return ks.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if k, _ := FromContext(r.Context()); !k.Admin {
+			writeError(w, http.StatusForbidden, "admin key required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Check returns the key of credentials in the "<id>:<secret>" form of
+// X-API-Key, for the gateways that do not speak HTTP.
+func (ks *KeyStore) Check(cred string) (Key, bool) {
+	id, secret, _ := strings.Cut(cred, ":")
+	k, ok := ks.lookup(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(secret), []byte(k.Secret)) != 1 {
+		return Key{}, false
+	}
+	return k, true
+}
+
+// verify returns the request's key, or why it is not authenticated.
+func (ks *KeyStore) verify(r *http.Request) (Key, string) {
+	hdr := r.Header.Get("X-API-Key")
+	if hdr == "" {
+		return Key{}, "missing API key"
+	}
+	sig := r.Header.Get("X-API-Signature")
+	if sig == "" {
+		k, ok := ks.Check(hdr)
+		if !ok {
+			return Key{}, "invalid API key"
+		}
+		return k, ""
+	}
+
+	k, ok := ks.lookup(hdr)
+	if !ok {
+		return Key{}, "invalid API key"
+	}
+	ts := r.Header.Get("X-API-Timestamp")
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Key{}, "invalid timestamp"
+	}
+	if d := time.Since(time.UnixMilli(ms)); d > MaxSkew || d < -MaxSkew {
+		return Key{}, "timestamp outside allowed window"
+	}
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+		if err != nil || len(body) > maxSignedBody {
+			return Key{}, "body too large to sign"
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	want := Sign(k.Secret, ts, r.Method, r.URL.RequestURI(), body)
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(want)) != 1 {
+		return Key{}, "invalid signature"
+	}
+	return k, ""
+}
+
+// Sign computes X-API-Signature for a request.
+func Sign(secret, timestamp, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + requestURI + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
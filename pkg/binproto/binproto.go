@@ -0,0 +1,439 @@
+// Package binproto is a compact binary order entry protocol, in the spirit
+// of OUCH/SBE: fixed-layout little-endian messages behind a length prefix.
+//
+// Every frame is
+//
+//	uint16  length of what follows
+//	uint8   message type
+//	...     fixed body for that type
+//
+// Strings are fixed-width, NUL padded. Prices are integer cents, as
+// everywhere else in the engine. A connection starts with a Logon carrying
+// an API key, answered with LoggedOn; its orders are placed for the key's
+// account, and it can only cancel or amend that account's orders. The
+// client tags each request with a
+// RequestID that the server echoes in every response to it; responses to
+// one connection come back in request order. A submit or amend that trades
+// is answered with one Fill per trade followed by its terminal message
+// (Ack or Amended); every other request gets exactly one response.
+package binproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MsgType is the first byte of every frame.
+type MsgType byte
+
+// Client to server.
+const (
+	TypeLogon    MsgType = 'L'
+	TypeNewOrder MsgType = 'N'
+	TypeCancel   MsgType = 'X'
+	TypeAmend    MsgType = 'M'
+)
+
+// Server to client.
+const (
+	TypeLoggedOn  MsgType = 'O'
+	TypeAck       MsgType = 'A'
+	TypeFill      MsgType = 'F'
+	TypeCancelled MsgType = 'C'
+	TypeAmended   MsgType = 'U'
+	TypeReject    MsgType = 'J'
+)
+
+// Field widths.
+const (
+	SymbolLen  = 8
+	OrderIDLen = 36 // room for an engine order ID, see engine.Router.NewOrderID
+	ReasonLen  = 64
+	KeyIDLen   = 16 // an API key ID
+	SecretLen  = 64 // an API key secret
+)
+
+// Side and order type codes.
+const (
+	SideBuy  byte = 1
+	SideSell byte = 2
+
+	OrdLimit  byte = 1
+	OrdMarket byte = 2
+)
+
+// Status of an order in Ack and Amended.
+const (
+	StatusNew     byte = 0 // resting, nothing filled
+	StatusPartial byte = 1 // resting, partly filled
+	StatusFilled  byte = 2
+)
+
+// Reject codes.
+const (
+	RejectInvalid  uint16 = 1 // malformed or failed validation
+	RejectNotFound uint16 = 2 // unknown or no longer open order
+	RejectEngine   uint16 = 3 // refused by the matching engine
+	RejectUnknown  uint16 = 4 // unknown message type
+	RejectRisk     uint16 = 5 // refused by a pre-trade risk limit
+	RejectAuth     uint16 = 6 // bad credentials, or not logged on
+	RejectRate     uint16 = 7 // over the account's or address's rate limit
+)
+
+// Message is implemented by every message type.
+type Message interface {
+	Type() MsgType
+	bodyLen() int
+	put(b []byte)
+	get(b []byte)
+}
+
+// Logon authenticates the connection with an API key. It must be the first
+// request.
+type Logon struct {
+	RequestID uint64
+	KeyID     string
+	Secret    string
+}
+
+// LoggedOn accepts a Logon.
+type LoggedOn struct {
+	RequestID uint64
+}
+
+// NewOrder submits an order.
+type NewOrder struct {
+	RequestID uint64
+	Symbol    string
+	Side      byte
+	OrdType   byte
+	Price     int64
+	Quantity  int64
+}
+
+// Cancel cancels a resting order.
+type Cancel struct {
+	RequestID uint64
+	Symbol    string
+	OrderID   string
+}
+
+// Amend changes a resting order's price and total quantity.
+type Amend struct {
+	RequestID uint64
+	Symbol    string
+	OrderID   string
+	Price     int64
+	Quantity  int64
+}
+
+// Ack is the terminal response to NewOrder.
+type Ack struct {
+	RequestID uint64
+	OrderID   string
+	Status    byte
+	Filled    int64
+	Remaining int64
+}
+
+// Fill reports one trade of the order named by RequestID; it precedes the
+// Ack or Amended it belongs to.
+type Fill struct {
+	RequestID uint64
+	Price     int64
+	Quantity  int64
+}
+
+// Cancelled confirms a Cancel.
+type Cancelled struct {
+	RequestID uint64
+	OrderID   string
+}
+
+// Amended confirms an Amend with the order's new state.
+type Amended struct {
+	RequestID uint64
+	OrderID   string
+	Status    byte
+	Price     int64
+	Quantity  int64
+	Filled    int64
+}
+
+// Reject refuses any request.
+type Reject struct {
+	RequestID uint64
+	Code      uint16
+	Reason    string
+}
+
+func (*Logon) Type() MsgType     { return TypeLogon }
+func (*LoggedOn) Type() MsgType  { return TypeLoggedOn }
+func (*NewOrder) Type() MsgType  { return TypeNewOrder }
+func (*Cancel) Type() MsgType    { return TypeCancel }
+func (*Amend) Type() MsgType     { return TypeAmend }
+func (*Ack) Type() MsgType       { return TypeAck }
+func (*Fill) Type() MsgType      { return TypeFill }
+func (*Cancelled) Type() MsgType { return TypeCancelled }
+func (*Amended) Type() MsgType   { return TypeAmended }
+func (*Reject) Type() MsgType    { return TypeReject }
+
+func (*Logon) bodyLen() int     { return 8 + KeyIDLen + SecretLen }
+func (*LoggedOn) bodyLen() int  { return 8 }
+func (*NewOrder) bodyLen() int  { return 8 + SymbolLen + 1 + 1 + 8 + 8 }
+func (*Cancel) bodyLen() int    { return 8 + SymbolLen + OrderIDLen }
+func (*Amend) bodyLen() int     { return 8 + SymbolLen + OrderIDLen + 8 + 8 }
+func (*Ack) bodyLen() int       { return 8 + OrderIDLen + 1 + 8 + 8 }
+func (*Fill) bodyLen() int      { return 8 + 8 + 8 }
+func (*Cancelled) bodyLen() int { return 8 + OrderIDLen }
+func (*Amended) bodyLen() int   { return 8 + OrderIDLen + 1 + 8 + 8 + 8 }
+func (*Reject) bodyLen() int    { return 8 + 2 + ReasonLen }
+
+var le = binary.LittleEndian
+
+func (m *Logon) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:8+KeyIDLen], m.KeyID)
+	putString(b[8+KeyIDLen:8+KeyIDLen+SecretLen], m.Secret)
+}
+
+func (m *Logon) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.KeyID = getString(b[8 : 8+KeyIDLen])
+	m.Secret = getString(b[8+KeyIDLen : 8+KeyIDLen+SecretLen])
+}
+
+func (m *LoggedOn) put(b []byte) { le.PutUint64(b, m.RequestID) }
+func (m *LoggedOn) get(b []byte) { m.RequestID = le.Uint64(b) }
+
+func (m *NewOrder) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:8+SymbolLen], m.Symbol)
+	b[16], b[17] = m.Side, m.OrdType
+	le.PutUint64(b[18:], uint64(m.Price))
+	le.PutUint64(b[26:], uint64(m.Quantity))
+}
+
+func (m *NewOrder) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.Symbol = getString(b[8 : 8+SymbolLen])
+	m.Side, m.OrdType = b[16], b[17]
+	m.Price = int64(le.Uint64(b[18:]))
+	m.Quantity = int64(le.Uint64(b[26:]))
+}
+
+func (m *Cancel) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:16], m.Symbol)
+	putString(b[16:16+OrderIDLen], m.OrderID)
+}
+
+func (m *Cancel) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.Symbol = getString(b[8:16])
+	m.OrderID = getString(b[16 : 16+OrderIDLen])
+}
+
+func (m *Amend) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:16], m.Symbol)
+	putString(b[16:52], m.OrderID)
+	le.PutUint64(b[52:], uint64(m.Price))
+	le.PutUint64(b[60:], uint64(m.Quantity))
+}
+
+func (m *Amend) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.Symbol = getString(b[8:16])
+	m.OrderID = getString(b[16:52])
+	m.Price = int64(le.Uint64(b[52:]))
+	m.Quantity = int64(le.Uint64(b[60:]))
+}
+
+func (m *Ack) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:44], m.OrderID)
+	b[44] = m.Status
+	le.PutUint64(b[45:], uint64(m.Filled))
+	le.PutUint64(b[53:], uint64(m.Remaining))
+}
+
+func (m *Ack) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.OrderID = getString(b[8:44])
+	m.Status = b[44]
+	m.Filled = int64(le.Uint64(b[45:]))
+	m.Remaining = int64(le.Uint64(b[53:]))
+}
+
+func (m *Fill) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	le.PutUint64(b[8:], uint64(m.Price))
+	le.PutUint64(b[16:], uint64(m.Quantity))
+}
+
+func (m *Fill) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.Price = int64(le.Uint64(b[8:]))
+	m.Quantity = int64(le.Uint64(b[16:]))
+}
+
+func (m *Cancelled) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:44], m.OrderID)
+}
+
+func (m *Cancelled) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.OrderID = getString(b[8:44])
+}
+
+func (m *Amended) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	putString(b[8:44], m.OrderID)
+	b[44] = m.Status
+	le.PutUint64(b[45:], uint64(m.Price))
+	le.PutUint64(b[53:], uint64(m.Quantity))
+	le.PutUint64(b[61:], uint64(m.Filled))
+}
+
+func (m *Amended) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.OrderID = getString(b[8:44])
+	m.Status = b[44]
+	m.Price = int64(le.Uint64(b[45:]))
+	m.Quantity = int64(le.Uint64(b[53:]))
+	m.Filled = int64(le.Uint64(b[61:]))
+}
+
+func (m *Reject) put(b []byte) {
+	le.PutUint64(b, m.RequestID)
+	le.PutUint16(b[8:], m.Code)
+	putString(b[10:10+ReasonLen], m.Reason)
+}
+
+func (m *Reject) get(b []byte) {
+	m.RequestID = le.Uint64(b)
+	m.Code = le.Uint16(b[8:])
+	m.Reason = getString(b[10 : 10+ReasonLen])
+}
+
+// putString copies s into the fixed field b, NUL padded. Longer strings are
+// truncated; callers validate lengths that matter (symbols, order IDs).
+func putString(b []byte, s string) {
+	n := copy(b, s)
+	for i := n; i < len(b); i++ {
+		b[i] = 0
+	}
+}
+
+func getString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// Append encodes m as one frame onto dst.
+func Append(dst []byte, m Message) []byte {
+	n := 1 + m.bodyLen()
+	off := len(dst)
+	dst = append(dst, make([]byte, 2+n)...)
+	le.PutUint16(dst[off:], uint16(n))
+	dst[off+2] = byte(m.Type())
+	m.put(dst[off+3:])
+	return dst
+}
+
+// ErrMalformed is returned for frames that do not decode.
+var ErrMalformed = errors.New("binproto: malformed frame")
+
+// Decode parses one frame payload (type byte and body, without the length).
+func Decode(payload []byte) (Message, error) {
+	if len(payload) == 0 {
+		return nil, ErrMalformed
+	}
+	var m Message
+	switch MsgType(payload[0]) {
+	case TypeLogon:
+		m = &Logon{}
+	case TypeLoggedOn:
+		m = &LoggedOn{}
+	case TypeNewOrder:
+		m = &NewOrder{}
+	case TypeCancel:
+		m = &Cancel{}
+	case TypeAmend:
+		m = &Amend{}
+	case TypeAck:
+		m = &Ack{}
+	case TypeFill:
+		m = &Fill{}
+	case TypeCancelled:
+		m = &Cancelled{}
+	case TypeAmended:
+		m = &Amended{}
+	case TypeReject:
+		m = &Reject{}
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrMalformed, payload[0])
+	}
+	if len(payload)-1 != m.bodyLen() {
+		return nil, fmt.Errorf("%w: type %q with %d byte body", ErrMalformed, payload[0], len(payload)-1)
+	}
+	m.get(payload[1:])
+	return m, nil
+}
+
+// Reader reads frames from a stream.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader wraps r; it buffers.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), buf: make([]byte, 256)}
+}
+
+// ReadFrame returns the next frame payload. The slice is only valid until
+// the next call.
+func (r *Reader) ReadFrame() ([]byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(le.Uint16(hdr[:]))
+	if n > len(r.buf) {
+		r.buf = make([]byte, n)
+	}
+	if _, err := io.ReadFull(r.r, r.buf[:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return r.buf[:n], nil
+}
+
+// Read returns the next decoded message. A frame of unknown type is
+// returned as an error wrapping ErrMalformed, after which the stream is
+// still in sync.
+func (r *Reader) Read() (Message, error) {
+	p, err := r.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return Decode(p)
+}
+
+// Buffered reports whether a complete or partial frame is already waiting,
+// so a server can hold its flush until the pipeline drains.
+func (r *Reader) Buffered() int {
+	return r.r.Buffered()
+}
@@ -0,0 +1,66 @@
+package binproto
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTripEveryMessage(t *testing.T) {
+	id := "0b8e4a52-7c1e-4c0e-9d0a-2f3c5e6a7b8c"
+	msgs := []Message{
+		&Logon{RequestID: 9, KeyID: "0123456789abcdef", Secret: "s3cret"},
+		&LoggedOn{RequestID: 9},
+		&NewOrder{RequestID: 1, Symbol: "BTCUSD", Side: SideSell, OrdType: OrdLimit, Price: 2_500_000, Quantity: 7},
+		&Cancel{RequestID: 2, Symbol: "ABC", OrderID: id},
+		&Amend{RequestID: 3, Symbol: "ABC", OrderID: id, Price: 101, Quantity: 9},
+		&Ack{RequestID: 1, OrderID: id, Status: StatusPartial, Filled: 3, Remaining: 4},
+		&Fill{RequestID: 1, Price: -5, Quantity: 3},
+		&Cancelled{RequestID: 2, OrderID: id},
+		&Amended{RequestID: 3, OrderID: id, Status: StatusNew, Price: 101, Quantity: 9, Filled: 0},
+		&Reject{RequestID: 4, Code: RejectEngine, Reason: "insufficient liquidity for market order"},
+	}
+	var stream []byte
+	for _, m := range msgs {
+		stream = Append(stream, m)
+	}
+
+	r := NewReader(bytes.NewReader(stream))
+	for _, want := range msgs {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestFixedLayout(t *testing.T) {
+	b := Append(nil, &Fill{RequestID: 0x0102, Price: 1, Quantity: 2})
+	want := []byte{
+		25, 0, // length: type + 24 byte body
+		'F',
+		0x02, 0x01, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("encoded % x", b)
+	}
+}
+
+func TestDecodeRejectsBadFrames(t *testing.T) {
+	good := Append(nil, &Cancelled{RequestID: 1, OrderID: "x"})[2:]
+	for name, p := range map[string][]byte{
+		"empty":        {},
+		"unknown type": {'?', 0, 0},
+		"short body":   good[:len(good)-1],
+	} {
+		if _, err := Decode(p); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package client is a Go client for the binproto order entry protocol.
+// A Client is safe for concurrent use: requests from many goroutines are
+// pipelined over one connection and matched to their responses by
+// RequestID.
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/binproto"
+)
+
+// ErrClosed is returned for requests on, or pending at the close of, a
+// closed connection.
+var ErrClosed = errors.New("binproto client: connection closed")
+
+// RejectError is a Reject from the server.
+type RejectError struct {
+	Code   uint16
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("rejected (%d): %s", e.Code, e.Reason)
+}
+
+// Result is the outcome of a submit or amend.
+type Result struct {
+	OrderID   string
+	Status    byte // binproto.Status*
+	Price     int64
+	Quantity  int64
+	Filled    int64
+	Remaining int64
+	Fills     []binproto.Fill // trades of this request
+}
+
+type call struct {
+	res  Result
+	err  error
+	done chan struct{}
+}
+
+// Client is one connection to the server.
+type Client struct {
+	conn net.Conn
+
+	wmu    sync.Mutex // serialises writes and request ID allocation
+	w      *bufio.Writer
+	nextID uint64
+	buf    []byte
+
+	mu      sync.Mutex
+	pending map[uint64]*call
+	err     error // set once the connection is gone
+}
+
+// Dial connects to addr.
+func Dial(addr string) (*Client, error) {
+	c, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.SetNoDelay(true)
+	}
+	return New(c), nil
+}
+
+// New runs a client over an established connection.
+func New(conn net.Conn) *Client {
+	c := &Client{
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+		pending: make(map[uint64]*call),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the connection; pending requests fail with ErrClosed.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Logon authenticates the connection with the API key keyID:secret. It must
+// be the first request; the server closes the connection if it fails.
+func (c *Client) Logon(keyID, secret string) error {
+	if len(keyID) > binproto.KeyIDLen || len(secret) > binproto.SecretLen {
+		return errors.New("API key longer than the Logon fields")
+	}
+	_, err := c.do(&binproto.Logon{KeyID: keyID, Secret: secret})
+	return err
+}
+
+// Submit sends a NewOrder and waits for its Ack. RequestID is assigned by
+// the client. An order the engine refuses returns a *RejectError.
+func (c *Client) Submit(o binproto.NewOrder) (*Result, error) {
+	if len(o.Symbol) > binproto.SymbolLen {
+		return nil, fmt.Errorf("symbol %q longer than %d bytes", o.Symbol, binproto.SymbolLen)
+	}
+	res, err := c.do(&o)
+	if res != nil {
+		res.Price = o.Price
+	}
+	return res, err
+}
+
+// Cancel cancels a resting order.
+func (c *Client) Cancel(symbol, orderID string) error {
+	_, err := c.do(&binproto.Cancel{Symbol: symbol, OrderID: orderID})
+	return err
+}
+
+// Amend changes a resting order's price and total quantity.
+func (c *Client) Amend(symbol, orderID string, price, qty int64) (*Result, error) {
+	return c.do(&binproto.Amend{Symbol: symbol, OrderID: orderID, Price: price, Quantity: qty})
+}
+
+func (c *Client) do(m binproto.Message) (*Result, error) {
+	cl := &call{done: make(chan struct{})}
+
+	c.wmu.Lock()
+	c.nextID++
+	id := c.nextID
+	switch m := m.(type) {
+	case *binproto.Logon:
+		m.RequestID = id
+	case *binproto.NewOrder:
+		m.RequestID = id
+	case *binproto.Cancel:
+		m.RequestID = id
+	case *binproto.Amend:
+		m.RequestID = id
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return nil, c.err
+	}
+	c.pending[id] = cl
+	c.mu.Unlock()
+
+	c.buf = binproto.Append(c.buf[:0], m)
+	_, err := c.w.Write(c.buf)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		c.conn.Close() // the read loop fails everything pending
+	}
+
+	<-cl.done
+	if cl.err != nil {
+		return nil, cl.err
+	}
+	return &cl.res, nil
+}
+
+func (c *Client) readLoop() {
+	r := binproto.NewReader(c.conn)
+	for {
+		m, err := r.Read()
+		if err != nil {
+			if errors.Is(err, binproto.ErrMalformed) {
+				continue
+			}
+			c.fail()
+			return
+		}
+		c.dispatch(m)
+	}
+}
+
+func (c *Client) dispatch(m binproto.Message) {
+	switch m := m.(type) {
+	case *binproto.Fill:
+		c.mu.Lock()
+		if cl, ok := c.pending[m.RequestID]; ok {
+			cl.res.Fills = append(cl.res.Fills, *m)
+		}
+		c.mu.Unlock()
+		return
+	case *binproto.Ack:
+		c.finish(m.RequestID, func(r *Result) {
+			r.OrderID, r.Status, r.Filled, r.Remaining = m.OrderID, m.Status, m.Filled, m.Remaining
+			r.Quantity = m.Filled + m.Remaining
+		}, nil)
+	case *binproto.Amended:
+		c.finish(m.RequestID, func(r *Result) {
+			r.OrderID, r.Status, r.Price, r.Quantity, r.Filled = m.OrderID, m.Status, m.Price, m.Quantity, m.Filled
+			r.Remaining = m.Quantity - m.Filled
+		}, nil)
+	case *binproto.Cancelled:
+		c.finish(m.RequestID, func(r *Result) { r.OrderID = m.OrderID }, nil)
+	case *binproto.LoggedOn:
+		c.finish(m.RequestID, nil, nil)
+	case *binproto.Reject:
+		c.finish(m.RequestID, nil, &RejectError{Code: m.Code, Reason: m.Reason})
+	}
+}
+
+func (c *Client) finish(id uint64, fill func(*Result), err error) {
+	c.mu.Lock()
+	cl, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if fill != nil {
+		fill(&cl.res)
+	}
+	cl.err = err
+	close(cl.done)
+}
+
+func (c *Client) fail() {
+	c.mu.Lock()
+	c.err = ErrClosed
+	pending := c.pending
+	c.pending = make(map[uint64]*call)
+	c.mu.Unlock()
+	for _, cl := range pending {
+		cl.err = ErrClosed
+		close(cl.done)
+	}
+}
@@ -0,0 +1,329 @@
+// Package server serves the binproto order entry protocol on TCP, calling
+// the engine Router directly. Connections log on with an API key and act
+// for its account.
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/binproto"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+// idleTimeout drops connections that send nothing for this long.
+const idleTimeout = 5 * time.Minute
+
+// Server accepts binproto connections.
+type Server struct {
+	router *engine.Router
+	keys   *auth.KeyStore
+	limits *ratelimit.Limiter // nil: unlimited
+
+	mu     sync.Mutex
+	lns    []net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New creates a server submitting to router. keys authenticates Logons;
+// limits, if not nil, throttles requests by account and remote address.
+func New(router *engine.Router, keys *auth.KeyStore, limits *ratelimit.Limiter) *Server {
+	return &Server{router: router, keys: keys, limits: limits, conns: make(map[net.Conn]struct{})}
+}
+
+// conn is the state of one connection.
+type conn struct {
+	*Server
+	ip      string // remote address, for the rate limits
+	account string // of the key it logged on with; "" until then
+}
+
+// Serve accepts connections on ln until Close.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return errors.New("binproto: server closed")
+	}
+	s.lns = append(s.lns, ln)
+	s.mu.Unlock()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		if tc, ok := c.(*net.TCPConn); ok {
+			tc.SetNoDelay(true)
+		}
+		go s.ServeConn(c)
+	}
+}
+
+// Close stops accepting and drops every connection.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for _, ln := range s.lns {
+		ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// ServeConn handles one connection. Requests are executed one at a time in
+// arrival order; responses are buffered and flushed whenever no further
+// request is already waiting, so a pipelining client gets them batched.
+func (s *Server) ServeConn(c net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		c.Close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+		s.wg.Done()
+	}()
+
+	r := binproto.NewReader(c)
+	w := bufio.NewWriter(c)
+	cs := &conn{Server: s, ip: ratelimit.AddrIP(c.RemoteAddr())}
+	var out []byte
+	for {
+		c.SetReadDeadline(time.Now().Add(idleTimeout))
+		p, err := r.ReadFrame()
+		if err != nil {
+			return
+		}
+		var ok bool
+		out, ok = cs.handle(out[:0], p)
+		if _, err := w.Write(out); err != nil {
+			return
+		}
+		if !ok {
+			w.Flush()
+			return
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle executes one request frame and appends its responses to out. It
+// reports false when the connection is to be closed after them.
+func (s *conn) handle(out, p []byte) ([]byte, bool) {
+	m, err := binproto.Decode(p)
+	if err != nil {
+		var id uint64
+		if len(p) >= 9 {
+			id = binary.LittleEndian.Uint64(p[1:]) // every request starts with its RequestID
+		}
+		code := binproto.RejectInvalid
+		if errors.Is(err, binproto.ErrMalformed) && len(p) > 0 && !isRequest(binproto.MsgType(p[0])) {
+			code = binproto.RejectUnknown
+		}
+		// a connection that has not logged on gets one chance
+		return binproto.Append(out, &binproto.Reject{RequestID: id, Code: code, Reason: err.Error()}), s.account != ""
+	}
+
+	if lm, ok := m.(*binproto.Logon); ok || s.account == "" {
+		return s.logon(out, m, lm)
+	}
+	return s.request(out, m), true
+}
+
+// logon answers the first request of a connection, which must be a Logon
+// with a valid key; anything else ends the connection.
+func (s *conn) logon(out []byte, m binproto.Message, lm *binproto.Logon) ([]byte, bool) {
+	reject := func(id uint64, reason string) ([]byte, bool) {
+		return binproto.Append(out, &binproto.Reject{RequestID: id, Code: binproto.RejectAuth, Reason: reason}), false
+	}
+	switch {
+	case lm == nil:
+		return reject(requestID(m), "not logged on")
+	case s.account != "":
+		return reject(lm.RequestID, "already logged on")
+	}
+	// charged before the key is checked, so keys cannot be guessed freely
+	refused := &binproto.Reject{RequestID: lm.RequestID, Code: binproto.RejectRate, Reason: "rate limit exceeded"}
+	if ok, _ := s.limits.Screen(ratelimit.Query, s.ip); !ok {
+		return binproto.Append(out, refused), false
+	}
+	k, ok := s.keys.Check(lm.KeyID + ":" + lm.Secret)
+	if !ok {
+		return reject(lm.RequestID, "invalid API key")
+	}
+	if ok, _ := s.limits.Allow(ratelimit.Query, k.Account, ""); !ok {
+		return binproto.Append(out, refused), false
+	}
+	s.account = k.Account
+	return binproto.Append(out, &binproto.LoggedOn{RequestID: lm.RequestID}), true
+}
+
+// request executes a request of a logged on connection.
+func (s *conn) request(out []byte, m binproto.Message) []byte {
+	class := ratelimit.Order
+	if _, ok := m.(*binproto.Cancel); ok {
+		class = ratelimit.Cancel
+	}
+	if ok, _ := s.limits.Allow(class, s.account, s.ip); !ok {
+		return binproto.Append(out, &binproto.Reject{RequestID: requestID(m), Code: binproto.RejectRate, Reason: "rate limit exceeded"})
+	}
+
+	switch m := m.(type) {
+	case *binproto.NewOrder:
+		return s.submit(out, m)
+	case *binproto.Cancel:
+		if !s.owns(m.Symbol, m.OrderID) {
+			return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: binproto.RejectNotFound, Reason: "order not found"})
+		}
+		res := s.router.CancelOrder(m.Symbol, m.OrderID)
+		if !res.OK {
+			return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: binproto.RejectNotFound, Reason: res.Err})
+		}
+		return binproto.Append(out, &binproto.Cancelled{RequestID: m.RequestID, OrderID: m.OrderID})
+	case *binproto.Amend:
+		if !s.owns(m.Symbol, m.OrderID) {
+			return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: binproto.RejectNotFound, Reason: "order not found"})
+		}
+		res := s.router.AmendOrder(m.Symbol, m.OrderID, m.Price, m.Quantity)
+		if res.Err != "" {
+			code := binproto.RejectEngine
+			switch {
+			case res.Reject != nil:
+				code = binproto.RejectRisk
+			case res.Err == "order not found":
+				code = binproto.RejectNotFound
+			}
+			return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: code, Reason: res.Err})
+		}
+		out = appendFills(out, m.RequestID, res.Trades)
+		o := res.Order
+		return binproto.Append(out, &binproto.Amended{
+			RequestID: m.RequestID, OrderID: o.ID, Status: status(o),
+			Price: o.Price, Quantity: o.Quantity, Filled: o.Filled,
+		})
+	default:
+		return binproto.Append(out, &binproto.Reject{Code: binproto.RejectUnknown, Reason: "not a request"})
+	}
+}
+
+// owns reports whether the connection's account owns the order. Orders of
+// other accounts are reported as not found, so their IDs cannot be probed.
+func (s *conn) owns(symbol, id string) bool {
+	res := s.router.GetOrder(symbol, id)
+	return res.Err == "" && res.Order.Account == s.account
+}
+
+func (s *conn) submit(out []byte, m *binproto.NewOrder) []byte {
+	o := &model.Order{
+		Symbol:    m.Symbol,
+		Price:     m.Price,
+		Quantity:  m.Quantity,
+		Account:   s.account,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	switch m.Side {
+	case binproto.SideBuy:
+		o.Side = model.BUY
+	case binproto.SideSell:
+		o.Side = model.SELL
+	}
+	switch m.OrdType {
+	case binproto.OrdLimit:
+		o.Type = model.LIMIT
+	case binproto.OrdMarket:
+		o.Type = model.MARKET
+	}
+	if err := o.Validate(); err != nil {
+		return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: binproto.RejectInvalid, Reason: err.Error()})
+	}
+	o.ID = s.router.NewOrderID(o.Symbol)
+
+	res := s.router.SubmitOrder(o)
+	if res.Err != "" {
+		code := binproto.RejectEngine
+		if res.Reject != nil {
+			code = binproto.RejectRisk
+		}
+		return binproto.Append(out, &binproto.Reject{RequestID: m.RequestID, Code: code, Reason: res.Err})
+	}
+	// res.Order is live in the shard once it rests; report from this
+	// submit's own trades instead
+	done := model.Order{Quantity: m.Quantity}
+	for _, t := range res.Trades {
+		done.Filled += t.Quantity
+	}
+	out = appendFills(out, m.RequestID, res.Trades)
+	return binproto.Append(out, &binproto.Ack{
+		RequestID: m.RequestID, OrderID: o.ID, Status: status(&done),
+		Filled: done.Filled, Remaining: done.Remaining(),
+	})
+}
+
+func appendFills(out []byte, id uint64, trades []model.Trade) []byte {
+	for _, t := range trades {
+		out = binproto.Append(out, &binproto.Fill{RequestID: id, Price: t.Price, Quantity: t.Quantity})
+	}
+	return out
+}
+
+func status(o *model.Order) byte {
+	switch {
+	case o.Remaining() == 0:
+		return binproto.StatusFilled
+	case o.Filled > 0:
+		return binproto.StatusPartial
+	}
+	return binproto.StatusNew
+}
+
+func isRequest(t binproto.MsgType) bool {
+	switch t {
+	case binproto.TypeLogon, binproto.TypeNewOrder, binproto.TypeCancel, binproto.TypeAmend:
+		return true
+	}
+	return false
+}
+
+// requestID returns the RequestID of a request.
+func requestID(m binproto.Message) uint64 {
+	switch m := m.(type) {
+	case *binproto.NewOrder:
+		return m.RequestID
+	case *binproto.Cancel:
+		return m.RequestID
+	case *binproto.Amend:
+		return m.RequestID
+	}
+	return 0
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/binproto"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/binproto/client"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+// setup starts a server and returns a client logged on as acct-1.
+func setup(t *testing.T) *client.Client {
+	t.Helper()
+	srv, _ := serve(t)
+	return login(t, srv, "acct-1")
+}
+
+func serve(t *testing.T) (*Server, *engine.Router) {
+	t.Helper()
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := engine.NewRouter(2, 64)
+	srv := New(r, keys, nil)
+	t.Cleanup(func() {
+		srv.Close()
+		r.Stop()
+	})
+	return srv, r
+}
+
+// connect opens a connection to srv without logging on.
+func connect(t *testing.T, srv *Server) *client.Client {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	go srv.ServeConn(c2)
+	c := client.New(c1)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// login connects and logs on with a new key of account.
+func login(t *testing.T, srv *Server, account string) *client.Client {
+	t.Helper()
+	k, err := srv.keys.Create(account, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connect(t, srv)
+	if err := c.Logon(k.ID, k.Secret); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSubmitAmendCancel(t *testing.T) {
+	c := setup(t)
+
+	rest, err := c.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideSell, OrdType: binproto.OrdLimit, Price: 100, Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.Status != binproto.StatusNew || rest.Remaining != 10 || rest.OrderID == "" {
+		t.Fatalf("unexpected ack %+v", rest)
+	}
+
+	take, err := c.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideBuy, OrdType: binproto.OrdMarket, Quantity: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if take.Status != binproto.StatusFilled || len(take.Fills) != 1 || take.Fills[0].Price != 100 || take.Fills[0].Quantity != 4 {
+		t.Fatalf("unexpected fill %+v", take)
+	}
+
+	am, err := c.Amend("ABC", rest.OrderID, 101, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if am.Price != 101 || am.Quantity != 8 || am.Filled != 4 || am.Remaining != 4 || am.Status != binproto.StatusPartial {
+		t.Fatalf("unexpected amend %+v", am)
+	}
+
+	if err := c.Cancel("ABC", rest.OrderID); err != nil {
+		t.Fatal(err)
+	}
+	var rej *client.RejectError
+	if err := c.Cancel("ABC", rest.OrderID); !errors.As(err, &rej) || rej.Code != binproto.RejectNotFound {
+		t.Fatalf("second cancel: %v", err)
+	}
+}
+
+func TestRejects(t *testing.T) {
+	c := setup(t)
+	var rej *client.RejectError
+
+	_, err := c.Submit(binproto.NewOrder{Symbol: "ABC", Side: 9, OrdType: binproto.OrdLimit, Price: 1, Quantity: 1})
+	if !errors.As(err, &rej) || rej.Code != binproto.RejectInvalid {
+		t.Fatalf("bad side: %v", err)
+	}
+	_, err = c.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideBuy, OrdType: binproto.OrdMarket, Quantity: 1})
+	if !errors.As(err, &rej) || rej.Code != binproto.RejectEngine {
+		t.Fatalf("market with empty book: %v", err)
+	}
+	if _, err := c.Submit(binproto.NewOrder{Symbol: "TOOLONGSYM"}); err == nil {
+		t.Fatal("long symbol accepted")
+	}
+}
+
+func TestPipelinedSubmits(t *testing.T) {
+	c := setup(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := c.Submit(binproto.NewOrder{Symbol: "P", Side: binproto.SideBuy, OrdType: binproto.OrdLimit, Price: int64(1 + i%5), Quantity: 1})
+			if err == nil && res.Remaining != 1 {
+				err = errors.New("buy-only book traded")
+			}
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectionsActForTheirKeysAccount(t *testing.T) {
+	srv, r := serve(t)
+	var rej *client.RejectError
+
+	anon := connect(t, srv)
+	if _, err := anon.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideBuy, OrdType: binproto.OrdLimit, Price: 1, Quantity: 1}); !errors.As(err, &rej) || rej.Code != binproto.RejectAuth {
+		t.Fatalf("expected a submit before logon refused, got %v", err)
+	}
+	if _, err := anon.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideBuy, OrdType: binproto.OrdLimit, Price: 1, Quantity: 1}); !errors.Is(err, client.ErrClosed) {
+		t.Fatalf("expected the connection closed, got %v", err)
+	}
+	if err := connect(t, srv).Logon("nope", "nope"); !errors.As(err, &rej) || rej.Code != binproto.RejectAuth {
+		t.Fatalf("expected a bad key refused, got %v", err)
+	}
+
+	alice, bob := login(t, srv, "alice"), login(t, srv, "bob")
+	res, err := alice.Submit(binproto.NewOrder{Symbol: "ABC", Side: binproto.SideSell, OrdType: binproto.OrdLimit, Price: 100, Quantity: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.GetOrder("ABC", res.OrderID); got.Err != "" || got.Order.Account != "alice" {
+		t.Fatalf("expected the order placed for alice, got %+v", got)
+	}
+	if err := bob.Cancel("ABC", res.OrderID); !errors.As(err, &rej) || rej.Code != binproto.RejectNotFound {
+		t.Fatalf("expected bob unable to cancel alice's order, got %v", err)
+	}
+	if _, err := bob.Amend("ABC", res.OrderID, 100, 1); !errors.As(err, &rej) || rej.Code != binproto.RejectNotFound {
+		t.Fatalf("expected bob unable to amend alice's order, got %v", err)
+	}
+	if err := alice.Cancel("ABC", res.OrderID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	srv, _ := serve(t)
+	budgets, err := ratelimit.ParseBudgets("order=1,cancel=1,query=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.limits = ratelimit.New(budgets, budgets)
+	c := login(t, srv, "acct-1")
+
+	order := binproto.NewOrder{Symbol: "ABC", Side: binproto.SideSell, OrdType: binproto.OrdLimit, Price: 100, Quantity: 1}
+	if _, err := c.Submit(order); err != nil {
+		t.Fatal(err)
+	}
+	var rej *client.RejectError
+	if _, err := c.Submit(order); !errors.As(err, &rej) || rej.Code != binproto.RejectRate {
+		t.Fatalf("expected the second order over the limit, got %v", err)
+	}
+
+	// the address spent its logon token; another logon is refused before
+	// its key is looked at
+	k, err := srv.keys.Create("acct-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(t, srv).Logon(k.ID, k.Secret); !errors.As(err, &rej) || rej.Code != binproto.RejectRate {
+		t.Fatalf("expected the logon over the limit, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Batches.
+//
+// SubmitBatch and CancelBatch take many orders at once and send one command
+// to each shard involved rather than one per order. The shards work on their
+// parts in parallel, each in the order given, and every item gets its own
+// result, in the order given.
+//
+// An atomic batch is all or nothing. It must be for a single symbol, so it
+// is one command to one shard and nothing else runs between its items.
+// Every order of an atomic submit passes the PreTrade checks and is admitted
+// by its book before any is processed, or none is processed; market orders,
+// which can still be refused for lack of liquidity once earlier items have
+// traded, cannot be in one. An atomic cancel cancels every order or none.
+
+var (
+	errBatchSymbol  = errors.New("an atomic batch must be for a single symbol")
+	errBatchMarket  = errors.New("an atomic batch cannot hold market orders")
+	errBatchAborted = errors.New("not processed: another item of the atomic batch was refused")
+	errBatchTwice   = errors.New("order listed twice")
+)
+
+// CancelItem is one order of a batch cancel.
+type CancelItem struct {
+	Symbol  string
+	OrderID string
+}
+
+// SubmitBatch submits orders with one command per shard and returns their
+// results in the same order. Orders repeating a client order ID are answered
+// as by SubmitOrder; an atomic batch must repeat all of an earlier batch's
+// or none.
+func (r *Router) SubmitBatch(orders []*model.Order, atomic bool) []SubmitResult {
+	res := make([]SubmitResult, len(orders))
+	if atomic {
+		if err := atomicSubmit(orders); err != nil {
+			return failSubmits(res, err)
+		}
+	}
+
+	defer r.lockClientIDs(orders)()
+	repeated := make([]bool, len(orders))
+	n := 0
+	for i, e := range r.clientEntries(orders) {
+		if e != nil {
+			res[i] = repeat(orders[i], e)
+			repeated[i] = true
+			n++
+		}
+	}
+	if atomic && n > 0 {
+		if n < len(orders) {
+			return failSubmits(res, errBatchRepeat)
+		}
+		for i := range res {
+			if res[i].Err != "" {
+				return abortSubmits(res, i)
+			}
+		}
+		return res
+	}
+
+	byShard := make(map[int][]int)
+	for i, o := range orders {
+		if repeated[i] {
+			continue
+		}
+		idx := r.routeIdx(o.Symbol)
+		if o.ID == "" {
+			o.ID = r.newID(idx)
+		}
+		if err := r.vet(o); err != nil {
+			res[i] = SubmitResult{Err: err.Error(), Reject: err}
+			if atomic {
+				for _, prev := range orders[:i] {
+					r.release(prev, r.pre)
+				}
+				return abortSubmits(res, i)
+			}
+			continue
+		}
+		byShard[idx] = append(byShard[idx], i)
+	}
+
+	r.fanOut(byShard, func(items []int) *Cmd {
+		cmd := &Cmd{Typ: CmdSubmitBatch, Atomic: atomic}
+		for _, i := range items {
+			cmd.Orders = append(cmd.Orders, orders[i])
+		}
+		return cmd
+	}, func(items []int, reply interface{}) {
+		for j, got := range reply.([]SubmitResult) {
+			res[items[j]] = got
+		}
+	})
+	r.remember(res)
+	return res
+}
+
+// CancelBatch cancels orders with one command per shard and returns their
+// results in the same order. As with CancelOrder, an item's symbol is only
+// needed when its ID was chosen by the caller. account, unless empty, must
+// own every order; orders of other accounts are reported as not found.
+func (r *Router) CancelBatch(account string, items []CancelItem, atomic bool) []CancelResult {
+	res := make([]CancelResult, len(items))
+	byShard := make(map[int][]int)
+	for i, it := range items {
+		idx, ok := r.orderIdx(it.Symbol, it.OrderID)
+		if !ok {
+			res[i] = CancelResult{Err: "order not found"}
+			if atomic {
+				return abortCancels(res, i)
+			}
+			continue
+		}
+		byShard[idx] = append(byShard[idx], i)
+	}
+	if atomic && len(byShard) > 1 {
+		for i := range res {
+			res[i] = CancelResult{Err: errBatchSymbol.Error()}
+		}
+		return res
+	}
+
+	r.fanOut(byShard, func(idx []int) *Cmd {
+		cmd := &Cmd{Typ: CmdCancelBatch, Account: account, Atomic: atomic}
+		for _, i := range idx {
+			cmd.OrderIDs = append(cmd.OrderIDs, items[i].OrderID)
+		}
+		return cmd
+	}, func(idx []int, reply interface{}) {
+		for j, got := range reply.([]CancelResult) {
+			res[idx[j]] = got
+		}
+	})
+	return res
+}
+
+// fanOut sends the command mk builds for each shard's items, by index, to
+// that shard and hands each reply to collect once all are sent.
+func (r *Router) fanOut(byShard map[int][]int, mk func(items []int) *Cmd, collect func(items []int, reply interface{})) {
+	type sent struct {
+		items []int
+		reply chan interface{}
+	}
+	out := make([]sent, 0, len(byShard))
+	for idx, items := range byShard {
+		cmd := mk(items)
+		cmd.Reply = make(chan interface{}, 1)
+		r.shards[idx].in <- cmd
+		out = append(out, sent{items, cmd.Reply})
+	}
+	for _, s := range out {
+		collect(s.items, <-s.reply)
+	}
+}
+
+// atomicSubmit reports why orders cannot be an atomic batch, if they cannot.
+func atomicSubmit(orders []*model.Order) error {
+	for _, o := range orders {
+		switch {
+		case o.Symbol != orders[0].Symbol:
+			return errBatchSymbol
+		case o.Type == model.MARKET:
+			return errBatchMarket
+		}
+	}
+	return nil
+}
+
+// failSubmits refuses every order of a batch for err.
+func failSubmits(res []SubmitResult, err error) []SubmitResult {
+	for i := range res {
+		res[i] = SubmitResult{Err: err.Error()}
+	}
+	return res
+}
+
+// abortSubmits marks every order of an atomic batch but the refused one at
+// failed as not processed.
+func abortSubmits(res []SubmitResult, failed int) []SubmitResult {
+	for i := range res {
+		if i != failed {
+			res[i] = SubmitResult{Err: errBatchAborted.Error()}
+		}
+	}
+	return res
+}
+
+// abortCancels is abortSubmits for a batch cancel.
+func abortCancels(res []CancelResult, failed int) []CancelResult {
+	for i := range res {
+		if i != failed {
+			res[i] = CancelResult{Err: errBatchAborted.Error()}
+		}
+	}
+	return res
+}
+
+func (s *shard) handleSubmitBatch(cmd *Cmd) {
+	res := make([]SubmitResult, len(cmd.Orders))
+	if cmd.Atomic {
+		for i, o := range cmd.Orders {
+			if err := s.getOrCreateBook(o.Symbol).admit(o); err != nil {
+				// refuse them all, which releases what the checks reserved
+				for j, o := range cmd.Orders {
+					reason := errBatchAborted
+					if j == i {
+						reason = err
+					}
+					s.getOrCreateBook(o.Symbol).reject(o, reason)
+					res[j] = SubmitResult{Err: reason.Error()}
+				}
+				cmd.Reply <- res
+				return
+			}
+		}
+	}
+	for i, o := range cmd.Orders {
+		res[i] = s.submit(o)
+	}
+	cmd.Reply <- res
+}
+
+func (s *shard) handleCancelBatch(cmd *Cmd) {
+	res := make([]CancelResult, len(cmd.OrderIDs))
+	if cmd.Atomic {
+		seen := make(map[string]bool, len(cmd.OrderIDs))
+		symbol := ""
+		for i, id := range cmd.OrderIDs {
+			o, msg := s.cancellable(id, cmd.Account)
+			switch {
+			case msg != "":
+			case seen[id]:
+				msg = errBatchTwice.Error()
+			case symbol != "" && o.Symbol != symbol:
+				msg = errBatchSymbol.Error()
+			default:
+				symbol = o.Symbol
+			}
+			seen[id] = true
+			if msg != "" {
+				res[i] = CancelResult{Err: msg}
+				cmd.Reply <- abortCancels(res, i)
+				return
+			}
+		}
+	}
+	for i, id := range cmd.OrderIDs {
+		res[i] = s.cancel(id, cmd.Account)
+	}
+	cmd.Reply <- res
+}
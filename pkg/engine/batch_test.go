@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func TestBatchAcrossShards(t *testing.T) {
+	r := NewRouter(4, 16)
+	defer r.Stop()
+
+	var orders []*model.Order
+	for i := 0; i < 12; i++ {
+		orders = append(orders, &model.Order{ID: fmt.Sprint("o", i), Symbol: fmt.Sprint("S", i%6), Side: model.SELL, Type: model.LIMIT, Price: 100 + int64(i), Quantity: 1})
+	}
+	orders = append(orders, &model.Order{ID: "mkt", Symbol: "S0", Side: model.BUY, Type: model.MARKET, Quantity: 5})
+	res := r.SubmitBatch(orders, false)
+	for i, o := range orders[:12] {
+		if res[i].Err != "" || res[i].Order.ID != o.ID || res[i].StatusCode != 201 {
+			t.Fatalf("item %d: expected %s resting, got %+v", i, o.ID, res[i])
+		}
+	}
+	if res[12].Err == "" {
+		t.Fatal("expected the market buy of 5 refused on its own")
+	}
+
+	items := []CancelItem{{"S1", "o1"}, {"S3", "nope"}, {"S2", "o8"}, {"", "unknown"}}
+	cres := r.CancelBatch("", items, false)
+	if !cres[0].OK || cres[1].OK || !cres[2].OK || cres[3].OK {
+		t.Fatalf("expected o1 and o8 cancelled, got %+v", cres)
+	}
+	if got := r.GetOrder("S1", "o1"); got.Err == "" {
+		t.Fatal("expected o1 gone")
+	}
+}
+
+func TestAtomicBatch(t *testing.T) {
+	check := &fakeCheck{max: 10, reserved: map[string]bool{}}
+	r := NewRouter(2, 16, WithPreTrade(check))
+	defer r.Stop()
+
+	orders := []*model.Order{
+		{ID: "a", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 99, Quantity: 5},
+		{ID: "b", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 98, Quantity: 50},
+	}
+	res := r.SubmitBatch(orders, true)
+	if res[1].Reject == nil || res[0].Err != errBatchAborted.Error() || check.reserved["a"] {
+		t.Fatalf("expected the batch refused and a's reservation released, got %+v", res)
+	}
+	if bk := r.GetOrderBook("P", 5); len(bk.Bids) != 0 {
+		t.Fatalf("expected nothing on the book, got %v", bk.Bids)
+	}
+
+	mixed := []*model.Order{orders[0], {ID: "q", Symbol: "Q", Side: model.BUY, Type: model.LIMIT, Price: 1, Quantity: 1}}
+	if res := r.SubmitBatch(mixed, true); res[0].Err != errBatchSymbol.Error() {
+		t.Fatalf("expected two symbols refused, got %+v", res)
+	}
+
+	orders[1].Quantity = 5
+	if res := r.SubmitBatch(orders, true); res[0].StatusCode != 201 || res[1].StatusCode != 201 {
+		t.Fatalf("expected both resting, got %+v", res)
+	}
+	cres := r.CancelBatch("", []CancelItem{{"P", "a"}, {"P", "a"}}, true)
+	if cres[0].OK || cres[1].Err != errBatchTwice.Error() {
+		t.Fatalf("expected a listed twice to refuse the cancel, got %+v", cres)
+	}
+	cres = r.CancelBatch("", []CancelItem{{"P", "a"}, {"P", "b"}}, true)
+	if !cres[0].OK || !cres[1].OK {
+		t.Fatalf("expected both cancelled, got %+v", cres)
+	}
+}
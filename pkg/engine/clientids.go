@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Client order IDs.
+//
+// An order's ClientOrderID is unique per account for ClientIDWindow, across
+// every gateway. Each (account, ID) has a home shard, picked by its hash,
+// which keeps the ID with the terms and result of the order that took it,
+// so looking an ID up is one round trip whichever shard holds the order.
+// A submit repeating an ID is not processed again: with the same terms it
+// gets the original result, marked Replayed; with other terms it is refused
+// with ErrClientOrderIDReused. The router serializes the submits of each ID,
+// so a repeat arriving while the original is still being processed waits
+// for its outcome. An order refused by a PreTrade check or by its book does
+// not take its ID.
+
+// ClientIDWindow is how long an account's client order ID stays taken.
+const ClientIDWindow = 24 * time.Hour
+
+// ErrClientOrderIDReused refuses an order repeating a client order ID with
+// other terms than the order that took it.
+var ErrClientOrderIDReused = errors.New("client_order_id already used for another order")
+
+// errBatchRepeat refuses an atomic batch that repeats some client order IDs
+// but not all.
+var errBatchRepeat = errors.New("an atomic batch must repeat all of an earlier batch's client_order_ids or none")
+
+// clientLocks is the number of locks the router spreads client order IDs over.
+const clientLocks = 64
+
+// clientKey is one account's client order ID.
+type clientKey struct {
+	account, id string
+}
+
+// hash picks k's lock and home shard.
+func (k clientKey) hash() uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(k.account))
+	h.Write([]byte{0})
+	h.Write([]byte(k.id))
+	return h.Sum32()
+}
+
+// clientEntry is the order that took a client order ID. It is never changed
+// once its home shard has stored it, so shards hand it out as is.
+type clientEntry struct {
+	key   clientKey
+	terms model.Order  // the order as submitted
+	res   SubmitResult // its result, with the order as it was then
+	at    time.Time    // set by the home shard
+}
+
+// newClientEntry makes the entry of the order submitted as terms, on the
+// shard that processed it while res.Order is still as res left it.
+func newClientEntry(terms *model.Order, res SubmitResult) *clientEntry {
+	cp := *res.Order
+	res.Order = &cp
+	return &clientEntry{key: clientKey{terms.Account, terms.ClientOrderID}, terms: *terms, res: res}
+}
+
+// handleRemember stores cmd's entries, whose IDs are homed on this shard.
+func (s *shard) handleRemember(cmd *Cmd) {
+	now := time.Now()
+	s.expireClients(now)
+	for _, e := range cmd.clients {
+		e.at = now
+		s.clients[e.key] = e
+		s.clientQ = append(s.clientQ, e)
+	}
+}
+
+// expireClients drops the entries older than ClientIDWindow. They are
+// queued in the order they were stored, so only the expired ones are
+// looked at.
+func (s *shard) expireClients(now time.Time) {
+	for len(s.clientQ) > 0 && now.Sub(s.clientQ[0].at) > ClientIDWindow {
+		e := s.clientQ[0]
+		if s.clients[e.key] == e {
+			delete(s.clients, e.key)
+		}
+		s.clientQ[0] = nil
+		s.clientQ = s.clientQ[1:]
+	}
+}
+
+// handleGetClients replies with the entry of each of cmd.Orders' client
+// order IDs, all homed on this shard, or nil.
+func (s *shard) handleGetClients(cmd *Cmd) {
+	s.expireClients(time.Now())
+	out := make([]*clientEntry, len(cmd.Orders))
+	for i, o := range cmd.Orders {
+		out[i] = s.clients[clientKey{o.Account, o.ClientOrderID}]
+	}
+	cmd.Reply <- out
+}
+
+// lockClientIDs takes the locks of orders' client order IDs, in order so
+// that submits sharing some never wait on each other in a circle, and
+// returns the function giving them back.
+func (r *Router) lockClientIDs(orders []*model.Order) func() {
+	var held []int
+	taken := make(map[int]bool)
+	for _, o := range orders {
+		if o.ClientOrderID == "" {
+			continue
+		}
+		i := int(clientKey{o.Account, o.ClientOrderID}.hash() % clientLocks)
+		if !taken[i] {
+			taken[i] = true
+			held = append(held, i)
+		}
+	}
+	sort.Ints(held)
+	for _, i := range held {
+		r.clientMu[i].Lock()
+	}
+	return func() {
+		for _, i := range held {
+			r.clientMu[i].Unlock()
+		}
+	}
+}
+
+// clientHome returns the index of the shard k is homed on.
+func (r *Router) clientHome(k clientKey) int {
+	return int(k.hash() % uint32(r.n))
+}
+
+// clientEntries returns the entry of each of orders' client order IDs, or
+// nil for orders without one or whose ID is free. Only the IDs' home shards
+// are asked.
+func (r *Router) clientEntries(orders []*model.Order) []*clientEntry {
+	out := make([]*clientEntry, len(orders))
+	byShard := make(map[int][]int)
+	for i, o := range orders {
+		if o.ClientOrderID != "" {
+			home := r.clientHome(clientKey{o.Account, o.ClientOrderID})
+			byShard[home] = append(byShard[home], i)
+		}
+	}
+	r.fanOut(byShard, func(items []int) *Cmd {
+		cmd := &Cmd{Typ: CmdGetClients}
+		for _, i := range items {
+			cmd.Orders = append(cmd.Orders, orders[i])
+		}
+		return cmd
+	}, func(items []int, reply interface{}) {
+		for j, e := range reply.([]*clientEntry) {
+			out[items[j]] = e
+		}
+	})
+	return out
+}
+
+// remember sends the entries of the client order IDs res took to their home
+// shards. The caller still holds the IDs' locks, and a later lookup is
+// queued behind them, so no reply is waited for.
+func (r *Router) remember(res []SubmitResult) {
+	var byShard map[int][]*clientEntry
+	for i := range res {
+		if e := res[i].client; e != nil {
+			if byShard == nil {
+				byShard = make(map[int][]*clientEntry)
+			}
+			home := r.clientHome(e.key)
+			byShard[home] = append(byShard[home], e)
+			res[i].client = nil
+		}
+	}
+	for idx, entries := range byShard {
+		r.shards[idx].in <- &Cmd{Typ: CmdRemember, clients: entries}
+	}
+}
+
+// repeat answers o, which repeats the client order ID e took.
+func repeat(o *model.Order, e *clientEntry) SubmitResult {
+	a, b := e.terms, *o
+	a.ID, a.Timestamp = "", 0
+	b.ID, b.Timestamp = "", 0
+	if a != b {
+		return SubmitResult{Err: ErrClientOrderIDReused.Error()}
+	}
+	res := e.res
+	res.Replayed = true
+	return res
+}
+
+// ClientOrder returns the ID of the order account submitted with client
+// order ID id within the last ClientIDWindow.
+func (r *Router) ClientOrder(account, id string) (string, bool) {
+	e := r.clientEntries([]*model.Order{{Account: account, ClientOrderID: id}})[0]
+	if e == nil {
+		return "", false
+	}
+	return e.res.Order.ID, true
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func TestClientOrderIDs(t *testing.T) {
+	check := &fakeCheck{max: 10, reserved: map[string]bool{}}
+	r := NewRouter(4, 16, WithPreTrade(check))
+	defer r.Stop()
+	order := func(account, symbol, cid string, qty int64) *model.Order {
+		return &model.Order{Symbol: symbol, Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: qty, Account: account, ClientOrderID: cid}
+	}
+
+	first := r.SubmitOrder(order("acc", "A", "c1", 2))
+	again := r.SubmitOrder(order("acc", "A", "c1", 2))
+	if first.Err != "" || !again.Replayed || again.Order.ID != first.Order.ID || again.StatusCode != 201 || len(check.reserved) != 1 {
+		t.Fatalf("expected the repeat replayed without another check, got %+v", again)
+	}
+	if id, ok := r.ClientOrder("acc", "c1"); !ok || id != first.Order.ID {
+		t.Fatalf("expected c1 to name %s, got %q", first.Order.ID, id)
+	}
+
+	// the same ID for another symbol, even on another shard, is a conflict
+	other := "B"
+	for r.routeIdx(other) == r.routeIdx("A") {
+		other += "B"
+	}
+	for _, o := range []*model.Order{order("acc", "A", "c1", 3), order("acc", other, "c1", 2)} {
+		if res := r.SubmitOrder(o); res.Err != ErrClientOrderIDReused.Error() {
+			t.Fatalf("expected %s/%d refused as a reused ID, got %+v", o.Symbol, o.Quantity, res)
+		}
+	}
+	if res := r.SubmitOrder(order("bob", "A", "c1", 2)); res.Err != "" || res.Replayed {
+		t.Fatalf("expected bob's c1 to be his own, got %+v", res)
+	}
+
+	// a refused order does not take its ID
+	if res := r.SubmitOrder(order("acc", "A", "c2", 50)); res.Reject == nil {
+		t.Fatalf("expected the order of 50 refused, got %+v", res)
+	}
+	if res := r.SubmitOrder(order("acc", "A", "c2", 5)); res.Err != "" || res.Replayed {
+		t.Fatalf("expected c2 free after the refusal, got %+v", res)
+	}
+
+	batch := []*model.Order{order("acc", "A", "c1", 2), order("acc", "A", "c3", 1)}
+	for _, res := range r.SubmitBatch(batch, true) {
+		if res.Err != errBatchRepeat.Error() {
+			t.Fatalf("expected an atomic batch repeating part of its IDs refused, got %+v", res)
+		}
+	}
+	res := r.SubmitBatch(batch, false)
+	if !res[0].Replayed || res[0].Order.ID != first.Order.ID || res[1].Err != "" || res[1].Replayed {
+		t.Fatalf("expected c1 replayed and c3 placed, got %+v", res)
+	}
+}
+
+func TestClientOrderIDRacesPlaceOnce(t *testing.T) {
+	r := NewRouter(4, 16)
+	defer r.Stop()
+
+	var wg sync.WaitGroup
+	res := make([]SubmitResult, 20)
+	for i := range res {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res[i] = r.SubmitOrder(&model.Order{Symbol: "R", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 1, Account: "acc", ClientOrderID: "once"})
+		}(i)
+	}
+	wg.Wait()
+	placed := 0
+	for _, got := range res {
+		if !got.Replayed {
+			placed++
+		}
+		if got.Err != "" || got.Order.ID != res[0].Order.ID {
+			t.Fatalf("expected every submit to answer with one order, got %+v", got)
+		}
+	}
+	if bk := r.GetOrderBook("R", 5); placed != 1 || len(bk.Asks) != 1 || bk.Asks[0]["quantity"] != int64(1) {
+		t.Fatalf("expected one order placed, got %d and %v", placed, bk.Asks)
+	}
+}
+
+func TestClientIDsExpireOldestFirst(t *testing.T) {
+	s := &shard{clients: make(map[clientKey]*clientEntry)}
+	entry := func(id string) *clientEntry {
+		return newClientEntry(&model.Order{Account: "acc", ClientOrderID: id}, SubmitResult{Order: &model.Order{ID: id}})
+	}
+	s.handleRemember(&Cmd{Typ: CmdRemember, clients: []*clientEntry{entry("old"), entry("new")}})
+	now := time.Now()
+	s.clientQ[0].at = now.Add(-ClientIDWindow - time.Second)
+
+	// the expired ID is taken again before its queued entry is reached
+	again := entry("old")
+	again.at = now
+	s.clients[again.key] = again
+	s.clientQ = append(s.clientQ, again)
+
+	s.expireClients(now)
+	if len(s.clientQ) != 2 || s.clients[clientKey{"acc", "old"}] != again || s.clients[clientKey{"acc", "new"}] == nil {
+		t.Fatalf("expected only the stale entry dropped, got %d queued and %v", len(s.clientQ), s.clients)
+	}
+}
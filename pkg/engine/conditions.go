@@ -0,0 +1,144 @@
+package engine
+
+import "github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+
+// Execution conditions.
+//
+// A LIMIT order may ask for a minimum execution size, on every execution or
+// only its first, or be all-or-none. Every execution must meet the
+// conditions of both orders in it. When an incoming order meets a level,
+// the orders there it cannot trade with are left out and the level is
+// shared among the rest as usual; the ones left out keep their place.
+//
+// An incoming all-or-none order trades only if it can fill completely right
+// away, across as many orders and levels as it needs. Resting, it waits for
+// a single order that takes all of it. Orders with conditions do not trade
+// with midpoint pegs.
+//
+// plan is the one place that decides who trades how much at a level. The
+// matching executes its plans, and fillable adds them up without trading,
+// so what an order is told it could fill is what it then fills.
+
+// conditional reports whether o has execution conditions.
+func conditional(o *model.Order) bool {
+	return o.MinQty > 0 || o.AllOrNone
+}
+
+// minFill returns the smallest execution o accepts, having filled and with
+// remaining left. An incoming all-or-none order is checked as a whole before
+// it trades, so as the taker it accepts any execution.
+func minFill(o *model.Order, filled, remaining int64, taker bool) int64 {
+	switch {
+	case o.AllOrNone && !taker:
+		return remaining
+	case o.MinQty > 0 && (o.MinQtyScope != model.MinQtyFirst || filled == 0):
+		return min(o.MinQty, remaining)
+	}
+	return 1
+}
+
+// crosses reports whether o would trade at price p on the other side.
+func crosses(o *model.Order, p int64) bool {
+	switch {
+	case o.Type == model.MARKET:
+		return true
+	case o.Side == model.BUY:
+		return p <= o.Price
+	default:
+		return p >= o.Price
+	}
+}
+
+// plan returns how much taker would trade with each order of level, by
+// index: the Matcher's allocation, to the displayed orders first and the
+// hidden ones with what is left, among the orders whose conditions the
+// execution meets. It changes nothing.
+func (ob *OrderBook) plan(taker *model.Order, level *PriceLevel) []int64 {
+	alloc := make([]int64, len(level.Orders))
+	n := level.shown()
+	filled := taker.Filled + ob.planClass(taker, taker.Filled, level.Orders[:n], alloc[:n])
+	if filled < taker.Quantity && n < len(level.Orders) {
+		ob.planClass(taker, filled, level.Orders[n:], alloc[n:])
+	}
+	return alloc
+}
+
+// planClass shares what taker has left after filled among makers, which
+// have one price and priority class, into alloc and returns the total.
+func (ob *OrderBook) planClass(taker *model.Order, filled int64, makers []*model.Order, alloc []int64) int64 {
+	m := ob.matcher
+	if m == nil {
+		m = FIFO{}
+	}
+	qty := taker.Quantity - filled
+
+	// leave out the makers too small for the taker's minimum, or whose own
+	// minimum is more than the taker has
+	need := minFill(taker, filled, qty, true)
+	idx := make([]int, 0, len(makers))
+	for i, mk := range makers {
+		if mk.Remaining() >= need && minFill(mk, mk.Filled, mk.Remaining(), false) <= qty {
+			idx = append(idx, i)
+		}
+	}
+
+	for len(idx) > 0 {
+		eligible := make([]*model.Order, len(idx))
+		for j, i := range idx {
+			eligible[j] = makers[i]
+		}
+		got := m.Allocate(eligible, qty)
+
+		// execute in index order, as the matching will; clamp a Matcher
+		// that overshoots and leave out the makers whose share an
+		// execution could not have, then share again without them
+		q := make([]int64, len(idx))
+		keep := make([]int, 0, len(idx))
+		left, f := qty, filled
+		for j, mk := range eligible {
+			if j < len(got) {
+				q[j] = min(got[j], min(left, mk.Remaining()))
+			}
+			if q[j] > 0 && (q[j] < minFill(taker, f, left, true) || q[j] < minFill(mk, mk.Filled, mk.Remaining(), false)) {
+				continue
+			}
+			keep = append(keep, idx[j])
+			left -= q[j]
+			f += q[j]
+		}
+		if len(keep) == len(idx) {
+			for j, i := range idx {
+				alloc[i] = q[j]
+			}
+			return qty - left
+		}
+		idx = keep
+	}
+	return 0
+}
+
+// fillable returns how much of o could trade right now, following the same
+// plans as the matching.
+func (ob *OrderBook) fillable(o *model.Order) int64 {
+	cp := *o
+	if mid, ok := ob.mid(); ok && len(ob.pegs) > 0 && !conditional(o) && takesMid(o, mid) {
+		for _, m := range ob.pegs {
+			if isMidpoint(m) && m.Side != o.Side && midEligible(m, mid) {
+				cp.Filled += min(m.Remaining(), cp.Remaining())
+			}
+		}
+	}
+	sideMap, asc := ob.Asks, true
+	if o.Side == model.SELL {
+		sideMap, asc = ob.Bids, false
+	}
+	for _, p := range ob.sortedPrices(sideMap, asc) {
+		if cp.Remaining() == 0 || !crosses(o, p) {
+			break
+		}
+		for _, q := range ob.plan(&cp, sideMap[p]) {
+			cp.Filled += q
+		}
+	}
+	return cp.Filled - o.Filled
+}
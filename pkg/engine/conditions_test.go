@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func minQtyOrder(id string, side model.Side, price, qty, minQty int64, scope model.MinQtyScope) *model.Order {
+	o := limitOrder(id, side, price, qty)
+	o.MinQty, o.MinQtyScope = minQty, scope
+	return o
+}
+
+func aonOrder(id string, side model.Side, price, qty int64) *model.Order {
+	o := limitOrder(id, side, price, qty)
+	o.AllOrNone = true
+	return o
+}
+
+func TestRestingMinQtyIsPassedOverKeepingItsPlace(t *testing.T) {
+	ob := NewOrderBook("P")
+	a := minQtyOrder("a", model.SELL, 100, 5, 5, "")
+	ob.ProcessOrder(a)
+	ob.ProcessOrder(limitOrder("b", model.SELL, 100, 3))
+	ob.ProcessOrder(limitOrder("c", model.SELL, 100, 10))
+
+	ob.ProcessOrder(limitOrder("x", model.BUY, 100, 4))
+	if a.Filled != 0 {
+		t.Fatalf("4 is below a's minimum of 5, a filled %d", a.Filled)
+	}
+	if q := queueAt(ob, model.SELL, 100); len(q) != 2 || q[0] != "a" || q[1] != "c" {
+		t.Fatalf("expected a still first, then c, got %v", q)
+	}
+
+	ob.ProcessOrder(limitOrder("y", model.BUY, 100, 6))
+	if a.Filled != 5 {
+		t.Fatalf("6 meets a's minimum, a filled %d", a.Filled)
+	}
+}
+
+func TestIncomingMinQtyEachOrFirst(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("a", model.SELL, 100, 2))
+	ob.ProcessOrder(limitOrder("b", model.SELL, 100, 6))
+	ob.ProcessOrder(limitOrder("c", model.SELL, 100, 6))
+	each := minQtyOrder("each", model.BUY, 100, 10, 5, model.MinQtyEach)
+	trades, _ := ob.ProcessOrder(each)
+	if len(trades) != 2 || trades[0].MakerOrderID != "b" || trades[1].Quantity != 4 {
+		t.Fatalf("expected a passed over and 6 from b, 4 from c, got %+v", trades)
+	}
+
+	ob = NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("b", model.SELL, 100, 6))
+	ob.ProcessOrder(limitOrder("a", model.SELL, 101, 2))
+	first := minQtyOrder("first", model.BUY, 101, 8, 5, model.MinQtyFirst)
+	if trades, _ := ob.ProcessOrder(first); len(trades) != 2 || first.Remaining() != 0 {
+		t.Fatalf("after a first fill of 6 any size goes, got %+v", trades)
+	}
+}
+
+func TestAllOrNone(t *testing.T) {
+	ob := NewOrderBook("P")
+	aon := aonOrder("aon", model.SELL, 100, 10)
+	ob.ProcessOrder(aon)
+	ob.ProcessOrder(limitOrder("p", model.SELL, 101, 5))
+	ob.ProcessOrder(limitOrder("x", model.BUY, 101, 6))
+	if aon.Filled != 0 || ob.Asks[101] != nil {
+		t.Fatalf("expected x to skip the resting all-or-none and take p, aon %+v", aon)
+	}
+	ob.ProcessOrder(limitOrder("y", model.BUY, 100, 12))
+	if aon.Remaining() != 0 {
+		t.Fatalf("12 covers all 10, aon %+v", aon)
+	}
+
+	ob = NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("s1", model.SELL, 100, 4))
+	ob.ProcessOrder(limitOrder("s2", model.SELL, 101, 4))
+	short := aonOrder("short", model.BUY, 101, 10)
+	if trades, _ := ob.ProcessOrder(short); len(trades) != 0 || queueAt(ob, model.BUY, 101)[0] != "short" {
+		t.Fatalf("8 available for 10: expected no trade and the order resting, got %+v", trades)
+	}
+	ob.ProcessOrder(limitOrder("s3", model.SELL, 101, 2)) // too small for the resting one
+	full := aonOrder("full", model.BUY, 101, 10)
+	if trades, _ := ob.ProcessOrder(full); len(trades) != 3 || full.Remaining() != 0 {
+		t.Fatalf("expected 10 filled across three orders, got %+v", trades)
+	}
+}
+
+func TestProRataResharesWithoutUnfitMakers(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.matcher = ProRata{}
+	a := minQtyOrder("a", model.SELL, 100, 10, 8, "")
+	ob.ProcessOrder(a)
+	ob.ProcessOrder(limitOrder("b", model.SELL, 100, 10))
+	ob.ProcessOrder(limitOrder("x", model.BUY, 100, 10))
+	if a.Filled != 0 || len(ob.Asks[100].Orders) != 1 {
+		t.Fatalf("a's pro-rata 5 is below its minimum: expected b to take all 10, a %+v", a)
+	}
+}
+
+func TestMarketOrderCountsOnlyWhatItCanTrade(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(aonOrder("aon", model.SELL, 100, 10))
+	m := &model.Order{ID: "m", Symbol: "P", Side: model.BUY, Type: model.MARKET, Quantity: 5}
+	if _, err := ob.ProcessOrder(m); err == nil || m.Filled != 0 {
+		t.Fatalf("expected the market order rejected untouched, got %v filled %d", err, m.Filled)
+	}
+}
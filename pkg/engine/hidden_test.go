@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func hiddenOrder(id string, side model.Side, price, qty int64) *model.Order {
+	o := limitOrder(id, side, price, qty)
+	o.Hidden = true
+	return o
+}
+
+func TestHiddenRanksBehindDisplayed(t *testing.T) {
+	ob := NewOrderBook("P")
+	var levels []events.Level
+	ob.publish = func(ev events.Event) {
+		if ev.Type == events.LevelChanged {
+			levels = append(levels, *ev.Level)
+		}
+	}
+	ob.ProcessOrder(hiddenOrder("h1", model.SELL, 100, 5))
+	ob.ProcessOrder(hiddenOrder("h2", model.SELL, 101, 5))
+	if len(levels) != 0 {
+		t.Fatalf("hidden orders must not move the levels, got %+v", levels)
+	}
+	ob.ProcessOrder(limitOrder("d", model.SELL, 100, 5))
+	if q := queueAt(ob, model.SELL, 100); len(q) != 2 || q[0] != "d" || q[1] != "h1" {
+		t.Fatalf("expected the displayed order ahead of the earlier hidden one, got %v", q)
+	}
+	if asks := aggregate(ob.Asks, 10, true); len(asks) != 1 || asks[0]["quantity"] != int64(5) {
+		t.Fatalf("expected only the displayed 5 at 100, got %v", asks)
+	}
+
+	ob.ProcessOrder(limitOrder("b", model.BUY, 100, 7))
+	h1 := ob.Asks[100].Orders[0]
+	if h1.ID != "h1" || h1.Remaining() != 3 {
+		t.Fatalf("expected d filled first and h1 left with 3, got %+v", h1)
+	}
+	// 100 now shows nothing, and neither does 101
+	if asks := aggregate(ob.Asks, 10, true); len(asks) != 0 {
+		t.Fatalf("expected no displayed asks, got %v", asks)
+	}
+	if tk := ob.ticker(0); tk.AskPrice != 0 || tk.AskSize != 0 {
+		t.Fatalf("expected no best ask in the ticker, got %d x %d", tk.AskPrice, tk.AskSize)
+	}
+	if last := levels[len(levels)-1]; last.Price != 100 || last.Quantity != 0 {
+		t.Fatalf("expected the level reported empty, got %+v", last)
+	}
+}
+
+func TestHiddenFillsAfterProRataShare(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.matcher = ProRata{}
+	ob.ProcessOrder(hiddenOrder("h", model.SELL, 100, 90))
+	ob.ProcessOrder(limitOrder("d", model.SELL, 100, 10))
+	ob.ProcessOrder(limitOrder("b", model.BUY, 100, 15))
+	lvl := ob.Asks[100].Orders
+	if len(lvl) != 1 || lvl[0].ID != "h" || lvl[0].Remaining() != 85 {
+		t.Fatalf("expected d filled in full before h shares, got %+v", lvl)
+	}
+}
+
+func TestHiddenIsNotAPegReference(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("bid", model.BUY, 100, 1))
+	ob.ProcessOrder(hiddenOrder("h", model.BUY, 103, 1))
+	peg := pegOrder("peg", model.BUY, model.PegPrimary, 0, 0, 1)
+	ob.ProcessOrder(peg)
+	if peg.Price != 100 {
+		t.Fatalf("expected the peg at the displayed bid of 100, got %d", peg.Price)
+	}
+}
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Matcher decides how an incoming order's quantity is shared among the
+// resting orders of one price level. Levels are still matched best price
+// first; the Matcher only allocates within a level.
+//
+// Allocate gets the level's orders in time priority and the quantity to
+// fill at this price, and returns how much each order trades, by index. A
+// shorter result means the rest get nothing. Allocations must not exceed
+// an order's remaining quantity, and their sum must not exceed qty. It must
+// be deterministic: the same level and qty always allocate the same way.
+type Matcher interface {
+	Allocate(level []*model.Order, qty int64) []int64
+}
+
+// WithMatching sets each symbol's Matcher as its book is created. A nil
+// result, or no WithMatching at all, means FIFO.
+func WithMatching(matcherFor func(symbol string) Matcher) Option {
+	return func(r *Router) { r.matching = matcherFor }
+}
+
+// FIFO is strict price-time priority: the oldest order fills first.
+type FIFO struct{}
+
+// Allocate implements Matcher.
+func (FIFO) Allocate(level []*model.Order, qty int64) []int64 {
+	return fifoFill(level, make([]int64, len(level)), qty)
+}
+
+// fifoFill adds up to qty to alloc in time priority, on top of what each
+// order already has, and returns alloc.
+func fifoFill(level []*model.Order, alloc []int64, qty int64) []int64 {
+	for i, o := range level {
+		if qty == 0 {
+			break
+		}
+		q := min(qty, o.Remaining()-alloc[i])
+		if q > 0 {
+			alloc[i] += q
+			qty -= q
+		}
+	}
+	return alloc
+}
+
+// ProRata shares qty in proportion to each order's remaining quantity,
+// regardless of time. Shares are rounded down to a multiple of Lot, and a
+// share below MinAllocation is not given at all. Whatever rounding leaves
+// over is then filled in time priority.
+type ProRata struct {
+	Lot           int64 // allocation granularity; 0 means 1
+	MinAllocation int64 // smallest pro-rata share worth giving; 0 means any
+}
+
+// Allocate implements Matcher.
+func (p ProRata) Allocate(level []*model.Order, qty int64) []int64 {
+	return p.allocate(level, make([]int64, len(level)), qty)
+}
+
+// allocate shares qty on top of alloc and returns alloc.
+func (p ProRata) allocate(level []*model.Order, alloc []int64, qty int64) []int64 {
+	lot := p.Lot
+	if lot <= 0 {
+		lot = 1
+	}
+	total := int64(0)
+	for i, o := range level {
+		total += o.Remaining() - alloc[i]
+	}
+	if total <= qty {
+		// everything at this price trades
+		for i, o := range level {
+			alloc[i] = o.Remaining()
+		}
+		return alloc
+	}
+
+	left := qty
+	for i, o := range level {
+		share := mulDiv(qty, o.Remaining()-alloc[i], total)
+		share -= share % lot
+		if share == 0 || share < p.MinAllocation {
+			continue
+		}
+		alloc[i] += share
+		left -= share
+	}
+	return fifoFill(level, alloc, left)
+}
+
+// Hybrid gives the first order in the queue priority for up to TopOrderMax
+// (0: all it needs), then shares what is left pro-rata among every order
+// still open, the top order included.
+type Hybrid struct {
+	TopOrderMax int64
+	ProRata     ProRata
+}
+
+// Allocate implements Matcher.
+func (h Hybrid) Allocate(level []*model.Order, qty int64) []int64 {
+	alloc := make([]int64, len(level))
+	if len(level) == 0 {
+		return alloc
+	}
+	top := min(qty, level[0].Remaining())
+	if h.TopOrderMax > 0 {
+		top = min(top, h.TopOrderMax)
+	}
+	alloc[0] = top
+	return h.ProRata.allocate(level, alloc, qty-top)
+}
+
+// mulDiv returns a*b/c rounded down without overflowing; all are >= 0 and
+// a*b/c fits since b <= c.
+func mulDiv(a, b, c int64) int64 {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	q, _ := bits.Div64(hi, lo, uint64(c))
+	return int64(q)
+}
+
+// ParseMatching parses per-symbol algorithms given as
+// "SYMBOL=ALGO[:key=value...]" separated by commas, for example
+// "ZN=pro_rata:lot=5:min=10,ZB=hybrid:top=20". ALGO is fifo, pro_rata
+// (keys lot, min) or hybrid (keys top, lot, min). Symbols not listed use
+// FIFO.
+func ParseMatching(s string) (map[string]Matcher, error) {
+	out := make(map[string]Matcher)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		symbol, spec, ok := strings.Cut(item, "=")
+		if !ok || symbol == "" {
+			return nil, fmt.Errorf("matching %q: want SYMBOL=ALGO[:key=value...]", item)
+		}
+		parts := strings.Split(spec, ":")
+		params := make(map[string]int64)
+		for _, kv := range parts[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("matching %s: %q: want key=N with N >= 0", symbol, kv)
+			}
+			params[k] = n
+		}
+		allowed := map[string]bool{}
+		var m Matcher
+		switch parts[0] {
+		case "fifo":
+			m = FIFO{}
+		case "pro_rata":
+			allowed["lot"], allowed["min"] = true, true
+			m = ProRata{Lot: params["lot"], MinAllocation: params["min"]}
+		case "hybrid":
+			allowed["top"], allowed["lot"], allowed["min"] = true, true, true
+			m = Hybrid{TopOrderMax: params["top"], ProRata: ProRata{Lot: params["lot"], MinAllocation: params["min"]}}
+		default:
+			return nil, fmt.Errorf("matching %s: unknown algorithm %q", symbol, parts[0])
+		}
+		for k := range params {
+			if !allowed[k] {
+				return nil, fmt.Errorf("matching %s: %s takes no %q", symbol, parts[0], k)
+			}
+		}
+		out[symbol] = m
+	}
+	return out, nil
+}
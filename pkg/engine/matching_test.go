@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// queue builds a level of resting orders with the given remaining sizes,
+// oldest first.
+func queue(sizes ...int64) []*model.Order {
+	out := make([]*model.Order, len(sizes))
+	for i, q := range sizes {
+		out[i] = &model.Order{Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: q}
+	}
+	return out
+}
+
+func TestAllocate(t *testing.T) {
+	cases := []struct {
+		name  string
+		m     Matcher
+		level []int64
+		qty   int64
+		want  []int64
+	}{
+		{"fifo fills oldest first", FIFO{}, []int64{5, 10, 5}, 12, []int64{5, 7, 0}},
+		{"fifo sweeps the level", FIFO{}, []int64{5, 10}, 20, []int64{5, 10}},
+
+		// 10 of 40: shares 2.5, 5, 2.5 round down to 2, 5, 2; 1 left over
+		// goes to the oldest order with room
+		{"pro rata", ProRata{}, []int64{10, 20, 10}, 10, []int64{3, 5, 2}},
+		{"pro rata sweeps the level", ProRata{}, []int64{10, 20}, 50, []int64{10, 20}},
+		// lots of 5: 7.5, 15, 7.5 round to 5, 15, 5; the 5 left over go
+		// to the oldest order
+		{"pro rata lots", ProRata{Lot: 5}, []int64{15, 30, 15}, 30, []int64{10, 15, 5}},
+		// 100 of 1000: 1, 99 -> the 1 is under the minimum of 2 and
+		// comes back FIFO, where the oldest order takes it anyway
+		{"pro rata minimum", ProRata{MinAllocation: 2}, []int64{10, 990}, 100, []int64{1, 99}},
+		{"pro rata minimum to fifo", ProRata{MinAllocation: 2}, []int64{990, 10}, 100, []int64{100, 0}},
+		{"pro rata ignores time", ProRata{}, []int64{1, 99}, 50, []int64{1, 49}},
+
+		// top order takes 4, then 6 of the remaining 1+20+9=30: 0.2, 4, 1.8
+		// round to 0, 4, 1 with 1 left for the top order
+		{"hybrid capped top", Hybrid{TopOrderMax: 4}, []int64{5, 20, 9}, 10, []int64{5, 4, 1}},
+		{"hybrid top takes all", Hybrid{}, []int64{8, 20}, 5, []int64{5, 0}},
+		{"hybrid pro rata after top", Hybrid{}, []int64{8, 20, 20}, 18, []int64{8, 5, 5}},
+		{"empty level", Hybrid{}, nil, 5, []int64{}},
+	}
+	for _, c := range cases {
+		level := queue(c.level...)
+		got := c.m.Allocate(level, c.qty)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+		// the same input always allocates the same way
+		if again := c.m.Allocate(level, c.qty); !reflect.DeepEqual(again, got) {
+			t.Errorf("%s: not deterministic: %v then %v", c.name, got, again)
+		}
+	}
+}
+
+func TestProRataBook(t *testing.T) {
+	ob := NewOrderBook("ZN")
+	ob.matcher = ProRata{}
+	a := newOrder("ZN", model.SELL, model.LIMIT, 100, 10)
+	b := newOrder("ZN", model.SELL, model.LIMIT, 100, 30)
+	c := newOrder("ZN", model.SELL, model.LIMIT, 101, 10)
+	a.ID, b.ID, c.ID = "a", "b", "c"
+	for _, o := range []*model.Order{a, b, c} {
+		ob.ProcessOrder(o)
+	}
+
+	// 20 at 100 is shared 5/15 even though a is first; c at 101 is
+	// not reached
+	trades, _ := ob.ProcessOrder(newOrder("ZN", model.BUY, model.LIMIT, 101, 20))
+	got := map[string]int64{}
+	for _, tr := range trades {
+		got[tr.MakerOrderID] += tr.Quantity
+	}
+	if !reflect.DeepEqual(got, map[string]int64{"a": 5, "b": 15}) {
+		t.Fatalf("unexpected allocation %v", got)
+	}
+	if lvl := ob.Asks[100]; len(lvl.Orders) != 2 || lvl.Orders[0] != a {
+		t.Fatal("partially filled orders keep their queue position")
+	}
+
+	// sweeping the level removes the filled makers
+	ob.ProcessOrder(newOrder("ZN", model.BUY, model.LIMIT, 100, 20))
+	if _, ok := ob.Asks[100]; ok {
+		t.Fatal("expected the swept level to be removed")
+	}
+}
+
+func TestParseMatching(t *testing.T) {
+	got, err := ParseMatching("ZN=pro_rata:lot=5:min=10, ZB=hybrid:top=20,ES=fifo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Matcher{
+		"ZN": ProRata{Lot: 5, MinAllocation: 10},
+		"ZB": Hybrid{TopOrderMax: 20},
+		"ES": FIFO{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for _, bad := range []string{"ZN", "ZN=lifo", "ZN=fifo:lot=5", "ZN=pro_rata:lot=x", "ZN=hybrid:top=-1"} {
+		if _, err := ParseMatching(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRouterMatchingPerSymbol(t *testing.T) {
+	r := NewRouter(2, 16, WithMatching(func(symbol string) Matcher {
+		if symbol == "ZN" {
+			return ProRata{}
+		}
+		return nil
+	}))
+	defer r.Stop()
+
+	for _, sym := range []string{"ZN", "ES"} {
+		r.SubmitOrder(&model.Order{ID: sym + "1", Symbol: sym, Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 10})
+		r.SubmitOrder(&model.Order{ID: sym + "2", Symbol: sym, Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 10})
+	}
+	zn := r.SubmitOrder(&model.Order{ID: "zb", Symbol: "ZN", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 10})
+	es := r.SubmitOrder(&model.Order{ID: "eb", Symbol: "ES", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 10})
+	if len(zn.Trades) != 2 || zn.Trades[0].Quantity != 5 {
+		t.Fatalf("ZN is pro-rata, expected 5 and 5, got %+v", zn.Trades)
+	}
+	if len(es.Trades) != 1 || es.Trades[0].MakerOrderID != "ES1" {
+		t.Fatalf("ES is FIFO, expected one trade with the oldest order, got %+v", es.Trades)
+	}
+}
@@ -3,22 +3,71 @@ package engine
 import (
 	"errors"
 	"sort"
+	"strconv"
+	"time"
 
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
 )
 
-// PriceLevel holds FIFO queue of orders at one price.
+var (
+	errNotResting  = errors.New("order is not resting")
+	errNoLiquidity = errors.New("insufficient liquidity for market order")
+)
+
+// PriceLevel holds the orders at one price: the displayed ones in time
+// priority, then the hidden ones in time priority.
 type PriceLevel struct {
 	Price  int64
 	Orders []*model.Order
 }
 
+// shown returns how many of the level's orders are displayed; they come
+// first.
+func (l *PriceLevel) shown() int {
+	n := len(l.Orders)
+	for n > 0 && l.Orders[n-1].Hidden {
+		n--
+	}
+	return n
+}
+
+// displayed returns the quantity the level shows, that of its displayed
+// orders. Every snapshot and market data view uses it; a level showing
+// nothing is not shown at all.
+func (l *PriceLevel) displayed() int64 {
+	if l == nil {
+		return 0
+	}
+	total := int64(0)
+	for _, o := range l.Orders[:l.shown()] {
+		total += o.Remaining()
+	}
+	return total
+}
+
 // OrderBook holds buy & sell levels for a single symbol.
 type OrderBook struct {
 	Symbol string
 
 	Bids map[int64]*PriceLevel // price -> level (BUY side)
 	Asks map[int64]*PriceLevel // price -> level (SELL side)
+
+	seq      uint64             // last event sequence number for this symbol
+	tradeSeq uint64             // last trade number for this symbol
+	tape     *tradeRing         // recent trades, newest last
+	stats    l1Stats            // last/open/high/low and rolling 24h volume
+	publish  func(events.Event) // set by the owning shard; nil drops events
+	fees     Fees               // set by the owning shard; nil charges nothing
+	matcher  Matcher            // set by the owning shard; nil is FIFO
+
+	pegs           []*model.Order // resting PEGGED orders in arrival order, see peg.go
+	refBid, refAsk int64          // lit best bid and ask the pegs are priced from
+
+	stops            []*trailingStop // waiting TRAILING_STOP orders in arrival order, see stop.go
+	printLo, printHi int64           // range of the trade prices the stops have not seen; 0: none
+
+	links []*spreadLink // spreads this book is or is a leg of, see spread.go
 }
 
 // NewOrderBook creates a fresh book for a symbol.
@@ -27,6 +76,7 @@ func NewOrderBook(symbol string) *OrderBook {
 		Symbol: symbol,
 		Bids:   make(map[int64]*PriceLevel),
 		Asks:   make(map[int64]*PriceLevel),
+		tape:   newTradeRing(tapeSize),
 	}
 }
 
@@ -43,109 +93,187 @@ func (ob *OrderBook) addToBook(o *model.Order) {
 		sideMap[o.Price] = level
 	}
 
-	level.Orders = append(level.Orders, o) // FIFO append
+	if o.Hidden {
+		level.Orders = append(level.Orders, o) // FIFO append
+	} else {
+		// displayed orders rank ahead of the hidden ones at their price
+		i := level.shown()
+		level.Orders = append(level.Orders, nil)
+		copy(level.Orders[i+1:], level.Orders[i:])
+		level.Orders[i] = o
+	}
+	ob.emitOrder(events.OrderBooked, o)
+	if !o.Hidden {
+		ob.emitLevel(o.Side, o.Price)
+	}
+}
+
+// emit stamps ev with the next sequence number and hands it to the shard.
+func (ob *OrderBook) emit(ev events.Event) {
+	ob.seq++
+	if ob.publish == nil {
+		return
+	}
+	ev.Symbol = ob.Symbol
+	ev.Seq = ob.seq
+	if ev.Timestamp == 0 {
+		ev.Timestamp = time.Now().UnixMilli()
+	}
+	ob.publish(ev)
+}
+
+// emitOrder publishes an order event carrying a copy of o.
+func (ob *OrderBook) emitOrder(typ events.Type, o *model.Order) {
+	cp := *o
+	ob.emit(events.Event{Type: typ, Order: &cp})
+}
+
+// emitFill publishes a fill of qty at price for o, charged fee.
+func (ob *OrderBook) emitFill(o *model.Order, price, qty, fee int64, maker bool) {
+	cp := *o
+	ob.emit(events.Event{Type: events.OrderFilled, Order: &cp, FillPrice: price, FillQty: qty, Fee: fee, Maker: maker})
+}
+
+// emitLevel publishes the current displayed quantity at one price. Changes
+// to hidden orders alone are not published.
+func (ob *OrderBook) emitLevel(side model.Side, price int64) {
+	sideMap := ob.Bids
+	if side == model.SELL {
+		sideMap = ob.Asks
+	}
+	ob.emit(events.Event{
+		Type:  events.LevelChanged,
+		Level: &events.Level{Side: side, Price: price, Quantity: sideMap[price].displayed()},
+	})
 }
 
-// matchLimit tries to match a limit order.
-func (ob *OrderBook) matchLimit(o *model.Order) (trades []model.Order) {
+// execute fills taker against maker for qty at the maker's price, prices
+// the trade's fees and reports the trade and both fills. The returned trade
+// carries the fees; the tape and the TradeExecuted event, which are public,
+// do not.
+func (ob *OrderBook) execute(taker, maker *model.Order, qty int64) model.Trade {
+	maker.Filled += qty
+	taker.Filled += qty
+	t := ob.record(taker, maker, taker.Side, maker.Price, qty)
+	ob.emitFill(maker, t.Price, qty, t.MakerFee, true)
+	ob.emitFill(taker, t.Price, qty, t.TakerFee, false)
+	return t
+}
+
+// record prints a trade of qty at price between taker, trading aggressor,
+// and maker and prices its fees. It fills and reports neither order.
+func (ob *OrderBook) record(taker, maker *model.Order, aggressor model.Side, price, qty int64) model.Trade {
+	ob.tradeSeq++
+	t := model.Trade{
+		ID:            ob.Symbol + "-" + strconv.FormatUint(ob.tradeSeq, 10),
+		Symbol:        ob.Symbol,
+		Price:         price,
+		Quantity:      qty,
+		AggressorSide: aggressor,
+		MakerOrderID:  maker.ID,
+		TakerOrderID:  taker.ID,
+		Timestamp:     time.Now().UnixMilli(),
+	}
+	ob.tape.add(t)
+	ob.stats.onTrade(t)
+	ob.print(t.Price)
+	public := t
+	ob.emit(events.Event{Type: events.TradeExecuted, Trade: &public, Timestamp: t.Timestamp})
+	if ob.fees != nil {
+		t.MakerFee, t.TakerFee = ob.fees.TradeFees(&t, maker, taker)
+	}
+	return t
+}
+
+// matchLimit matches an order against the midpoint pegs, if it trades at
+// the midpoint, after the levels priced better than the midpoint, and then
+// the levels, along with any implied liquidity from the spreads the book
+// belongs to. An all-or-none order matches only if it fills completely.
+func (ob *OrderBook) matchLimit(o *model.Order) (trades []model.Trade) {
+	if o.AllOrNone && ob.fillable(o) < o.Remaining() {
+		return nil
+	}
+	if mid, ok := ob.midFor(o); ok {
+		trades = ob.matchInside(o, mid)
+		trades = append(trades, ob.matchMidpoint(o)...)
+	}
+	if o.Remaining() == 0 {
+		return trades
+	}
+	if len(ob.links) > 0 && impliable(o) {
+		return append(trades, ob.matchWithImplied(o)...)
+	}
 	if o.Side == model.BUY {
-		return ob.matchBuyLimit(o)
+		return append(trades, ob.matchBuyLimit(o)...)
 	}
-	return ob.matchSellLimit(o)
+	return append(trades, ob.matchSellLimit(o)...)
 }
 
 // matchBuyLimit matches BUY with lowest ASK prices.
-func (ob *OrderBook) matchBuyLimit(o *model.Order) (trades []model.Order) {
+func (ob *OrderBook) matchBuyLimit(o *model.Order) (trades []model.Trade) {
 	askPrices := ob.sortedPrices(ob.Asks, true) // ascending
 	for _, p := range askPrices {
-		if o.Quantity == 0 {
+		if o.Remaining() == 0 {
 			break
 		}
-		if p > o.Price {
+		if o.Type != model.MARKET && p > o.Price {
 			break // cannot cross further
 		}
-		level := ob.Asks[p]
-		i := 0
-		for i < len(level.Orders) && o.Quantity > 0 {
-			sell := level.Orders[i]
-
-			// trade at resting order's price
-			tradeQty := min(o.Quantity, sell.Quantity-sell.Filled)
-			if tradeQty <= 0 {
-				i++
-				continue
-			}
-
-			sell.Filled += tradeQty
-			o.Filled += tradeQty
-			o.Quantity -= tradeQty
-
-			trades = append(trades, model.Order{
-				ID:       "", // Trade object simplified
-				Symbol:   ob.Symbol,
-				Side:     model.BUY,
-				Price:    sell.Price,
-				Quantity: tradeQty,
-			})
-
-			if sell.Filled == sell.Quantity {
-				// remove sell order from level
-				level.Orders = append(level.Orders[:i], level.Orders[i+1:]...)
-				continue
-			}
-			i++
-		}
-
-		if len(level.Orders) == 0 {
-			delete(ob.Asks, p)
-		}
+		trades = append(trades, ob.matchAt(o, p)...)
 	}
 	return trades
 }
 
 // matchSellLimit matches SELL with highest BID prices.
-func (ob *OrderBook) matchSellLimit(o *model.Order) (trades []model.Order) {
+func (ob *OrderBook) matchSellLimit(o *model.Order) (trades []model.Trade) {
 	bidPrices := ob.sortedPrices(ob.Bids, false) // descending
 	for _, p := range bidPrices {
-		if o.Quantity == 0 {
+		if o.Remaining() == 0 {
 			break
 		}
-		if p < o.Price {
+		if o.Type != model.MARKET && p < o.Price {
 			break
 		}
-		level := ob.Bids[p]
-		i := 0
-		for i < len(level.Orders) && o.Quantity > 0 {
-			buy := level.Orders[i]
-
-			tradeQty := min(o.Quantity, buy.Quantity-buy.Filled)
-			if tradeQty <= 0 {
-				i++
-				continue
-			}
+		trades = append(trades, ob.matchAt(o, p)...)
+	}
+	return trades
+}
 
-			buy.Filled += tradeQty
-			o.Filled += tradeQty
-			o.Quantity -= tradeQty
-
-			trades = append(trades, model.Order{
-				ID:       "",
-				Symbol:   ob.Symbol,
-				Side:     model.SELL,
-				Price:    buy.Price,
-				Quantity: tradeQty,
-			})
-
-			if buy.Filled == buy.Quantity {
-				level.Orders = append(level.Orders[:i], level.Orders[i+1:]...)
-				continue
-			}
-			i++
+// matchAt matches o against the opposite level at p, which must exist.
+func (ob *OrderBook) matchAt(o *model.Order, p int64) []model.Trade {
+	sideMap, side := ob.Asks, model.SELL
+	if o.Side == model.SELL {
+		sideMap, side = ob.Bids, model.BUY
+	}
+	level := sideMap[p]
+	shown := level.displayed()
+	trades := ob.matchLevel(o, level)
+	if len(level.Orders) == 0 {
+		delete(sideMap, p)
+	}
+	if level.displayed() != shown {
+		ob.emitLevel(side, p)
+	}
+	return trades
+}
+
+// matchLevel fills o against one crossing level as plan shares it, then
+// drops the makers it filled, keeping time priority.
+func (ob *OrderBook) matchLevel(o *model.Order, level *PriceLevel) (trades []model.Trade) {
+	for i, q := range ob.plan(o, level) {
+		if q > 0 {
+			// trade at the resting order's price
+			trades = append(trades, ob.execute(o, level.Orders[i], q))
 		}
-		if len(level.Orders) == 0 {
-			delete(ob.Bids, p)
+	}
+
+	kept := level.Orders[:0]
+	for _, maker := range level.Orders {
+		if maker.Remaining() > 0 {
+			kept = append(kept, maker)
 		}
 	}
+	level.Orders = kept
 	return trades
 }
 
@@ -164,42 +292,198 @@ func (ob *OrderBook) sortedPrices(m map[int64]*PriceLevel, asc bool) []int64 {
 	return keys
 }
 
-// ProcessOrder handles LIMIT and MARKET orders for a single symbol.
-// MARKET must fully execute or be rejected.
-func (ob *OrderBook) ProcessOrder(o *model.Order) ([]model.Order, error) {
-	if o.Type == model.MARKET {
-		return ob.processMarket(o)
+// ProcessOrder handles LIMIT, MARKET, PEGGED and TRAILING_STOP orders for a
+// single symbol.
+// MARKET must fully execute or be rejected. A spread's book takes only plain
+// limit orders.
+// Quantity stays the original size; Filled accumulates as the order trades.
+func (ob *OrderBook) ProcessOrder(o *model.Order) ([]model.Trade, error) {
+	var trades []model.Trade
+	err := ob.admit(o)
+	switch {
+	case err != nil:
+	case o.Type == model.MARKET:
+		trades, err = ob.processMarket(o)
+	case o.Type == model.PEGGED:
+		trades = ob.processPeg(o)
+	case o.Type == model.TRAILING_STOP:
+		ob.processStop(o)
+	default:
+		trades = ob.processLimit(o)
+	}
+	if err != nil {
+		ob.reject(o, err)
+	}
+	ob.react()
+	return trades, err
+}
+
+// admit reports why the book refuses o outright, if it does.
+func (ob *OrderBook) admit(o *model.Order) error {
+	if ob.isSpread() && !spreadable(o) {
+		return errSpreadOrder
 	}
-	return ob.processLimit(o), nil
+	return nil
+}
+
+// reject reports o refused for err.
+func (ob *OrderBook) reject(o *model.Order, err error) {
+	cp := *o
+	ob.emit(events.Event{Type: events.OrderRejected, Order: &cp, Reason: err.Error()})
 }
 
-func (ob *OrderBook) processLimit(o *model.Order) []model.Order {
+// react brings the pegs and trailing stops up to date after a command, in
+// ob and then in the books its spreads link it to, which implied trades may
+// have moved. Repricing pegs can trade, and triggered stops trade and move
+// the book, so each book goes on until its stops are quiet.
+func (ob *OrderBook) react() {
+	ob.settle()
+	for _, l := range ob.links {
+		for _, b := range l.books() {
+			if b != ob {
+				b.settle()
+			}
+		}
+	}
+}
+
+// settle brings ob's own pegs and stops up to date.
+func (ob *OrderBook) settle() {
+	for {
+		ob.repeg()
+		if !ob.trail() {
+			return
+		}
+	}
+}
+
+func (ob *OrderBook) processLimit(o *model.Order) []model.Trade {
+	ob.emitOrder(events.OrderAccepted, o)
 	trades := ob.matchLimit(o)
-	if o.Quantity > 0 && o.Type == model.LIMIT {
+	if o.Remaining() > 0 && o.Type == model.LIMIT {
 		ob.addToBook(o)
 	}
 	return trades
 }
 
-func (ob *OrderBook) processMarket(o *model.Order) ([]model.Order, error) {
-	// compute available liquidity
-	available := int64(0)
-	if o.Side == model.BUY {
-		for _, lvl := range ob.Asks {
-			for _, s := range lvl.Orders {
-				available += s.Quantity - s.Filled
-			}
+// unlink takes a resting order out of the book without emitting anything.
+// It reports false if the order is not resting in this book.
+func (ob *OrderBook) unlink(o *model.Order) bool {
+	if o.Type == model.TRAILING_STOP {
+		return ob.dropStop(o)
+	}
+	if o.Type == model.PEGGED {
+		if !ob.dropPeg(o) {
+			return false
 		}
-	} else {
-		for _, lvl := range ob.Bids {
-			for _, b := range lvl.Orders {
-				available += b.Quantity - b.Filled
-			}
+		if inLevel(o) {
+			ob.unlinkLevel(o)
 		}
+		return true
 	}
-	if available < o.Quantity {
-		return nil, errors.New("insufficient liquidity for market order")
+	return ob.unlinkLevel(o)
+}
+
+// unlinkLevel takes o out of its price level.
+func (ob *OrderBook) unlinkLevel(o *model.Order) bool {
+	sideMap := ob.Bids
+	if o.Side == model.SELL {
+		sideMap = ob.Asks
+	}
+	level, ok := sideMap[o.Price]
+	if !ok {
+		return false
+	}
+
+	// rebuild slice without the order
+	found := false
+	newOrders := level.Orders[:0]
+	for _, ord := range level.Orders {
+		if ord.ID == o.ID {
+			found = true
+			continue
+		}
+		newOrders = append(newOrders, ord)
+	}
+	level.Orders = newOrders
+	if len(level.Orders) == 0 {
+		delete(sideMap, o.Price)
+	}
+	return found
+}
+
+// cancel removes a resting order from its price level.
+// It reports false if the order is not resting in this book.
+func (ob *OrderBook) cancel(o *model.Order) bool {
+	if !ob.unlink(o) {
+		return false
+	}
+	ob.emitOrder(events.OrderCancelled, o)
+	if inLevel(o) && !o.Hidden {
+		ob.emitLevel(o.Side, o.Price)
+	}
+	ob.react()
+	return true
+}
+
+// amend changes a resting order's price and/or total quantity.
+// Reducing the size at the same price keeps queue position; any other change
+// takes the order out and re-enters it like a new order, so it goes to the
+// back of the queue and may trade.
+// A pegged order's price follows its peg and a waiting trailing stop's
+// trigger follows the trades: only their quantity changes.
+func (ob *OrderBook) amend(o *model.Order, price, qty int64) ([]model.Trade, error) {
+	if qty <= o.Filled {
+		return nil, errors.New("quantity must be greater than filled quantity")
+	}
+	switch o.Type {
+	case model.PEGGED:
+		err := ob.amendPeg(o, qty)
+		ob.react()
+		return nil, err
+	case model.TRAILING_STOP:
+		return nil, ob.amendStop(o, qty)
+	}
+	trades, err := ob.amendLimit(o, price, qty)
+	ob.react()
+	return trades, err
+}
+
+func (ob *OrderBook) amendLimit(o *model.Order, price, qty int64) ([]model.Trade, error) {
+	if price <= 0 && !ob.isSpread() {
+		return nil, errors.New("price must be > 0 (in cents)")
+	}
+	if price == o.Price && qty <= o.Quantity {
+		o.Quantity = qty
+		ob.emitOrder(events.OrderAmended, o)
+		if !o.Hidden {
+			ob.emitLevel(o.Side, o.Price)
+		}
+		return nil, nil
+	}
+
+	if !ob.unlink(o) {
+		return nil, errNotResting
+	}
+	oldPrice := o.Price
+	o.Price, o.Quantity = price, qty
+	ob.emitOrder(events.OrderAmended, o)
+	if !o.Hidden {
+		ob.emitLevel(o.Side, oldPrice)
+	}
+
+	trades := ob.matchLimit(o)
+	if o.Remaining() > 0 {
+		ob.addToBook(o)
+	}
+	return trades, nil
+}
+
+func (ob *OrderBook) processMarket(o *model.Order) ([]model.Trade, error) {
+	if ob.fillable(o) < o.Remaining() {
+		return nil, errNoLiquidity
 	}
+	ob.emitOrder(events.OrderAccepted, o)
 	return ob.matchLimit(o), nil
 }
 
@@ -70,3 +70,78 @@ func TestMarketOrderReject(t *testing.T) {
 		t.Fatalf("expected rejection for insufficient liquidity")
 	}
 }
+
+func TestMarketBuySweepsAsks(t *testing.T) {
+	ob := NewOrderBook("MKT")
+	ob.ProcessOrder(newOrder("MKT", model.SELL, model.LIMIT, 100, 3))
+	ob.ProcessOrder(newOrder("MKT", model.SELL, model.LIMIT, 101, 3))
+
+	mkt := newOrder("MKT", model.BUY, model.MARKET, 0, 5)
+	trades, err := ob.ProcessOrder(mkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 || mkt.Filled != 5 || mkt.Remaining() != 0 {
+		t.Fatalf("expected full fill over 2 levels, got trades=%d filled=%d", len(trades), mkt.Filled)
+	}
+	if lvl := ob.Asks[101]; lvl == nil || lvl.Orders[0].Remaining() != 1 {
+		t.Fatalf("expected 1 left at 101")
+	}
+}
+
+func TestTradeTapeWrapsNewestFirst(t *testing.T) {
+	r := newTradeRing(3)
+	for i := int64(1); i <= 5; i++ {
+		r.add(model.Trade{Price: i, MakerOrderID: "m", TakerOrderID: "t"})
+	}
+	got := r.last(10)
+	if len(got) != 3 || got[0].Price != 5 || got[2].Price != 3 {
+		t.Fatalf("expected prices 5,4,3; got %+v", got)
+	}
+	if got[0].MakerOrderID != "" || got[0].TakerOrderID != "" {
+		t.Fatalf("tape must not expose order IDs")
+	}
+	if n := len(r.last(2)); n != 2 {
+		t.Fatalf("expected limit 2 honoured, got %d", n)
+	}
+}
+
+func TestAmendKeepsPriorityOnlyWhenReducing(t *testing.T) {
+	ob := NewOrderBook("AMD")
+	a := newOrder("AMD", model.SELL, model.LIMIT, 100, 5)
+	a.ID = "a"
+	b := newOrder("AMD", model.SELL, model.LIMIT, 100, 5)
+	b.ID = "b"
+	ob.ProcessOrder(a)
+	ob.ProcessOrder(b)
+
+	// reduce a: stays first
+	if _, err := ob.amend(a, 100, 3); err != nil {
+		t.Fatal(err)
+	}
+	if ob.Asks[100].Orders[0] != a || a.Remaining() != 3 {
+		t.Fatalf("reduced order should keep its place")
+	}
+
+	// grow a: goes behind b
+	if _, err := ob.amend(a, 100, 6); err != nil {
+		t.Fatal(err)
+	}
+	if ob.Asks[100].Orders[0] != b || ob.Asks[100].Orders[1] != a {
+		t.Fatalf("grown order should lose priority")
+	}
+
+	// re-price b through a resting bid: trades immediately
+	ob.ProcessOrder(newOrder("AMD", model.BUY, model.LIMIT, 98, 2))
+	trades, err := ob.amend(b, 98, 5)
+	if err != nil || len(trades) != 1 || b.Remaining() != 3 {
+		t.Fatalf("expected crossing amend to trade 2, got trades=%d remaining=%d err=%v", len(trades), b.Remaining(), err)
+	}
+	if _, ok := ob.Asks[98]; !ok {
+		t.Fatalf("expected remainder of b resting at 98")
+	}
+
+	if _, err := ob.amend(b, 98, 2); err == nil {
+		t.Fatalf("expected error amending below filled quantity")
+	}
+}
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Pegged orders.
+//
+// A peg's reference is the best bid and ask of the book's non-pegged
+// ("lit") orders, so pegs never chase each other. Primary and market pegs
+// rest in the price levels like limit orders, at the price their peg gives
+// them, and are displayed. Whenever a command leaves the lit best bid or
+// ask somewhere else, every peg is repriced: one whose price changed is
+// taken out, reported as amended and re-entered at the back of its new
+// level, trading first if it now crosses. A peg with nothing to follow
+// (its reference side is empty) is parked at price 0 until it has one.
+//
+// Midpoint pegs are not displayed and never enter the levels. They trade
+// only at the midpoint of the lit best bid and ask, rounded down to a
+// cent: against each other, and against incoming orders willing to trade
+// at the midpoint, which meet them after the levels priced better than the
+// midpoint (hidden orders and pegs inside the lit spread) and before the
+// rest. Lit orders resting in the book never trade with them.
+
+// maxRepeg bounds the repricing rounds after one command: a repriced peg
+// that trades with lit orders moves the reference again.
+const maxRepeg = 8
+
+func isMidpoint(o *model.Order) bool {
+	return o.Type == model.PEGGED && o.PegType == model.PegMidpoint
+}
+
+// inLevel reports whether o is (or would be) resting in a price level.
+func inLevel(o *model.Order) bool {
+	return !isMidpoint(o) && o.Price > 0
+}
+
+// litBest returns the best price on side among displayed non-pegged orders,
+// or 0.
+func (ob *OrderBook) litBest(side model.Side) int64 {
+	sideMap := ob.Bids
+	if side == model.SELL {
+		sideMap = ob.Asks
+	}
+	best := int64(0)
+	for p, level := range sideMap {
+		if best != 0 && ((side == model.BUY && p <= best) || (side == model.SELL && p >= best)) {
+			continue
+		}
+		for _, o := range level.Orders {
+			if o.Type != model.PEGGED && !o.Hidden {
+				best = p
+				break
+			}
+		}
+	}
+	return best
+}
+
+// mid returns the midpoint of the reference, if both sides have one.
+func (ob *OrderBook) mid() (int64, bool) {
+	if ob.refBid == 0 || ob.refAsk == 0 {
+		return 0, false
+	}
+	return (ob.refBid + ob.refAsk) / 2, true
+}
+
+// pegPrice returns the price o's peg gives it now.
+func (ob *OrderBook) pegPrice(o *model.Order) (int64, bool) {
+	var ref int64
+	switch o.PegType {
+	case model.PegMidpoint:
+		return ob.mid()
+	case model.PegPrimary:
+		ref = ob.refBid
+		if o.Side == model.SELL {
+			ref = ob.refAsk
+		}
+	case model.PegMarket:
+		ref = ob.refAsk
+		if o.Side == model.SELL {
+			ref = ob.refBid
+		}
+	}
+	if ref == 0 {
+		return 0, false
+	}
+	price := ref - o.PegOffset
+	if o.Side == model.SELL {
+		price = ref + o.PegOffset
+	}
+	if o.PegLimit > 0 {
+		if o.Side == model.BUY && price > o.PegLimit {
+			price = o.PegLimit
+		}
+		if o.Side == model.SELL && price < o.PegLimit {
+			price = o.PegLimit
+		}
+	}
+	if price <= 0 {
+		return 0, false
+	}
+	return price, true
+}
+
+// midEligible reports whether midpoint peg o may trade at mid under its cap.
+func midEligible(o *model.Order, mid int64) bool {
+	switch {
+	case o.PegLimit == 0:
+		return true
+	case o.Side == model.BUY:
+		return mid <= o.PegLimit
+	default:
+		return mid >= o.PegLimit
+	}
+}
+
+// takesMid reports whether incoming order o would trade at mid.
+func takesMid(o *model.Order, mid int64) bool {
+	switch {
+	case isMidpoint(o):
+		return midEligible(o, mid)
+	case o.Type == model.MARKET:
+		return true
+	case o.Side == model.BUY:
+		return o.Price >= mid
+	default:
+		return o.Price <= mid
+	}
+}
+
+// processPeg enters a new pegged order.
+func (ob *OrderBook) processPeg(o *model.Order) (trades []model.Trade) {
+	ob.emitOrder(events.OrderAccepted, o)
+	if len(ob.pegs) == 0 {
+		// the reference is only kept up to date while there are pegs
+		ob.refBid, ob.refAsk = ob.litBest(model.BUY), ob.litBest(model.SELL)
+	}
+	if isMidpoint(o) {
+		o.Price, _ = ob.mid()
+		trades = ob.matchMidpoint(o)
+	} else if price, ok := ob.pegPrice(o); ok {
+		o.Price = price
+		trades = ob.matchLimit(o)
+	}
+	if o.Remaining() > 0 {
+		ob.pegs = append(ob.pegs, o)
+		if inLevel(o) {
+			ob.addToBook(o)
+		}
+	}
+	return trades
+}
+
+// midFor returns the midpoint incoming o would trade with the midpoint
+// pegs at, if it would. Orders with execution conditions do not take part.
+func (ob *OrderBook) midFor(o *model.Order) (int64, bool) {
+	if len(ob.pegs) == 0 || conditional(o) {
+		return 0, false
+	}
+	mid, ok := ob.mid()
+	if !ok || !takesMid(o, mid) {
+		return 0, false
+	}
+	return mid, true
+}
+
+// matchInside fills incoming o against the levels of the other side priced
+// better for it than mid, best first.
+func (ob *OrderBook) matchInside(o *model.Order, mid int64) (trades []model.Trade) {
+	if o.Side == model.BUY {
+		for _, p := range ob.sortedPrices(ob.Asks, true) {
+			if o.Remaining() == 0 || p >= mid {
+				break
+			}
+			trades = append(trades, ob.matchAt(o, p)...)
+		}
+		return trades
+	}
+	for _, p := range ob.sortedPrices(ob.Bids, false) {
+		if o.Remaining() == 0 || p <= mid {
+			break
+		}
+		trades = append(trades, ob.matchAt(o, p)...)
+	}
+	return trades
+}
+
+// matchMidpoint fills incoming o against resting midpoint pegs of the
+// other side, oldest first, at the midpoint.
+func (ob *OrderBook) matchMidpoint(o *model.Order) (trades []model.Trade) {
+	mid, ok := ob.midFor(o)
+	if !ok {
+		return nil
+	}
+	for _, m := range ob.pegs {
+		if o.Remaining() == 0 {
+			break
+		}
+		if !isMidpoint(m) || m.Side == o.Side || m.Remaining() == 0 || !midEligible(m, mid) {
+			continue
+		}
+		m.Price = mid // trades happen at the maker's price
+		trades = append(trades, ob.execute(o, m, min(o.Remaining(), m.Remaining())))
+	}
+	return trades
+}
+
+// repeg reprices the pegs if the lit best bid or ask has moved since they
+// were last priced. It runs after every command that changes the book.
+func (ob *OrderBook) repeg() {
+	for i := 0; i < maxRepeg && len(ob.pegs) > 0; i++ {
+		live := ob.pegs[:0]
+		for _, o := range ob.pegs {
+			if o.Remaining() > 0 {
+				live = append(live, o)
+			}
+		}
+		ob.pegs = live
+
+		bid, ask := ob.litBest(model.BUY), ob.litBest(model.SELL)
+		if bid == ob.refBid && ask == ob.refAsk {
+			return
+		}
+		ob.refBid, ob.refAsk = bid, ask
+		ob.reprice()
+	}
+}
+
+// reprice moves every peg to the price the current reference gives it and
+// crosses the midpoint pegs that can now trade.
+func (ob *OrderBook) reprice() {
+	pegs := append([]*model.Order(nil), ob.pegs...)
+	for _, o := range pegs {
+		if o.Remaining() == 0 || isMidpoint(o) {
+			continue
+		}
+		price, ok := ob.pegPrice(o)
+		if price == o.Price {
+			continue
+		}
+		if inLevel(o) {
+			ob.unlinkLevel(o)
+			ob.emitLevel(o.Side, o.Price)
+		}
+		o.Price = price
+		ob.emitOrder(events.OrderAmended, o)
+		if !ok {
+			continue // parked
+		}
+		ob.matchLimit(o)
+		if o.Remaining() > 0 {
+			ob.addToBook(o)
+		}
+	}
+
+	mid, ok := ob.mid()
+	for _, o := range ob.pegs {
+		if isMidpoint(o) {
+			o.Price = mid
+		}
+	}
+	if !ok {
+		return
+	}
+	// a later midpoint order takes from earlier ones the new mid brought
+	// within their caps
+	for i, taker := range ob.pegs {
+		if !isMidpoint(taker) || taker.Remaining() == 0 || !midEligible(taker, mid) {
+			continue
+		}
+		for _, maker := range ob.pegs[:i] {
+			if taker.Remaining() == 0 {
+				break
+			}
+			if !isMidpoint(maker) || maker.Side == taker.Side || maker.Remaining() == 0 || !midEligible(maker, mid) {
+				continue
+			}
+			ob.execute(taker, maker, min(taker.Remaining(), maker.Remaining()))
+		}
+	}
+}
+
+// dropPeg forgets a resting peg. It reports false if o is not one.
+func (ob *OrderBook) dropPeg(o *model.Order) bool {
+	for i, p := range ob.pegs {
+		if p == o {
+			ob.pegs = append(ob.pegs[:i], ob.pegs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// amendPeg changes a peg's quantity; its price follows the peg. Growing
+// it costs its time priority, among the midpoint pegs too.
+func (ob *OrderBook) amendPeg(o *model.Order, qty int64) error {
+	if qty <= o.Quantity {
+		if !ob.hasPeg(o) {
+			return errNotResting
+		}
+		o.Quantity = qty
+		ob.emitOrder(events.OrderAmended, o)
+		if inLevel(o) {
+			ob.emitLevel(o.Side, o.Price)
+		}
+		return nil
+	}
+
+	if !ob.dropPeg(o) {
+		return errNotResting
+	}
+	if inLevel(o) {
+		ob.unlinkLevel(o)
+		ob.emitLevel(o.Side, o.Price)
+	}
+	o.Quantity = qty
+	ob.emitOrder(events.OrderAmended, o)
+	ob.pegs = append(ob.pegs, o)
+	if inLevel(o) {
+		ob.addToBook(o)
+	}
+	return nil
+}
+
+func (ob *OrderBook) hasPeg(o *model.Order) bool {
+	for _, p := range ob.pegs {
+		if p == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func limitOrder(id string, side model.Side, price, qty int64) *model.Order {
+	return &model.Order{ID: id, Symbol: "P", Side: side, Type: model.LIMIT, Price: price, Quantity: qty}
+}
+
+func pegOrder(id string, side model.Side, peg model.PegType, offset, cap, qty int64) *model.Order {
+	return &model.Order{ID: id, Symbol: "P", Side: side, Type: model.PEGGED, PegType: peg, PegOffset: offset, PegLimit: cap, Quantity: qty}
+}
+
+// queueAt returns the IDs resting at price on side, in time priority.
+func queueAt(ob *OrderBook, side model.Side, price int64) []string {
+	sideMap := ob.Bids
+	if side == model.SELL {
+		sideMap = ob.Asks
+	}
+	var ids []string
+	if lvl, ok := sideMap[price]; ok {
+		for _, o := range lvl.Orders {
+			ids = append(ids, o.ID)
+		}
+	}
+	return ids
+}
+
+func TestPrimaryPegFollowsBestBid(t *testing.T) {
+	ob := NewOrderBook("P")
+	var amended []int64
+	ob.publish = func(ev events.Event) {
+		if ev.Type == events.OrderAmended && ev.Order.ID == "peg" {
+			amended = append(amended, ev.Order.Price)
+		}
+	}
+	ob.ProcessOrder(limitOrder("b1", model.BUY, 100, 5))
+	peg := pegOrder("peg", model.BUY, model.PegPrimary, 1, 0, 5)
+	ob.ProcessOrder(peg)
+	if peg.Price != 99 {
+		t.Fatalf("expected the peg one below the best bid at 99, got %d", peg.Price)
+	}
+
+	b2 := limitOrder("b2", model.BUY, 102, 5)
+	ob.ProcessOrder(b2)
+	if peg.Price != 101 || len(queueAt(ob, model.BUY, 101)) != 1 || len(queueAt(ob, model.BUY, 99)) != 0 {
+		t.Fatalf("expected the peg moved to 101, at %d", peg.Price)
+	}
+	ob.cancel(b2)
+	if peg.Price != 99 {
+		t.Fatalf("expected the peg back at 99, at %d", peg.Price)
+	}
+	if len(amended) != 2 || amended[0] != 101 || amended[1] != 99 {
+		t.Fatalf("expected each reprice reported as an amend, got %v", amended)
+	}
+
+	// the peg itself is not a reference: a sell that takes it leaves the
+	// lit bid, and the peg, where they were
+	ob.ProcessOrder(limitOrder("s", model.SELL, 99, 2))
+	if peg.Price != 99 || peg.Remaining() != 5 {
+		t.Fatalf("expected the sell to hit the lit bid at 100 first, peg %+v", peg)
+	}
+}
+
+func TestPegCapAndParking(t *testing.T) {
+	ob := NewOrderBook("P")
+	capped := pegOrder("capped", model.BUY, model.PegPrimary, 0, 100, 5)
+	ob.ProcessOrder(capped)
+	if capped.Price != 0 || len(ob.Bids) != 0 {
+		t.Fatalf("with no bid to follow the peg must be parked, got price %d", capped.Price)
+	}
+	ob.ProcessOrder(limitOrder("b", model.BUY, 105, 1))
+	if capped.Price != 100 {
+		t.Fatalf("expected the cap of 100 to hold the peg below 105, got %d", capped.Price)
+	}
+
+	// a market peg sells two above the best bid
+	mp := pegOrder("mp", model.SELL, model.PegMarket, 2, 0, 3)
+	ob.ProcessOrder(mp)
+	if mp.Price != 107 {
+		t.Fatalf("expected market peg sell at 107, got %d", mp.Price)
+	}
+
+	// the bid goes away: both park, neither is displayed
+	ob.ProcessOrder(limitOrder("hit", model.SELL, 105, 1))
+	if capped.Price != 0 || mp.Price != 0 || len(ob.Bids) != 0 || len(ob.Asks) != 0 {
+		t.Fatalf("expected both parked, got capped=%d mp=%d", capped.Price, mp.Price)
+	}
+}
+
+func TestRepricedPegGoesToBackOfQueue(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("lit1", model.BUY, 100, 1))
+	peg := pegOrder("peg", model.BUY, model.PegPrimary, 0, 0, 1)
+	ob.ProcessOrder(peg)
+	ob.ProcessOrder(limitOrder("lit2", model.BUY, 100, 1))
+	if q := queueAt(ob, model.BUY, 100); len(q) != 3 || q[1] != "peg" {
+		t.Fatalf("expected the peg second, got %v", q)
+	}
+
+	// the best bid moves up and back: the peg re-enters behind lit2
+	b := limitOrder("up", model.BUY, 101, 1)
+	ob.ProcessOrder(b)
+	ob.cancel(b)
+	if q := queueAt(ob, model.BUY, 100); len(q) != 3 || q[2] != "peg" {
+		t.Fatalf("expected the peg re-ranked last, got %v", q)
+	}
+}
+
+func TestMidpointPeg(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("bid", model.BUY, 100, 10))
+	ob.ProcessOrder(limitOrder("ask", model.SELL, 110, 10))
+
+	mid := pegOrder("mid", model.BUY, model.PegMidpoint, 0, 0, 5)
+	if trades, _ := ob.ProcessOrder(mid); len(trades) != 0 || mid.Price != 105 {
+		t.Fatalf("expected the midpoint buy resting at 105, got %d trades at %d", len(trades), mid.Price)
+	}
+	if len(ob.Bids) != 1 || len(queueAt(ob, model.BUY, 100)) != 1 {
+		t.Fatal("a midpoint peg is not displayed in the levels")
+	}
+
+	// a sell above the midpoint does not take it
+	if trades, _ := ob.ProcessOrder(limitOrder("s1", model.SELL, 106, 1)); len(trades) != 0 {
+		t.Fatalf("a sell at 106 does not trade at 105, got %+v", trades)
+	}
+	ob.cancel(ob.Asks[106].Orders[0])
+
+	// a sell at the bid meets the midpoint first, then the bid
+	trades, _ := ob.ProcessOrder(limitOrder("s2", model.SELL, 100, 7))
+	if len(trades) != 2 || trades[0].Price != 105 || trades[0].Quantity != 5 || trades[1].Price != 100 || trades[1].Quantity != 2 {
+		t.Fatalf("expected 5 at the midpoint and 2 at the bid, got %+v", trades)
+	}
+}
+
+func TestBetterPricesComeBeforeTheMidpoint(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("bid", model.BUY, 90, 10))
+	ob.ProcessOrder(limitOrder("ask", model.SELL, 110, 10))
+	hidden := limitOrder("hidden", model.SELL, 95, 1)
+	hidden.Hidden = true
+	ob.ProcessOrder(hidden)
+	ob.ProcessOrder(pegOrder("mid", model.SELL, model.PegMidpoint, 0, 0, 1))
+
+	// the hidden ask at 95 is inside the lit spread, below the mid of 100
+	trades, _ := ob.ProcessOrder(limitOrder("buy", model.BUY, 110, 3))
+	if len(trades) != 3 || trades[0].Price != 95 || trades[1].Price != 100 || trades[2].Price != 110 {
+		t.Fatalf("expected fills at 95, 100 and 110 in that order, got %+v", trades)
+	}
+}
+
+func TestMidpointPegsCrossWhenTheMidMoves(t *testing.T) {
+	ob := NewOrderBook("P")
+	ob.ProcessOrder(limitOrder("bid", model.BUY, 100, 10))
+	ask := limitOrder("ask", model.SELL, 110, 10)
+	ob.ProcessOrder(ask)
+
+	// the buy will not pay 105; the sell is happy at any midpoint
+	buy := pegOrder("mb", model.BUY, model.PegMidpoint, 0, 103, 4)
+	sell := pegOrder("ms", model.SELL, model.PegMidpoint, 0, 0, 4)
+	ob.ProcessOrder(buy)
+	if trades, _ := ob.ProcessOrder(sell); len(trades) != 0 {
+		t.Fatalf("mid 105 is above the buy's cap, got %+v", trades)
+	}
+
+	// a midpoint peg never takes lit liquidity, even when the cap allows
+	if bid := queueAt(ob, model.BUY, 100); len(bid) != 1 || ob.Bids[100].Orders[0].Filled != 0 {
+		t.Fatal("the midpoint sell must not trade with the lit bid")
+	}
+
+	ob.cancel(ask)
+	ob.ProcessOrder(limitOrder("ask2", model.SELL, 106, 10))
+	if buy.Filled != 4 || sell.Filled != 4 || buy.Price != 103 {
+		t.Fatalf("expected both midpoint pegs filled at 103, buy %+v sell %+v", buy, sell)
+	}
+}
+
+func TestRouterPeggedOrders(t *testing.T) {
+	r := NewRouter(1, 16)
+	defer r.Stop()
+	r.SubmitOrder(&model.Order{ID: "bid", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 1})
+	r.SubmitOrder(&model.Order{ID: "peg", Symbol: "P", Side: model.BUY, Type: model.PEGGED, PegType: model.PegPrimary, Quantity: 3})
+	r.SubmitOrder(&model.Order{ID: "up", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 104, Quantity: 1})
+
+	if got := r.GetOrder("P", "peg"); got.Err != "" || got.Order.Price != 104 {
+		t.Fatalf("expected the peg at 104, got %+v", got)
+	}
+	if res := r.AmendOrder("P", "peg", 0, 2); res.Err != "" || res.Order.Price != 104 || res.Order.Quantity != 2 {
+		t.Fatalf("expected a quantity-only amend, got %+v", res)
+	}
+	if res := r.CancelOrder("P", "peg"); !res.OK {
+		t.Fatal(res.Err)
+	}
+	if bk := r.GetOrderBook("P", 10); len(bk.Bids) != 2 {
+		t.Fatalf("expected only the two lit bids left, got %v", bk.Bids)
+	}
+}
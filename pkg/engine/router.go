@@ -3,19 +3,75 @@ package engine
 import (
 	"hash/fnv"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
 )
 
 // Router routes commands to N shards.
 type Router struct {
-	shards []*shard
-	n      int
-	buf    int
+	shards   []*shard
+	n        int
+	buf      int
+	bus      *events.Bus
+	pre      []PreTrade
+	fees     Fees
+	matching func(symbol string) Matcher
+	spreads  []Spread
+	group    map[string]string // symbol -> routing key, for spreads and legs
+	boot     string            // start time, in the IDs of this run's orders
+	roll     time.Duration     // session start past midnight UTC, see WithSessionRoll
+
+	clientMu [clientLocks]sync.Mutex // serialize the submits of each client order ID
+}
+
+// Option configures a Router at construction time.
+type Option func(*Router)
+
+// WithEventBus makes every shard publish its events to bus.
+func WithEventBus(bus *events.Bus) Option {
+	return func(r *Router) { r.bus = bus }
+}
+
+// PreTrade vets orders before the router hands them to a shard, e.g. risk
+// limits. A non-nil error refuses the order or amend; it is returned as the
+// result's Reject, and nothing reaches the shard.
+type PreTrade interface {
+	CheckOrder(o *model.Order) error
+	CheckAmend(symbol, orderID string, price, qty int64) error
+}
+
+// Releaser is implemented by PreTrade checks that reserve something for an
+// order they pass. Release undoes that when a later check refuses the order.
+type Releaser interface {
+	Release(o *model.Order)
+}
+
+// WithPreTrade runs p on every submit and amend. Given more than once, the
+// checks run in the order given and the first refusal wins.
+func WithPreTrade(p PreTrade) Option {
+	return func(r *Router) { r.pre = append(r.pre, p) }
+}
+
+// Fees prices each trade for both sides as it executes. Fees are in price
+// units (cents); a negative fee is a rebate. It is called from every shard
+// concurrently and must not block.
+type Fees interface {
+	TradeFees(t *model.Trade, maker, taker *model.Order) (makerFee, takerFee int64)
+}
+
+// WithFees charges every trade according to f.
+func WithFees(f Fees) Option {
+	return func(r *Router) { r.fees = f }
 }
 
 // NewRouter creates a router with numShards worker shards and channel buffer size buf.
-func NewRouter(numShards int, buf int) *Router {
+func NewRouter(numShards int, buf int, opts ...Option) *Router {
 	if numShards <= 0 {
 		numShards = runtime.NumCPU()
 	}
@@ -23,9 +79,17 @@ func NewRouter(numShards int, buf int) *Router {
 		shards: make([]*shard, numShards),
 		n:      numShards,
 		buf:    buf,
+		boot:   strconv.FormatInt(time.Now().UnixMilli(), 36),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	if err := checkSpreads(r.spreads); err != nil {
+		panic("engine: " + err.Error())
+	}
+	r.group = spreadGroups(r.spreads)
 	for i := 0; i < numShards; i++ {
-		r.shards[i] = newShard(buf)
+		r.shards[i] = newShard(buf, r.bus, r.fees, r.matching, r.spreads, r.roll)
 	}
 	return r
 }
@@ -36,16 +100,74 @@ func (r *Router) Stop() {
 	}
 }
 
-// routeIdx returns the shard index for a symbol.
+// IsSpread reports whether symbol is a spread instrument.
+func (r *Router) IsSpread(symbol string) bool {
+	for _, sp := range r.spreads {
+		if sp.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// routeIdx returns the shard index for a symbol. The symbols of connected
+// spreads share a routing key, so they always land on one shard.
 func (r *Router) routeIdx(symbol string) int {
+	if key, ok := r.group[symbol]; ok {
+		symbol = key
+	}
 	h := fnv.New32a()
 	h.Write([]byte(symbol))
 	return int(h.Sum32()) % r.n
 }
 
+// NewOrderID returns a fresh order ID for an order in symbol. The ID names
+// the shard that owns symbol, "<shard>-<start time>-<sequence>", so orders
+// are found from their ID alone.
+func (r *Router) NewOrderID(symbol string) string {
+	return r.newID(r.routeIdx(symbol))
+}
+
+func (r *Router) newID(idx int) string {
+	seq := r.shards[idx].ids.Add(1)
+	return strconv.Itoa(idx) + "-" + r.boot + "-" + strconv.FormatUint(seq, 10)
+}
+
+// orderIdx returns the index of the shard owning an order: the one its ID
+// names, or for an ID the caller chose, the one owning symbol. It reports
+// false for an ID that is not the router's given without a symbol.
+func (r *Router) orderIdx(symbol, orderID string) (int, bool) {
+	head, rest, _ := strings.Cut(orderID, "-")
+	boot, seq, _ := strings.Cut(rest, "-")
+	if idx, err := strconv.Atoi(head); err == nil && idx >= 0 && idx < r.n && boot == r.boot {
+		if _, err := strconv.ParseUint(seq, 10, 64); err == nil {
+			return idx, true
+		}
+	}
+	if symbol == "" {
+		return 0, false
+	}
+	return r.routeIdx(symbol), true
+}
+
 // SubmitOrder routes an order to the owning shard and waits for a SubmitResult.
+// An order without an ID is given one, see NewOrderID. An order repeating a
+// client order ID is answered as described in clientids.go.
 func (r *Router) SubmitOrder(o *model.Order) SubmitResult {
+	if o.ClientOrderID != "" {
+		orders := []*model.Order{o}
+		defer r.lockClientIDs(orders)()
+		if e := r.clientEntries(orders)[0]; e != nil {
+			return repeat(o, e)
+		}
+	}
 	idx := r.routeIdx(o.Symbol)
+	if o.ID == "" {
+		o.ID = r.newID(idx)
+	}
+	if err := r.vet(o); err != nil {
+		return SubmitResult{Err: err.Error(), Reject: err}
+	}
 	reply := make(chan interface{})
 	cmd := &Cmd{
 		Typ:    CmdSubmit,
@@ -54,16 +176,40 @@ func (r *Router) SubmitOrder(o *model.Order) SubmitResult {
 		Reply:  reply,
 	}
 	r.shards[idx].in <- cmd
-	ri := <-reply
-	return ri.(SubmitResult)
+	res := []SubmitResult{(<-reply).(SubmitResult)}
+	r.remember(res)
+	return res[0]
 }
 
-// CancelOrder routes a cancel request to the shard that should own the order.
-// Note: caller must know which symbol the order belonged to. For this router we
-// accept cancel with orderID and symbol to ensure correct routing. If orderID->symbol mapping is external,
-// caller must supply symbol; for now we'll assume caller provides symbol (the API will route by symbol).
+// vet runs the PreTrade checks on o. If one refuses it, what the checks
+// before it reserved is released.
+func (r *Router) vet(o *model.Order) error {
+	for i, p := range r.pre {
+		if err := p.CheckOrder(o); err != nil {
+			r.release(o, r.pre[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// release undoes what checks reserved for o.
+func (r *Router) release(o *model.Order, checks []PreTrade) {
+	for _, p := range checks {
+		if rel, ok := p.(Releaser); ok {
+			rel.Release(o)
+		}
+	}
+}
+
+// CancelOrder routes a cancel request to the shard that owns the order.
+// The order ID is enough for the IDs the router gives out; symbol is only
+// needed, for routing, for an ID the caller chose, and may be "" otherwise.
 func (r *Router) CancelOrder(symbol, orderID string) CancelResult {
-	idx := r.routeIdx(symbol)
+	idx, ok := r.orderIdx(symbol, orderID)
+	if !ok {
+		return CancelResult{Err: "order not found"}
+	}
 	reply := make(chan interface{})
 	cmd := &Cmd{Typ: CmdCancel, OrderID: orderID, Symbol: symbol, Reply: reply}
 	r.shards[idx].in <- cmd
@@ -71,10 +217,32 @@ func (r *Router) CancelOrder(symbol, orderID string) CancelResult {
 	return ri.(CancelResult)
 }
 
-// GetOrder retrieves an order by id from the shard owning the symbol.
-// As above, caller must pass the symbol for routing.
+// AmendOrder changes the price and/or total quantity of a resting order.
+// As with cancel, symbol is only needed for an ID the caller chose.
+func (r *Router) AmendOrder(symbol, orderID string, price, qty int64) AmendResult {
+	idx, ok := r.orderIdx(symbol, orderID)
+	if !ok {
+		return AmendResult{Err: "order not found"}
+	}
+	for _, p := range r.pre {
+		if err := p.CheckAmend(symbol, orderID, price, qty); err != nil {
+			return AmendResult{Err: err.Error(), Reject: err}
+		}
+	}
+	reply := make(chan interface{})
+	cmd := &Cmd{Typ: CmdAmend, OrderID: orderID, Symbol: symbol, Price: price, Quantity: qty, Reply: reply}
+	r.shards[idx].in <- cmd
+	ri := <-reply
+	return ri.(AmendResult)
+}
+
+// GetOrder retrieves an order by id from the shard owning it. As with
+// cancel, symbol is only needed for an ID the caller chose.
 func (r *Router) GetOrder(symbol, orderID string) GetResult {
-	idx := r.routeIdx(symbol)
+	idx, ok := r.orderIdx(symbol, orderID)
+	if !ok {
+		return GetResult{Err: "order not found"}
+	}
 	reply := make(chan interface{})
 	cmd := &Cmd{Typ: CmdGetOrder, OrderID: orderID, Symbol: symbol, Reply: reply}
 	r.shards[idx].in <- cmd
@@ -91,3 +259,44 @@ func (r *Router) GetOrderBook(symbol string, depth int) BookSnapshot {
 	ri := <-reply
 	return ri.(BookSnapshot)
 }
+
+// GetTrades returns up to limit recent trades for a symbol, newest first.
+// Order IDs are not included.
+func (r *Router) GetTrades(symbol string, limit int) []model.Trade {
+	idx := r.routeIdx(symbol)
+	reply := make(chan interface{})
+	cmd := &Cmd{Typ: CmdGetTrades, Symbol: symbol, Depth: limit, Reply: reply}
+	r.shards[idx].in <- cmd
+	ri := <-reply
+	return ri.([]model.Trade)
+}
+
+// GetTicker returns the level-1 summary for a symbol.
+// ok is false if the symbol has never been seen.
+func (r *Router) GetTicker(symbol string) (Ticker, bool) {
+	idx := r.routeIdx(symbol)
+	reply := make(chan interface{})
+	cmd := &Cmd{Typ: CmdGetTicker, Symbol: symbol, Reply: reply}
+	r.shards[idx].in <- cmd
+	ts := (<-reply).([]Ticker)
+	if len(ts) == 0 {
+		return Ticker{}, false
+	}
+	return ts[0], true
+}
+
+// GetTickers returns the level-1 summary of every symbol on every shard,
+// sorted by symbol. Shards are queried in parallel.
+func (r *Router) GetTickers() []Ticker {
+	replies := make([]chan interface{}, len(r.shards))
+	for i, s := range r.shards {
+		replies[i] = make(chan interface{}, 1)
+		s.in <- &Cmd{Typ: CmdGetTicker, Reply: replies[i]}
+	}
+	out := []Ticker{}
+	for _, reply := range replies {
+		out = append(out, (<-reply).([]Ticker)...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
 )
 
@@ -63,3 +66,170 @@ func TestRouterShardConsistency(t *testing.T) {
 		t.Fatalf("expected get after cancel to fail")
 	}
 }
+
+func TestRouterPublishesEvents(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(64, events.Block, nil)
+	defer sub.Close()
+
+	r := NewRouter(2, 16, WithEventBus(bus))
+	defer r.Stop()
+
+	r.SubmitOrder(&model.Order{ID: "s1", Symbol: "EVT", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 5})
+	r.SubmitOrder(&model.Order{ID: "b1", Symbol: "EVT", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 3})
+
+	want := []events.Type{
+		events.OrderAccepted, events.OrderBooked, events.LevelChanged, // s1 rests
+		events.OrderAccepted, events.TradeExecuted, events.OrderFilled, events.OrderFilled, events.LevelChanged,
+	}
+	for i, typ := range want {
+		ev := <-sub.C()
+		if ev.Type != typ {
+			t.Fatalf("event %d: expected %s, got %s", i, typ, ev.Type)
+		}
+		if ev.Seq != uint64(i+1) {
+			t.Fatalf("event %d: expected seq %d, got %d", i, i+1, ev.Seq)
+		}
+		if ev.Type == events.TradeExecuted && (ev.Trade.MakerOrderID != "s1" || ev.Trade.TakerOrderID != "b1" || ev.Trade.Quantity != 3) {
+			t.Fatalf("unexpected trade %+v", ev.Trade)
+		}
+		if ev.Type == events.LevelChanged && i == len(want)-1 && ev.Level.Quantity != 2 {
+			t.Fatalf("expected ask level at 2 after fill, got %d", ev.Level.Quantity)
+		}
+	}
+}
+
+// fakeCheck refuses orders above max and records what it reserved.
+type fakeCheck struct {
+	max      int64
+	reserved map[string]bool
+}
+
+func (f *fakeCheck) CheckOrder(o *model.Order) error {
+	if o.Quantity > f.max {
+		return errors.New("too big")
+	}
+	f.reserved[o.ID] = true
+	return nil
+}
+
+func (f *fakeCheck) CheckAmend(symbol, orderID string, price, qty int64) error { return nil }
+
+func (f *fakeCheck) Release(o *model.Order) { delete(f.reserved, o.ID) }
+
+func TestPreTradeChain(t *testing.T) {
+	first := &fakeCheck{max: 100, reserved: map[string]bool{}}
+	second := &fakeCheck{max: 10, reserved: map[string]bool{}}
+	r := NewRouter(1, 16, WithPreTrade(first), WithPreTrade(second))
+	defer r.Stop()
+
+	res := r.SubmitOrder(&model.Order{ID: "big", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 1, Quantity: 50})
+	if res.Reject == nil || res.Err != "too big" {
+		t.Fatalf("expected the second check to refuse, got %+v", res)
+	}
+	if first.reserved["big"] {
+		t.Fatal("expected the first check's reservation to be released")
+	}
+	if got := r.GetOrder("P", "big"); got.Err == "" {
+		t.Fatal("a refused order must not reach the shard")
+	}
+
+	if res := r.SubmitOrder(&model.Order{ID: "ok", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 1, Quantity: 5}); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if !first.reserved["ok"] || !second.reserved["ok"] {
+		t.Fatal("expected both checks to have passed the order")
+	}
+}
+
+// flatFees charges the maker 1 and the taker 2 per unit.
+type flatFees struct{}
+
+func (flatFees) TradeFees(t *model.Trade, maker, taker *model.Order) (int64, int64) {
+	return t.Quantity, 2 * t.Quantity
+}
+
+func TestFeesOnParticipantsOnly(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(64, events.Block, nil)
+	r := NewRouter(1, 16, WithEventBus(bus), WithFees(flatFees{}))
+	defer r.Stop()
+
+	r.SubmitOrder(&model.Order{ID: "m", Symbol: "F", Side: model.SELL, Type: model.LIMIT, Price: 10, Quantity: 5})
+	res := r.SubmitOrder(&model.Order{ID: "t", Symbol: "F", Side: model.BUY, Type: model.LIMIT, Price: 10, Quantity: 3})
+	if len(res.Trades) != 1 || res.Trades[0].MakerFee != 3 || res.Trades[0].TakerFee != 6 {
+		t.Fatalf("expected fees 3/6 on the taker's trade, got %+v", res.Trades)
+	}
+	if tape := r.GetTrades("F", 1); tape[0].MakerFee != 0 || tape[0].TakerFee != 0 {
+		t.Fatalf("the public tape must not carry fees: %+v", tape[0])
+	}
+
+	fills := map[string]events.Event{}
+	for len(fills) < 2 {
+		ev := <-sub.C()
+		switch ev.Type {
+		case events.TradeExecuted:
+			if ev.Trade.MakerFee != 0 || ev.Trade.TakerFee != 0 {
+				t.Fatalf("the public trade event must not carry fees: %+v", ev.Trade)
+			}
+		case events.OrderFilled:
+			fills[ev.Order.ID] = ev
+		}
+	}
+	if m, tk := fills["m"], fills["t"]; m.Fee != 3 || !m.Maker || tk.Fee != 6 || tk.Maker {
+		t.Fatalf("unexpected fills maker %+v taker %+v", m, tk)
+	}
+}
+
+func TestRouterRoutesByOrderID(t *testing.T) {
+	r := NewRouter(4, 16)
+	defer r.Stop()
+
+	ids := map[string]string{}
+	for _, sym := range []string{"A", "B", "C", "D", "E"} {
+		res := r.SubmitOrder(&model.Order{Symbol: sym, Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 2})
+		if res.Err != "" || res.Order.ID == "" {
+			t.Fatalf("expected %s resting with an ID, got %+v", sym, res)
+		}
+		ids[sym] = res.Order.ID
+	}
+	for sym, id := range ids {
+		if got := r.GetOrder("", id); got.Err != "" || got.Order.Symbol != sym {
+			t.Fatalf("expected %s found by ID alone, got %+v", id, got)
+		}
+	}
+	if got := r.GetOrder("", "nope"); got.Err != "order not found" {
+		t.Fatalf("expected an ID not ours not found, got %+v", got)
+	}
+
+	// a filled order can still be looked up, but not cancelled
+	r.SubmitOrder(&model.Order{Symbol: "A", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 2})
+	if got := r.GetOrder("", ids["A"]); got.Err != "" || got.Order.Remaining() != 0 {
+		t.Fatalf("expected the filled order found, got %+v", got)
+	}
+	if c := r.CancelOrder("", ids["A"]); c.OK {
+		t.Fatal("expected a filled order not cancellable")
+	}
+	if c := r.CancelOrder("", ids["B"]); !c.OK {
+		t.Fatalf("expected B cancelled by ID alone, got %s", c.Err)
+	}
+}
+
+func TestShardForgetsOldFilledOrders(t *testing.T) {
+	s := &shard{orders: map[string]*model.Order{}}
+	for i := 0; i <= doneKeep; i++ {
+		o := &model.Order{ID: strconv.Itoa(i), Quantity: 1, Filled: 1}
+		s.orders[o.ID] = o
+		s.observe(events.Event{Type: events.OrderFilled, Order: o})
+	}
+	if _, ok := s.orders["0"]; ok || len(s.orders) != doneKeep {
+		t.Fatalf("expected the oldest filled order forgotten, %d kept", len(s.orders))
+	}
+
+	o := &model.Order{ID: "r", Quantity: 1}
+	s.orders[o.ID] = o
+	s.observe(events.Event{Type: events.OrderRejected, Order: o})
+	if _, ok := s.orders["r"]; ok {
+		t.Fatal("expected a refused order forgotten at once")
+	}
+}
@@ -3,7 +3,10 @@ package engine
 import (
 	"fmt"
 	"sort"
+	"sync/atomic"
+	"time"
 
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/metrics"
 	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
 )
@@ -16,24 +19,43 @@ const (
 	CmdCancel
 	CmdGetOrder
 	CmdGetBook
+	CmdGetTrades
+	CmdGetTicker
+	CmdAmend
+	CmdSubmitBatch
+	CmdCancelBatch
+	CmdGetClients
+	CmdRemember
 )
 
 // Cmd is a command routed to a shard.
 type Cmd struct {
-	Typ     CmdType
-	Order   *model.Order // for submit
-	OrderID string       // for cancel/get
-	Symbol  string       // routing key (for submit/getbook)
-	Depth   int          // for orderbook snapshot
-	Reply   chan interface{}
+	Typ      CmdType
+	Order    *model.Order   // for submit
+	OrderID  string         // for cancel/get
+	Symbol   string         // routing key (for submit/getbook); "" = all for ticker
+	Depth    int            // for orderbook snapshot / number of trades
+	Price    int64          // for amend: new limit price
+	Quantity int64          // for amend: new total quantity
+	Orders   []*model.Order // for batch submit and client order ID lookups
+	OrderIDs []string       // for batch cancel
+	Account  string         // for batch cancel: the orders' owner; "" = any
+	Atomic   bool           // for batches: all or nothing
+	Reply    chan interface{}
+
+	clients []*clientEntry // for CmdRemember, see clientids.go
 }
 
 // SubmitResult is returned by a submit command.
 type SubmitResult struct {
 	Order      *model.Order  // order after processing (filled/remaining updated)
-	Trades     []model.Order // trades executed (trade objects simplified)
+	Trades     []model.Trade // trades executed by this order
 	StatusCode int           // HTTP-like status (201/200/202 semantics)
 	Err        string        // non-empty on error
+	Reject     error         // set when the PreTrade check refused the order
+	Replayed   bool          // the order repeated a client order ID; this is the original result
+
+	client *clientEntry // the client order ID the order took, for Router.remember
 }
 
 // CancelResult for cancel command
@@ -42,6 +64,14 @@ type CancelResult struct {
 	Err string
 }
 
+// AmendResult for amend command
+type AmendResult struct {
+	Order  *model.Order  // copy of the order after the amend
+	Trades []model.Trade // trades if the new price crossed
+	Err    string
+	Reject error // set when the PreTrade check refused the amend
+}
+
 // GetResult for GET order
 type GetResult struct {
 	Order *model.Order
@@ -51,28 +81,60 @@ type GetResult struct {
 // BookSnapshot is returned by GetOrderBook
 type BookSnapshot struct {
 	Symbol string
+	Seq    uint64 // last event seq applied to the book
 	Bids   []map[string]interface{}
 	Asks   []map[string]interface{}
+
+	// best implied price on each side, for a spread or one of its legs;
+	// empty when there is none
+	ImpliedBids []map[string]interface{}
+	ImpliedAsks []map[string]interface{}
 }
 
+// doneKeep is how many finished orders a shard keeps for lookups.
+const doneKeep = 1 << 14
+
 // shard is the actor owning a subset of symbols.
 type shard struct {
 	in      chan *Cmd
 	books   map[string]*OrderBook   // symbol -> orderbook (owned)
-	orders  map[string]*model.Order // orderID -> order (owned)
+	orders  map[string]*model.Order // orderID -> order (owned): open ones and the last doneKeep filled
+	done    []string                // filled orders still in orders, oldest first
+	ids     atomic.Uint64           // last order ID sequence number given out, see Router.NewOrderID
 	bufSize int
+	roll    int64                       // session start, ms past midnight UTC
+	bus     *events.Bus                 // optional sink for engine events
+	fees    Fees                        // optional
+	matcher func(symbol string) Matcher // optional, per new book
+	spreads map[string][]Spread         // symbol -> spreads it is or is a leg of
+	links   map[string]*spreadLink      // spread symbol -> its linked books
+	clients map[clientKey]*clientEntry  // client order IDs homed on this shard, see clientids.go
+	clientQ []*clientEntry              // clients' entries, oldest first
 	quit    chan struct{}
 }
 
 // newShard creates and starts a shard loop.
-func newShard(bufSize int) *shard {
+func newShard(bufSize int, bus *events.Bus, fees Fees, matcher func(string) Matcher, spreads []Spread, roll time.Duration) *shard {
 	s := &shard{
 		in:      make(chan *Cmd, bufSize),
 		books:   make(map[string]*OrderBook),
 		orders:  make(map[string]*model.Order),
 		bufSize: bufSize,
+		roll:    roll.Milliseconds(),
+		bus:     bus,
+		fees:    fees,
+		matcher: matcher,
+		spreads: make(map[string][]Spread),
+		links:   make(map[string]*spreadLink),
+		clients: make(map[clientKey]*clientEntry),
 		quit:    make(chan struct{}),
 	}
+	for _, sp := range spreads {
+		s.spreads[sp.Symbol] = append(s.spreads[sp.Symbol], sp)
+		for _, l := range sp.Legs {
+			s.spreads[l.Symbol] = append(s.spreads[l.Symbol], sp)
+		}
+	}
 	go s.loop()
 	return s
 }
@@ -90,6 +152,20 @@ func (s *shard) loop() {
 				s.handleGet(cmd)
 			case CmdGetBook:
 				s.handleGetBook(cmd)
+			case CmdGetTrades:
+				s.handleGetTrades(cmd)
+			case CmdGetTicker:
+				s.handleGetTicker(cmd)
+			case CmdAmend:
+				s.handleAmend(cmd)
+			case CmdSubmitBatch:
+				s.handleSubmitBatch(cmd)
+			case CmdCancelBatch:
+				s.handleCancelBatch(cmd)
+			case CmdGetClients:
+				s.handleGetClients(cmd)
+			case CmdRemember:
+				s.handleRemember(cmd)
 			}
 		case <-s.quit:
 			return
@@ -105,34 +181,85 @@ func (s *shard) getOrCreateBook(symbol string) *OrderBook {
 	ob, ok := s.books[symbol]
 	if !ok {
 		ob = NewOrderBook(symbol)
+		ob.fees = s.fees
+		ob.stats.roll = s.roll
+		if s.matcher != nil {
+			ob.matcher = s.matcher(symbol)
+		}
+		ob.publish = s.observe
 		s.books[symbol] = ob
+		s.link(symbol)
 	}
 	return ob
 }
 
+// observe keeps the shard's orders in step with a book's event and hands
+// the event on to the bus. A filled order stays for lookups until doneKeep
+// more have filled; a refused one is forgotten at once.
+func (s *shard) observe(ev events.Event) {
+	if o := ev.Order; o != nil {
+		switch {
+		case ev.Type == events.OrderFilled && o.Remaining() == 0:
+			if _, ok := s.orders[o.ID]; ok {
+				s.done = append(s.done, o.ID)
+			}
+			if len(s.done) > doneKeep {
+				delete(s.orders, s.done[0])
+				s.done = s.done[1:]
+			}
+		case ev.Type == events.OrderRejected:
+			delete(s.orders, o.ID)
+		}
+	}
+	if s.bus != nil {
+		s.bus.Publish(ev)
+	}
+}
+
+// link creates the books of the spreads symbol belongs to and links them.
+func (s *shard) link(symbol string) {
+	for _, sp := range s.spreads[symbol] {
+		if _, ok := s.links[sp.Symbol]; ok {
+			continue
+		}
+		l := &spreadLink{spread: sp}
+		s.links[sp.Symbol] = l
+		l.book = s.getOrCreateBook(sp.Symbol)
+		for i, leg := range sp.Legs {
+			l.legs[i] = s.getOrCreateBook(leg.Symbol)
+		}
+		for _, ob := range l.books() {
+			ob.links = append(ob.links, l)
+		}
+	}
+}
+
 func (s *shard) handleSubmit(cmd *Cmd) {
-	o := cmd.Order
+	cmd.Reply <- s.submit(cmd.Order)
+}
+
+// submit processes one order and builds its result.
+func (s *shard) submit(o *model.Order) SubmitResult {
 	ob := s.getOrCreateBook(o.Symbol)
+	terms := *o
 
 	// Process order inside the shard (serial)
 	trades, err := ob.ProcessOrder(o)
 	if err != nil {
 		// market rejection etc.
-		res := SubmitResult{Err: err.Error()}
-		cmd.Reply <- res
-		return
+		return SubmitResult{Err: err.Error()}
 	}
 
-	// If limit order with remaining quantity, store it in shard state
-	if o.Type == model.LIMIT && o.Quantity > 0 {
+	// If the order rests with remaining quantity, store it in shard state
+	if o.CanRest() && o.Remaining() > 0 {
 		s.orders[o.ID] = o
 	}
 
 	// Build status semantics used by API: 201, 200, 202
 	status := 201
-	if o.Filled > 0 && o.Quantity == 0 {
+	if o.Filled > 0 && o.Remaining() == 0 {
 		status = 200
-	} else if o.Filled > 0 && o.Quantity > 0 {
+	} else if o.Filled > 0 {
 		status = 202
 	}
 
@@ -141,26 +268,41 @@ func (s *shard) handleSubmit(cmd *Cmd) {
 		Trades:     trades,
 		StatusCode: status,
 	}
+	if o.ClientOrderID != "" {
+		res.client = newClientEntry(&terms, res)
+	}
 
 	// instrumentation: count this submit (regardless of trade/remaining)
 	metrics.AddOrdersProcessed(1)
 
-	cmd.Reply <- res
+	return res
 }
 
 func (s *shard) handleCancel(cmd *Cmd) {
-	id := cmd.OrderID
+	cmd.Reply <- s.cancel(cmd.OrderID, "")
+}
+
+// cancellable returns the order id names if account, unless empty, may
+// cancel it, or why not.
+func (s *shard) cancellable(id, account string) (*model.Order, string) {
 	o, ok := s.orders[id]
-	if !ok {
+	if !ok || (account != "" && o.Account != account) {
 		// either not found or already filled/removed
-		cmd.Reply <- CancelResult{OK: false, Err: "order not found"}
-		return
+		return nil, "order not found"
 	}
 
 	// If fully filled
 	if o.Filled >= o.Quantity {
-		cmd.Reply <- CancelResult{OK: false, Err: "cannot cancel a fully filled order"}
-		return
+		return nil, "cannot cancel a fully filled order"
+	}
+	return o, ""
+}
+
+// cancel cancels order id on behalf of account, unless empty.
+func (s *shard) cancel(id, account string) CancelResult {
+	o, msg := s.cancellable(id, account)
+	if o == nil {
+		return CancelResult{OK: false, Err: msg}
 	}
 
 	// Remove from shard orders map
@@ -168,33 +310,30 @@ func (s *shard) handleCancel(cmd *Cmd) {
 
 	// Remove from orderbook price level
 	ob := s.getOrCreateBook(o.Symbol)
-	var sideMap map[int64]*PriceLevel
-	if o.Side == model.BUY {
-		sideMap = ob.Bids
-	} else {
-		sideMap = ob.Asks
-	}
+	ob.cancel(o)
 
-	if level, ok := sideMap[o.Price]; ok {
-		// rebuild slice without the cancelled order
-		newOrders := level.Orders[:0]
-		for _, ord := range level.Orders {
-			if ord.ID == o.ID {
-				continue
-			}
-			newOrders = append(newOrders, ord)
-		}
-		level.Orders = newOrders
-		if len(level.Orders) == 0 {
-			if o.Side == model.BUY {
-				delete(ob.Bids, o.Price)
-			} else {
-				delete(ob.Asks, o.Price)
-			}
-		}
+	return CancelResult{OK: true}
+}
+
+func (s *shard) handleAmend(cmd *Cmd) {
+	o, ok := s.orders[cmd.OrderID]
+	if !ok {
+		cmd.Reply <- AmendResult{Err: "order not found"}
+		return
+	}
+	if o.Filled >= o.Quantity {
+		cmd.Reply <- AmendResult{Err: "cannot amend a fully filled order"}
+		return
 	}
 
-	cmd.Reply <- CancelResult{OK: true}
+	ob := s.getOrCreateBook(o.Symbol)
+	trades, err := ob.amend(o, cmd.Price, cmd.Quantity)
+	if err != nil {
+		cmd.Reply <- AmendResult{Err: err.Error()}
+		return
+	}
+	cp := *o
+	cmd.Reply <- AmendResult{Order: &cp, Trades: trades}
 }
 
 func (s *shard) handleGet(cmd *Cmd) {
@@ -204,7 +343,8 @@ func (s *shard) handleGet(cmd *Cmd) {
 		cmd.Reply <- GetResult{Err: "order not found"}
 		return
 	}
-	cmd.Reply <- GetResult{Order: o}
+	cp := *o
+	cmd.Reply <- GetResult{Order: &cp}
 }
 
 func (s *shard) handleGetBook(cmd *Cmd) {
@@ -215,12 +355,39 @@ func (s *shard) handleGetBook(cmd *Cmd) {
 	}
 	snap := BookSnapshot{
 		Symbol: cmd.Symbol,
+		Seq:    ob.seq,
 		Bids:   aggregate(ob.Bids, depth, false),
 		Asks:   aggregate(ob.Asks, depth, true),
 	}
+	snap.ImpliedBids, snap.ImpliedAsks = ob.impliedQuotes()
 	cmd.Reply <- snap
 }
 
+func (s *shard) handleGetTrades(cmd *Cmd) {
+	var trades []model.Trade
+	if ob, ok := s.books[cmd.Symbol]; ok {
+		trades = ob.tape.last(cmd.Depth)
+	}
+	cmd.Reply <- trades
+}
+
+// handleGetTicker replies with the tickers of cmd.Symbol, or of every book
+// this shard owns when cmd.Symbol is empty.
+func (s *shard) handleGetTicker(cmd *Cmd) {
+	now := time.Now().UnixMilli()
+	var out []Ticker
+	if cmd.Symbol != "" {
+		if ob, ok := s.books[cmd.Symbol]; ok {
+			out = append(out, ob.ticker(now))
+		}
+	} else {
+		for _, ob := range s.books {
+			out = append(out, ob.ticker(now))
+		}
+	}
+	cmd.Reply <- out
+}
+
 // small helper for debugging
 func (s *shard) String() string {
 	return fmt.Sprintf("shard{books=%d,orders=%d}", len(s.books), len(s.orders))
@@ -234,10 +401,12 @@ func aggregate(side map[int64]*PriceLevel, depth int, asc bool) []map[string]int
 		return []map[string]interface{}{}
 	}
 
-	// collect prices
+	// collect prices, leaving out levels that show nothing
 	prices := make([]int64, 0, len(side))
-	for p := range side {
-		prices = append(prices, p)
+	for p, level := range side {
+		if level.displayed() > 0 {
+			prices = append(prices, p)
+		}
 	}
 
 	// sort prices
@@ -253,14 +422,9 @@ func aggregate(side map[int64]*PriceLevel, depth int, asc bool) []map[string]int
 		if len(out) >= depth {
 			break
 		}
-		level := side[p]
-		total := int64(0)
-		for _, o := range level.Orders {
-			total += (o.Quantity - o.Filled)
-		}
 		out = append(out, map[string]interface{}{
 			"price":    p,
-			"quantity": total,
+			"quantity": side[p].displayed(),
 		})
 	}
 	return out
@@ -0,0 +1,470 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Spreads.
+//
+// A Spread is an instrument made of two outright legs. Buying one unit buys
+// Ratio of a leg with a positive ratio and sells -Ratio of a leg with a
+// negative one, and its price is the sum of each leg's Ratio times its
+// price: a calendar spread (near 1, far -1) is priced near minus far, and
+// may be zero or negative.
+//
+// The spread has its own book, where spread orders match each other, and
+// the books of its legs add implied liquidity to it and to each other:
+//
+//   - implied in: the best orders of both legs combine into a spread price
+//     an incoming spread order can trade at;
+//   - implied out: resting spread orders and the best orders of one leg
+//     combine into a price in the other leg an incoming outright order can
+//     trade at.
+//
+// At each step an incoming order takes the better of its book's best price
+// and the best implied price, its own book first at equal prices. Implied
+// liquidity is built from displayed orders without execution conditions at
+// the best price of each book, and only incoming plain limit orders trade
+// with it. An implied trade prints on the legs' tapes; the spread orders in
+// it are filled in spread units at the spread price it gives them.
+//
+// Implied matching needs every book involved in one place. The Router
+// routes all symbols of a spread, and of any spreads sharing legs with it,
+// by one group key, so a spread and its legs always live on the same shard
+// and an implied trade is one command on one shard, with nothing to
+// coordinate across shards. The grouping is fixed when the Router is built.
+
+// Leg is one outright of a Spread.
+type Leg struct {
+	Symbol string
+	Ratio  int64 // per spread unit; negative: sold when the spread is bought
+}
+
+// Spread is a two-legged instrument.
+type Spread struct {
+	Symbol string
+	Legs   [2]Leg
+}
+
+var errSpreadOrder = errors.New("spread orders must be plain limit orders")
+
+// WithSpreads lists spread instruments. Their definitions must be valid as
+// ParseSpreads checks them; NewRouter panics otherwise.
+func WithSpreads(spreads ...Spread) Option {
+	return func(r *Router) { r.spreads = append(r.spreads, spreads...) }
+}
+
+// ParseSpreads parses spreads given as "SYMBOL=LEG:RATIO,LEG:RATIO"
+// separated by semicolons, for example "ESZ4-ESH5=ESZ4:1,ESH5:-1".
+func ParseSpreads(s string) ([]Spread, error) {
+	var out []Spread
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		symbol, spec, _ := strings.Cut(item, "=")
+		legs := strings.Split(spec, ",")
+		if symbol == "" || len(legs) != 2 {
+			return nil, fmt.Errorf("spread %q: want SYMBOL=LEG:RATIO,LEG:RATIO", item)
+		}
+		sp := Spread{Symbol: symbol}
+		for i, leg := range legs {
+			sym, ratio, _ := strings.Cut(leg, ":")
+			n, err := strconv.ParseInt(ratio, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("spread %s: leg %q: want LEG:RATIO", symbol, leg)
+			}
+			sp.Legs[i] = Leg{Symbol: sym, Ratio: n}
+		}
+		out = append(out, sp)
+	}
+	return out, checkSpreads(out)
+}
+
+// checkSpreads validates spread definitions together.
+func checkSpreads(spreads []Spread) error {
+	isSpread := make(map[string]bool)
+	for _, sp := range spreads {
+		if sp.Symbol == "" || isSpread[sp.Symbol] {
+			return fmt.Errorf("spread %q: missing or listed twice", sp.Symbol)
+		}
+		isSpread[sp.Symbol] = true
+	}
+	for _, sp := range spreads {
+		if sp.Legs[0].Symbol == sp.Legs[1].Symbol {
+			return fmt.Errorf("spread %s: legs must differ", sp.Symbol)
+		}
+		for _, l := range sp.Legs {
+			switch {
+			case l.Symbol == "" || l.Ratio == 0:
+				return fmt.Errorf("spread %s: legs need a symbol and a non-zero ratio", sp.Symbol)
+			case isSpread[l.Symbol]:
+				return fmt.Errorf("spread %s: leg %s is itself a spread", sp.Symbol, l.Symbol)
+			}
+		}
+	}
+	return nil
+}
+
+// spreadGroups maps every symbol of every spread to its routing key: the
+// smallest symbol among the spreads and legs connected to it.
+func spreadGroups(spreads []Spread) map[string]string {
+	parent := make(map[string]string)
+	var find func(s string) string
+	find = func(s string) string {
+		p, ok := parent[s]
+		if !ok || p == s {
+			parent[s] = s
+			return s
+		}
+		root := find(p)
+		parent[s] = root
+		return root
+	}
+	for _, sp := range spreads {
+		for _, l := range sp.Legs {
+			a, b := find(sp.Symbol), find(l.Symbol)
+			if b < a {
+				a, b = b, a
+			}
+			parent[b] = a
+		}
+	}
+	out := make(map[string]string, len(parent))
+	for s := range parent {
+		out[s] = find(s)
+	}
+	return out
+}
+
+// spreadLink ties a spread's book to its legs' books on one shard.
+type spreadLink struct {
+	spread Spread
+	book   *OrderBook
+	legs   [2]*OrderBook
+}
+
+func (l *spreadLink) books() [3]*OrderBook {
+	return [3]*OrderBook{l.book, l.legs[0], l.legs[1]}
+}
+
+// isSpread reports whether ob is a spread's book.
+func (ob *OrderBook) isSpread() bool {
+	for _, l := range ob.links {
+		if l.book == ob {
+			return true
+		}
+	}
+	return false
+}
+
+// spreadable reports whether o may be entered in a spread's book.
+func spreadable(o *model.Order) bool {
+	return o.Type == model.LIMIT && !o.Hidden && !conditional(o)
+}
+
+// impliable reports whether incoming o may trade with implied liquidity.
+func impliable(o *model.Order) bool {
+	return o.Type == model.LIMIT && !conditional(o) && !o.Triggered
+}
+
+func opposite(side model.Side) model.Side {
+	if side == model.BUY {
+		return model.SELL
+	}
+	return model.BUY
+}
+
+// legSide returns the side a leg of ratio trades when its spread trades side.
+func legSide(side model.Side, ratio int64) model.Side {
+	if ratio > 0 {
+		return side
+	}
+	return opposite(side)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// better reports whether price a is better than b for an order on side.
+func better(side model.Side, a, b int64) bool {
+	if side == model.BUY {
+		return a < b
+	}
+	return a > b
+}
+
+// impliedTop returns the best price resting on side of ob that has
+// displayed orders without conditions, and their quantity there.
+func (ob *OrderBook) impliedTop(side model.Side) (price, qty int64, ok bool) {
+	sideMap := ob.Bids
+	if side == model.SELL {
+		sideMap = ob.Asks
+	}
+	for p, level := range sideMap {
+		if ok && !better(opposite(side), p, price) {
+			continue
+		}
+		q := int64(0)
+		for _, o := range level.Orders[:level.shown()] {
+			if !conditional(o) {
+				q += o.Remaining()
+			}
+		}
+		if q > 0 {
+			price, qty, ok = p, q, true
+		}
+	}
+	return price, qty, ok
+}
+
+// impliedTrade is one implied execution open to an incoming order.
+type impliedTrade struct {
+	link  *spreadLink
+	price int64 // in the incoming order's book
+	qty   int64 // spread units
+	size  int64 // in the incoming order's book's units
+
+	legPrices   [2]int64 // implied in: where each leg trades
+	spreadPrice int64    // implied out: the resting spread orders' level
+	otherPrice  int64    // implied out: where the other leg trades
+	leg         int      // implied out: the incoming order's leg
+}
+
+// bestImplied returns the best implied execution o could make now.
+func (ob *OrderBook) bestImplied(o *model.Order) (best impliedTrade, ok bool) {
+	for _, l := range ob.links {
+		c, found := ob.impliedVia(l, o)
+		if found && crosses(o, c.price) && (!ok || better(o.Side, c.price, best.price)) {
+			best, ok = c, true
+		}
+	}
+	return best, ok
+}
+
+// impliedVia prices the implied execution open to o through l.
+func (ob *OrderBook) impliedVia(l *spreadLink, o *model.Order) (impliedTrade, bool) {
+	c := impliedTrade{link: l}
+	legs := l.spread.Legs
+	if l.book == ob {
+		// implied in: take the best of both legs
+		c.qty = o.Remaining()
+		for i, leg := range legs {
+			p, q, ok := l.legs[i].impliedTop(opposite(legSide(o.Side, leg.Ratio)))
+			if !ok {
+				return c, false
+			}
+			c.legPrices[i] = p
+			c.price += leg.Ratio * p
+			c.qty = min(c.qty, q/abs(leg.Ratio))
+		}
+		c.size = c.qty
+		return c, c.qty > 0
+	}
+
+	// implied out: resting spread orders trading this leg against o, and
+	// the best of the other leg
+	j := 0
+	if l.legs[1] == ob {
+		j = 1
+	}
+	k := 1 - j
+	spreadSide := opposite(o.Side)
+	if legs[j].Ratio < 0 {
+		spreadSide = o.Side
+	}
+	sp, sq, ok := l.book.impliedTop(spreadSide)
+	if !ok {
+		return c, false
+	}
+	op, oq, ok := l.legs[k].impliedTop(opposite(legSide(spreadSide, legs[k].Ratio)))
+	if !ok {
+		return c, false
+	}
+	// legs[j].Ratio*price + legs[k].Ratio*op = sp, rounded in the resting
+	// spread orders' favour
+	num, den := sp-legs[k].Ratio*op, legs[j].Ratio
+	if den < 0 {
+		num, den = -num, -den
+	}
+	c.price = floorDiv(num, den)
+	if o.Side == model.BUY && c.price*den != num {
+		c.price++
+	}
+	c.leg, c.spreadPrice, c.otherPrice = j, sp, op
+	c.qty = min(o.Remaining()/abs(legs[j].Ratio), min(sq, oq/abs(legs[k].Ratio)))
+	c.size = c.qty * abs(legs[j].Ratio)
+	return c, c.qty > 0
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// matchWithImplied matches o against its book's levels and its spreads'
+// implied liquidity, best price first and its own book first at equal
+// prices.
+func (ob *OrderBook) matchWithImplied(o *model.Order) (trades []model.Trade) {
+	sideMap, asc := ob.Asks, true
+	if o.Side == model.SELL {
+		sideMap, asc = ob.Bids, false
+	}
+	prices := ob.sortedPrices(sideMap, asc)
+	for o.Remaining() > 0 {
+		direct := len(prices) > 0 && crosses(o, prices[0])
+		c, ok := ob.bestImplied(o)
+		switch {
+		case direct && (!ok || !better(o.Side, c.price, prices[0])):
+			trades = append(trades, ob.matchAt(o, prices[0])...)
+			prices = prices[1:]
+		case ok:
+			trades = append(trades, ob.fillImplied(o, c)...)
+		default:
+			return trades
+		}
+	}
+	return trades
+}
+
+// fillImplied executes c for o and returns o's trades.
+func (ob *OrderBook) fillImplied(o *model.Order, c impliedTrade) (trades []model.Trade) {
+	l, legs := c.link, c.link.spread.Legs
+	if l.book == ob {
+		fee := int64(0)
+		for i, leg := range legs {
+			t, f := l.legs[i].takeImplied(o, legSide(o.Side, leg.Ratio), c.legPrices[i], c.qty*abs(leg.Ratio))
+			trades = append(trades, t...)
+			fee += f
+		}
+		o.Filled += c.qty
+		ob.emitFill(o, c.price, c.qty, fee, false)
+		return trades
+	}
+
+	j, k := c.leg, 1-c.leg
+	spreadSide := opposite(o.Side)
+	if legs[j].Ratio < 0 {
+		spreadSide = o.Side
+	}
+	spreadPrice := legs[j].Ratio*c.price + legs[k].Ratio*c.otherPrice
+	l.book.shareImplied(spreadSide, c.spreadPrice, c.qty, func(m *model.Order, q int64) {
+		qj := q * abs(legs[j].Ratio)
+		t := ob.record(o, m, o.Side, c.price, qj)
+		o.Filled += qj
+		ob.emitFill(o, c.price, qj, t.TakerFee, false)
+		trades = append(trades, t)
+
+		// the spread order takes the other leg from its book
+		_, fee := l.legs[k].takeImplied(m, legSide(spreadSide, legs[k].Ratio), c.otherPrice, q*abs(legs[k].Ratio))
+		m.Filled += q
+		l.book.emitFill(m, spreadPrice, q, t.MakerFee+fee, true)
+	})
+	return trades
+}
+
+// takeImplied fills qty at price from the orders resting opposite side for
+// taker, an order of another book trading side here. The makers are filled
+// and reported; taker is not. It returns the trades and taker's fees.
+func (ob *OrderBook) takeImplied(taker *model.Order, side model.Side, price, qty int64) (trades []model.Trade, fee int64) {
+	ob.shareImplied(opposite(side), price, qty, func(m *model.Order, q int64) {
+		m.Filled += q
+		t := ob.record(taker, m, side, price, q)
+		ob.emitFill(m, price, q, t.MakerFee, true)
+		fee += t.TakerFee
+		trades = append(trades, t)
+	})
+	return trades, fee
+}
+
+// shareImplied shares qty among the displayed orders without conditions
+// resting at price on side, as the book's Matcher allocates and then in
+// time priority, calls fill for each share and tidies the level. fill must
+// fill the order. The orders there must hold at least qty.
+func (ob *OrderBook) shareImplied(side model.Side, price, qty int64, fill func(m *model.Order, q int64)) {
+	sideMap := ob.Bids
+	if side == model.SELL {
+		sideMap = ob.Asks
+	}
+	level := sideMap[price]
+	shown := level.displayed()
+
+	var makers []*model.Order
+	for _, o := range level.Orders[:level.shown()] {
+		if !conditional(o) {
+			makers = append(makers, o)
+		}
+	}
+	m := ob.matcher
+	if m == nil {
+		m = FIFO{}
+	}
+	alloc := make([]int64, len(makers))
+	left := qty
+	for i, q := range m.Allocate(makers, qty) {
+		if i < len(makers) {
+			alloc[i] = min(q, min(left, makers[i].Remaining()))
+			left -= alloc[i]
+		}
+	}
+	// an implied trade must fill every leg in full
+	for i, q := range fifoFill(makers, alloc, left) {
+		if q > 0 {
+			fill(makers[i], q)
+		}
+	}
+
+	kept := level.Orders[:0]
+	for _, o := range level.Orders {
+		if o.Remaining() > 0 {
+			kept = append(kept, o)
+		}
+	}
+	level.Orders = kept
+	if len(level.Orders) == 0 {
+		delete(sideMap, price)
+	}
+	if level.displayed() != shown {
+		ob.emitLevel(side, price)
+	}
+}
+
+// impliedQuotes returns the best implied bid and ask a new order in ob
+// could trade with, summarised like a snapshot's levels.
+func (ob *OrderBook) impliedQuotes() (bids, asks []map[string]interface{}) {
+	if len(ob.links) == 0 {
+		return nil, nil
+	}
+	const huge = math.MaxInt64 / 4
+	for _, side := range []model.Side{model.BUY, model.SELL} {
+		probe := &model.Order{Side: side, Type: model.LIMIT, Price: huge, Quantity: huge}
+		if side == model.SELL {
+			probe.Price = -huge
+		}
+		c, ok := ob.bestImplied(probe)
+		if !ok {
+			continue
+		}
+		level := []map[string]interface{}{{"price": c.price, "quantity": c.size}}
+		if side == model.BUY {
+			asks = level
+		} else {
+			bids = level
+		}
+	}
+	return bids, asks
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+var calendar = Spread{Symbol: "A-B", Legs: [2]Leg{{"A", 1}, {"B", -1}}}
+
+// linked builds the books of sp and links them as a shard would.
+func linked(sp Spread) *spreadLink {
+	l := &spreadLink{spread: sp, book: NewOrderBook(sp.Symbol)}
+	for i, leg := range sp.Legs {
+		l.legs[i] = NewOrderBook(leg.Symbol)
+	}
+	for _, ob := range l.books() {
+		ob.links = append(ob.links, l)
+	}
+	return l
+}
+
+func on(ob *OrderBook, o *model.Order) *model.Order {
+	o.Symbol = ob.Symbol
+	return o
+}
+
+func TestImpliedInAfterDirectSpread(t *testing.T) {
+	l := linked(calendar)
+	a, b := l.legs[0], l.legs[1]
+	a.ProcessOrder(on(a, limitOrder("a-ask", model.SELL, 100, 5)))
+	b.ProcessOrder(on(b, limitOrder("b-bid", model.BUY, 90, 3)))
+	l.book.ProcessOrder(on(l.book, limitOrder("s-ask", model.SELL, 10, 1)))
+
+	if bids, asks := l.book.impliedQuotes(); len(bids) != 0 || len(asks) != 1 || asks[0]["price"] != int64(10) || asks[0]["quantity"] != int64(3) {
+		t.Fatalf("expected an implied ask of 3 at 10, got %v %v", bids, asks)
+	}
+
+	// the spread ask at 10 goes first, then 100 - 90 from the legs
+	buy := on(l.book, limitOrder("buy", model.BUY, 10, 5))
+	trades, _ := l.book.ProcessOrder(buy)
+	if buy.Filled != 4 || len(trades) != 3 || trades[0].Symbol != "A-B" {
+		t.Fatalf("expected 1 direct and 3 implied, filled %d trades %+v", buy.Filled, trades)
+	}
+	if trades[1].Symbol != "A" || trades[1].Price != 100 || trades[2].Symbol != "B" || trades[2].Price != 90 || trades[2].Quantity != 3 {
+		t.Fatalf("expected the legs to trade at 100 and 90, got %+v", trades[1:])
+	}
+	if q := queueAt(a, model.SELL, 100); len(q) != 1 || a.Asks[100].Orders[0].Remaining() != 2 || len(b.Bids) != 0 {
+		t.Fatal("expected the legs' orders filled by the implied trade")
+	}
+	if q := queueAt(l.book, model.BUY, 10); len(q) != 1 || buy.Remaining() != 1 {
+		t.Fatalf("expected the rest of the spread buy on its book, got %v", q)
+	}
+}
+
+func TestImpliedOutTradesRestingSpreads(t *testing.T) {
+	l := linked(calendar)
+	a, b := l.legs[0], l.legs[1]
+	var fills []events.Event
+	l.book.publish = func(ev events.Event) {
+		if ev.Type == events.OrderFilled {
+			fills = append(fills, ev)
+		}
+	}
+	bid := on(l.book, limitOrder("s-bid", model.BUY, 10, 2))
+	l.book.ProcessOrder(bid)
+	b.ProcessOrder(on(b, limitOrder("b-bid", model.BUY, 90, 5)))
+
+	// the spread buyer sells B at 90, so it buys A up to 100
+	if _, asks := a.impliedQuotes(); len(asks) != 0 {
+		t.Fatalf("a spread bid implies no ask in A, got %v", asks)
+	}
+	if bids, _ := a.impliedQuotes(); len(bids) != 1 || bids[0]["price"] != int64(100) || bids[0]["quantity"] != int64(2) {
+		t.Fatalf("expected an implied bid of 2 at 100 in A, got %v", bids)
+	}
+
+	sell := on(a, limitOrder("a-sell", model.SELL, 100, 3))
+	trades, _ := a.ProcessOrder(sell)
+	if len(trades) != 1 || trades[0].Price != 100 || trades[0].Quantity != 2 || trades[0].MakerOrderID != "s-bid" {
+		t.Fatalf("expected 2 of A to the spread bid at 100, got %+v", trades)
+	}
+	if bid.Filled != 2 || len(l.book.Bids) != 0 || b.Bids[90].Orders[0].Remaining() != 3 {
+		t.Fatalf("expected the spread bid filled by selling 2 of B, got %+v", bid)
+	}
+	if len(fills) != 1 || fills[0].FillPrice != 10 || fills[0].FillQty != 2 || !fills[0].Maker {
+		t.Fatalf("expected the spread bid filled 2 at 10, got %+v", fills)
+	}
+	if q := queueAt(a, model.SELL, 100); len(q) != 1 || sell.Remaining() != 1 {
+		t.Fatalf("expected the rest of the sell resting in A, got %v", q)
+	}
+}
+
+func TestImpliedOutRoundsForTheSpread(t *testing.T) {
+	l := linked(Spread{Symbol: "2A-B", Legs: [2]Leg{{"A", 2}, {"B", -1}}})
+	a, b := l.legs[0], l.legs[1]
+	var fill int64
+	l.book.publish = func(ev events.Event) {
+		if ev.Type == events.OrderFilled {
+			fill = ev.FillPrice
+		}
+	}
+	l.book.ProcessOrder(on(l.book, limitOrder("s-bid", model.BUY, 11, 1)))
+	b.ProcessOrder(on(b, limitOrder("b-bid", model.BUY, 90, 5)))
+
+	// 2A - 90 <= 11 holds up to A = 50.5: a seller of A gets 50, and only
+	// whole spreads trade
+	if trades, _ := a.ProcessOrder(on(a, limitOrder("a-51", model.SELL, 51, 3))); len(trades) != 0 {
+		t.Fatalf("51 is above the implied bid, got %+v", trades)
+	}
+	sell := on(a, limitOrder("a-50", model.SELL, 50, 3))
+	trades, _ := a.ProcessOrder(sell)
+	if len(trades) != 1 || trades[0].Price != 50 || trades[0].Quantity != 2 || sell.Remaining() != 1 {
+		t.Fatalf("expected 2 of A at 50, got %+v", trades)
+	}
+	if fill != 10 {
+		t.Fatalf("expected the spread bid to pay 2*50-90 = 10, got %d", fill)
+	}
+}
+
+func TestSpreadBookTakesPlainLimitsOnly(t *testing.T) {
+	l := linked(calendar)
+	if _, err := l.book.ProcessOrder(on(l.book, &model.Order{ID: "m", Side: model.BUY, Type: model.MARKET, Quantity: 1})); err != errSpreadOrder {
+		t.Fatalf("expected a market spread order rejected, got %v", err)
+	}
+	hidden := on(l.book, limitOrder("h", model.BUY, -5, 1))
+	hidden.Hidden = true
+	if _, err := l.book.ProcessOrder(hidden); err != errSpreadOrder {
+		t.Fatalf("expected a hidden spread order rejected, got %v", err)
+	}
+	neg := on(l.book, limitOrder("n", model.BUY, -5, 1))
+	if _, err := l.book.ProcessOrder(neg); err != nil || len(queueAt(l.book, model.BUY, -5)) != 1 {
+		t.Fatalf("expected a bid at -5 on the spread book, got %v", err)
+	}
+	if _, err := l.book.amend(neg, 0, 1); err != nil {
+		t.Fatalf("expected an amend to 0, got %v", err)
+	}
+}
+
+func TestParseSpreads(t *testing.T) {
+	sp, err := ParseSpreads("A-B=A:1,B:-1; 2A-C=A:2,C:-1")
+	if err != nil || len(sp) != 2 || sp[1].Legs[0] != (Leg{"A", 2}) || sp[1].Legs[1] != (Leg{"C", -1}) {
+		t.Fatalf("unexpected spreads %+v, %v", sp, err)
+	}
+	for _, bad := range []string{"A-B=A:1", "A-B=A:1,A:-1", "A-B=A:0,B:1", "A-B=A:1,B:x", "A-B=A:1,B:-1;X=A-B:1,C:1", "A-B=A:1,B:-1;A-B=A:1,C:-1"} {
+		if _, err := ParseSpreads(bad); err == nil {
+			t.Errorf("expected %q refused", bad)
+		}
+	}
+}
+
+func TestRouterKeepsSpreadsWithTheirLegs(t *testing.T) {
+	r := NewRouter(16, 16, WithSpreads(calendar, Spread{Symbol: "B-C", Legs: [2]Leg{{"B", 1}, {"C", -1}}}))
+	defer r.Stop()
+	for _, sym := range []string{"A-B", "B", "B-C", "C"} {
+		if r.routeIdx(sym) != r.routeIdx("A") {
+			t.Fatalf("%s is not on the shard of A", sym)
+		}
+	}
+	if !r.IsSpread("B-C") || r.IsSpread("B") {
+		t.Fatal("IsSpread disagrees with the definitions")
+	}
+
+	r.SubmitOrder(&model.Order{ID: "a", Symbol: "A", Side: model.SELL, Type: model.LIMIT, Price: 95, Quantity: 1})
+	r.SubmitOrder(&model.Order{ID: "b", Symbol: "B", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 1})
+	if bk := r.GetOrderBook("A-B", 5); len(bk.ImpliedAsks) != 1 || bk.ImpliedAsks[0]["price"] != int64(-5) {
+		t.Fatalf("expected an implied ask at -5, got %+v", bk)
+	}
+	res := r.SubmitOrder(&model.Order{ID: "s", Symbol: "A-B", Side: model.BUY, Type: model.LIMIT, Price: -5, Quantity: 1})
+	if res.Err != "" || res.StatusCode != 200 || len(res.Trades) != 2 {
+		t.Fatalf("expected the spread filled from both legs, got %+v", res)
+	}
+	if tk, _ := r.GetTicker("B"); tk.LastPrice != 100 {
+		t.Fatalf("expected B's tape to show the leg trade, got %+v", tk)
+	}
+}
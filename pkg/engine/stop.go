@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Trailing stops.
+//
+// A TRAILING_STOP order waits off the book, not displayed. Its mark is the
+// best trade price since it arrived, the highest for a sell and the lowest
+// for a buy, and its StopPrice trails the mark by a fixed amount or a
+// percentage of it: below for a sell, above for a buy. The mark only moves
+// in the order's favour, so the stop only ever tightens.
+//
+// execute records the range of the prices that print during a command, which
+// costs nothing per trade; once the command is done, trail moves every stop
+// with that range in one pass and triggers those the last trade price has
+// reached, oldest first. A triggered stop becomes its TriggerType, is
+// reported as amended and enters the book like a new order. Its trades move
+// the price again, so triggering repeats until no stop fires.
+
+// trailingStop is a waiting TRAILING_STOP order and its mark.
+type trailingStop struct {
+	o    *model.Order
+	mark int64 // 0 until a trade prints
+}
+
+// setMark moves s to mark and recomputes its stop price.
+func (s *trailingStop) setMark(mark int64) {
+	s.mark = mark
+	trail := s.o.TrailAmount
+	if s.o.TrailPercent > 0 {
+		trail = int64(math.Round(float64(mark) * s.o.TrailPercent / 100))
+	}
+	if s.o.Side == model.SELL {
+		s.o.StopPrice = mark - trail
+	} else {
+		s.o.StopPrice = mark + trail
+	}
+}
+
+// follow moves s's mark to the favourable end of the prices from lo to hi
+// and reports whether last triggers it.
+func (s *trailingStop) follow(lo, hi, last int64) bool {
+	if s.o.Side == model.SELL {
+		if hi > s.mark {
+			s.setMark(hi)
+		}
+		return last <= s.o.StopPrice
+	}
+	if s.mark == 0 || lo < s.mark {
+		s.setMark(lo)
+	}
+	return last >= s.o.StopPrice
+}
+
+// print records a trade price for the stops.
+func (ob *OrderBook) print(price int64) {
+	if ob.printHi == 0 || price > ob.printHi {
+		ob.printHi = price
+	}
+	if ob.printLo == 0 || price < ob.printLo {
+		ob.printLo = price
+	}
+}
+
+// processStop enters a new trailing stop, marked at the last trade price.
+func (ob *OrderBook) processStop(o *model.Order) {
+	ob.emitOrder(events.OrderAccepted, o)
+	s := &trailingStop{o: o}
+	if last := ob.stats.last.Price; last > 0 {
+		s.setMark(last)
+	}
+	ob.stops = append(ob.stops, s)
+}
+
+// trail moves the stops with the prices printed since it last ran and
+// triggers those the last price has reached. It reports whether any fired.
+func (ob *OrderBook) trail() bool {
+	lo, hi := ob.printLo, ob.printHi
+	ob.printLo, ob.printHi = 0, 0
+	if hi == 0 || len(ob.stops) == 0 {
+		return false
+	}
+	last := ob.stats.last.Price
+
+	var fired []*model.Order
+	waiting := ob.stops[:0]
+	for _, s := range ob.stops {
+		if s.follow(lo, hi, last) {
+			fired = append(fired, s.o)
+			continue
+		}
+		waiting = append(waiting, s)
+	}
+	for i := len(waiting); i < len(ob.stops); i++ {
+		ob.stops[i] = nil
+	}
+	ob.stops = waiting
+
+	for _, o := range fired {
+		ob.trigger(o)
+	}
+	return len(fired) > 0
+}
+
+// trigger turns a fired stop into its TriggerType and enters it.
+func (ob *OrderBook) trigger(o *model.Order) {
+	o.Triggered = true
+	o.Type = model.MARKET
+	if o.TriggerType == model.LIMIT {
+		o.Type = model.LIMIT
+		o.Price = o.StopPrice + o.LimitOffset
+		if o.Side == model.SELL {
+			o.Price = o.StopPrice - o.LimitOffset
+		}
+		if o.Price <= 0 {
+			o.Price = 1
+		}
+	}
+	ob.emitOrder(events.OrderAmended, o)
+
+	if o.Type == model.MARKET {
+		if ob.fillable(o) < o.Remaining() {
+			ob.reject(o, errNoLiquidity)
+			return
+		}
+		ob.matchLimit(o)
+		return
+	}
+	ob.matchLimit(o)
+	if o.Remaining() > 0 {
+		ob.addToBook(o)
+	}
+}
+
+// dropStop forgets a waiting stop. It reports false if o is not one.
+func (ob *OrderBook) dropStop(o *model.Order) bool {
+	for i, s := range ob.stops {
+		if s.o == o {
+			ob.stops = append(ob.stops[:i], ob.stops[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// amendStop changes a waiting stop's quantity; its trigger follows the
+// trades.
+func (ob *OrderBook) amendStop(o *model.Order, qty int64) error {
+	for _, s := range ob.stops {
+		if s.o == o {
+			o.Quantity = qty
+			ob.emitOrder(events.OrderAmended, o)
+			return nil
+		}
+	}
+	return errNotResting
+}
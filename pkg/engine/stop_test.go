@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func stopOrder(id string, side model.Side, amount int64, percent float64, qty int64) *model.Order {
+	return &model.Order{ID: id, Symbol: "P", Side: side, Type: model.TRAILING_STOP, TrailAmount: amount, TrailPercent: percent, Quantity: qty}
+}
+
+// printAt makes a trade of 1 at price in an otherwise empty book.
+func printAt(ob *OrderBook, id string, price int64) {
+	ob.ProcessOrder(limitOrder(id+"s", model.SELL, price, 1))
+	ob.ProcessOrder(limitOrder(id+"b", model.BUY, price, 1))
+}
+
+func TestSellStopTrailsUpAndTriggers(t *testing.T) {
+	ob := NewOrderBook("P")
+	printAt(ob, "t1", 100)
+	stop := stopOrder("stop", model.SELL, 5, 0, 2)
+	ob.ProcessOrder(stop)
+	if stop.StopPrice != 95 || len(ob.Asks) != 0 {
+		t.Fatalf("expected a stop at 95 off the book, got %d", stop.StopPrice)
+	}
+
+	printAt(ob, "t2", 110)
+	printAt(ob, "t3", 107)
+	if stop.StopPrice != 105 || stop.Triggered {
+		t.Fatalf("expected the stop raised to 105 and left there, got %d", stop.StopPrice)
+	}
+
+	ob.ProcessOrder(limitOrder("bid", model.BUY, 104, 3))
+	ob.ProcessOrder(limitOrder("hit", model.SELL, 104, 1))
+	if !stop.Triggered || stop.Type != model.MARKET || stop.Filled != 2 {
+		t.Fatalf("expected the print at 104 to trigger a market sell of 2, got %+v", stop)
+	}
+}
+
+func TestBuyStopByPercentBecomesLimit(t *testing.T) {
+	ob := NewOrderBook("P")
+	printAt(ob, "t1", 1000)
+	stop := stopOrder("stop", model.BUY, 0, 1, 4)
+	stop.TriggerType, stop.LimitOffset = model.LIMIT, 5
+	ob.ProcessOrder(stop)
+	if stop.StopPrice != 1010 {
+		t.Fatalf("expected 1%% above 1000, got %d", stop.StopPrice)
+	}
+
+	printAt(ob, "t2", 900)
+	if stop.StopPrice != 909 {
+		t.Fatalf("expected the stop lowered to 909, got %d", stop.StopPrice)
+	}
+	printAt(ob, "t3", 920)
+	if !stop.Triggered || stop.Type != model.LIMIT || stop.Price != 914 {
+		t.Fatalf("expected a limit buy at 914, got %+v", stop)
+	}
+	if q := queueAt(ob, model.BUY, 914); len(q) != 1 || q[0] != "stop" {
+		t.Fatalf("expected the triggered stop resting at 914, got %v", q)
+	}
+}
+
+func TestTriggeredStopsCascade(t *testing.T) {
+	ob := NewOrderBook("P")
+	printAt(ob, "t", 100)
+	a := stopOrder("a", model.SELL, 2, 0, 1) // 98
+	b := stopOrder("b", model.SELL, 8, 0, 1) // 92
+	ob.ProcessOrder(a)
+	ob.ProcessOrder(b)
+	ob.ProcessOrder(limitOrder("b1", model.BUY, 97, 1))
+	ob.ProcessOrder(limitOrder("b2", model.BUY, 91, 1))
+	ob.ProcessOrder(limitOrder("b3", model.BUY, 90, 5))
+
+	// 97 triggers a, whose sale at 91 triggers b
+	trades, _ := ob.ProcessOrder(limitOrder("s", model.SELL, 97, 1))
+	if len(trades) != 1 {
+		t.Fatalf("the stops' trades are not the incoming order's, got %+v", trades)
+	}
+	if a.Filled != 1 || b.Filled != 1 || len(ob.stops) != 0 {
+		t.Fatalf("expected both stops to fire, a %+v b %+v", a, b)
+	}
+	if q := queueAt(ob, model.BUY, 90); len(q) != 1 || ob.Bids[90].Orders[0].Remaining() != 4 {
+		t.Fatalf("expected b to sell 1 at 90, bids at 90 %v", q)
+	}
+}
+
+func TestRouterTrailingStop(t *testing.T) {
+	r := NewRouter(1, 16)
+	defer r.Stop()
+	r.SubmitOrder(&model.Order{ID: "s", Symbol: "P", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 1})
+	r.SubmitOrder(&model.Order{ID: "b", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 1})
+	r.SubmitOrder(&model.Order{ID: "stop", Symbol: "P", Side: model.SELL, Type: model.TRAILING_STOP, TrailPercent: 10, Quantity: 2})
+
+	if got := r.GetOrder("P", "stop"); got.Err != "" || got.Order.StopPrice != 90 {
+		t.Fatalf("expected the trigger at 90, got %+v", got)
+	}
+	r.SubmitOrder(&model.Order{ID: "s2", Symbol: "P", Side: model.SELL, Type: model.LIMIT, Price: 120, Quantity: 1})
+	r.SubmitOrder(&model.Order{ID: "b2", Symbol: "P", Side: model.BUY, Type: model.LIMIT, Price: 120, Quantity: 1})
+	if got := r.GetOrder("P", "stop"); got.Order.StopPrice != 108 {
+		t.Fatalf("expected the trigger raised to 108, got %+v", got.Order)
+	}
+	if res := r.AmendOrder("P", "stop", 0, 3); res.Err != "" || res.Order.Quantity != 3 {
+		t.Fatalf("expected a quantity-only amend, got %+v", res)
+	}
+	if res := r.CancelOrder("P", "stop"); !res.OK {
+		t.Fatal(res.Err)
+	}
+}
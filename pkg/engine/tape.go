@@ -0,0 +1,40 @@
+package engine
+
+import "github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+
+// tapeSize is how many recent trades each book keeps.
+const tapeSize = 1000
+
+// tradeRing is a fixed-size ring of the most recent trades of one symbol.
+type tradeRing struct {
+	buf  []model.Trade
+	next int // slot the next trade goes to
+	n    int // number of valid entries
+}
+
+func newTradeRing(size int) *tradeRing {
+	return &tradeRing{buf: make([]model.Trade, size)}
+}
+
+func (r *tradeRing) add(t model.Trade) {
+	r.buf[r.next] = t
+	r.next = (r.next + 1) % len(r.buf)
+	if r.n < len(r.buf) {
+		r.n++
+	}
+}
+
+// last returns up to limit trades, newest first, with order IDs stripped
+// so the result can be published as-is.
+func (r *tradeRing) last(limit int) []model.Trade {
+	if limit <= 0 || limit > r.n {
+		limit = r.n
+	}
+	out := make([]model.Trade, 0, limit)
+	for i := 1; i <= limit; i++ {
+		t := r.buf[(r.next-i+len(r.buf))%len(r.buf)]
+		t.MakerOrderID, t.TakerOrderID = "", ""
+		out = append(out, t)
+	}
+	return out
+}
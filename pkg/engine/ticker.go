@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// WithSessionRoll starts each day's trading session at offset at past
+// midnight UTC instead of at midnight. The tickers' open, high and low
+// cover the current session only.
+func WithSessionRoll(at time.Duration) Option {
+	return func(r *Router) { r.roll = at }
+}
+
+// Ticker is the level-1 summary of one symbol.
+// Prices are integer cents; zero means "none yet". Open, high and low are
+// zero until the session's first trade.
+type Ticker struct {
+	Symbol    string  `json:"symbol"`
+	BidPrice  int64   `json:"bid_price"`
+	BidSize   int64   `json:"bid_size"`
+	AskPrice  int64   `json:"ask_price"`
+	AskSize   int64   `json:"ask_size"`
+	LastPrice int64   `json:"last_price"`
+	LastSize  int64   `json:"last_size"`
+	LastTime  int64   `json:"last_time"` // unix ms
+	Open      int64   `json:"open"`      // first trade of the session, see WithSessionRoll
+	High      int64   `json:"high"`
+	Low       int64   `json:"low"`
+	Volume24h int64   `json:"volume_24h"`
+	VWAP24h   float64 `json:"vwap_24h"` // cents
+}
+
+const (
+	bucketMs   = 60 * 1000 // one bucket per minute
+	windowMins = 24 * 60
+	dayMs      = windowMins * bucketMs
+)
+
+// volBucket accumulates one minute of trading.
+type volBucket struct {
+	minute   int64 // unix minute this bucket holds; stale buckets are reset
+	volume   int64
+	notional int64 // sum(price*qty), cents
+}
+
+// l1Stats keeps the trade-driven part of the ticker. The 24h figures come
+// from a ring of per-minute buckets so updates and reads stay O(1)/O(1440)
+// regardless of trade count.
+type l1Stats struct {
+	last    model.Trade
+	roll    int64 // ms past midnight UTC at which a session starts
+	session int64 // session of the last trade, see sessionOf
+	open    int64
+	high    int64
+	low     int64
+	buckets [windowMins]volBucket
+}
+
+// sessionOf returns the number of the session holding unix ms ms.
+func (st *l1Stats) sessionOf(ms int64) int64 {
+	return (ms - st.roll) / dayMs
+}
+
+func (st *l1Stats) onTrade(t model.Trade) {
+	// the first trade of the book or of a new session opens it; prices may
+	// be zero or negative on spreads
+	session := st.sessionOf(t.Timestamp)
+	first := st.last.Quantity == 0 || session != st.session
+	st.last, st.session = t, session
+	if first {
+		st.open, st.high, st.low = t.Price, t.Price, t.Price
+	}
+	if t.Price > st.high {
+		st.high = t.Price
+	}
+	if t.Price < st.low {
+		st.low = t.Price
+	}
+
+	minute := t.Timestamp / bucketMs
+	b := &st.buckets[minute%windowMins]
+	if b.minute != minute {
+		*b = volBucket{minute: minute}
+	}
+	b.volume += t.Quantity
+	b.notional += t.Price * t.Quantity
+}
+
+// rolling returns volume and VWAP over the 24h ending at nowMs.
+func (st *l1Stats) rolling(nowMs int64) (volume int64, vwap float64) {
+	now := nowMs / bucketMs
+	var notional int64
+	for i := range st.buckets {
+		b := &st.buckets[i]
+		if b.minute > now-windowMins && b.minute <= now {
+			volume += b.volume
+			notional += b.notional
+		}
+	}
+	if volume > 0 {
+		vwap = float64(notional) / float64(volume)
+	}
+	return volume, vwap
+}
+
+// ticker builds the L1 view of ob at nowMs.
+func (ob *OrderBook) ticker(nowMs int64) Ticker {
+	t := Ticker{
+		Symbol:    ob.Symbol,
+		LastPrice: ob.stats.last.Price,
+		LastSize:  ob.stats.last.Quantity,
+		LastTime:  ob.stats.last.Timestamp,
+	}
+	if ob.stats.last.Quantity > 0 && ob.stats.session == ob.stats.sessionOf(nowMs) {
+		t.Open, t.High, t.Low = ob.stats.open, ob.stats.high, ob.stats.low
+	}
+	t.BidPrice, t.BidSize = bestLevel(ob.Bids, false)
+	t.AskPrice, t.AskSize = bestLevel(ob.Asks, true)
+	t.Volume24h, t.VWAP24h = ob.stats.rolling(nowMs)
+	return t
+}
+
+// bestLevel returns the top displayed price of a side and the quantity
+// shown there.
+func bestLevel(side map[int64]*PriceLevel, asc bool) (price, size int64) {
+	for p, level := range side {
+		if size != 0 && ((asc && p >= price) || (!asc && p <= price)) {
+			continue
+		}
+		if q := level.displayed(); q > 0 {
+			price, size = p, q
+		}
+	}
+	return price, size
+}
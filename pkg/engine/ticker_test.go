@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func TestL1StatsRollingWindow(t *testing.T) {
+	day := int64(24 * 60 * 60 * 1000)
+	t0 := int64(1_700_000_000_000) // 22:13 UTC
+	st := l1Stats{roll: 22 * 60 * 60 * 1000}
+
+	st.onTrade(model.Trade{Price: 100, Quantity: 10, Timestamp: t0})
+	st.onTrade(model.Trade{Price: 110, Quantity: 30, Timestamp: t0 + 60_000})
+	st.onTrade(model.Trade{Price: 90, Quantity: 10, Timestamp: t0 + day/2})
+
+	if st.open != 100 || st.high != 110 || st.low != 90 || st.last.Price != 90 {
+		t.Fatalf("unexpected session stats %+v", st)
+	}
+
+	vol, vwap := st.rolling(t0 + day/2)
+	if vol != 50 || vwap != float64(100*10+110*30+90*10)/50 {
+		t.Fatalf("expected vol 50 vwap 104, got %d %.2f", vol, vwap)
+	}
+
+	// a day after the first trade only the midday trade is in the window
+	vol, vwap = st.rolling(t0 + day + 2*60_000)
+	if vol != 10 || vwap != 90 {
+		t.Fatalf("expected old trades to roll off, got vol=%d vwap=%.2f", vol, vwap)
+	}
+}
+
+func TestTickerSessionRoll(t *testing.T) {
+	ob := NewOrderBook("S")
+	ob.stats.roll = (21*60 + 30) * 60 * 1000 // 21:30 UTC
+	at := func(hhmm string) int64 {
+		ts, _ := time.Parse("2006-01-02 15:04", "2024-05-01 "+hhmm)
+		return ts.UnixMilli()
+	}
+
+	ob.stats.onTrade(model.Trade{Price: 100, Quantity: 1, Timestamp: at("20:00")})
+	ob.stats.onTrade(model.Trade{Price: 120, Quantity: 1, Timestamp: at("21:00")})
+	if tk := ob.ticker(at("21:29")); tk.Open != 100 || tk.High != 120 || tk.Low != 100 {
+		t.Fatalf("expected the session open at 100 high 120, got %+v", tk)
+	}
+
+	// past the roll nothing has traded yet this session
+	tk := ob.ticker(at("21:30"))
+	if tk.Open != 0 || tk.High != 0 || tk.Low != 0 || tk.LastPrice != 120 || tk.Volume24h != 2 {
+		t.Fatalf("expected open, high and low cleared at the roll, got %+v", tk)
+	}
+
+	ob.stats.onTrade(model.Trade{Price: 110, Quantity: 1, Timestamp: at("22:00")})
+	ob.stats.onTrade(model.Trade{Price: 105, Quantity: 1, Timestamp: at("22:05")})
+	if tk := ob.ticker(at("22:10")); tk.Open != 110 || tk.High != 110 || tk.Low != 105 {
+		t.Fatalf("expected the new session open at 110 low 105, got %+v", tk)
+	}
+}
+
+func TestRouterTickersAcrossShards(t *testing.T) {
+	r := NewRouter(4, 16)
+	defer r.Stop()
+
+	for i, sym := range []string{"T-C", "T-A", "T-B"} {
+		r.SubmitOrder(&model.Order{ID: fmt.Sprint("s", i), Symbol: sym, Side: model.SELL, Type: model.LIMIT, Price: 105, Quantity: 5})
+		r.SubmitOrder(&model.Order{ID: fmt.Sprint("b", i), Symbol: sym, Side: model.BUY, Type: model.LIMIT, Price: 105, Quantity: 2})
+		r.SubmitOrder(&model.Order{ID: fmt.Sprint("bb", i), Symbol: sym, Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 7})
+	}
+
+	all := r.GetTickers()
+	if len(all) != 3 || all[0].Symbol != "T-A" || all[2].Symbol != "T-C" {
+		t.Fatalf("expected 3 tickers sorted by symbol, got %+v", all)
+	}
+	tk, ok := r.GetTicker("T-B")
+	if !ok {
+		t.Fatalf("expected ticker for T-B")
+	}
+	if tk.BidPrice != 100 || tk.BidSize != 7 || tk.AskPrice != 105 || tk.AskSize != 3 {
+		t.Fatalf("unexpected top of book %+v", tk)
+	}
+	if tk.LastPrice != 105 || tk.LastSize != 2 || tk.Volume24h != 2 || tk.VWAP24h != 105 {
+		t.Fatalf("unexpected trade stats %+v", tk)
+	}
+	if _, ok := r.GetTicker("NOPE"); ok {
+		t.Fatalf("unknown symbol should not have a ticker")
+	}
+}
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what Publish does when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Drop discards the event for that subscriber and counts it.
+	Drop Policy = iota
+	// Block waits until the subscriber makes room. Use only for consumers
+	// that must see every event (persistence, audit); a stuck consumer
+	// stalls the publishing shard.
+	Block
+	// Disconnect closes the subscription; Err reports ErrSlowConsumer.
+	Disconnect
+)
+
+// ErrSlowConsumer is reported by a subscription closed under Disconnect.
+var ErrSlowConsumer = errors.New("subscriber too slow, disconnected")
+
+// Filter selects the events a subscriber wants. nil means all events.
+type Filter func(*Event) bool
+
+// Bus is a publish/subscribe fan-out for engine events.
+// Publish is safe to call from every shard concurrently.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription is one consumer's bounded view of the bus.
+type Subscription struct {
+	bus    *Bus
+	ch     chan Event
+	policy Policy
+	filter Filter
+
+	mu      sync.Mutex // guards sends against close of ch
+	closed  bool
+	done    chan struct{}
+	once    sync.Once
+	err     error
+	dropped uint64
+}
+
+// Subscribe registers a consumer with a buffer of size buffer.
+func (b *Bus) Subscribe(buffer int, policy Policy, filter Filter) *Subscription {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	s := &Subscription{
+		bus:    b,
+		ch:     make(chan Event, buffer),
+		policy: policy,
+		filter: filter,
+		done:   make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Publish delivers ev to every matching subscriber according to its policy.
+func (b *Bus) Publish(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if s.filter != nil && !s.filter(&ev) {
+			continue
+		}
+		s.deliver(ev)
+	}
+}
+
+func (s *Subscription) deliver(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+	case Disconnect:
+		select {
+		case s.ch <- ev:
+		default:
+			s.err = ErrSlowConsumer
+			s.closeLocked()
+		}
+	default:
+		select {
+		case s.ch <- ev:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// C returns the event channel. It is closed when the subscription ends.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Done is closed when the subscription ends.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns how many events were discarded under the Drop policy.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err returns why the subscription ended, or nil.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close unregisters the subscription and closes its channel.
+func (s *Subscription) Close() {
+	// wake up a publisher blocked on our channel before taking the lock
+	s.once.Do(func() { close(s.done) })
+	s.mu.Lock()
+	s.closeLocked()
+	s.mu.Unlock()
+}
+
+func (s *Subscription) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.once.Do(func() { close(s.done) })
+	close(s.ch)
+	// unregister asynchronously: Publish may hold bus.mu while calling us
+	go func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+	}()
+}
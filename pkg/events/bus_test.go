@@ -0,0 +1,104 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusFilterAndOrder(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(8, Drop, func(ev *Event) bool { return ev.Symbol == "ABC" })
+	defer sub.Close()
+
+	b.Publish(Event{Type: TradeExecuted, Symbol: "ABC", Seq: 1})
+	b.Publish(Event{Type: TradeExecuted, Symbol: "XYZ", Seq: 1})
+	b.Publish(Event{Type: LevelChanged, Symbol: "ABC", Seq: 2})
+
+	for want := uint64(1); want <= 2; want++ {
+		ev := <-sub.C()
+		if ev.Symbol != "ABC" || ev.Seq != want {
+			t.Fatalf("expected ABC seq %d, got %s seq %d", want, ev.Symbol, ev.Seq)
+		}
+	}
+	if len(sub.C()) != 0 {
+		t.Fatalf("filtered event leaked through")
+	}
+}
+
+func TestBusDropPolicy(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(1, Drop, nil)
+	defer sub.Close()
+
+	b.Publish(Event{Seq: 1})
+	b.Publish(Event{Seq: 2})
+	b.Publish(Event{Seq: 3})
+
+	if got := sub.Dropped(); got != 2 {
+		t.Fatalf("expected 2 dropped, got %d", got)
+	}
+	if ev := <-sub.C(); ev.Seq != 1 {
+		t.Fatalf("expected first event kept, got seq %d", ev.Seq)
+	}
+}
+
+func TestBusDisconnectPolicy(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(1, Disconnect, nil)
+
+	b.Publish(Event{Seq: 1})
+	b.Publish(Event{Seq: 2}) // overflows -> disconnect
+
+	<-sub.C()
+	if _, ok := <-sub.C(); ok {
+		t.Fatalf("expected channel closed after overflow")
+	}
+	if sub.Err() != ErrSlowConsumer {
+		t.Fatalf("expected ErrSlowConsumer, got %v", sub.Err())
+	}
+}
+
+func TestBusBlockPolicy(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(1, Block, nil)
+	defer sub.Close()
+
+	b.Publish(Event{Seq: 1})
+	published := make(chan struct{})
+	go func() {
+		b.Publish(Event{Seq: 2}) // blocks until the consumer reads
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatalf("publish should block while buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-sub.C()
+	<-published
+	if ev := <-sub.C(); ev.Seq != 2 {
+		t.Fatalf("expected seq 2, got %d", ev.Seq)
+	}
+}
+
+func TestBusCloseUnblocksPublisher(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(1, Block, nil)
+
+	b.Publish(Event{Seq: 1})
+	published := make(chan struct{})
+	go func() {
+		b.Publish(Event{Seq: 2})
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	sub.Close()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatalf("publisher still blocked after Close")
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+import "github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+
+// Type identifies the kind of engine event.
+type Type string
+
+const (
+	OrderAccepted  Type = "ACCEPTED"
+	OrderRejected  Type = "REJECTED"
+	OrderBooked    Type = "BOOKED"  // remainder now resting at the back of its level
+	OrderAmended   Type = "AMENDED" // price/size changed; see OrderBook.amend for priority
+	OrderFilled    Type = "FILLED"  // partial or full, see Order.Remaining()
+	OrderCancelled Type = "CANCELLED"
+	TradeExecuted  Type = "TRADE"
+	LevelChanged   Type = "LEVEL"
+)
+
+// Event is a single piece of engine output.
+// Exactly one of Order, Trade or Level is set depending on Type.
+// Seq is assigned by the shard owning Symbol and increases by one for every
+// event of that symbol, so consumers can detect gaps per symbol.
+type Event struct {
+	Type      Type   `json:"type"`
+	Symbol    string `json:"symbol"`
+	Seq       uint64 `json:"seq"`
+	Timestamp int64  `json:"timestamp"` // unix ms
+
+	Order     *model.Order `json:"order,omitempty"`      // copy, safe to read
+	FillPrice int64        `json:"fill_price,omitempty"` // OrderFilled only
+	FillQty   int64        `json:"fill_qty,omitempty"`   // OrderFilled only
+	Fee       int64        `json:"fee,omitempty"`        // OrderFilled only; < 0 is a rebate
+	Maker     bool         `json:"maker,omitempty"`      // OrderFilled only: the order was resting
+	Reason    string       `json:"reason,omitempty"`     // OrderRejected only
+
+	Trade *model.Trade `json:"trade,omitempty"`
+	Level *Level       `json:"level,omitempty"`
+}
+
+// Level is the new aggregate state of one price level.
+// Quantity 0 means the level was removed.
+type Level struct {
+	Side     model.Side `json:"side"`
+	Price    int64      `json:"price"`
+	Quantity int64      `json:"quantity"`
+}
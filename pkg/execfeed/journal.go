@@ -0,0 +1,286 @@
+// Package execfeed turns engine order events into per-account execution
+// reports that clients can resume from a sequence number.
+//
+// Every report gets a journal-wide Seq. A client remembers the last Seq it
+// processed and, after a disconnect, resumes after it: reports still held in
+// the journal are replayed and the live stream continues without a gap. The
+// same journal serves the firm drop copy, which follows every account.
+package execfeed
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Report types.
+const (
+	ReportAck         = "ACK"
+	ReportReject      = "REJECT"
+	ReportPartialFill = "PARTIAL_FILL"
+	ReportFill        = "FILL"
+	ReportCancel      = "CANCEL"
+	ReportAmend       = "AMEND"
+	ReportExpire      = "EXPIRE" // ended by the engine after it was accepted
+)
+
+// Report is one execution report.
+type Report struct {
+	Seq       uint64      `json:"seq"` // journal-wide, the resume point
+	Type      string      `json:"type"`
+	Account   string      `json:"account,omitempty"`
+	Symbol    string      `json:"symbol"`
+	EngineSeq uint64      `json:"engine_seq"` // per-symbol engine event seq
+	Timestamp int64       `json:"timestamp"`  // unix ms
+	Order     model.Order `json:"order"`      // state after this report
+	FillPrice int64       `json:"fill_price,omitempty"`
+	FillQty   int64       `json:"fill_qty,omitempty"`
+	Fee       int64       `json:"fee,omitempty"`       // charged on this fill; < 0 is a rebate
+	Liquidity string      `json:"liquidity,omitempty"` // fills: "maker" or "taker"
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// Live as the after argument of Follow starts at the next report, with no
+// replay.
+const Live = ^uint64(0)
+
+// DefaultJournalSize is how many reports are kept for resuming.
+const DefaultJournalSize = 100_000
+
+var (
+	// ErrTooOld means the requested reports have left the journal; the
+	// client has to rebuild its state from the REST API.
+	ErrTooOld = errors.New("execfeed: sequence no longer in journal")
+	// ErrAhead means the client has a sequence the journal never issued,
+	// typically because the server restarted.
+	ErrAhead = errors.New("execfeed: sequence ahead of journal")
+	// ErrSlowConsumer ends a feed that fell too far behind; it can resume
+	// from its last seq.
+	ErrSlowConsumer = errors.New("execfeed: consumer too slow, disconnected")
+	// ErrClosed ends every feed when the journal closes.
+	ErrClosed = errors.New("execfeed: journal closed")
+)
+
+// feedLimit is how many live reports may queue for one feed beyond its
+// replay before it is cut off.
+const feedLimit = 4096
+
+// Journal records execution reports and fans them out to feeds.
+type Journal struct {
+	sub *events.Subscription
+
+	mu    sync.Mutex
+	ring  []Report
+	next  uint64 // seq of the next report
+	feeds map[*Feed]struct{}
+	done  chan struct{}
+}
+
+// NewJournal subscribes to bus and keeps the last size reports.
+func NewJournal(bus *events.Bus, size int) *Journal {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	j := &Journal{
+		ring:  make([]Report, size),
+		next:  1,
+		feeds: make(map[*Feed]struct{}),
+		done:  make(chan struct{}),
+	}
+	j.sub = bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.OrderAccepted, events.OrderRejected, events.OrderFilled,
+			events.OrderCancelled, events.OrderAmended:
+			return true
+		}
+		return false
+	})
+	go j.run()
+	return j
+}
+
+func (j *Journal) run() {
+	defer close(j.done)
+	for ev := range j.sub.C() {
+		j.add(report(&ev))
+	}
+	j.mu.Lock()
+	for f := range j.feeds {
+		f.end(ErrClosed)
+	}
+	j.feeds = nil
+	j.mu.Unlock()
+}
+
+// Close stops the journal and ends every feed with ErrClosed.
+func (j *Journal) Close() {
+	j.sub.Close()
+	<-j.done
+}
+
+func report(ev *events.Event) Report {
+	r := Report{
+		Account:   ev.Order.Account,
+		Symbol:    ev.Symbol,
+		EngineSeq: ev.Seq,
+		Timestamp: ev.Timestamp,
+		Order:     *ev.Order,
+		FillPrice: ev.FillPrice,
+		FillQty:   ev.FillQty,
+		Reason:    ev.Reason,
+	}
+	switch ev.Type {
+	case events.OrderAccepted:
+		r.Type = ReportAck
+	case events.OrderRejected:
+		// a triggered stop the book cannot fill was accepted long before;
+		// the engine ends it rather than refusing it
+		r.Type = ReportReject
+		if ev.Order.Triggered {
+			r.Type = ReportExpire
+		}
+	case events.OrderFilled:
+		r.Type = ReportFill
+		r.Fee, r.Liquidity = ev.Fee, "taker"
+		if ev.Maker {
+			r.Liquidity = "maker"
+		}
+		if ev.Order.Remaining() > 0 {
+			r.Type = ReportPartialFill
+		}
+	case events.OrderCancelled:
+		r.Type = ReportCancel
+	case events.OrderAmended:
+		r.Type = ReportAmend
+	}
+	return r
+}
+
+func (j *Journal) add(r Report) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r.Seq = j.next
+	j.next++
+	j.ring[r.Seq%uint64(len(j.ring))] = r
+	for f := range j.feeds {
+		if f.wants(&r) {
+			f.push(r)
+		}
+	}
+}
+
+// Follow opens a feed of account's reports after seq after; account ""
+// follows every account (the drop copy). Use Live to skip the replay.
+func (j *Journal) Follow(account string, after uint64) (*Feed, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.feeds == nil {
+		return nil, ErrClosed
+	}
+
+	f := &Feed{j: j, account: account, signal: make(chan struct{}, 1), done: make(chan struct{})}
+	if after != Live {
+		last := j.next - 1
+		if after > last {
+			return nil, ErrAhead
+		}
+		oldest := uint64(1)
+		if last > uint64(len(j.ring)) {
+			oldest = last - uint64(len(j.ring)) + 1
+		}
+		if after+1 < oldest {
+			return nil, ErrTooOld
+		}
+		for seq := after + 1; seq <= last; seq++ {
+			r := &j.ring[seq%uint64(len(j.ring))]
+			if f.wants(r) {
+				f.queue = append(f.queue, *r)
+			}
+		}
+	}
+	f.limit = len(f.queue) + feedLimit
+	j.feeds[f] = struct{}{}
+	return f, nil
+}
+
+// Feed is one follower of the journal.
+type Feed struct {
+	j       *Journal
+	account string
+
+	// guarded by j.mu
+	queue []Report
+	limit int
+	err   error
+
+	signal chan struct{}
+	done   chan struct{}
+}
+
+func (f *Feed) wants(r *Report) bool {
+	return f.account == "" || r.Account == f.account
+}
+
+// push queues r; caller holds j.mu.
+func (f *Feed) push(r Report) {
+	if len(f.queue) >= f.limit {
+		delete(f.j.feeds, f)
+		f.end(ErrSlowConsumer)
+		return
+	}
+	f.queue = append(f.queue, r)
+	select {
+	case f.signal <- struct{}{}:
+	default:
+	}
+}
+
+// end stops the feed; caller holds j.mu.
+func (f *Feed) end(err error) {
+	if f.err == nil {
+		f.err = err
+		close(f.done)
+	}
+}
+
+// Next returns the next report, waiting for one if needed. Once the feed
+// has ended it returns the reason, after any reports already queued.
+func (f *Feed) Next(ctx context.Context) (Report, error) {
+	for {
+		f.j.mu.Lock()
+		if len(f.queue) > 0 {
+			r := f.queue[0]
+			f.queue = f.queue[1:]
+			if len(f.queue) == 0 {
+				f.queue = nil // let a large replay be collected
+			}
+			f.j.mu.Unlock()
+			return r, nil
+		}
+		err := f.err
+		f.j.mu.Unlock()
+		if err != nil {
+			return Report{}, err
+		}
+
+		select {
+		case <-f.signal:
+		case <-f.done:
+		case <-ctx.Done():
+			return Report{}, ctx.Err()
+		}
+	}
+}
+
+// Close detaches the feed.
+func (f *Feed) Close() {
+	f.j.mu.Lock()
+	if f.j.feeds != nil {
+		delete(f.j.feeds, f)
+	}
+	f.end(ErrClosed)
+	f.j.mu.Unlock()
+}
@@ -0,0 +1,182 @@
+package execfeed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func next(t *testing.T, f *Feed) Report {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	r, err := f.Next(ctx)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	return r
+}
+
+func submit(r *engine.Router, id, account string, side model.Side, price, qty int64) {
+	r.SubmitOrder(&model.Order{ID: id, Account: account, Symbol: "X", Side: side, Type: model.LIMIT, Price: price, Quantity: qty})
+}
+
+func TestFeedReportsAndResume(t *testing.T) {
+	b := events.NewBus()
+	j := NewJournal(b, 0)
+	defer j.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+
+	f, err := j.Follow("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit(r, "s1", "alice", model.SELL, 100, 5)
+	submit(r, "b1", "bob", model.BUY, 100, 2)
+	submit(r, "b2", "bob", model.BUY, 100, 3)
+
+	var got []string
+	var seqs []uint64
+	for i := 0; i < 3; i++ {
+		rep := next(t, f)
+		if rep.Account != "alice" || (i > 0 && rep.Seq <= seqs[i-1]) {
+			t.Fatalf("unexpected report %+v after seqs %v", rep, seqs)
+		}
+		seqs = append(seqs, rep.Seq)
+		got = append(got, rep.Type)
+	}
+	f.Close()
+	want := []string{ReportAck, ReportPartialFill, ReportFill}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	// resuming after the ack replays the two fills
+	f, err = j.Follow("alice", seqs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if rep := next(t, f); rep.Type != ReportPartialFill || rep.FillQty != 2 {
+		t.Fatalf("expected replayed partial fill of 2, got %+v", rep)
+	}
+	if rep := next(t, f); rep.Seq != seqs[2] || rep.Order.Remaining() != 0 {
+		t.Fatalf("expected replayed final fill at seq %d, got %+v", seqs[2], rep)
+	}
+
+	// and continues live
+	submit(r, "s2", "alice", model.SELL, 101, 1)
+	r.CancelOrder("X", "s2")
+	if rep := next(t, f); rep.Type != ReportAck || rep.Order.ID != "s2" {
+		t.Fatalf("expected live ack of s2, got %+v", rep)
+	}
+	if rep := next(t, f); rep.Type != ReportCancel {
+		t.Fatalf("expected cancel, got %+v", rep)
+	}
+}
+
+func TestDropCopySeesAllAccounts(t *testing.T) {
+	b := events.NewBus()
+	j := NewJournal(b, 0)
+	defer j.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+
+	f, err := j.Follow("", Live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	submit(r, "s1", "alice", model.SELL, 100, 1)
+	submit(r, "b1", "bob", model.BUY, 100, 1)
+
+	accounts := map[string]int{}
+	for i := 0; i < 4; i++ { // two acks, two fills
+		accounts[next(t, f).Account]++
+	}
+	if accounts["alice"] != 2 || accounts["bob"] != 2 {
+		t.Fatalf("expected two reports per account, got %v", accounts)
+	}
+}
+
+func TestFollowOutOfRange(t *testing.T) {
+	b := events.NewBus()
+	j := NewJournal(b, 2)
+	defer j.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+
+	f, _ := j.Follow("", 0)
+	for i := 0; i < 3; i++ {
+		submit(r, string(rune('a'+i)), "a", model.SELL, int64(100+i), 1)
+	}
+	for i := 0; i < 3; i++ {
+		next(t, f)
+	}
+	f.Close()
+
+	if _, err := j.Follow("", 0); !errors.Is(err, ErrTooOld) {
+		t.Fatalf("expected ErrTooOld, got %v", err)
+	}
+	if _, err := j.Follow("", 1); err != nil {
+		t.Fatalf("seq 2 and 3 are still held: %v", err)
+	}
+	if _, err := j.Follow("", 4); !errors.Is(err, ErrAhead) {
+		t.Fatalf("expected ErrAhead, got %v", err)
+	}
+}
+
+func TestSlowFeedIsCutOff(t *testing.T) {
+	j := &Journal{ring: make([]Report, 8), next: 1, feeds: map[*Feed]struct{}{}}
+	f, _ := j.Follow("", Live)
+	for i := 0; i <= feedLimit; i++ {
+		j.add(Report{Type: ReportAck})
+	}
+	var n int
+	for {
+		_, err := f.Next(context.Background())
+		if err != nil {
+			if !errors.Is(err, ErrSlowConsumer) {
+				t.Fatalf("expected ErrSlowConsumer, got %v", err)
+			}
+			break
+		}
+		n++
+	}
+	if n != feedLimit {
+		t.Fatalf("expected the %d queued reports before the error, got %d", feedLimit, n)
+	}
+}
+
+func TestStopWithoutLiquidityExpires(t *testing.T) {
+	b := events.NewBus()
+	j := NewJournal(b, 0)
+	defer j.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(b))
+	defer r.Stop()
+
+	f, err := j.Follow("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	submit(r, "s1", "bob", model.SELL, 100, 1)
+	submit(r, "b1", "carol", model.BUY, 100, 1)
+	r.SubmitOrder(&model.Order{ID: "stop", Account: "alice", Symbol: "X", Side: model.SELL, Type: model.TRAILING_STOP, Quantity: 10, TrailAmount: 5})
+	submit(r, "s2", "bob", model.SELL, 94, 1)
+	submit(r, "b2", "carol", model.BUY, 94, 1)
+
+	for _, want := range []string{ReportAck, ReportAmend, ReportExpire} {
+		if rep := next(t, f); rep.Type != want || rep.Order.ID != "stop" {
+			t.Fatalf("expected %s of the stop, got %+v", want, rep)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package fees prices trades for the maker and the taker and totals what
+// each account owes per day.
+//
+// A Fee is a rate in basis points of the trade's notional, a fixed amount
+// per unit traded, or both; negative values are rebates. Schedules are
+// looked up per instrument and per account tier, most specific first: the
+// account's tier for the instrument, the tier's default, the venue's
+// schedule for the instrument, the venue default. Maker and taker are
+// looked up separately, each by their own account.
+//
+// The Engine prices each trade synchronously inside the matching loop, so
+// the fee is part of the trade the shard reports. Running totals are built
+// from the fills on the bus, like every other consumer, and trail the
+// engine by a moment.
+package fees
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Fee for one side of a trade. Amounts are in price units (cents).
+type Fee struct {
+	Bps     float64 `json:"bps,omitempty"`      // of price * quantity
+	PerUnit int64   `json:"per_unit,omitempty"` // times quantity
+}
+
+// Amount is the fee on qty at price, rounded to the nearest unit (half away
+// from zero). Bps apply to the size of the notional, so a spread traded at
+// a negative price is charged like any other trade.
+func (f Fee) Amount(price, qty int64) int64 {
+	n := float64(price * qty)
+	return int64(math.Round(math.Abs(n)*f.Bps/10_000)) + f.PerUnit*qty
+}
+
+// Schedule is the maker and taker fee.
+type Schedule struct {
+	Maker Fee `json:"maker"`
+	Taker Fee `json:"taker"`
+}
+
+// Table is a default schedule with per-instrument exceptions.
+type Table struct {
+	Default     *Schedule           `json:"default,omitempty"`
+	Instruments map[string]Schedule `json:"instruments,omitempty"`
+}
+
+// lookup returns the table's schedule for symbol.
+func (t *Table) lookup(symbol string) (Schedule, bool) {
+	if s, ok := t.Instruments[symbol]; ok {
+		return s, true
+	}
+	if t.Default != nil {
+		return *t.Default, true
+	}
+	return Schedule{}, false
+}
+
+// Config is the venue's fee table, the tiers and which account is in
+// which tier. Accounts without a tier pay the venue table.
+type Config struct {
+	Table
+	Tiers    map[string]Table  `json:"tiers,omitempty"`
+	Accounts map[string]string `json:"accounts,omitempty"` // account -> tier
+}
+
+// LoadConfig reads a Config from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(b, &cfg)
+	return cfg, err
+}
+
+// Schedule returns the schedule account pays on symbol.
+func (c *Config) Schedule(account, symbol string) Schedule {
+	if tier, ok := c.Tiers[c.Accounts[account]]; ok {
+		if s, ok := tier.lookup(symbol); ok {
+			return s
+		}
+	}
+	s, _ := c.Table.lookup(symbol)
+	return s
+}
+
+// Total is one account's fees for one UTC day. Fees is what it paid net of
+// rebates.
+type Total struct {
+	Account     string `json:"account"`
+	Day         string `json:"day"` // YYYY-MM-DD, UTC
+	MakerFees   int64  `json:"maker_fees"`
+	TakerFees   int64  `json:"taker_fees"`
+	Fees        int64  `json:"fees"`
+	MakerVolume int64  `json:"maker_volume"` // quantity
+	TakerVolume int64  `json:"taker_volume"`
+	Fills       int64  `json:"fills"`
+}
+
+// DefaultHistoryDays is how many days of totals are kept.
+const DefaultHistoryDays = 90
+
+// DayLayout formats the Day of a Total.
+const DayLayout = "2006-01-02"
+
+// Engine implements engine.Fees.
+type Engine struct {
+	cfg Config // read-only once built
+	sub *events.Subscription
+
+	mu      sync.Mutex
+	days    map[string]map[string]*Total // day -> account
+	history int
+	done    chan struct{}
+}
+
+// NewEngine prices trades by cfg and totals the fees of the fills on bus,
+// keeping history days. Pass it to engine.WithFees.
+func NewEngine(bus *events.Bus, cfg Config, history int) *Engine {
+	if history <= 0 {
+		history = DefaultHistoryDays
+	}
+	e := &Engine{
+		cfg:     cfg,
+		days:    make(map[string]map[string]*Total),
+		history: history,
+		done:    make(chan struct{}),
+	}
+	e.sub = bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+		return ev.Type == events.OrderFilled && ev.Order.Account != ""
+	})
+	go e.run()
+	return e
+}
+
+// Close stops totalling.
+func (e *Engine) Close() {
+	e.sub.Close()
+	<-e.done
+}
+
+// TradeFees prices t for both sides.
+func (e *Engine) TradeFees(t *model.Trade, maker, taker *model.Order) (makerFee, takerFee int64) {
+	makerFee = e.cfg.Schedule(maker.Account, t.Symbol).Maker.Amount(t.Price, t.Quantity)
+	takerFee = e.cfg.Schedule(taker.Account, t.Symbol).Taker.Amount(t.Price, t.Quantity)
+	return makerFee, takerFee
+}
+
+// Schedule returns the schedule account pays on symbol.
+func (e *Engine) Schedule(account, symbol string) Schedule {
+	return e.cfg.Schedule(account, symbol)
+}
+
+// Total returns account's fees for day (YYYY-MM-DD, UTC).
+func (e *Engine) Total(account, day string) Total {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t, ok := e.days[day][account]; ok {
+		return *t
+	}
+	return Total{Account: account, Day: day}
+}
+
+// Totals returns every account's fees for day, by account: the day's
+// invoice run.
+func (e *Engine) Totals(day string) []Total {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]Total, 0, len(e.days[day]))
+	for _, t := range e.days[day] {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Account < out[j].Account })
+	return out
+}
+
+func (e *Engine) run() {
+	defer close(e.done)
+	for ev := range e.sub.C() {
+		e.apply(&ev)
+	}
+}
+
+func (e *Engine) apply(ev *events.Event) {
+	day := time.UnixMilli(ev.Timestamp).UTC().Format(DayLayout)
+	account := ev.Order.Account
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	accounts := e.days[day]
+	if accounts == nil {
+		accounts = make(map[string]*Total)
+		e.days[day] = accounts
+		e.pruneLocked()
+	}
+	t := accounts[account]
+	if t == nil {
+		t = &Total{Account: account, Day: day}
+		accounts[account] = t
+	}
+	if ev.Maker {
+		t.MakerFees += ev.Fee
+		t.MakerVolume += ev.FillQty
+	} else {
+		t.TakerFees += ev.Fee
+		t.TakerVolume += ev.FillQty
+	}
+	t.Fees += ev.Fee
+	t.Fills++
+}
+
+// pruneLocked drops the oldest days beyond the history.
+func (e *Engine) pruneLocked() {
+	if len(e.days) <= e.history {
+		return
+	}
+	days := make([]string, 0, len(e.days))
+	for d := range e.days {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+	for _, d := range days[:len(days)-e.history] {
+		delete(e.days, d)
+	}
+}
@@ -0,0 +1,126 @@
+package fees
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+const testConfig = `{
+	"default": {"maker": {"bps": -1}, "taker": {"bps": 5}},
+	"instruments": {"ES": {"maker": {"per_unit": -25}, "taker": {"per_unit": 85}}},
+	"tiers": {
+		"vip": {"default": {"maker": {"bps": -2}, "taker": {"bps": 2.5}}},
+		"mm": {"instruments": {"ES": {"maker": {"per_unit": -50}, "taker": {"per_unit": 50}}}}
+	},
+	"accounts": {"v": "vip", "m": "mm"}
+}`
+
+func config(t *testing.T) Config {
+	t.Helper()
+	var cfg Config
+	if err := json.Unmarshal([]byte(testConfig), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestScheduleLookup(t *testing.T) {
+	cfg := config(t)
+	for _, c := range []struct {
+		account, symbol string
+		want            Schedule
+	}{
+		{"anyone", "BTC", Schedule{Maker: Fee{Bps: -1}, Taker: Fee{Bps: 5}}},
+		{"anyone", "ES", Schedule{Maker: Fee{PerUnit: -25}, Taker: Fee{PerUnit: 85}}},
+		{"v", "ES", Schedule{Maker: Fee{Bps: -2}, Taker: Fee{Bps: 2.5}}},         // tier default beats venue instrument
+		{"m", "ES", Schedule{Maker: Fee{PerUnit: -50}, Taker: Fee{PerUnit: 50}}}, // tier instrument
+		{"m", "BTC", Schedule{Maker: Fee{Bps: -1}, Taker: Fee{Bps: 5}}},          // tier has nothing for it
+	} {
+		if got := cfg.Schedule(c.account, c.symbol); got != c.want {
+			t.Errorf("%s %s: expected %+v, got %+v", c.account, c.symbol, c.want, got)
+		}
+	}
+}
+
+func TestAmount(t *testing.T) {
+	for _, c := range []struct {
+		fee        Fee
+		price, qty int64
+		want       int64
+	}{
+		{Fee{Bps: 5}, 10_000, 3, 15},
+		{Fee{Bps: -1}, 10_000, 3, -3},
+		{Fee{Bps: 2.5}, 100, 3, 0},      // 0.075 rounds to nothing
+		{Fee{Bps: 2.5}, 10_000, 3, 8},   // 7.5 rounds up
+		{Fee{Bps: -2.5}, 10_000, 3, -8}, // and a rebate away from zero
+		{Fee{PerUnit: 85}, 4_500, 2, 170},
+		{Fee{Bps: 1, PerUnit: 1}, 10_000, 2, 4},
+		{Fee{Bps: 5}, -10_000, 3, 15}, // a spread below zero still pays
+		{Fee{Bps: -1}, -10_000, 3, -3},
+	} {
+		if got := c.fee.Amount(c.price, c.qty); got != c.want {
+			t.Errorf("%+v on %d x %d: expected %d, got %d", c.fee, c.qty, c.price, c.want, got)
+		}
+	}
+}
+
+// waitTotal waits for the bus to bring account's fills for day to fills.
+func waitTotal(t *testing.T, e *Engine, account, day string, fills int64) Total {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tot := e.Total(account, day)
+		if tot.Fills == fills {
+			return tot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: expected %d fills, got %+v", account, fills, tot)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestChargesTradesAndTotalsPerAccount(t *testing.T) {
+	b := events.NewBus()
+	e := NewEngine(b, config(t), 0)
+	defer e.Close()
+	r := engine.NewRouter(2, 16, engine.WithEventBus(b), engine.WithFees(e))
+	defer r.Stop()
+
+	limit := func(id, account string, side model.Side, price, qty int64) *model.Order {
+		return &model.Order{ID: id, Account: account, Symbol: "ES", Side: side, Type: model.LIMIT, Price: price, Quantity: qty}
+	}
+	r.SubmitOrder(limit("m1", "m", model.SELL, 4_500, 10))
+	res := r.SubmitOrder(limit("t1", "x", model.BUY, 4_500, 4))
+	if tr := res.Trades[0]; tr.MakerFee != -200 || tr.TakerFee != 340 {
+		t.Fatalf("expected the mm rebate of 200 and a taker fee of 340, got %+v", tr)
+	}
+	r.SubmitOrder(limit("t2", "v", model.BUY, 4_500, 6))
+
+	day := time.Now().UTC().Format(DayLayout)
+	m := waitTotal(t, e, "m", day, 2)
+	if m.MakerFees != -500 || m.TakerFees != 0 || m.Fees != -500 || m.MakerVolume != 10 {
+		t.Fatalf("unexpected maker total %+v", m)
+	}
+	waitTotal(t, e, "v", day, 1)
+	totals := e.Totals(day)
+	if len(totals) != 3 || totals[0].Account != "m" || totals[1].Account != "v" || totals[1].TakerFees != 7 {
+		t.Fatalf("unexpected invoice run %+v", totals)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	e := &Engine{days: make(map[string]map[string]*Total), history: 2}
+	for d := 1; d <= 4; d++ {
+		ts := time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC).UnixMilli()
+		e.apply(&events.Event{Type: events.OrderFilled, Timestamp: ts, Order: &model.Order{Account: "a"}, Fee: 1})
+	}
+	if len(e.days) != 2 || e.Total("a", "2024-01-04").Fees != 1 || e.Total("a", "2024-01-02").Fills != 0 {
+		t.Fatalf("expected only the last two days kept, have %v", e.days)
+	}
+}
@@ -0,0 +1,306 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+// Config configures an Acceptor.
+type Config struct {
+	CompID string // our SenderCompID
+	// StoreDir holds one FileStore per counterparty so sequence numbers and
+	// sent messages survive a restart. Empty keeps them in memory.
+	StoreDir string
+	// Keys authenticates logons: Username (553) and Password (554) are an
+	// API key's ID and secret, and the SenderCompID must be its account.
+	Keys *auth.KeyStore
+	// Limits, if not nil, throttles logons by address and orders, cancels
+	// and replaces by account and address.
+	Limits *ratelimit.Limiter
+}
+
+const (
+	logonTimeout = 10 * time.Second
+	outQueue     = 4096 // per connection; overflow disconnects
+	eventBuffer  = 4096
+)
+
+// Acceptor is the FIX 4.4 order entry gateway. Counterparties log on with
+// their account as SenderCompID and one of its API keys as Username and
+// Password; each CompID gets its own session on first logon, and its orders
+// are placed for that account.
+type Acceptor struct {
+	cfg    Config
+	router *engine.Router
+	sub    *events.Subscription
+
+	mu       sync.Mutex
+	sessions map[string]*session // counterparty CompID -> session
+	byID     map[string]*order   // engine order ID -> live order
+	lns      []net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// NewAcceptor creates the gateway. It subscribes to bus for the order events
+// that drive execution reports, so it must be created before the router
+// starts taking orders from it; the router must publish to the same bus.
+func NewAcceptor(cfg Config, router *engine.Router, bus *events.Bus) *Acceptor {
+	a := &Acceptor{
+		cfg:      cfg,
+		router:   router,
+		sessions: make(map[string]*session),
+		byID:     make(map[string]*order),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	a.sub = bus.Subscribe(eventBuffer, events.Block, func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.OrderAccepted, events.OrderRejected, events.OrderFilled,
+			events.OrderCancelled, events.OrderAmended:
+			return true
+		}
+		return false
+	})
+	a.wg.Add(1)
+	go a.run()
+	return a
+}
+
+// Serve accepts connections on ln until Close.
+func (a *Acceptor) Serve(ln net.Listener) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		ln.Close()
+		return errors.New("fix: acceptor closed")
+	}
+	a.lns = append(a.lns, ln)
+	a.mu.Unlock()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			a.mu.Lock()
+			closed := a.closed
+			a.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go a.ServeConn(c)
+	}
+}
+
+// ServeConn runs one counterparty connection: it waits for a Logon, then
+// serves the session until logout or disconnect.
+func (a *Acceptor) ServeConn(c net.Conn) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		c.Close()
+		return
+	}
+	a.conns[c] = struct{}{}
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.conns, c)
+		a.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	c.SetReadDeadline(time.Now().Add(logonTimeout))
+	m, err := ReadMessage(r)
+	if err != nil {
+		return
+	}
+	s, sc, ok := a.logon(c, m)
+	if !ok {
+		return
+	}
+	s.serve(sc, r)
+}
+
+// logon validates the first message of a connection and attaches it to the
+// counterparty's session.
+func (a *Acceptor) logon(c net.Conn, m *Message) (*session, *sessionConn, bool) {
+	fail := func(text string) (*session, *sessionConn, bool) {
+		log.Printf("fix: logon from %s refused: %s", c.RemoteAddr(), text)
+		out := NewMessage(MsgLogout).
+			Set(TagSenderCompID, a.cfg.CompID).
+			Set(TagTargetCompID, m.Get(TagSenderCompID)).
+			SetInt(TagMsgSeqNum, 1).
+			Set(TagSendingTime, time.Now().UTC().Format(TimeFormat)).
+			Set(TagText, text)
+		c.SetWriteDeadline(time.Now().Add(time.Second))
+		c.Write(out.Bytes())
+		return nil, nil, false
+	}
+
+	if m.MsgType() != MsgLogon {
+		return fail("first message must be Logon")
+	}
+	target := m.Get(TagSenderCompID)
+	if target == "" {
+		return fail("SenderCompID missing")
+	}
+	if m.Get(TagTargetCompID) != a.cfg.CompID {
+		return fail("unknown TargetCompID " + m.Get(TagTargetCompID))
+	}
+	ip := ratelimit.AddrIP(c.RemoteAddr())
+	if ok, _ := a.cfg.Limits.Screen(ratelimit.Query, ip); !ok {
+		return fail(rateLimitText)
+	}
+	k, ok := a.cfg.Keys.Check(m.Get(TagUsername) + ":" + m.Get(TagPassword))
+	if !ok {
+		return fail("invalid Username or Password")
+	}
+	if k.Account != target {
+		return fail("SenderCompID must be the key's account")
+	}
+	if ok, _ := a.cfg.Limits.Allow(ratelimit.Query, k.Account, ""); !ok {
+		return fail(rateLimitText)
+	}
+	if em := m.Get(TagEncryptMethod); em != "" && em != "0" {
+		return fail("EncryptMethod not supported")
+	}
+	hb, err := m.GetInt(TagHeartBtInt)
+	if err != nil || hb <= 0 {
+		return fail("HeartBtInt must be > 0")
+	}
+	seq, err := m.GetInt(TagMsgSeqNum)
+	if err != nil {
+		return fail("MsgSeqNum missing")
+	}
+
+	s, err := a.session(target)
+	if err != nil {
+		return fail("session store unavailable")
+	}
+
+	// a reconnect can race the teardown of the previous connection
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		old := s.conn
+		if old == nil {
+			break // keep s.mu
+		}
+		s.mu.Unlock()
+		select {
+		case <-old.done:
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fail("session already logged on")
+		}
+	}
+	defer s.mu.Unlock()
+
+	reset := m.Get(TagResetSeqNumFlag) == "Y"
+	if reset {
+		if err := s.store.Reset(); err != nil {
+			log.Printf("fix: %s: store reset: %v", target, err)
+		}
+	}
+	expected := s.store.NextTargetSeq()
+	if int(seq) < expected {
+		return fail(fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", expected, seq))
+	}
+
+	now := time.Now().UnixNano()
+	sc := &sessionConn{
+		c:       c,
+		ip:      ip,
+		out:     make(chan []byte, outQueue),
+		done:    make(chan struct{}),
+		heartBt: time.Duration(hb) * time.Second,
+	}
+	sc.lastRecv.Store(now)
+	sc.lastSent.Store(now)
+	s.conn = sc
+
+	resp := NewMessage(MsgLogon).
+		Set(TagEncryptMethod, "0").
+		SetInt(TagHeartBtInt, hb)
+	if reset {
+		resp.Set(TagResetSeqNumFlag, "Y")
+	}
+	s.sendLocked(resp)
+
+	if int(seq) == expected {
+		s.store.SetNextTargetSeq(expected + 1)
+	} else {
+		// the counterparty sent messages we never saw
+		sc.resendPending = true
+		s.sendLocked(NewMessage(MsgResendRequest).
+			SetInt(TagBeginSeqNo, int64(expected)).
+			SetInt(TagEndSeqNo, 0))
+	}
+	log.Printf("fix: %s logged on from %s", target, c.RemoteAddr())
+	return s, sc, true
+}
+
+// session returns the counterparty's session, creating it on first logon.
+func (a *Acceptor) session(target string) (*session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s, ok := a.sessions[target]; ok {
+		return s, nil
+	}
+	var st Store = NewMemoryStore()
+	if a.cfg.StoreDir != "" {
+		fs, err := NewFileStore(a.cfg.StoreDir, a.cfg.CompID+"-"+target)
+		if err != nil {
+			log.Printf("fix: %s: %v", target, err)
+			return nil, err
+		}
+		st = fs
+	}
+	s := &session{acc: a, target: target, store: st, orders: make(map[string]*order)}
+	a.sessions[target] = s
+	return s, nil
+}
+
+// Close stops accepting, drops every connection and releases the stores.
+// Sessions are not logged out: counterparties reconnect and resend.
+func (a *Acceptor) Close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	for _, ln := range a.lns {
+		ln.Close()
+	}
+	for c := range a.conns {
+		c.Close()
+	}
+	a.mu.Unlock()
+
+	a.sub.Close()
+	a.wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, s := range a.sessions {
+		if fs, ok := s.store.(*FileStore); ok {
+			fs.Close()
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package fix
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+func newGateway(t *testing.T) *Acceptor {
+	t.Helper()
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := events.NewBus()
+	r := engine.NewRouter(2, 16, engine.WithEventBus(bus))
+	acc := NewAcceptor(Config{CompID: "EXCH", Keys: keys}, r, bus)
+	t.Cleanup(func() {
+		acc.Close()
+		r.Stop()
+	})
+	return acc
+}
+
+// connect opens a session over an in-memory pipe and logs on with a new
+// key of the account sender.
+func connect(t *testing.T, acc *Acceptor, sender string, nextSeq int) *Initiator {
+	t.Helper()
+	i := dial(t, acc, sender, nextSeq)
+	if _, err := i.Logon(30, false); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// dial is connect without the Logon.
+func dial(t *testing.T, acc *Acceptor, sender string, nextSeq int) *Initiator {
+	t.Helper()
+	k, err := acc.cfg.Keys.Create(sender, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, c2 := net.Pipe()
+	go acc.ServeConn(c2)
+	i := NewInitiator(c1, sender, "EXCH", nextSeq)
+	i.SetCredentials(k.ID, k.Secret)
+	t.Cleanup(func() { i.Close() })
+	return i
+}
+
+// expect reads the next message and checks its MsgType and the given
+// tag/value pairs.
+func expect(t *testing.T, i *Initiator, msgType string, kv ...interface{}) *Message {
+	t.Helper()
+	m, err := i.Next(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MsgType() != msgType {
+		t.Fatalf("got MsgType %s, want %s: %s", m.MsgType(), msgType, m)
+	}
+	for k := 0; k < len(kv); k += 2 {
+		tag, want := kv[k].(int), kv[k+1].(string)
+		if got := m.Get(tag); got != want {
+			t.Fatalf("tag %d = %q, want %q: %s", tag, got, want, m)
+		}
+	}
+	return m
+}
+
+func newOrder(clOrdID, side, qty, price string) *Message {
+	return NewMessage(MsgNewOrderSingle).
+		Set(TagClOrdID, clOrdID).
+		Set(TagSymbol, "ABC").
+		Set(TagSide, side).
+		Set(TagOrderQty, qty).
+		Set(TagOrdType, OrdTypeLimit).
+		Set(TagPrice, price).
+		Set(TagTransactTime, time.Now().UTC().Format(TimeFormat))
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := NewMessage(MsgNewOrderSingle).
+		Set(TagClOrdID, "c1").
+		Set(TagSenderCompID, "A").
+		SetInt(TagMsgSeqNum, 7).
+		Set(TagTargetCompID, "B")
+	raw := m.Bytes()
+	if !strings.HasPrefix(string(raw), "8=FIX.4.4\x019=") {
+		t.Fatalf("bad framing: %s", m)
+	}
+	// header fields are moved in front of the body in standard order
+	if !strings.Contains(string(raw), "35=D\x0149=A\x0156=B\x0134=7\x0111=c1\x01") {
+		t.Fatalf("bad field order: %s", m)
+	}
+
+	got, err := ParseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get(TagClOrdID) != "c1" || got.MsgType() != MsgNewOrderSingle {
+		t.Fatalf("round trip lost fields: %s", got)
+	}
+
+	raw[len(raw)-3]++ // corrupt the checksum
+	if _, err := ParseMessage(raw); !errors.Is(err, ErrGarbled) {
+		t.Fatalf("want ErrGarbled, got %v", err)
+	}
+}
+
+func TestPriceConversion(t *testing.T) {
+	for in, want := range map[string]int64{"100": 10000, "100.5": 10050, "100.50": 10050, "0.01": 1, "12.340": 1234} {
+		got, err := parsePrice(in)
+		if err != nil || got != want {
+			t.Errorf("parsePrice(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "1.001", "-1", "+1", "abc", "1.x", "1.-5", "1.+5", "1.-", ".5", "1. 5", " 1", "1e2", "999999999999999999"} {
+		if _, err := parsePrice(in); err == nil {
+			t.Errorf("parsePrice(%q) succeeded", in)
+		}
+	}
+	if got := formatPrice(10050); got != "100.50" {
+		t.Errorf("formatPrice = %s", got)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	acc := newGateway(t)
+	buyer := connect(t, acc, "BUYER", 1)
+	seller := connect(t, acc, "SELLER", 1)
+
+	buyer.Send(newOrder("B1", SideBuy, "10", "100.50"))
+	ack := expect(t, buyer, MsgExecutionReport, TagClOrdID, "B1", TagExecType, ExecNew, TagOrdStatus, ExecNew, TagLeavesQty, "10")
+	orderID := ack.Get(TagOrderID)
+
+	seller.Send(newOrder("S1", SideSell, "4", "100.00"))
+	expect(t, seller, MsgExecutionReport, TagExecType, ExecNew)
+	expect(t, seller, MsgExecutionReport, TagExecType, ExecTrade, TagOrdStatus, ExecFilled, TagLastQty, "4", TagLastPx, "100.50")
+
+	// the resting side is told about its fill too
+	expect(t, buyer, MsgExecutionReport, TagOrderID, orderID, TagExecType, ExecTrade, TagOrdStatus, ExecPartial,
+		TagCumQty, "4", TagLeavesQty, "6", TagAvgPx, "100.5000")
+
+	buyer.Send(NewMessage(MsgOrderCancelReplace).
+		Set(TagOrigClOrdID, "B1").Set(TagClOrdID, "B2").Set(TagSymbol, "ABC").Set(TagSide, SideBuy).
+		Set(TagOrderQty, "8").Set(TagOrdType, OrdTypeLimit).Set(TagPrice, "100.50"))
+	expect(t, buyer, MsgExecutionReport, TagExecType, ExecReplaced, TagClOrdID, "B2", TagOrigClOrdID, "B1",
+		TagOrderQty, "8", TagLeavesQty, "4")
+
+	// B1 has been replaced, so it no longer names the order
+	buyer.Send(NewMessage(MsgOrderCancelRequest).Set(TagOrigClOrdID, "B1").Set(TagClOrdID, "B3").Set(TagSymbol, "ABC").Set(TagSide, SideBuy))
+	expect(t, buyer, MsgOrderCancelReject, TagCxlRejResponseTo, "1", TagClOrdID, "B3")
+
+	buyer.Send(NewMessage(MsgOrderCancelRequest).Set(TagOrigClOrdID, "B2").Set(TagClOrdID, "B4").Set(TagSymbol, "ABC").Set(TagSide, SideBuy))
+	expect(t, buyer, MsgExecutionReport, TagExecType, ExecCanceled, TagClOrdID, "B4", TagOrigClOrdID, "B2", TagCumQty, "4", TagLeavesQty, "0")
+
+	buyer.Send(NewMessage(MsgOrderCancelRequest).Set(TagOrigClOrdID, "B2").Set(TagClOrdID, "B5").Set(TagSymbol, "ABC").Set(TagSide, SideBuy))
+	expect(t, buyer, MsgOrderCancelReject, TagClOrdID, "B5", TagText, "order is not open")
+}
+
+func TestNewOrderRejects(t *testing.T) {
+	acc := newGateway(t)
+	c := connect(t, acc, "C", 1)
+
+	c.Send(newOrder("X1", SideBuy, "10", "1.001"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "X1", TagExecType, ExecRejected, TagOrdStatus, ExecRejected)
+
+	c.Send(newOrder("X2", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "X2", TagExecType, ExecNew)
+	c.Send(newOrder("X2", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "X2", TagExecType, ExecRejected, TagText, "duplicate ClOrdID")
+
+	// the account's client order IDs are shared with the other gateways
+	acc.router.SubmitOrder(&model.Order{Symbol: "ABC", Side: model.SELL, Type: model.LIMIT, Price: 500, Quantity: 1, Account: "C", ClientOrderID: "X4"})
+	c.Send(newOrder("X4", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "X4", TagExecType, ExecRejected, TagText, engine.ErrClientOrderIDReused.Error())
+
+	// market order with nothing to trade against is rejected by the engine
+	c.Send(NewMessage(MsgNewOrderSingle).Set(TagClOrdID, "X3").Set(TagSymbol, "ABC").Set(TagSide, SideBuy).
+		Set(TagOrderQty, "5").Set(TagOrdType, OrdTypeMarket))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "X3", TagExecType, ExecRejected, TagText, "insufficient liquidity for market order")
+}
+
+func TestFinishedOrdersArePruned(t *testing.T) {
+	acc := newGateway(t)
+	c := connect(t, acc, "C", 1)
+
+	c.Send(newOrder("P1", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "P1", TagExecType, ExecNew)
+	c.Send(NewMessage(MsgOrderCancelReplace).Set(TagOrigClOrdID, "P1").Set(TagClOrdID, "P2").Set(TagSymbol, "ABC").Set(TagSide, SideBuy).Set(TagOrderQty, "5"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "P2", TagExecType, ExecReplaced)
+	c.Send(NewMessage(MsgOrderCancelRequest).Set(TagOrigClOrdID, "P2").Set(TagClOrdID, "P3").Set(TagSymbol, "ABC").Set(TagSide, SideBuy))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "P3", TagExecType, ExecCanceled)
+
+	// the cancelled order's ClOrdIDs outlive it for the window, then go
+	s := acc.sessions["C"]
+	acc.mu.Lock()
+	if len(s.orders) != 2 || len(s.done) != 1 {
+		acc.mu.Unlock()
+		t.Fatalf("expected P1 and P2 kept after the cancel, got %d and %d done", len(s.orders), len(s.done))
+	}
+	s.done[0].at = s.done[0].at.Add(-engine.ClientIDWindow)
+	acc.mu.Unlock()
+
+	c.Send(newOrder("P4", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "P4", TagExecType, ExecNew)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	if _, ok := s.orders["P4"]; !ok || len(s.orders) != 1 || len(s.done) != 0 {
+		t.Fatalf("expected only P4 left, got %v", s.orders)
+	}
+}
+
+func TestResendAfterReconnect(t *testing.T) {
+	acc := newGateway(t)
+	buyer := connect(t, acc, "BUYER", 1)
+	seller := connect(t, acc, "SELLER", 1)
+
+	buyer.Send(newOrder("B1", SideBuy, "10", "5.00"))
+	ack := expect(t, buyer, MsgExecutionReport, TagExecType, ExecNew)
+	seen, _ := ack.GetInt(TagMsgSeqNum)
+	buyer.Logout() // the server's Logout reply is seen+1
+
+	// fills while the buyer is away are stored for it
+	seller.Send(newOrder("S1", SideSell, "10", "5.00"))
+	expect(t, seller, MsgExecutionReport, TagExecType, ExecNew)
+	expect(t, seller, MsgExecutionReport, TagExecType, ExecTrade)
+
+	// logging on again with a stale sequence number is refused
+	stale := dial(t, acc, "BUYER", 1)
+	if m, err := stale.Logon(30, false); err == nil || m.MsgType() != MsgLogout || !strings.Contains(m.Get(TagText), "too low") {
+		t.Fatalf("stale logon: %v %v", m, err)
+	}
+	stale.Close()
+
+	back := connect(t, acc, "BUYER", buyer.NextSeq())
+	back.Send(NewMessage(MsgResendRequest).SetInt(TagBeginSeqNo, seen+2).SetInt(TagEndSeqNo, 0))
+	fill := expect(t, back, MsgExecutionReport, TagExecType, ExecTrade, TagClOrdID, "B1", TagPossDupFlag, "Y", TagOrdStatus, ExecFilled)
+	if n, _ := fill.GetInt(TagMsgSeqNum); n != seen+2 || !fill.Has(TagOrigSendingTime) {
+		t.Fatalf("bad resent message: %s", fill)
+	}
+	// the Logon in between is an admin message and is gap filled
+	expect(t, back, MsgSequenceReset, TagGapFillFlag, "Y", TagMsgSeqNum, strconv.FormatInt(seen+3, 10), TagNewSeqNo, strconv.FormatInt(seen+4, 10))
+}
+
+func TestLogonNeedsTheAccountsKey(t *testing.T) {
+	acc := newGateway(t)
+
+	anon := dial(t, acc, "ANON", 1)
+	anon.SetCredentials("", "")
+	if m, err := anon.Logon(30, false); err == nil || !strings.Contains(m.Get(TagText), "Password") {
+		t.Fatalf("expected a logon without a key refused, got %v %v", m, err)
+	}
+
+	wrong := dial(t, acc, "MALLORY", 1)
+	wrong.SetCredentials(wrong.username, "not-the-secret")
+	if m, err := wrong.Logon(30, false); err == nil || !strings.Contains(m.Get(TagText), "Password") {
+		t.Fatalf("expected a bad password refused, got %v %v", m, err)
+	}
+
+	// a valid key of one account cannot log on as another
+	k, _ := acc.cfg.Keys.Create("MALLORY", false)
+	victim := dial(t, acc, "VICTIM", 1)
+	victim.SetCredentials(k.ID, k.Secret)
+	if m, err := victim.Logon(30, false); err == nil || !strings.Contains(m.Get(TagText), "account") {
+		t.Fatalf("expected MALLORY's key refused for VICTIM, got %v %v", m, err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	acc := newGateway(t)
+	budgets, err := ratelimit.ParseBudgets("order=1,cancel=1,query=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.cfg.Limits = ratelimit.New(budgets, budgets)
+	c := connect(t, acc, "C", 1)
+
+	c.Send(newOrder("L1", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "L1", TagExecType, ExecNew)
+	c.Send(newOrder("L2", SideBuy, "10", "1.00"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "L2", TagExecType, ExecRejected, TagText, "rate limit exceeded")
+	c.Send(NewMessage(MsgOrderCancelReplace).Set(TagOrigClOrdID, "L1").Set(TagClOrdID, "L3").Set(TagSymbol, "ABC").Set(TagSide, SideBuy).Set(TagOrderQty, "5"))
+	expect(t, c, MsgOrderCancelReject, TagClOrdID, "L3", TagCxlRejResponseTo, "2", TagText, "rate limit exceeded")
+
+	// the address spent its logon token; another logon is refused before
+	// its key is looked at
+	again := dial(t, acc, "D", 1)
+	again.SetCredentials("", "")
+	if m, err := again.Logon(30, false); err == nil || m.Get(TagText) != "rate limit exceeded" {
+		t.Fatalf("expected the logon over the limit, got %v %v", m, err)
+	}
+}
+
+func TestInboundGapRequestsResend(t *testing.T) {
+	acc := newGateway(t)
+	c := connect(t, acc, "C", 1)
+
+	// skip 2 and 3
+	c.SetNextSeq(4)
+	c.Send(NewMessage(MsgHeartbeat))
+	expect(t, c, MsgResendRequest, TagBeginSeqNo, "2", TagEndSeqNo, "0")
+
+	c.SetNextSeq(2)
+	c.Send(NewMessage(MsgSequenceReset).Set(TagPossDupFlag, "Y").Set(TagGapFillFlag, "Y").SetInt(TagNewSeqNo, 5))
+	c.SetNextSeq(5)
+	c.Send(newOrder("A", SideSell, "1", "9.99"))
+	expect(t, c, MsgExecutionReport, TagClOrdID, "A", TagExecType, ExecNew)
+}
+
+func TestFileStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir, "EXCH-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.Save(1, []byte("one"))
+	fs.Save(2, []byte("two"))
+	fs.SetNextSenderSeq(3)
+	fs.SetNextTargetSeq(9)
+	fs.Close()
+
+	fs, err = NewFileStore(dir, "EXCH-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if fs.NextSenderSeq() != 3 || fs.NextTargetSeq() != 9 {
+		t.Fatalf("seqnums not restored: %d %d", fs.NextSenderSeq(), fs.NextTargetSeq())
+	}
+	msgs, seqs := fs.Messages(2, 5)
+	if len(seqs) != 1 || string(msgs[2]) != "two" {
+		t.Fatalf("messages not restored: %v", seqs)
+	}
+}
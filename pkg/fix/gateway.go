@@ -0,0 +1,432 @@
+package fix
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// order is the gateway's view of an order entered over FIX. Fields are
+// guarded by Acceptor.mu.
+type order struct {
+	sess     *session
+	id       string // engine order ID, sent as OrderID (37)
+	clOrdID  string // current ClOrdID; a replace moves it forward
+	symbol   string
+	side     model.Side
+	typ      model.OrderType
+	price    int64
+	qty      int64
+	cum      int64
+	notional int64    // sum of fill price * qty, for AvgPx
+	clOrdIDs []string // every ClOrdID it has had, for pruning
+
+	// ClOrdIDs of the cancel or replace in flight, reported on the
+	// matching Cancelled/Amended event
+	pendingCancel  string
+	pendingReplace string
+}
+
+// Execution reports are driven by engine events rather than by the
+// Router's replies, so they come out in engine order: a resting order's
+// fills, triggered by someone else's submit, are reported exactly like the
+// fills of an aggressive order.
+func (a *Acceptor) run() {
+	defer a.wg.Done()
+	for ev := range a.sub.C() {
+		a.onEvent(&ev)
+	}
+}
+
+func (a *Acceptor) onEvent(ev *events.Event) {
+	a.mu.Lock()
+	o, ok := a.byID[ev.Order.ID]
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	execID := ev.Symbol + "-" + strconv.FormatUint(ev.Seq, 10)
+	var er *Message
+	switch ev.Type {
+	case events.OrderAccepted:
+		er = o.execReport(execID).Set(TagExecType, ExecNew)
+	case events.OrderRejected:
+		o.sess.finishLocked(o)
+		er = o.execReport(execID).
+			Set(TagExecType, ExecRejected).
+			Set(TagOrdStatus, ExecRejected).
+			Set(TagOrdRejReason, "99").
+			SetInt(TagLeavesQty, 0).
+			Set(TagText, ev.Reason)
+	case events.OrderFilled:
+		o.cum = ev.Order.Filled
+		o.notional += ev.FillPrice * ev.FillQty
+		if o.cum >= o.qty {
+			o.sess.finishLocked(o)
+		}
+		er = o.execReport(execID).
+			Set(TagExecType, ExecTrade).
+			SetInt(TagLastQty, ev.FillQty).
+			Set(TagLastPx, formatPrice(ev.FillPrice))
+		if ev.Fee != 0 {
+			er.Set(TagCommission, formatPrice(ev.Fee)).Set(TagCommType, CommTypeAbsolute)
+		}
+	case events.OrderCancelled:
+		o.sess.finishLocked(o)
+		er = o.execReport(execID)
+		if o.pendingCancel != "" {
+			er.Set(TagClOrdID, o.pendingCancel).Set(TagOrigClOrdID, o.clOrdID)
+			o.pendingCancel = ""
+		}
+		er.Set(TagExecType, ExecCanceled).
+			Set(TagOrdStatus, ExecCanceled).
+			SetInt(TagLeavesQty, 0)
+	case events.OrderAmended:
+		// also seen for amends made over REST, reported under the
+		// current ClOrdID
+		o.price, o.qty = ev.Order.Price, ev.Order.Quantity
+		er = o.execReport(execID)
+		if o.pendingReplace != "" {
+			o.sess.orders[o.pendingReplace] = o
+			o.clOrdIDs = append(o.clOrdIDs, o.pendingReplace)
+			er.Set(TagClOrdID, o.pendingReplace).Set(TagOrigClOrdID, o.clOrdID)
+			o.clOrdID, o.pendingReplace = o.pendingReplace, ""
+		}
+		er.Set(TagExecType, ExecReplaced)
+	}
+	a.mu.Unlock()
+	if er != nil {
+		o.sess.send(er)
+	}
+}
+
+// finishLocked takes o off the live orders. Its ClOrdIDs stay taken until
+// pruneLocked drops them. Caller holds Acceptor.mu.
+func (s *session) finishLocked(o *order) {
+	delete(s.acc.byID, o.id)
+	s.done = append(s.done, doneOrder{o, time.Now()})
+}
+
+// pruneLocked forgets the orders done for longer than engine.ClientIDWindow,
+// after which the engine lets the account use their ClOrdIDs again. Caller
+// holds Acceptor.mu.
+func (s *session) pruneLocked(now time.Time) {
+	for len(s.done) > 0 && now.Sub(s.done[0].at) > engine.ClientIDWindow {
+		for _, id := range s.done[0].o.clOrdIDs {
+			if s.orders[id] == s.done[0].o {
+				delete(s.orders, id)
+			}
+		}
+		s.done[0] = doneOrder{}
+		s.done = s.done[1:]
+	}
+}
+
+// execReport builds an ExecutionReport carrying the order's current state;
+// callers set ExecType and anything specific to the execution.
+func (o *order) execReport(execID string) *Message {
+	status := ExecNew
+	switch {
+	case o.cum >= o.qty:
+		status = ExecFilled
+	case o.cum > 0:
+		status = ExecPartial
+	}
+	m := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, o.id).
+		Set(TagClOrdID, o.clOrdID).
+		Set(TagExecID, execID).
+		Set(TagOrdStatus, status).
+		Set(TagSymbol, o.symbol).
+		Set(TagSide, fixSide(o.side)).
+		Set(TagOrdType, fixOrdType(o.typ)).
+		SetInt(TagOrderQty, o.qty)
+	if o.typ == model.LIMIT {
+		m.Set(TagPrice, formatPrice(o.price))
+	}
+	m.SetInt(TagLeavesQty, o.qty-o.cum).
+		SetInt(TagCumQty, o.cum).
+		Set(TagAvgPx, o.avgPx()).
+		Set(TagTransactTime, time.Now().UTC().Format(TimeFormat))
+	return m
+}
+
+func (o *order) avgPx() string {
+	if o.cum == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(o.notional)/float64(o.cum)/100, 'f', 4, 64)
+}
+
+// onNewOrder handles NewOrderSingle (D).
+func (s *session) onNewOrder(m *Message) {
+	clOrdID := m.Get(TagClOrdID)
+	o, err := parseNewOrder(m)
+	if err == nil && clOrdID == "" {
+		err = errors.New("ClOrdID missing")
+	}
+	if err == nil {
+		err = o.Validate()
+	}
+
+	a := s.acc
+	a.mu.Lock()
+	s.pruneLocked(time.Now())
+	if _, dup := s.orders[clOrdID]; err == nil && dup {
+		err = errors.New("duplicate ClOrdID")
+	}
+	if err != nil {
+		a.mu.Unlock()
+		s.rejectNew(m, err.Error())
+		return
+	}
+	o.ID = a.router.NewOrderID(o.Symbol)
+	o.Timestamp = time.Now().UnixMilli()
+	o.Account = s.target
+	o.ClientOrderID = clOrdID
+	// registered before submit: the events it produces arrive before
+	// SubmitOrder returns
+	ord := &order{sess: s, id: o.ID, clOrdID: clOrdID, symbol: o.Symbol, side: o.Side, typ: o.Type, price: o.Price, qty: o.Quantity, clOrdIDs: []string{clOrdID}}
+	s.orders[clOrdID] = ord
+	a.byID[o.ID] = ord
+	a.mu.Unlock()
+
+	// neither a pre-trade refusal nor a ClOrdID the account used on
+	// another session or gateway reaches the shard, so no event reports it
+	res := a.router.SubmitOrder(o)
+	if res.Reject != nil || res.Replayed || res.Err == engine.ErrClientOrderIDReused.Error() {
+		a.mu.Lock()
+		delete(s.orders, clOrdID)
+		delete(a.byID, o.ID)
+		a.mu.Unlock()
+		text := res.Err
+		if res.Replayed {
+			text = "duplicate ClOrdID"
+		}
+		s.rejectNew(m, text)
+	}
+}
+
+// rejectNew reports a NewOrderSingle that never reached the engine.
+func (s *session) rejectNew(m *Message, text string) {
+	er := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, "NONE").
+		Set(TagClOrdID, m.Get(TagClOrdID)).
+		Set(TagExecID, uuid.NewString()).
+		Set(TagExecType, ExecRejected).
+		Set(TagOrdStatus, ExecRejected).
+		Set(TagOrdRejReason, "99").
+		Set(TagSymbol, m.Get(TagSymbol)).
+		Set(TagSide, m.Get(TagSide)).
+		SetInt(TagLeavesQty, 0).
+		SetInt(TagCumQty, 0).
+		Set(TagAvgPx, "0").
+		Set(TagText, text)
+	if q := m.Get(TagOrderQty); q != "" {
+		er.Set(TagOrderQty, q)
+	}
+	s.send(er)
+}
+
+// onCancel handles OrderCancelRequest (F).
+func (s *session) onCancel(m *Message) {
+	clOrdID, orig := m.Get(TagClOrdID), m.Get(TagOrigClOrdID)
+	a := s.acc
+	a.mu.Lock()
+	o, err := s.pendingOrder(clOrdID, orig)
+	if err != nil {
+		a.mu.Unlock()
+		s.cancelReject(m, nil, "1", "1", err.Error())
+		return
+	}
+	o.pendingCancel = clOrdID
+	a.mu.Unlock()
+
+	if res := a.router.CancelOrder(o.symbol, o.id); !res.OK {
+		a.mu.Lock()
+		o.pendingCancel = ""
+		a.mu.Unlock()
+		s.cancelReject(m, o, "1", "0", res.Err)
+	}
+}
+
+// onReplace handles OrderCancelReplaceRequest (G). Only price and OrderQty
+// can change; see OrderBook.amend for what happens to queue priority.
+func (s *session) onReplace(m *Message) {
+	clOrdID, orig := m.Get(TagClOrdID), m.Get(TagOrigClOrdID)
+	a := s.acc
+	a.mu.Lock()
+	o, err := s.pendingOrder(clOrdID, orig)
+	if err != nil {
+		a.mu.Unlock()
+		s.cancelReject(m, nil, "2", "1", err.Error())
+		return
+	}
+	price, qty := o.price, o.qty
+	if v := m.Get(TagPrice); v != "" {
+		price, err = parsePrice(v)
+	}
+	if err == nil && m.Has(TagOrderQty) {
+		qty, err = m.GetInt(TagOrderQty)
+	}
+	if err == nil && o.typ != model.LIMIT {
+		err = errors.New("only limit orders can be replaced")
+	}
+	if err == nil && m.Get(TagSide) != "" && m.Get(TagSide) != fixSide(o.side) {
+		err = errors.New("side cannot be changed")
+	}
+	if err != nil {
+		a.mu.Unlock()
+		s.cancelReject(m, o, "2", "99", err.Error())
+		return
+	}
+	o.pendingReplace = clOrdID
+	a.mu.Unlock()
+
+	if res := a.router.AmendOrder(o.symbol, o.id, price, qty); res.Err != "" {
+		a.mu.Lock()
+		o.pendingReplace = ""
+		a.mu.Unlock()
+		s.cancelReject(m, o, "2", "0", res.Err)
+	}
+}
+
+// pendingOrder finds the live order a cancel or replace refers to.
+// Caller holds Acceptor.mu.
+func (s *session) pendingOrder(clOrdID, orig string) (*order, error) {
+	if clOrdID == "" {
+		return nil, errors.New("ClOrdID missing")
+	}
+	if _, dup := s.orders[clOrdID]; dup {
+		return nil, errors.New("duplicate ClOrdID")
+	}
+	o, ok := s.orders[orig]
+	if !ok || orig != o.clOrdID {
+		return nil, errors.New("unknown order")
+	}
+	if _, live := s.acc.byID[o.id]; !live {
+		return nil, errors.New("order is not open")
+	}
+	if o.pendingCancel != "" || o.pendingReplace != "" {
+		return nil, errors.New("order has a cancel or replace pending")
+	}
+	return o, nil
+}
+
+// cancelReject sends OrderCancelReject (9). responseTo is CxlRejResponseTo
+// (1 cancel, 2 replace), reason is CxlRejReason.
+func (s *session) cancelReject(m *Message, o *order, responseTo, reason, text string) {
+	r := NewMessage(MsgOrderCancelReject).
+		Set(TagClOrdID, m.Get(TagClOrdID)).
+		Set(TagOrigClOrdID, m.Get(TagOrigClOrdID)).
+		Set(TagOrderID, "NONE").
+		Set(TagOrdStatus, ExecRejected).
+		Set(TagCxlRejResponseTo, responseTo).
+		Set(TagCxlRejReason, reason).
+		Set(TagText, text)
+	if o != nil {
+		s.acc.mu.Lock()
+		st := o.execReport("").Get(TagOrdStatus)
+		if _, live := s.acc.byID[o.id]; !live && o.cum < o.qty {
+			st = ExecCanceled
+		}
+		s.acc.mu.Unlock()
+		r.Set(TagOrderID, o.id).Set(TagOrdStatus, st)
+	}
+	s.send(r)
+}
+
+func parseNewOrder(m *Message) (*model.Order, error) {
+	o := &model.Order{Symbol: m.Get(TagSymbol)}
+	switch m.Get(TagSide) {
+	case SideBuy:
+		o.Side = model.BUY
+	case SideSell:
+		o.Side = model.SELL
+	default:
+		return nil, fmt.Errorf("unsupported Side %q", m.Get(TagSide))
+	}
+	switch m.Get(TagOrdType) {
+	case OrdTypeLimit:
+		o.Type = model.LIMIT
+		p, err := parsePrice(m.Get(TagPrice))
+		if err != nil {
+			return nil, err
+		}
+		o.Price = p
+	case OrdTypeMarket:
+		o.Type = model.MARKET
+	default:
+		return nil, fmt.Errorf("unsupported OrdType %q", m.Get(TagOrdType))
+	}
+	q, err := m.GetInt(TagOrderQty)
+	if err != nil {
+		return nil, err
+	}
+	o.Quantity = q
+	return o, nil
+}
+
+// parsePrice converts a FIX decimal price to integer cents. Sub-cent
+// precision is rejected rather than rounded.
+func parsePrice(v string) (int64, error) {
+	whole, frac, _ := strings.Cut(v, ".")
+	if !digits(whole) || (frac != "" && !digits(frac)) {
+		return 0, fmt.Errorf("bad price %q", v)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("price %q has sub-cent precision", v)
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("bad price %q", v)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	c, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad price %q", v)
+	}
+	return w*100 + c, nil
+}
+
+// digits reports whether s is one or more decimal digits, with no sign.
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// formatPrice converts integer cents to a FIX decimal price.
+func formatPrice(cents int64) string {
+	if cents < 0 {
+		return "-" + formatPrice(-cents) // rebates
+	}
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+func fixSide(s model.Side) string {
+	if s == model.SELL {
+		return SideSell
+	}
+	return SideBuy
+}
+
+func fixOrdType(t model.OrderType) string {
+	if t == model.MARKET {
+		return OrdTypeMarket
+	}
+	return OrdTypeLimit
+}
@@ -0,0 +1,172 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Initiator is a minimal client side of a FIX session, enough to drive the
+// gateway from tests and tools. It answers TestRequests and swallows
+// Heartbeats; everything else is handed to the caller through Next, which
+// is responsible for the rest of the session protocol.
+type Initiator struct {
+	conn               net.Conn
+	sender, target     string
+	username, password string
+
+	mu      sync.Mutex
+	nextOut int
+
+	in        chan *Message
+	err       error // set before in is closed
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewInitiator wraps conn. nextSeq is the MsgSeqNum of the first message
+// sent, 1 for a fresh session.
+func NewInitiator(conn net.Conn, sender, target string, nextSeq int) *Initiator {
+	i := &Initiator{
+		conn:    conn,
+		sender:  sender,
+		target:  target,
+		nextOut: nextSeq,
+		in:      make(chan *Message, 1024),
+		done:    make(chan struct{}),
+	}
+	go i.readLoop()
+	return i
+}
+
+func (i *Initiator) readLoop() {
+	defer close(i.in)
+	r := bufio.NewReader(i.conn)
+	for {
+		m, err := ReadMessage(r)
+		if err != nil {
+			i.err = err
+			return
+		}
+		switch m.MsgType() {
+		case MsgHeartbeat:
+			continue
+		case MsgTestRequest:
+			i.Send(NewMessage(MsgHeartbeat).Set(TagTestReqID, m.Get(TagTestReqID)))
+			continue
+		}
+		select {
+		case i.in <- m:
+		case <-i.done:
+			return
+		}
+	}
+}
+
+// NextSeq returns the MsgSeqNum the next Send will use.
+func (i *Initiator) NextSeq() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.nextOut
+}
+
+// SetNextSeq changes the MsgSeqNum of the next Send, for sequence resets.
+func (i *Initiator) SetNextSeq(n int) {
+	i.mu.Lock()
+	i.nextOut = n
+	i.mu.Unlock()
+}
+
+// Send fills in the header of m and writes it.
+func (i *Initiator) Send(m *Message) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	m.Set(TagSenderCompID, i.sender)
+	m.Set(TagTargetCompID, i.target)
+	m.SetInt(TagMsgSeqNum, int64(i.nextOut))
+	m.Set(TagSendingTime, time.Now().UTC().Format(TimeFormat))
+	i.nextOut++
+	_, err := i.conn.Write(m.Bytes())
+	return err
+}
+
+// SendRaw writes m as is, header included. Tests use it to send out of
+// sequence.
+func (i *Initiator) SendRaw(m *Message) error {
+	_, err := i.conn.Write(m.Bytes())
+	return err
+}
+
+// Next returns the next application or session message other than a
+// Heartbeat or TestRequest.
+func (i *Initiator) Next(timeout time.Duration) (*Message, error) {
+	select {
+	case m, ok := <-i.in:
+		if !ok {
+			return nil, fmt.Errorf("fix: connection closed: %w", i.err)
+		}
+		return m, nil
+	case <-time.After(timeout):
+		return nil, errors.New("fix: timed out waiting for message")
+	}
+}
+
+// SetCredentials sets the Username and Password sent on Logon.
+func (i *Initiator) SetCredentials(username, password string) {
+	i.username, i.password = username, password
+}
+
+// Logon sends a Logon and waits for the acceptor's.
+func (i *Initiator) Logon(heartBtInt int, reset bool) (*Message, error) {
+	m := NewMessage(MsgLogon).
+		Set(TagEncryptMethod, "0").
+		SetInt(TagHeartBtInt, int64(heartBtInt))
+	if reset {
+		m.Set(TagResetSeqNumFlag, "Y")
+	}
+	if i.username != "" {
+		m.Set(TagUsername, i.username).Set(TagPassword, i.password)
+	}
+	if err := i.Send(m); err != nil {
+		return nil, err
+	}
+	resp, err := i.Next(5 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if resp.MsgType() != MsgLogon {
+		return resp, fmt.Errorf("fix: logon refused: %s", resp.Get(TagText))
+	}
+	return resp, nil
+}
+
+// Logout sends a Logout, waits briefly for the reply and closes the
+// connection.
+func (i *Initiator) Logout() error {
+	err := i.Send(NewMessage(MsgLogout))
+	if err == nil {
+		deadline := time.After(2 * time.Second)
+	wait:
+		for {
+			select {
+			case m, ok := <-i.in:
+				if !ok || m.MsgType() == MsgLogout {
+					break wait
+				}
+			case <-deadline:
+				break wait
+			}
+		}
+	}
+	i.Close()
+	return err
+}
+
+// Close drops the connection without logging out.
+func (i *Initiator) Close() error {
+	i.closeOnce.Do(func() { close(i.done) })
+	return i.conn.Close()
+}
@@ -0,0 +1,205 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SOH is the FIX field delimiter.
+const SOH = '\x01'
+
+// BeginString is the only protocol version this package speaks.
+const BeginString = "FIX.4.4"
+
+// Field is one tag=value pair.
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is a FIX message without its framing fields (8, 9 and 10), which
+// are computed by Bytes and checked by ReadMessage.
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage creates a message of the given MsgType.
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{TagMsgType, msgType}}}
+}
+
+// MsgType returns tag 35.
+func (m *Message) MsgType() string {
+	return m.Get(TagMsgType)
+}
+
+// Get returns the first value of tag, or "".
+func (m *Message) Get(tag int) string {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Has reports whether tag is present.
+func (m *Message) Has(tag int) bool {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInt parses tag as an integer.
+func (m *Message) GetInt(tag int) (int64, error) {
+	v := m.Get(tag)
+	if v == "" {
+		return 0, fmt.Errorf("missing tag %d", tag)
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tag %d: not an integer: %q", tag, v)
+	}
+	return n, nil
+}
+
+// Set replaces the value of tag, or appends it if absent.
+func (m *Message) Set(tag int, value string) *Message {
+	for i := range m.Fields {
+		if m.Fields[i].Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{tag, value})
+	return m
+}
+
+// SetInt is Set for integers.
+func (m *Message) SetInt(tag int, v int64) *Message {
+	return m.Set(tag, strconv.FormatInt(v, 10))
+}
+
+// headerOrder is the order standard header fields must appear in, right
+// after BodyLength.
+var headerOrder = []int{TagMsgType, TagSenderCompID, TagTargetCompID, TagMsgSeqNum, TagPossDupFlag, TagSendingTime, TagOrigSendingTime}
+
+func isHeader(tag int) bool {
+	for _, t := range headerOrder {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes encodes the message with BeginString, BodyLength and CheckSum.
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	for _, tag := range headerOrder {
+		if m.Has(tag) {
+			writeField(&body, tag, m.Get(tag))
+		}
+	}
+	for _, f := range m.Fields {
+		if !isHeader(f.Tag) {
+			writeField(&body, f.Tag, f.Value)
+		}
+	}
+
+	var out bytes.Buffer
+	writeField(&out, TagBeginString, BeginString)
+	writeField(&out, TagBodyLength, strconv.Itoa(body.Len()))
+	out.Write(body.Bytes())
+	writeField(&out, TagCheckSum, fmt.Sprintf("%03d", checksum(out.Bytes())))
+	return out.Bytes()
+}
+
+func (m *Message) String() string {
+	return string(bytes.ReplaceAll(m.Bytes(), []byte{SOH}, []byte{'|'}))
+}
+
+func writeField(b *bytes.Buffer, tag int, value string) {
+	b.WriteString(strconv.Itoa(tag))
+	b.WriteByte('=')
+	b.WriteString(value)
+	b.WriteByte(SOH)
+}
+
+func checksum(b []byte) int {
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	return sum % 256
+}
+
+// ErrGarbled is returned for a message that fails framing or checksum.
+var ErrGarbled = errors.New("fix: garbled message")
+
+// ReadMessage reads one framed message from r.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	begin, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	if begin != "8="+BeginString+string(SOH) {
+		return nil, fmt.Errorf("%w: unexpected BeginString %q", ErrGarbled, begin)
+	}
+	lenField, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	if len(lenField) < 4 || lenField[:2] != "9=" {
+		return nil, fmt.Errorf("%w: missing BodyLength", ErrGarbled)
+	}
+	n, err := strconv.Atoi(lenField[2 : len(lenField)-1])
+	if err != nil || n <= 0 || n > 1<<20 {
+		return nil, fmt.Errorf("%w: bad BodyLength %q", ErrGarbled, lenField)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	sumField, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	if len(sumField) != 7 || sumField[:3] != "10=" {
+		return nil, fmt.Errorf("%w: missing CheckSum", ErrGarbled)
+	}
+	want, _ := strconv.Atoi(sumField[3:6])
+	got := (checksum([]byte(begin)) + checksum([]byte(lenField)) + checksum(body)) % 256
+	if want != got {
+		return nil, fmt.Errorf("%w: checksum %d, computed %d", ErrGarbled, want, got)
+	}
+
+	m := &Message{}
+	for _, raw := range bytes.Split(bytes.TrimSuffix(body, []byte{SOH}), []byte{SOH}) {
+		eq := bytes.IndexByte(raw, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%w: bad field %q", ErrGarbled, raw)
+		}
+		tag, err := strconv.Atoi(string(raw[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad tag %q", ErrGarbled, raw[:eq])
+		}
+		m.Fields = append(m.Fields, Field{tag, string(raw[eq+1:])})
+	}
+	if m.MsgType() == "" {
+		return nil, fmt.Errorf("%w: missing MsgType", ErrGarbled)
+	}
+	return m, nil
+}
+
+// ParseMessage decodes one framed message held in memory.
+func ParseMessage(raw []byte) (*Message, error) {
+	return ReadMessage(bufio.NewReader(bytes.NewReader(raw)))
+}
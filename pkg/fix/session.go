@@ -0,0 +1,357 @@
+package fix
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+// session is the acceptor's state for one counterparty CompID. It outlives
+// connections: sequence numbers and orders carry over a reconnect, and
+// execution reports produced while the counterparty is away are stored and
+// delivered when it asks for a resend.
+type session struct {
+	acc    *Acceptor
+	target string // counterparty CompID
+	store  Store
+
+	mu   sync.Mutex // guards conn and the send sequence
+	conn *sessionConn
+
+	orders map[string]*order // ClOrdID -> order, guarded by Acceptor.mu
+	done   []doneOrder       // orders no longer open, oldest first, guarded by Acceptor.mu
+}
+
+// doneOrder is an order that stopped trading at a time.
+type doneOrder struct {
+	o  *order
+	at time.Time
+}
+
+// sessionConn is one logged-on TCP connection of a session.
+type sessionConn struct {
+	c       net.Conn
+	ip      string // for the rate limits
+	out     chan []byte
+	done    chan struct{}
+	once    sync.Once
+	heartBt time.Duration
+
+	lastRecv atomic.Int64 // unix nanos
+	lastSent atomic.Int64
+	testReq  atomic.Bool // TestRequest outstanding
+
+	resendPending bool // read loop only
+}
+
+func (sc *sessionConn) close() {
+	sc.once.Do(func() {
+		close(sc.done)
+		sc.c.Close()
+	})
+}
+
+// shutdown lets the writer flush what is queued (a Logout, typically) and
+// then closes the connection.
+func (sc *sessionConn) shutdown() {
+	sc.write(nil)
+	select {
+	case <-sc.done:
+	case <-time.After(time.Second):
+		sc.close()
+	}
+}
+
+// write queues raw for the writer; nil asks the writer to close once the
+// queue ahead of it is flushed. A connection that cannot drain its queue is
+// dropped; stored messages are recovered through a resend after logon.
+func (sc *sessionConn) write(raw []byte) {
+	select {
+	case sc.out <- raw:
+	case <-sc.done:
+	default:
+		log.Println("fix: outbound queue full, disconnecting")
+		sc.close()
+	}
+}
+
+func (sc *sessionConn) writeLoop() {
+	defer sc.close()
+	for {
+		select {
+		case raw := <-sc.out:
+			if raw == nil {
+				return
+			}
+			sc.c.SetWriteDeadline(time.Now().Add(sc.heartBt))
+			if _, err := sc.c.Write(raw); err != nil {
+				return
+			}
+			sc.lastSent.Store(time.Now().UnixNano())
+		case <-sc.done:
+			return
+		}
+	}
+}
+
+// send stamps m with the next MsgSeqNum, stores it and writes it if the
+// counterparty is connected.
+func (s *session) send(m *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendLocked(m)
+}
+
+func (s *session) sendLocked(m *Message) {
+	seq := s.store.NextSenderSeq()
+	m.Set(TagSenderCompID, s.acc.cfg.CompID)
+	m.Set(TagTargetCompID, s.target)
+	m.SetInt(TagMsgSeqNum, int64(seq))
+	m.Set(TagSendingTime, time.Now().UTC().Format(TimeFormat))
+	raw := m.Bytes()
+	if err := s.store.Save(seq, raw); err != nil {
+		log.Printf("fix: %s: store: %v", s.target, err)
+	}
+	if err := s.store.SetNextSenderSeq(seq + 1); err != nil {
+		log.Printf("fix: %s: store: %v", s.target, err)
+	}
+	if s.conn != nil {
+		s.conn.write(raw)
+	}
+}
+
+// serve runs a logged-on connection until it ends.
+func (s *session) serve(sc *sessionConn, r *bufio.Reader) {
+	go sc.writeLoop()
+	go s.monitor(sc)
+	defer func() {
+		// detach first so nothing is queued behind the close marker;
+		// anything sent meanwhile is stored and goes out on a resend
+		s.mu.Lock()
+		if s.conn == sc {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+		sc.shutdown()
+	}()
+
+	for {
+		sc.c.SetReadDeadline(time.Now().Add(3 * sc.heartBt))
+		m, err := ReadMessage(r)
+		if err != nil {
+			return
+		}
+		sc.lastRecv.Store(time.Now().UnixNano())
+		sc.testReq.Store(false)
+		if !s.handle(sc, m) {
+			return
+		}
+	}
+}
+
+// monitor sends heartbeats when we are idle and a TestRequest when the
+// counterparty is; a counterparty silent for two intervals is dropped.
+func (s *session) monitor(sc *sessionConn) {
+	t := time.NewTicker(sc.heartBt / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-sc.done:
+			return
+		}
+		now := time.Now().UnixNano()
+		hb := int64(sc.heartBt)
+		if now-sc.lastSent.Load() >= hb {
+			s.send(NewMessage(MsgHeartbeat))
+		}
+		idle := now - sc.lastRecv.Load()
+		switch {
+		case idle >= 2*hb+hb/5:
+			log.Printf("fix: %s: no heartbeat, disconnecting", s.target)
+			sc.close()
+			return
+		case idle >= hb+hb/5 && !sc.testReq.Load():
+			sc.testReq.Store(true)
+			s.send(NewMessage(MsgTestRequest).Set(TagTestReqID, strconv.FormatInt(now, 10)))
+		}
+	}
+}
+
+// handle applies sequence checks and dispatches one inbound message.
+// It returns false when the connection must end.
+func (s *session) handle(sc *sessionConn, m *Message) bool {
+	seq64, err := m.GetInt(TagMsgSeqNum)
+	if err != nil {
+		s.logout(sc, "MsgSeqNum missing")
+		return false
+	}
+	seq := int(seq64)
+	expected := s.store.NextTargetSeq()
+	possDup := m.Get(TagPossDupFlag) == "Y"
+
+	if m.MsgType() == MsgSequenceReset {
+		newSeq, err := m.GetInt(TagNewSeqNo)
+		if err != nil {
+			s.reject(m, seq, "NewSeqNo missing")
+			return true
+		}
+		gapFill := m.Get(TagGapFillFlag) == "Y"
+		if gapFill && seq < expected {
+			return true // already seen
+		}
+		if gapFill && seq > expected {
+			s.requestResend(sc, expected)
+			return true
+		}
+		if int(newSeq) > expected {
+			s.store.SetNextTargetSeq(int(newSeq))
+			sc.resendPending = false
+		}
+		return true
+	}
+
+	if seq > expected {
+		s.requestResend(sc, expected)
+		return true
+	}
+	if seq < expected {
+		if possDup {
+			return true
+		}
+		s.logout(sc, fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", expected, seq))
+		return false
+	}
+	s.store.SetNextTargetSeq(seq + 1)
+	sc.resendPending = false
+
+	switch m.MsgType() {
+	case MsgHeartbeat:
+	case MsgTestRequest:
+		s.send(NewMessage(MsgHeartbeat).Set(TagTestReqID, m.Get(TagTestReqID)))
+	case MsgResendRequest:
+		s.resend(sc, m)
+	case MsgReject:
+		log.Printf("fix: %s: session reject of %s: %s", s.target, m.Get(TagRefSeqNum), m.Get(TagText))
+	case MsgLogout:
+		s.send(NewMessage(MsgLogout))
+		return false
+	case MsgLogon:
+		s.reject(m, seq, "already logged on")
+	case MsgNewOrderSingle:
+		if s.allow(sc, ratelimit.Order) {
+			s.onNewOrder(m)
+		} else {
+			s.rejectNew(m, rateLimitText)
+		}
+	case MsgOrderCancelRequest:
+		if s.allow(sc, ratelimit.Cancel) {
+			s.onCancel(m)
+		} else {
+			s.cancelReject(m, nil, "1", "99", rateLimitText)
+		}
+	case MsgOrderCancelReplace:
+		if s.allow(sc, ratelimit.Order) {
+			s.onReplace(m)
+		} else {
+			s.cancelReject(m, nil, "2", "99", rateLimitText)
+		}
+	default:
+		s.reject(m, seq, "unsupported MsgType "+m.MsgType())
+	}
+	return true
+}
+
+// rateLimitText is the Text of a message refused by Config.Limits.
+const rateLimitText = "rate limit exceeded"
+
+// allow takes a token of class for the session's account and sc's address.
+func (s *session) allow(sc *sessionConn, class ratelimit.Class) bool {
+	ok, _ := s.acc.cfg.Limits.Allow(class, s.target, sc.ip)
+	return ok
+}
+
+func (s *session) requestResend(sc *sessionConn, from int) {
+	if sc.resendPending {
+		return
+	}
+	sc.resendPending = true
+	s.send(NewMessage(MsgResendRequest).
+		SetInt(TagBeginSeqNo, int64(from)).
+		SetInt(TagEndSeqNo, 0))
+}
+
+// resend answers a ResendRequest: stored application messages go out again
+// with PossDupFlag=Y, and runs of admin messages are replaced by a
+// SequenceReset-GapFill.
+func (s *session) resend(sc *sessionConn, req *Message) {
+	begin, err1 := req.GetInt(TagBeginSeqNo)
+	end, err2 := req.GetInt(TagEndSeqNo)
+	if err1 != nil || err2 != nil || begin < 1 {
+		s.reject(req, 0, "bad ResendRequest range")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := s.store.NextSenderSeq() - 1
+	if end == 0 || int(end) > last {
+		end = int64(last)
+	}
+	msgs, _ := s.store.Messages(int(begin), int(end))
+
+	gapStart := 0
+	flushGap := func(next int) {
+		if gapStart == 0 {
+			return
+		}
+		gf := NewMessage(MsgSequenceReset).
+			Set(TagSenderCompID, s.acc.cfg.CompID).
+			Set(TagTargetCompID, s.target).
+			SetInt(TagMsgSeqNum, int64(gapStart)).
+			Set(TagPossDupFlag, "Y").
+			Set(TagSendingTime, time.Now().UTC().Format(TimeFormat)).
+			Set(TagGapFillFlag, "Y").
+			SetInt(TagNewSeqNo, int64(next))
+		sc.write(gf.Bytes())
+		gapStart = 0
+	}
+	for seq := int(begin); seq <= int(end); seq++ {
+		var orig *Message
+		if raw, ok := msgs[seq]; ok {
+			orig, _ = ParseMessage(raw)
+		}
+		if orig == nil || isAdmin(orig.MsgType()) {
+			if gapStart == 0 {
+				gapStart = seq
+			}
+			continue
+		}
+		flushGap(seq)
+		orig.Set(TagPossDupFlag, "Y")
+		orig.Set(TagOrigSendingTime, orig.Get(TagSendingTime))
+		orig.Set(TagSendingTime, time.Now().UTC().Format(TimeFormat))
+		sc.write(orig.Bytes())
+	}
+	flushGap(int(end) + 1)
+}
+
+// reject sends a session-level Reject for m.
+func (s *session) reject(m *Message, seq int, text string) {
+	s.send(NewMessage(MsgReject).
+		SetInt(TagRefSeqNum, int64(seq)).
+		Set(TagText, text))
+}
+
+// logout sends a Logout; the caller then ends the connection, which flushes
+// it before closing.
+func (s *session) logout(sc *sessionConn, text string) {
+	s.send(NewMessage(MsgLogout).Set(TagText, text))
+}
@@ -0,0 +1,222 @@
+package fix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Store persists a session's sequence numbers and the messages it sent, so
+// a restart continues the sequence and resend requests can be answered.
+type Store interface {
+	NextSenderSeq() int
+	NextTargetSeq() int
+	SetNextSenderSeq(int) error
+	SetNextTargetSeq(int) error
+	// Save records an outgoing message under its MsgSeqNum.
+	Save(seq int, raw []byte) error
+	// Messages returns saved messages with from <= seq <= to, in order.
+	Messages(from, to int) (map[int][]byte, []int)
+	// Reset starts both sequences over at 1 and forgets sent messages.
+	Reset() error
+}
+
+// MemoryStore keeps everything in memory. Used by tests and when no store
+// directory is configured.
+type MemoryStore struct {
+	mu         sync.Mutex
+	nextSender int
+	nextTarget int
+	sent       map[int][]byte
+}
+
+// NewMemoryStore creates a store starting at 1/1.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextSender: 1, nextTarget: 1, sent: make(map[int][]byte)}
+}
+
+func (s *MemoryStore) NextSenderSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextSender
+}
+
+func (s *MemoryStore) NextTargetSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextTarget
+}
+
+func (s *MemoryStore) SetNextSenderSeq(n int) error {
+	s.mu.Lock()
+	s.nextSender = n
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) SetNextTargetSeq(n int) error {
+	s.mu.Lock()
+	s.nextTarget = n
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Save(seq int, raw []byte) error {
+	s.mu.Lock()
+	s.sent[seq] = append([]byte(nil), raw...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Messages(from, to int) (map[int][]byte, []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[int][]byte)
+	var seqs []int
+	for seq, raw := range s.sent {
+		if seq >= from && seq <= to {
+			out[seq] = raw
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return out, seqs
+}
+
+func (s *MemoryStore) Reset() error {
+	s.mu.Lock()
+	s.nextSender, s.nextTarget = 1, 1
+	s.sent = make(map[int][]byte)
+	s.mu.Unlock()
+	return nil
+}
+
+// FileStore is a MemoryStore backed by two files per session in dir:
+//
+//	<id>.seqnums  "<nextSender> <nextTarget>", rewritten on every change
+//	<id>.body     append-only log of "<seq> <len>\n<raw>" records
+type FileStore struct {
+	MemoryStore
+	seqPath  string
+	bodyPath string
+	body     *os.File
+}
+
+// NewFileStore opens (or creates) the store for session id under dir.
+func NewFileStore(dir, id string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	fs := &FileStore{
+		MemoryStore: MemoryStore{nextSender: 1, nextTarget: 1, sent: make(map[int][]byte)},
+		seqPath:     filepath.Join(dir, id+".seqnums"),
+		bodyPath:    filepath.Join(dir, id+".body"),
+	}
+	if b, err := os.ReadFile(fs.seqPath); err == nil {
+		if _, err := fmt.Sscanf(string(b), "%d %d", &fs.nextSender, &fs.nextTarget); err != nil {
+			return nil, fmt.Errorf("fix store %s: %w", fs.seqPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := fs.loadBody(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(fs.bodyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	fs.body = f
+	return fs, nil
+}
+
+func (fs *FileStore) loadBody() error {
+	f, err := os.Open(fs.bodyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		hdr, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		parts := strings.Fields(hdr)
+		if len(parts) != 2 {
+			return fmt.Errorf("fix store %s: bad record header %q", fs.bodyPath, hdr)
+		}
+		seq, err1 := strconv.Atoi(parts[0])
+		n, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("fix store %s: bad record header %q", fs.bodyPath, hdr)
+		}
+		raw := make([]byte, n)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil // torn last write: keep what is complete
+		}
+		fs.sent[seq] = raw
+	}
+}
+
+func (fs *FileStore) writeSeqs() error {
+	tmp := fs.seqPath + ".tmp"
+	s := fmt.Sprintf("%d %d\n", fs.nextSender, fs.nextTarget)
+	if err := os.WriteFile(tmp, []byte(s), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.seqPath)
+}
+
+func (fs *FileStore) SetNextSenderSeq(n int) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.nextSender = n
+	return fs.writeSeqs()
+}
+
+func (fs *FileStore) SetNextTargetSeq(n int) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.nextTarget = n
+	return fs.writeSeqs()
+}
+
+func (fs *FileStore) Save(seq int, raw []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sent[seq] = append([]byte(nil), raw...)
+	if _, err := fmt.Fprintf(fs.body, "%d %d\n", seq, len(raw)); err != nil {
+		return err
+	}
+	_, err := fs.body.Write(raw)
+	return err
+}
+
+func (fs *FileStore) Reset() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.nextSender, fs.nextTarget = 1, 1
+	fs.sent = make(map[int][]byte)
+	if err := fs.body.Truncate(0); err != nil {
+		return err
+	}
+	return fs.writeSeqs()
+}
+
+// Close releases the message log.
+func (fs *FileStore) Close() error {
+	return fs.body.Close()
+}
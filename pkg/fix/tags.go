@@ -0,0 +1,104 @@
+package fix
+
+// Tags used by this gateway (FIX 4.4).
+const (
+	TagAvgPx            = 6
+	TagBeginSeqNo       = 7
+	TagBeginString      = 8
+	TagBodyLength       = 9
+	TagCheckSum         = 10
+	TagClOrdID          = 11
+	TagCommission       = 12
+	TagCommType         = 13
+	TagCumQty           = 14
+	TagEndSeqNo         = 16
+	TagExecID           = 17
+	TagLastPx           = 31
+	TagLastQty          = 32
+	TagMsgSeqNum        = 34
+	TagMsgType          = 35
+	TagNewSeqNo         = 36
+	TagOrderID          = 37
+	TagOrderQty         = 38
+	TagOrdStatus        = 39
+	TagOrdType          = 40
+	TagOrigClOrdID      = 41
+	TagPossDupFlag      = 43
+	TagPrice            = 44
+	TagRefSeqNum        = 45
+	TagSenderCompID     = 49
+	TagSendingTime      = 52
+	TagSide             = 54
+	TagSymbol           = 55
+	TagTargetCompID     = 56
+	TagText             = 58
+	TagTransactTime     = 60
+	TagEncryptMethod    = 98
+	TagCxlRejReason     = 102
+	TagOrdRejReason     = 103
+	TagHeartBtInt       = 108
+	TagTestReqID        = 112
+	TagOrigSendingTime  = 122
+	TagGapFillFlag      = 123
+	TagResetSeqNumFlag  = 141
+	TagExecType         = 150
+	TagLeavesQty        = 151
+	TagCxlRejResponseTo = 434
+	TagUsername         = 553
+	TagPassword         = 554
+)
+
+// MsgType values.
+const (
+	MsgHeartbeat          = "0"
+	MsgTestRequest        = "1"
+	MsgResendRequest      = "2"
+	MsgReject             = "3"
+	MsgSequenceReset      = "4"
+	MsgLogout             = "5"
+	MsgExecutionReport    = "8"
+	MsgOrderCancelReject  = "9"
+	MsgLogon              = "A"
+	MsgNewOrderSingle     = "D"
+	MsgOrderCancelRequest = "F"
+	MsgOrderCancelReplace = "G"
+)
+
+// isAdmin reports whether msgType is a session-level message. Admin
+// messages are never resent; a resend replaces them with a gap fill.
+func isAdmin(msgType string) bool {
+	switch msgType {
+	case MsgHeartbeat, MsgTestRequest, MsgResendRequest, MsgReject, MsgSequenceReset, MsgLogout, MsgLogon:
+		return true
+	}
+	return false
+}
+
+// Side values (54).
+const (
+	SideBuy  = "1"
+	SideSell = "2"
+)
+
+// OrdType values (40).
+const (
+	OrdTypeMarket = "1"
+	OrdTypeLimit  = "2"
+)
+
+// ExecType (150) and OrdStatus (39) values.
+const (
+	ExecNew      = "0"
+	ExecPartial  = "1" // OrdStatus only
+	ExecFilled   = "2" // OrdStatus only
+	ExecCanceled = "4"
+	ExecReplaced = "5"
+	ExecRejected = "8"
+	ExecTrade    = "F" // ExecType only
+)
+
+// CommType (13) value: Commission is an absolute amount.
+const CommTypeAbsolute = "3"
+
+// TimeFormat is UTCTimestamp with milliseconds.
+const TimeFormat = "20060102-15:04:05.000"
@@ -0,0 +1,101 @@
+package grpcapi
+
+import (
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/grpcapi/pb"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func fromSide(s pb.Side) model.Side {
+	switch s {
+	case pb.Side_BUY:
+		return model.BUY
+	case pb.Side_SELL:
+		return model.SELL
+	}
+	return ""
+}
+
+func toSide(s model.Side) pb.Side {
+	switch s {
+	case model.BUY:
+		return pb.Side_BUY
+	case model.SELL:
+		return pb.Side_SELL
+	}
+	return pb.Side_SIDE_UNSPECIFIED
+}
+
+func fromType(t pb.OrderType) model.OrderType {
+	switch t {
+	case pb.OrderType_LIMIT:
+		return model.LIMIT
+	case pb.OrderType_MARKET:
+		return model.MARKET
+	}
+	return ""
+}
+
+func toType(t model.OrderType) pb.OrderType {
+	switch t {
+	case model.LIMIT:
+		return pb.OrderType_LIMIT
+	case model.MARKET:
+		return pb.OrderType_MARKET
+	}
+	return pb.OrderType_ORDER_TYPE_UNSPECIFIED
+}
+
+func toOrder(o *model.Order) *pb.Order {
+	return &pb.Order{
+		OrderId:           o.ID,
+		Symbol:            o.Symbol,
+		Side:              toSide(o.Side),
+		Type:              toType(o.Type),
+		Price:             o.Price,
+		Quantity:          o.Quantity,
+		FilledQuantity:    o.Filled,
+		RemainingQuantity: o.Remaining(),
+		Timestamp:         o.Timestamp,
+		Account:           o.Account,
+	}
+}
+
+func toTrade(t model.Trade) *pb.Trade {
+	return &pb.Trade{
+		TradeId:       t.ID,
+		Price:         t.Price,
+		Quantity:      t.Quantity,
+		AggressorSide: toSide(t.AggressorSide),
+		Timestamp:     t.Timestamp,
+	}
+}
+
+// toLevels converts the engine's aggregated snapshot levels.
+func toLevels(side []map[string]interface{}) []*pb.Level {
+	out := make([]*pb.Level, 0, len(side))
+	for _, m := range side {
+		out = append(out, &pb.Level{Price: m["price"].(int64), Quantity: m["quantity"].(int64)})
+	}
+	return out
+}
+
+var execTypes = map[events.Type]pb.ExecutionReport_Type{
+	events.OrderAccepted:  pb.ExecutionReport_ACCEPTED,
+	events.OrderRejected:  pb.ExecutionReport_REJECTED,
+	events.OrderFilled:    pb.ExecutionReport_FILLED,
+	events.OrderCancelled: pb.ExecutionReport_CANCELLED,
+	events.OrderAmended:   pb.ExecutionReport_AMENDED,
+}
+
+func toExecReport(ev *events.Event) *pb.ExecutionReport {
+	return &pb.ExecutionReport{
+		Type:         execTypes[ev.Type],
+		Seq:          ev.Seq,
+		Timestamp:    ev.Timestamp,
+		Order:        toOrder(ev.Order),
+		FillPrice:    ev.FillPrice,
+		FillQuantity: ev.FillQty,
+		Reason:       ev.Reason,
+	}
+}
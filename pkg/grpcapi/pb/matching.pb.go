@@ -0,0 +1,1269 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: matching.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Side int32
+
+const (
+	Side_SIDE_UNSPECIFIED Side = 0
+	Side_BUY              Side = 1
+	Side_SELL             Side = 2
+)
+
+// Enum value maps for Side.
+var (
+	Side_name = map[int32]string{
+		0: "SIDE_UNSPECIFIED",
+		1: "BUY",
+		2: "SELL",
+	}
+	Side_value = map[string]int32{
+		"SIDE_UNSPECIFIED": 0,
+		"BUY":              1,
+		"SELL":             2,
+	}
+)
+
+func (x Side) Enum() *Side {
+	p := new(Side)
+	*p = x
+	return p
+}
+
+func (x Side) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Side) Descriptor() protoreflect.EnumDescriptor {
+	return file_matching_proto_enumTypes[0].Descriptor()
+}
+
+func (Side) Type() protoreflect.EnumType {
+	return &file_matching_proto_enumTypes[0]
+}
+
+func (x Side) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Side.Descriptor instead.
+func (Side) EnumDescriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{0}
+}
+
+type OrderType int32
+
+const (
+	OrderType_ORDER_TYPE_UNSPECIFIED OrderType = 0
+	OrderType_LIMIT                  OrderType = 1
+	OrderType_MARKET                 OrderType = 2
+)
+
+// Enum value maps for OrderType.
+var (
+	OrderType_name = map[int32]string{
+		0: "ORDER_TYPE_UNSPECIFIED",
+		1: "LIMIT",
+		2: "MARKET",
+	}
+	OrderType_value = map[string]int32{
+		"ORDER_TYPE_UNSPECIFIED": 0,
+		"LIMIT":                  1,
+		"MARKET":                 2,
+	}
+)
+
+func (x OrderType) Enum() *OrderType {
+	p := new(OrderType)
+	*p = x
+	return p
+}
+
+func (x OrderType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderType) Descriptor() protoreflect.EnumDescriptor {
+	return file_matching_proto_enumTypes[1].Descriptor()
+}
+
+func (OrderType) Type() protoreflect.EnumType {
+	return &file_matching_proto_enumTypes[1]
+}
+
+func (x OrderType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderType.Descriptor instead.
+func (OrderType) EnumDescriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{1}
+}
+
+type ExecutionReport_Type int32
+
+const (
+	ExecutionReport_TYPE_UNSPECIFIED ExecutionReport_Type = 0
+	ExecutionReport_ACCEPTED         ExecutionReport_Type = 1
+	ExecutionReport_REJECTED         ExecutionReport_Type = 2
+	ExecutionReport_FILLED           ExecutionReport_Type = 3
+	ExecutionReport_CANCELLED        ExecutionReport_Type = 4
+	ExecutionReport_AMENDED          ExecutionReport_Type = 5
+)
+
+// Enum value maps for ExecutionReport_Type.
+var (
+	ExecutionReport_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "ACCEPTED",
+		2: "REJECTED",
+		3: "FILLED",
+		4: "CANCELLED",
+		5: "AMENDED",
+	}
+	ExecutionReport_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"ACCEPTED":         1,
+		"REJECTED":         2,
+		"FILLED":           3,
+		"CANCELLED":        4,
+		"AMENDED":          5,
+	}
+)
+
+func (x ExecutionReport_Type) Enum() *ExecutionReport_Type {
+	p := new(ExecutionReport_Type)
+	*p = x
+	return p
+}
+
+func (x ExecutionReport_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExecutionReport_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_matching_proto_enumTypes[2].Descriptor()
+}
+
+func (ExecutionReport_Type) Type() protoreflect.EnumType {
+	return &file_matching_proto_enumTypes[2]
+}
+
+func (x ExecutionReport_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExecutionReport_Type.Descriptor instead.
+func (ExecutionReport_Type) EnumDescriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{11, 0}
+}
+
+type Order struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	OrderId           string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Symbol            string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Side              Side                   `protobuf:"varint,3,opt,name=side,proto3,enum=matching.v1.Side" json:"side,omitempty"`
+	Type              OrderType              `protobuf:"varint,4,opt,name=type,proto3,enum=matching.v1.OrderType" json:"type,omitempty"`
+	Price             int64                  `protobuf:"varint,5,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity          int64                  `protobuf:"varint,6,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	FilledQuantity    int64                  `protobuf:"varint,7,opt,name=filled_quantity,json=filledQuantity,proto3" json:"filled_quantity,omitempty"`
+	RemainingQuantity int64                  `protobuf:"varint,8,opt,name=remaining_quantity,json=remainingQuantity,proto3" json:"remaining_quantity,omitempty"`
+	Timestamp         int64                  `protobuf:"varint,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Account           string                 `protobuf:"bytes,10,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Order) Reset() {
+	*x = Order{}
+	mi := &file_matching_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Order) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Order) ProtoMessage() {}
+
+func (x *Order) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor instead.
+func (*Order) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Order) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *Order) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Order) GetSide() Side {
+	if x != nil {
+		return x.Side
+	}
+	return Side_SIDE_UNSPECIFIED
+}
+
+func (x *Order) GetType() OrderType {
+	if x != nil {
+		return x.Type
+	}
+	return OrderType_ORDER_TYPE_UNSPECIFIED
+}
+
+func (x *Order) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Order) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Order) GetFilledQuantity() int64 {
+	if x != nil {
+		return x.FilledQuantity
+	}
+	return 0
+}
+
+func (x *Order) GetRemainingQuantity() int64 {
+	if x != nil {
+		return x.RemainingQuantity
+	}
+	return 0
+}
+
+func (x *Order) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Order) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+type Trade struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TradeId       string                 `protobuf:"bytes,1,opt,name=trade_id,json=tradeId,proto3" json:"trade_id,omitempty"`
+	Price         int64                  `protobuf:"varint,2,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity      int64                  `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	AggressorSide Side                   `protobuf:"varint,4,opt,name=aggressor_side,json=aggressorSide,proto3,enum=matching.v1.Side" json:"aggressor_side,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trade) Reset() {
+	*x = Trade{}
+	mi := &file_matching_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trade) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trade) ProtoMessage() {}
+
+func (x *Trade) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trade.ProtoReflect.Descriptor instead.
+func (*Trade) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Trade) GetTradeId() string {
+	if x != nil {
+		return x.TradeId
+	}
+	return ""
+}
+
+func (x *Trade) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Trade) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Trade) GetAggressorSide() Side {
+	if x != nil {
+		return x.AggressorSide
+	}
+	return Side_SIDE_UNSPECIFIED
+}
+
+func (x *Trade) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type SubmitOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Side          Side                   `protobuf:"varint,2,opt,name=side,proto3,enum=matching.v1.Side" json:"side,omitempty"`
+	Type          OrderType              `protobuf:"varint,3,opt,name=type,proto3,enum=matching.v1.OrderType" json:"type,omitempty"`
+	Price         int64                  `protobuf:"varint,4,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity      int64                  `protobuf:"varint,5,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Account       string                 `protobuf:"bytes,6,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitOrderRequest) Reset() {
+	*x = SubmitOrderRequest{}
+	mi := &file_matching_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitOrderRequest) ProtoMessage() {}
+
+func (x *SubmitOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitOrderRequest.ProtoReflect.Descriptor instead.
+func (*SubmitOrderRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubmitOrderRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *SubmitOrderRequest) GetSide() Side {
+	if x != nil {
+		return x.Side
+	}
+	return Side_SIDE_UNSPECIFIED
+}
+
+func (x *SubmitOrderRequest) GetType() OrderType {
+	if x != nil {
+		return x.Type
+	}
+	return OrderType_ORDER_TYPE_UNSPECIFIED
+}
+
+func (x *SubmitOrderRequest) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *SubmitOrderRequest) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *SubmitOrderRequest) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+type SubmitOrderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Order         *Order                 `protobuf:"bytes,1,opt,name=order,proto3" json:"order,omitempty"`
+	Trades        []*Trade               `protobuf:"bytes,2,rep,name=trades,proto3" json:"trades,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitOrderResponse) Reset() {
+	*x = SubmitOrderResponse{}
+	mi := &file_matching_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitOrderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitOrderResponse) ProtoMessage() {}
+
+func (x *SubmitOrderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitOrderResponse.ProtoReflect.Descriptor instead.
+func (*SubmitOrderResponse) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SubmitOrderResponse) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+func (x *SubmitOrderResponse) GetTrades() []*Trade {
+	if x != nil {
+		return x.Trades
+	}
+	return nil
+}
+
+type CancelOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	OrderId       string                 `protobuf:"bytes,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelOrderRequest) Reset() {
+	*x = CancelOrderRequest{}
+	mi := &file_matching_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelOrderRequest) ProtoMessage() {}
+
+func (x *CancelOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelOrderRequest.ProtoReflect.Descriptor instead.
+func (*CancelOrderRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CancelOrderRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *CancelOrderRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+type CancelOrderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelOrderResponse) Reset() {
+	*x = CancelOrderResponse{}
+	mi := &file_matching_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelOrderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelOrderResponse) ProtoMessage() {}
+
+func (x *CancelOrderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelOrderResponse.ProtoReflect.Descriptor instead.
+func (*CancelOrderResponse) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{5}
+}
+
+type GetOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	OrderId       string                 `protobuf:"bytes,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOrderRequest) Reset() {
+	*x = GetOrderRequest{}
+	mi := &file_matching_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOrderRequest) ProtoMessage() {}
+
+func (x *GetOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOrderRequest.ProtoReflect.Descriptor instead.
+func (*GetOrderRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetOrderRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *GetOrderRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+type GetOrderBookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Depth         int32                  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOrderBookRequest) Reset() {
+	*x = GetOrderBookRequest{}
+	mi := &file_matching_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOrderBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOrderBookRequest) ProtoMessage() {}
+
+func (x *GetOrderBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOrderBookRequest.ProtoReflect.Descriptor instead.
+func (*GetOrderBookRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetOrderBookRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *GetOrderBookRequest) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+type Level struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Price         int64                  `protobuf:"varint,1,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity      int64                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Level) Reset() {
+	*x = Level{}
+	mi := &file_matching_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Level) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Level) ProtoMessage() {}
+
+func (x *Level) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Level.ProtoReflect.Descriptor instead.
+func (*Level) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Level) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Level) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+type OrderBook struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Bids          []*Level               `protobuf:"bytes,3,rep,name=bids,proto3" json:"bids,omitempty"`
+	Asks          []*Level               `protobuf:"bytes,4,rep,name=asks,proto3" json:"asks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderBook) Reset() {
+	*x = OrderBook{}
+	mi := &file_matching_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderBook) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderBook) ProtoMessage() {}
+
+func (x *OrderBook) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderBook.ProtoReflect.Descriptor instead.
+func (*OrderBook) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *OrderBook) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *OrderBook) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *OrderBook) GetBids() []*Level {
+	if x != nil {
+		return x.Bids
+	}
+	return nil
+}
+
+func (x *OrderBook) GetAsks() []*Level {
+	if x != nil {
+		return x.Asks
+	}
+	return nil
+}
+
+type StreamExecutionReportsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Account       string                 `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamExecutionReportsRequest) Reset() {
+	*x = StreamExecutionReportsRequest{}
+	mi := &file_matching_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamExecutionReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamExecutionReportsRequest) ProtoMessage() {}
+
+func (x *StreamExecutionReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamExecutionReportsRequest.ProtoReflect.Descriptor instead.
+func (*StreamExecutionReportsRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StreamExecutionReportsRequest) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+type ExecutionReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          ExecutionReport_Type   `protobuf:"varint,1,opt,name=type,proto3,enum=matching.v1.ExecutionReport_Type" json:"type,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Order         *Order                 `protobuf:"bytes,4,opt,name=order,proto3" json:"order,omitempty"`
+	FillPrice     int64                  `protobuf:"varint,5,opt,name=fill_price,json=fillPrice,proto3" json:"fill_price,omitempty"`
+	FillQuantity  int64                  `protobuf:"varint,6,opt,name=fill_quantity,json=fillQuantity,proto3" json:"fill_quantity,omitempty"`
+	Reason        string                 `protobuf:"bytes,7,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionReport) Reset() {
+	*x = ExecutionReport{}
+	mi := &file_matching_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionReport) ProtoMessage() {}
+
+func (x *ExecutionReport) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionReport.ProtoReflect.Descriptor instead.
+func (*ExecutionReport) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ExecutionReport) GetType() ExecutionReport_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ExecutionReport_TYPE_UNSPECIFIED
+}
+
+func (x *ExecutionReport) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ExecutionReport) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ExecutionReport) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+func (x *ExecutionReport) GetFillPrice() int64 {
+	if x != nil {
+		return x.FillPrice
+	}
+	return 0
+}
+
+func (x *ExecutionReport) GetFillQuantity() int64 {
+	if x != nil {
+		return x.FillQuantity
+	}
+	return 0
+}
+
+func (x *ExecutionReport) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type StreamOrderBookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Depth         int32                  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamOrderBookRequest) Reset() {
+	*x = StreamOrderBookRequest{}
+	mi := &file_matching_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamOrderBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamOrderBookRequest) ProtoMessage() {}
+
+func (x *StreamOrderBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamOrderBookRequest.ProtoReflect.Descriptor instead.
+func (*StreamOrderBookRequest) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StreamOrderBookRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *StreamOrderBookRequest) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+type OrderBookUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Snapshot      bool                   `protobuf:"varint,3,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	Bids          []*Level               `protobuf:"bytes,4,rep,name=bids,proto3" json:"bids,omitempty"`
+	Asks          []*Level               `protobuf:"bytes,5,rep,name=asks,proto3" json:"asks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderBookUpdate) Reset() {
+	*x = OrderBookUpdate{}
+	mi := &file_matching_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderBookUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderBookUpdate) ProtoMessage() {}
+
+func (x *OrderBookUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_matching_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderBookUpdate.ProtoReflect.Descriptor instead.
+func (*OrderBookUpdate) Descriptor() ([]byte, []int) {
+	return file_matching_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *OrderBookUpdate) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *OrderBookUpdate) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *OrderBookUpdate) GetSnapshot() bool {
+	if x != nil {
+		return x.Snapshot
+	}
+	return false
+}
+
+func (x *OrderBookUpdate) GetBids() []*Level {
+	if x != nil {
+		return x.Bids
+	}
+	return nil
+}
+
+func (x *OrderBookUpdate) GetAsks() []*Level {
+	if x != nil {
+		return x.Asks
+	}
+	return nil
+}
+
+var File_matching_proto protoreflect.FileDescriptor
+
+const file_matching_proto_rawDesc = "" +
+	"\n" +
+	"\x0ematching.proto\x12\vmatching.v1\"\xcf\x02\n" +
+	"\x05Order\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12%\n" +
+	"\x04side\x18\x03 \x01(\x0e2\x11.matching.v1.SideR\x04side\x12*\n" +
+	"\x04type\x18\x04 \x01(\x0e2\x16.matching.v1.OrderTypeR\x04type\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x06 \x01(\x03R\bquantity\x12'\n" +
+	"\x0ffilled_quantity\x18\a \x01(\x03R\x0efilledQuantity\x12-\n" +
+	"\x12remaining_quantity\x18\b \x01(\x03R\x11remainingQuantity\x12\x1c\n" +
+	"\ttimestamp\x18\t \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\aaccount\x18\n" +
+	" \x01(\tR\aaccount\"\xac\x01\n" +
+	"\x05Trade\x12\x19\n" +
+	"\btrade_id\x18\x01 \x01(\tR\atradeId\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x03R\bquantity\x128\n" +
+	"\x0eaggressor_side\x18\x04 \x01(\x0e2\x11.matching.v1.SideR\raggressorSide\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\"\xcb\x01\n" +
+	"\x12SubmitOrderRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12%\n" +
+	"\x04side\x18\x02 \x01(\x0e2\x11.matching.v1.SideR\x04side\x12*\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x16.matching.v1.OrderTypeR\x04type\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x05 \x01(\x03R\bquantity\x12\x18\n" +
+	"\aaccount\x18\x06 \x01(\tR\aaccount\"k\n" +
+	"\x13SubmitOrderResponse\x12(\n" +
+	"\x05order\x18\x01 \x01(\v2\x12.matching.v1.OrderR\x05order\x12*\n" +
+	"\x06trades\x18\x02 \x03(\v2\x12.matching.v1.TradeR\x06trades\"G\n" +
+	"\x12CancelOrderRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x19\n" +
+	"\border_id\x18\x02 \x01(\tR\aorderId\"\x15\n" +
+	"\x13CancelOrderResponse\"D\n" +
+	"\x0fGetOrderRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x19\n" +
+	"\border_id\x18\x02 \x01(\tR\aorderId\"C\n" +
+	"\x13GetOrderBookRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x05R\x05depth\"9\n" +
+	"\x05Level\x12\x14\n" +
+	"\x05price\x18\x01 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x02 \x01(\x03R\bquantity\"\x85\x01\n" +
+	"\tOrderBook\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12&\n" +
+	"\x04bids\x18\x03 \x03(\v2\x12.matching.v1.LevelR\x04bids\x12&\n" +
+	"\x04asks\x18\x04 \x03(\v2\x12.matching.v1.LevelR\x04asks\"9\n" +
+	"\x1dStreamExecutionReportsRequest\x12\x18\n" +
+	"\aaccount\x18\x01 \x01(\tR\aaccount\"\xe0\x02\n" +
+	"\x0fExecutionReport\x125\n" +
+	"\x04type\x18\x01 \x01(\x0e2!.matching.v1.ExecutionReport.TypeR\x04type\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12(\n" +
+	"\x05order\x18\x04 \x01(\v2\x12.matching.v1.OrderR\x05order\x12\x1d\n" +
+	"\n" +
+	"fill_price\x18\x05 \x01(\x03R\tfillPrice\x12#\n" +
+	"\rfill_quantity\x18\x06 \x01(\x03R\ffillQuantity\x12\x16\n" +
+	"\x06reason\x18\a \x01(\tR\x06reason\"`\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\f\n" +
+	"\bACCEPTED\x10\x01\x12\f\n" +
+	"\bREJECTED\x10\x02\x12\n" +
+	"\n" +
+	"\x06FILLED\x10\x03\x12\r\n" +
+	"\tCANCELLED\x10\x04\x12\v\n" +
+	"\aAMENDED\x10\x05\"F\n" +
+	"\x16StreamOrderBookRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x05R\x05depth\"\xa7\x01\n" +
+	"\x0fOrderBookUpdate\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12\x1a\n" +
+	"\bsnapshot\x18\x03 \x01(\bR\bsnapshot\x12&\n" +
+	"\x04bids\x18\x04 \x03(\v2\x12.matching.v1.LevelR\x04bids\x12&\n" +
+	"\x04asks\x18\x05 \x03(\v2\x12.matching.v1.LevelR\x04asks*/\n" +
+	"\x04Side\x12\x14\n" +
+	"\x10SIDE_UNSPECIFIED\x10\x00\x12\a\n" +
+	"\x03BUY\x10\x01\x12\b\n" +
+	"\x04SELL\x10\x02*>\n" +
+	"\tOrderType\x12\x1a\n" +
+	"\x16ORDER_TYPE_UNSPECIFIED\x10\x00\x12\t\n" +
+	"\x05LIMIT\x10\x01\x12\n" +
+	"\n" +
+	"\x06MARKET\x10\x022\xfa\x03\n" +
+	"\x0eMatchingEngine\x12P\n" +
+	"\vSubmitOrder\x12\x1f.matching.v1.SubmitOrderRequest\x1a .matching.v1.SubmitOrderResponse\x12P\n" +
+	"\vCancelOrder\x12\x1f.matching.v1.CancelOrderRequest\x1a .matching.v1.CancelOrderResponse\x12<\n" +
+	"\bGetOrder\x12\x1c.matching.v1.GetOrderRequest\x1a\x12.matching.v1.Order\x12H\n" +
+	"\fGetOrderBook\x12 .matching.v1.GetOrderBookRequest\x1a\x16.matching.v1.OrderBook\x12d\n" +
+	"\x16StreamExecutionReports\x12*.matching.v1.StreamExecutionReportsRequest\x1a\x1c.matching.v1.ExecutionReport0\x01\x12V\n" +
+	"\x0fStreamOrderBook\x12#.matching.v1.StreamOrderBookRequest\x1a\x1c.matching.v1.OrderBookUpdate0\x01B@Z>github.com/2019UGEC100/order-matching-engine-go/pkg/grpcapi/pbb\x06proto3"
+
+var (
+	file_matching_proto_rawDescOnce sync.Once
+	file_matching_proto_rawDescData []byte
+)
+
+func file_matching_proto_rawDescGZIP() []byte {
+	file_matching_proto_rawDescOnce.Do(func() {
+		file_matching_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_matching_proto_rawDesc), len(file_matching_proto_rawDesc)))
+	})
+	return file_matching_proto_rawDescData
+}
+
+var file_matching_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_matching_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_matching_proto_goTypes = []any{
+	(Side)(0),                             // 0: matching.v1.Side
+	(OrderType)(0),                        // 1: matching.v1.OrderType
+	(ExecutionReport_Type)(0),             // 2: matching.v1.ExecutionReport.Type
+	(*Order)(nil),                         // 3: matching.v1.Order
+	(*Trade)(nil),                         // 4: matching.v1.Trade
+	(*SubmitOrderRequest)(nil),            // 5: matching.v1.SubmitOrderRequest
+	(*SubmitOrderResponse)(nil),           // 6: matching.v1.SubmitOrderResponse
+	(*CancelOrderRequest)(nil),            // 7: matching.v1.CancelOrderRequest
+	(*CancelOrderResponse)(nil),           // 8: matching.v1.CancelOrderResponse
+	(*GetOrderRequest)(nil),               // 9: matching.v1.GetOrderRequest
+	(*GetOrderBookRequest)(nil),           // 10: matching.v1.GetOrderBookRequest
+	(*Level)(nil),                         // 11: matching.v1.Level
+	(*OrderBook)(nil),                     // 12: matching.v1.OrderBook
+	(*StreamExecutionReportsRequest)(nil), // 13: matching.v1.StreamExecutionReportsRequest
+	(*ExecutionReport)(nil),               // 14: matching.v1.ExecutionReport
+	(*StreamOrderBookRequest)(nil),        // 15: matching.v1.StreamOrderBookRequest
+	(*OrderBookUpdate)(nil),               // 16: matching.v1.OrderBookUpdate
+}
+var file_matching_proto_depIdxs = []int32{
+	0,  // 0: matching.v1.Order.side:type_name -> matching.v1.Side
+	1,  // 1: matching.v1.Order.type:type_name -> matching.v1.OrderType
+	0,  // 2: matching.v1.Trade.aggressor_side:type_name -> matching.v1.Side
+	0,  // 3: matching.v1.SubmitOrderRequest.side:type_name -> matching.v1.Side
+	1,  // 4: matching.v1.SubmitOrderRequest.type:type_name -> matching.v1.OrderType
+	3,  // 5: matching.v1.SubmitOrderResponse.order:type_name -> matching.v1.Order
+	4,  // 6: matching.v1.SubmitOrderResponse.trades:type_name -> matching.v1.Trade
+	11, // 7: matching.v1.OrderBook.bids:type_name -> matching.v1.Level
+	11, // 8: matching.v1.OrderBook.asks:type_name -> matching.v1.Level
+	2,  // 9: matching.v1.ExecutionReport.type:type_name -> matching.v1.ExecutionReport.Type
+	3,  // 10: matching.v1.ExecutionReport.order:type_name -> matching.v1.Order
+	11, // 11: matching.v1.OrderBookUpdate.bids:type_name -> matching.v1.Level
+	11, // 12: matching.v1.OrderBookUpdate.asks:type_name -> matching.v1.Level
+	5,  // 13: matching.v1.MatchingEngine.SubmitOrder:input_type -> matching.v1.SubmitOrderRequest
+	7,  // 14: matching.v1.MatchingEngine.CancelOrder:input_type -> matching.v1.CancelOrderRequest
+	9,  // 15: matching.v1.MatchingEngine.GetOrder:input_type -> matching.v1.GetOrderRequest
+	10, // 16: matching.v1.MatchingEngine.GetOrderBook:input_type -> matching.v1.GetOrderBookRequest
+	13, // 17: matching.v1.MatchingEngine.StreamExecutionReports:input_type -> matching.v1.StreamExecutionReportsRequest
+	15, // 18: matching.v1.MatchingEngine.StreamOrderBook:input_type -> matching.v1.StreamOrderBookRequest
+	6,  // 19: matching.v1.MatchingEngine.SubmitOrder:output_type -> matching.v1.SubmitOrderResponse
+	8,  // 20: matching.v1.MatchingEngine.CancelOrder:output_type -> matching.v1.CancelOrderResponse
+	3,  // 21: matching.v1.MatchingEngine.GetOrder:output_type -> matching.v1.Order
+	12, // 22: matching.v1.MatchingEngine.GetOrderBook:output_type -> matching.v1.OrderBook
+	14, // 23: matching.v1.MatchingEngine.StreamExecutionReports:output_type -> matching.v1.ExecutionReport
+	16, // 24: matching.v1.MatchingEngine.StreamOrderBook:output_type -> matching.v1.OrderBookUpdate
+	19, // [19:25] is the sub-list for method output_type
+	13, // [13:19] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_matching_proto_init() }
+func file_matching_proto_init() {
+	if File_matching_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_matching_proto_rawDesc), len(file_matching_proto_rawDesc)),
+			NumEnums:      3,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_matching_proto_goTypes,
+		DependencyIndexes: file_matching_proto_depIdxs,
+		EnumInfos:         file_matching_proto_enumTypes,
+		MessageInfos:      file_matching_proto_msgTypes,
+	}.Build()
+	File_matching_proto = out.File
+	file_matching_proto_goTypes = nil
+	file_matching_proto_depIdxs = nil
+}
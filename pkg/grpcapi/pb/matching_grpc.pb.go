@@ -0,0 +1,318 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: matching.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MatchingEngine_SubmitOrder_FullMethodName            = "/matching.v1.MatchingEngine/SubmitOrder"
+	MatchingEngine_CancelOrder_FullMethodName            = "/matching.v1.MatchingEngine/CancelOrder"
+	MatchingEngine_GetOrder_FullMethodName               = "/matching.v1.MatchingEngine/GetOrder"
+	MatchingEngine_GetOrderBook_FullMethodName           = "/matching.v1.MatchingEngine/GetOrderBook"
+	MatchingEngine_StreamExecutionReports_FullMethodName = "/matching.v1.MatchingEngine/StreamExecutionReports"
+	MatchingEngine_StreamOrderBook_FullMethodName        = "/matching.v1.MatchingEngine/StreamOrderBook"
+)
+
+// MatchingEngineClient is the client API for MatchingEngine service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MatchingEngineClient interface {
+	SubmitOrder(ctx context.Context, in *SubmitOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error)
+	CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error)
+	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	GetOrderBook(ctx context.Context, in *GetOrderBookRequest, opts ...grpc.CallOption) (*OrderBook, error)
+	StreamExecutionReports(ctx context.Context, in *StreamExecutionReportsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecutionReport], error)
+	StreamOrderBook(ctx context.Context, in *StreamOrderBookRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderBookUpdate], error)
+}
+
+type matchingEngineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatchingEngineClient(cc grpc.ClientConnInterface) MatchingEngineClient {
+	return &matchingEngineClient{cc}
+}
+
+func (c *matchingEngineClient) SubmitOrder(ctx context.Context, in *SubmitOrderRequest, opts ...grpc.CallOption) (*SubmitOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitOrderResponse)
+	err := c.cc.Invoke(ctx, MatchingEngine_SubmitOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelOrderResponse)
+	err := c.cc.Invoke(ctx, MatchingEngine_CancelOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, MatchingEngine_GetOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) GetOrderBook(ctx context.Context, in *GetOrderBookRequest, opts ...grpc.CallOption) (*OrderBook, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderBook)
+	err := c.cc.Invoke(ctx, MatchingEngine_GetOrderBook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) StreamExecutionReports(ctx context.Context, in *StreamExecutionReportsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecutionReport], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MatchingEngine_ServiceDesc.Streams[0], MatchingEngine_StreamExecutionReports_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamExecutionReportsRequest, ExecutionReport]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchingEngine_StreamExecutionReportsClient = grpc.ServerStreamingClient[ExecutionReport]
+
+func (c *matchingEngineClient) StreamOrderBook(ctx context.Context, in *StreamOrderBookRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderBookUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MatchingEngine_ServiceDesc.Streams[1], MatchingEngine_StreamOrderBook_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamOrderBookRequest, OrderBookUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchingEngine_StreamOrderBookClient = grpc.ServerStreamingClient[OrderBookUpdate]
+
+// MatchingEngineServer is the server API for MatchingEngine service.
+// All implementations must embed UnimplementedMatchingEngineServer
+// for forward compatibility.
+type MatchingEngineServer interface {
+	SubmitOrder(context.Context, *SubmitOrderRequest) (*SubmitOrderResponse, error)
+	CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error)
+	GetOrder(context.Context, *GetOrderRequest) (*Order, error)
+	GetOrderBook(context.Context, *GetOrderBookRequest) (*OrderBook, error)
+	StreamExecutionReports(*StreamExecutionReportsRequest, grpc.ServerStreamingServer[ExecutionReport]) error
+	StreamOrderBook(*StreamOrderBookRequest, grpc.ServerStreamingServer[OrderBookUpdate]) error
+	mustEmbedUnimplementedMatchingEngineServer()
+}
+
+// UnimplementedMatchingEngineServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMatchingEngineServer struct{}
+
+func (UnimplementedMatchingEngineServer) SubmitOrder(context.Context, *SubmitOrderRequest) (*SubmitOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) GetOrder(context.Context, *GetOrderRequest) (*Order, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) GetOrderBook(context.Context, *GetOrderBookRequest) (*OrderBook, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetOrderBook not implemented")
+}
+func (UnimplementedMatchingEngineServer) StreamExecutionReports(*StreamExecutionReportsRequest, grpc.ServerStreamingServer[ExecutionReport]) error {
+	return status.Error(codes.Unimplemented, "method StreamExecutionReports not implemented")
+}
+func (UnimplementedMatchingEngineServer) StreamOrderBook(*StreamOrderBookRequest, grpc.ServerStreamingServer[OrderBookUpdate]) error {
+	return status.Error(codes.Unimplemented, "method StreamOrderBook not implemented")
+}
+func (UnimplementedMatchingEngineServer) mustEmbedUnimplementedMatchingEngineServer() {}
+func (UnimplementedMatchingEngineServer) testEmbeddedByValue()                        {}
+
+// UnsafeMatchingEngineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MatchingEngineServer will
+// result in compilation errors.
+type UnsafeMatchingEngineServer interface {
+	mustEmbedUnimplementedMatchingEngineServer()
+}
+
+func RegisterMatchingEngineServer(s grpc.ServiceRegistrar, srv MatchingEngineServer) {
+	// If the following call panics, it indicates UnimplementedMatchingEngineServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MatchingEngine_ServiceDesc, srv)
+}
+
+func _MatchingEngine_SubmitOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).SubmitOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_SubmitOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).SubmitOrder(ctx, req.(*SubmitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_CancelOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).CancelOrder(ctx, req.(*CancelOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).GetOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_GetOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).GetOrder(ctx, req.(*GetOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_GetOrderBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).GetOrderBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_GetOrderBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).GetOrderBook(ctx, req.(*GetOrderBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_StreamExecutionReports_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamExecutionReportsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchingEngineServer).StreamExecutionReports(m, &grpc.GenericServerStream[StreamExecutionReportsRequest, ExecutionReport]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchingEngine_StreamExecutionReportsServer = grpc.ServerStreamingServer[ExecutionReport]
+
+func _MatchingEngine_StreamOrderBook_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamOrderBookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchingEngineServer).StreamOrderBook(m, &grpc.GenericServerStream[StreamOrderBookRequest, OrderBookUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchingEngine_StreamOrderBookServer = grpc.ServerStreamingServer[OrderBookUpdate]
+
+// MatchingEngine_ServiceDesc is the grpc.ServiceDesc for MatchingEngine service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MatchingEngine_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "matching.v1.MatchingEngine",
+	HandlerType: (*MatchingEngineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitOrder",
+			Handler:    _MatchingEngine_SubmitOrder_Handler,
+		},
+		{
+			MethodName: "CancelOrder",
+			Handler:    _MatchingEngine_CancelOrder_Handler,
+		},
+		{
+			MethodName: "GetOrder",
+			Handler:    _MatchingEngine_GetOrder_Handler,
+		},
+		{
+			MethodName: "GetOrderBook",
+			Handler:    _MatchingEngine_GetOrderBook_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamExecutionReports",
+			Handler:       _MatchingEngine_StreamExecutionReports_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamOrderBook",
+			Handler:       _MatchingEngine_StreamOrderBook_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "matching.proto",
+}
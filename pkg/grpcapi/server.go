@@ -0,0 +1,362 @@
+// Package grpcapi serves the MatchingEngine gRPC service defined in
+// pb/matching.proto.
+//
+// The order calls and StreamExecutionReports need an API key, sent as the
+// "x-api-key" metadata in the "<id>:<secret>" form of the REST header. Orders
+// are placed for the key's account, and only that account's orders can be
+// cancelled, looked up or streamed. The book calls are public. Every call
+// is rate limited by the caller's address and, once authenticated, its
+// account; a refused call gets ResourceExhausted.
+package grpcapi
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative pb/matching.proto
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/accounts"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/grpcapi/pb"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/risk"
+)
+
+const (
+	streamBuffer = 4096 // events per stream before the client is cut off
+	maxBookDepth = 1000 // StreamOrderBook snapshot depth when 0 is asked for
+)
+
+// Server implements pb.MatchingEngineServer on top of the Router.
+type Server struct {
+	pb.UnimplementedMatchingEngineServer
+	router *engine.Router
+	bus    *events.Bus
+	keys   *auth.KeyStore
+	limits *ratelimit.Limiter // nil: unlimited
+	buffer int                // events per stream, streamBuffer
+}
+
+// New creates the service. bus is what the router publishes to; it feeds
+// the streaming RPCs. keys authenticates the calls that need an account;
+// limits, if not nil, throttles every call.
+func New(router *engine.Router, bus *events.Bus, keys *auth.KeyStore, limits *ratelimit.Limiter) *Server {
+	return &Server{router: router, bus: bus, keys: keys, limits: limits, buffer: streamBuffer}
+}
+
+// Register adds the service to gs.
+func (s *Server) Register(gs *grpc.Server) {
+	pb.RegisterMatchingEngineServer(gs, s)
+}
+
+// errCodes maps the Err strings of the engine's results to gRPC codes.
+// Anything not listed is a validation failure from the order itself.
+var errCodes = map[string]codes.Code{
+	"order not found":                         codes.NotFound,
+	"cannot cancel a fully filled order":      codes.FailedPrecondition,
+	"cannot amend a fully filled order":       codes.FailedPrecondition,
+	"order is not resting":                    codes.FailedPrecondition,
+	"insufficient liquidity for market order": codes.FailedPrecondition,
+}
+
+func engineError(err string) error {
+	code, ok := errCodes[err]
+	if !ok {
+		code = codes.InvalidArgument
+	}
+	return status.Error(code, err)
+}
+
+// rejectError is FailedPrecondition for a pre-trade refusal. A risk limit
+// or missing funds are described by an ErrorInfo detail.
+func rejectError(err error) error {
+	st := status.New(codes.FailedPrecondition, err.Error())
+	var (
+		info  *errdetails.ErrorInfo
+		v     *risk.Violation
+		funds *accounts.InsufficientFunds
+	)
+	switch {
+	case errors.As(err, &v):
+		info = &errdetails.ErrorInfo{Reason: v.Limit, Domain: "risk", Metadata: map[string]string{
+			"symbol": v.Symbol,
+			"value":  strconv.FormatInt(v.Value, 10),
+			"max":    strconv.FormatInt(v.Max, 10),
+		}}
+	case errors.As(err, &funds):
+		info = &errdetails.ErrorInfo{Reason: "insufficient_funds", Domain: "accounts", Metadata: map[string]string{
+			"asset":     funds.Asset,
+			"needed":    strconv.FormatInt(funds.Needed, 10),
+			"available": strconv.FormatInt(funds.Available, 10),
+		}}
+	default:
+		return st.Err()
+	}
+	if ds, derr := st.WithDetails(info); derr == nil {
+		st = ds
+	}
+	return st.Err()
+}
+
+// errRateLimit refuses a call over its rate limit.
+var errRateLimit = status.Error(codes.ResourceExhausted, "rate limit exceeded")
+
+// addr returns the IP of the caller of ctx.
+func addr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return ratelimit.AddrIP(p.Addr)
+	}
+	return ""
+}
+
+// public takes a token of class for a call that needs no key.
+func (s *Server) public(ctx context.Context, class ratelimit.Class) error {
+	if ok, _ := s.limits.Allow(class, "", addr(ctx)); !ok {
+		return errRateLimit
+	}
+	return nil
+}
+
+// account returns the account of the API key in ctx's metadata, after
+// taking a token of class from the caller's address and then the account.
+// A request naming an account, which may be left empty, must name that one.
+func (s *Server) account(ctx context.Context, named string, class ratelimit.Class) (string, error) {
+	if ok, _ := s.limits.Screen(class, addr(ctx)); !ok {
+		return "", errRateLimit
+	}
+	var cred string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-api-key"); len(v) > 0 {
+			cred = v[0]
+		}
+	}
+	if cred == "" {
+		return "", status.Error(codes.Unauthenticated, "missing API key")
+	}
+	k, ok := s.keys.Check(cred)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if named != "" && named != k.Account {
+		return "", status.Error(codes.PermissionDenied, "account does not match the API key")
+	}
+	if ok, _ := s.limits.Allow(class, k.Account, ""); !ok {
+		return "", errRateLimit
+	}
+	return k.Account, nil
+}
+
+// ownOrder looks up an order of account. Orders of other accounts are
+// reported as not found, so their IDs cannot be probed.
+func (s *Server) ownOrder(account, symbol, id string) (*model.Order, error) {
+	res := s.router.GetOrder(symbol, id)
+	if res.Err != "" {
+		return nil, engineError(res.Err)
+	}
+	if res.Order.Account != account {
+		return nil, engineError("order not found")
+	}
+	return res.Order, nil
+}
+
+func (s *Server) SubmitOrder(ctx context.Context, req *pb.SubmitOrderRequest) (*pb.SubmitOrderResponse, error) {
+	account, err := s.account(ctx, req.Account, ratelimit.Order)
+	if err != nil {
+		return nil, err
+	}
+	o := &model.Order{
+		Symbol:   req.Symbol,
+		Side:     fromSide(req.Side),
+		Type:     fromType(req.Type),
+		Price:    req.Price,
+		Quantity: req.Quantity,
+		Account:  account,
+	}
+	if err := o.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	o.ID = s.router.NewOrderID(o.Symbol)
+	o.Timestamp = time.Now().UnixMilli()
+	submitted := *o
+
+	res := s.router.SubmitOrder(o)
+	if res.Reject != nil {
+		return nil, rejectError(res.Reject)
+	}
+	if res.Err != "" {
+		return nil, engineError(res.Err)
+	}
+	// res.Order is live in the shard once it rests; build the reply from
+	// the copy taken before submit and this submit's own trades
+	resp := &pb.SubmitOrderResponse{}
+	for _, t := range res.Trades {
+		submitted.Filled += t.Quantity
+		resp.Trades = append(resp.Trades, toTrade(t))
+	}
+	resp.Order = toOrder(&submitted)
+	return resp, nil
+}
+
+func (s *Server) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	if req.Symbol == "" || req.OrderId == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol and order_id are required")
+	}
+	account, err := s.account(ctx, "", ratelimit.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ownOrder(account, req.Symbol, req.OrderId); err != nil {
+		return nil, err
+	}
+	res := s.router.CancelOrder(req.Symbol, req.OrderId)
+	if !res.OK {
+		return nil, engineError(res.Err)
+	}
+	return &pb.CancelOrderResponse{}, nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	if req.Symbol == "" || req.OrderId == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol and order_id are required")
+	}
+	account, err := s.account(ctx, "", ratelimit.Query)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.ownOrder(account, req.Symbol, req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(o), nil
+}
+
+func (s *Server) GetOrderBook(ctx context.Context, req *pb.GetOrderBookRequest) (*pb.OrderBook, error) {
+	if req.Symbol == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol is required")
+	}
+	if err := s.public(ctx, ratelimit.Query); err != nil {
+		return nil, err
+	}
+	snap := s.router.GetOrderBook(req.Symbol, int(req.Depth))
+	return &pb.OrderBook{
+		Symbol: snap.Symbol,
+		Seq:    snap.Seq,
+		Bids:   toLevels(snap.Bids),
+		Asks:   toLevels(snap.Asks),
+	}, nil
+}
+
+// StreamExecutionReports sends the key's account's order events as they
+// happen; req.Account may be left empty. Response headers are sent once the
+// subscription is in place, so a client that waits for them before
+// submitting misses nothing. A client that cannot
+// keep up is cut off with ResourceExhausted.
+func (s *Server) StreamExecutionReports(req *pb.StreamExecutionReportsRequest, stream pb.MatchingEngine_StreamExecutionReportsServer) error {
+	account, err := s.account(stream.Context(), req.Account, ratelimit.Query)
+	if err != nil {
+		return err
+	}
+	sub := s.bus.Subscribe(s.buffer, events.Disconnect, func(ev *events.Event) bool {
+		_, ok := execTypes[ev.Type]
+		return ok && ev.Order.Account == account
+	})
+	defer sub.Close()
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				return cutOff(sub)
+			}
+			if err := stream.Send(toExecReport(&ev)); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			return cutOff(sub)
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// StreamOrderBook sends a snapshot and then every level change after it.
+// Updates cover the whole book, not just the snapshot depth.
+func (s *Server) StreamOrderBook(req *pb.StreamOrderBookRequest, stream pb.MatchingEngine_StreamOrderBookServer) error {
+	if req.Symbol == "" {
+		return status.Error(codes.InvalidArgument, "symbol is required")
+	}
+	if err := s.public(stream.Context(), ratelimit.Query); err != nil {
+		return err
+	}
+	// subscribe before taking the snapshot so nothing falls in between;
+	// events the snapshot already contains are skipped by seq
+	sub := s.bus.Subscribe(s.buffer, events.Disconnect, func(ev *events.Event) bool {
+		return ev.Type == events.LevelChanged && ev.Symbol == req.Symbol
+	})
+	defer sub.Close()
+
+	depth := int(req.Depth)
+	if depth <= 0 || depth > maxBookDepth {
+		depth = maxBookDepth
+	}
+	snap := s.router.GetOrderBook(req.Symbol, depth)
+	if err := stream.Send(&pb.OrderBookUpdate{
+		Symbol:   snap.Symbol,
+		Seq:      snap.Seq,
+		Snapshot: true,
+		Bids:     toLevels(snap.Bids),
+		Asks:     toLevels(snap.Asks),
+	}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				return cutOff(sub)
+			}
+			if ev.Seq <= snap.Seq {
+				continue
+			}
+			u := &pb.OrderBookUpdate{Symbol: ev.Symbol, Seq: ev.Seq}
+			lvl := &pb.Level{Price: ev.Level.Price, Quantity: ev.Level.Quantity}
+			if ev.Level.Side == model.BUY {
+				u.Bids = []*pb.Level{lvl}
+			} else {
+				u.Asks = []*pb.Level{lvl}
+			}
+			if err := stream.Send(u); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			return cutOff(sub)
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// cutOff is the error ending a stream whose subscription the bus closed.
+func cutOff(sub *events.Subscription) error {
+	err := sub.Err()
+	if err == nil {
+		err = events.ErrSlowConsumer
+	}
+	return status.Error(codes.ResourceExhausted, err.Error())
+}
@@ -0,0 +1,282 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/grpcapi/pb"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/ratelimit"
+)
+
+func dial(t *testing.T) (pb.MatchingEngineClient, *auth.KeyStore) {
+	t.Helper()
+	c, _, keys := dialWith(t, nil)
+	return c, keys
+}
+
+// dialWith is dial that lets setup adjust the server first and also returns
+// the bus the router publishes to.
+func dialWith(t *testing.T, setup func(*Server)) (pb.MatchingEngineClient, *events.Bus, *auth.KeyStore) {
+	t.Helper()
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := events.NewBus()
+	r := engine.NewRouter(2, 64, engine.WithEventBus(bus))
+	gs := grpc.NewServer()
+	srv := New(r, bus, keys, nil)
+	if setup != nil {
+		setup(srv)
+	}
+	srv.Register(gs)
+	ln := bufconn.Listen(1 << 20)
+	go gs.Serve(ln)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cc.Close()
+		gs.Stop()
+		r.Stop()
+	})
+	return pb.NewMatchingEngineClient(cc), bus, keys
+}
+
+// as returns ctx carrying a new API key of account.
+func as(t *testing.T, ctx context.Context, keys *auth.KeyStore, account string) context.Context {
+	t.Helper()
+	k, err := keys.Create(account, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", k.ID+":"+k.Secret)
+}
+
+func TestUnaryCallsAndErrorCodes(t *testing.T) {
+	c, keys := dial(t)
+	ctx := as(t, context.Background(), keys, "acct-1")
+
+	sell, err := c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buy, err := c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_BUY, Type: pb.OrderType_MARKET, Quantity: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buy.Trades) != 1 || buy.Order.FilledQuantity != 4 || buy.Order.RemainingQuantity != 0 {
+		t.Fatalf("unexpected submit response %v", buy)
+	}
+
+	o, err := c.GetOrder(ctx, &pb.GetOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId})
+	if err != nil || o.RemainingQuantity != 6 {
+		t.Fatalf("GetOrder = %v, %v", o, err)
+	}
+	book, err := c.GetOrderBook(ctx, &pb.GetOrderBookRequest{Symbol: "ABC"})
+	if err != nil || len(book.Asks) != 1 || book.Asks[0].Quantity != 6 {
+		t.Fatalf("GetOrderBook = %v, %v", book, err)
+	}
+	if _, err := c.CancelOrder(ctx, &pb.CancelOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"cancel twice": {func() error {
+			_, err := c.CancelOrder(ctx, &pb.CancelOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId})
+			return err
+		}(), codes.NotFound},
+		"no liquidity": {func() error {
+			_, err := c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_BUY, Type: pb.OrderType_MARKET, Quantity: 1})
+			return err
+		}(), codes.FailedPrecondition},
+		"no price": {func() error {
+			_, err := c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_BUY, Type: pb.OrderType_LIMIT, Quantity: 1})
+			return err
+		}(), codes.InvalidArgument},
+	} {
+		if status.Code(tc.err) != tc.code {
+			t.Errorf("%s: got %v, want %v", name, tc.err, tc.code)
+		}
+	}
+}
+
+func TestExecutionReportStreamIncludesPassiveFills(t *testing.T) {
+	c, keys := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx1, ctx2 := as(t, ctx, keys, "acct-1"), as(t, ctx, keys, "acct-2")
+
+	stream, err := c.StreamExecutionReports(ctx1, &pb.StreamExecutionReportsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SubmitOrder(ctx1, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 5})
+	c.SubmitOrder(ctx2, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_BUY, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 2})
+
+	want := []pb.ExecutionReport_Type{pb.ExecutionReport_ACCEPTED, pb.ExecutionReport_FILLED}
+	for _, typ := range want {
+		er, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if er.Type != typ || er.Order.Account != "acct-1" {
+			t.Fatalf("got %v, want %v for acct-1", er, typ)
+		}
+		if typ == pb.ExecutionReport_FILLED && (er.FillQuantity != 2 || er.Order.RemainingQuantity != 3) {
+			t.Fatalf("bad fill %v", er)
+		}
+	}
+}
+
+func TestOrderBookStream(t *testing.T) {
+	c, keys := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = as(t, ctx, keys, "acct-1")
+
+	c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_BUY, Type: pb.OrderType_LIMIT, Price: 99, Quantity: 3})
+	stream, err := c.StreamOrderBook(ctx, &pb.StreamOrderBookRequest{Symbol: "ABC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snap.Snapshot || len(snap.Bids) != 1 || snap.Bids[0].Quantity != 3 {
+		t.Fatalf("bad snapshot %v", snap)
+	}
+
+	c.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 99, Quantity: 3})
+	u, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Snapshot || u.Seq <= snap.Seq || len(u.Bids) != 1 || u.Bids[0].Price != 99 || u.Bids[0].Quantity != 0 {
+		t.Fatalf("bad update %v", u)
+	}
+}
+
+func TestSlowStreamIsCutOff(t *testing.T) {
+	c, bus, keys := dialWith(t, func(s *Server) { s.buffer = 1 })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = as(t, ctx, keys, "acct-1")
+
+	// the closed channel and Done race in the server's select, so overflow
+	// a few streams for the closed channel to be seen
+	o := &model.Order{ID: "o", Symbol: "ABC", Account: "acct-1", Quantity: 1}
+	for n := 0; n < 20; n++ {
+		stream, err := c.StreamExecutionReports(ctx, &pb.StreamExecutionReportsRequest{Account: "acct-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Header(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			bus.Publish(events.Event{Type: events.OrderAccepted, Seq: uint64(i), Order: o})
+		}
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected the stream cut off with ResourceExhausted, got %v", err)
+		}
+	}
+}
+
+func TestCallsAreBoundToTheKeysAccount(t *testing.T) {
+	c, keys := dial(t)
+	bg := context.Background()
+	alice, bob := as(t, bg, keys, "alice"), as(t, bg, keys, "bob")
+
+	if _, err := c.SubmitOrder(bg, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 1}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a call without a key refused, got %v", err)
+	}
+	bad := metadata.AppendToOutgoingContext(bg, "x-api-key", "nope:nope")
+	if _, err := c.GetOrder(bad, &pb.GetOrderRequest{Symbol: "ABC", OrderId: "x"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a bad key refused, got %v", err)
+	}
+	if _, err := c.SubmitOrder(bob, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 1, Account: "alice"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected bob refused as alice, got %v", err)
+	}
+
+	sell, err := c.SubmitOrder(alice, &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 1})
+	if err != nil || sell.Order.Account != "alice" {
+		t.Fatalf("expected alice's order, got %v, %v", sell, err)
+	}
+	if _, err := c.GetOrder(bob, &pb.GetOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected alice's order not found for bob, got %v", err)
+	}
+	if _, err := c.CancelOrder(bob, &pb.CancelOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected bob unable to cancel alice's order, got %v", err)
+	}
+	if _, err := c.CancelOrder(alice, &pb.CancelOrderRequest{Symbol: "ABC", OrderId: sell.Order.OrderId}); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := c.StreamExecutionReports(bob, &pb.StreamExecutionReportsRequest{Account: "alice"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected bob refused alice's reports, got %v", err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	budgets, err := ratelimit.ParseBudgets("order=1,cancel=1,query=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _, keys := dialWith(t, func(s *Server) { s.limits = ratelimit.New(budgets, budgets) })
+	bg := context.Background()
+	ctx := as(t, bg, keys, "acct-1")
+
+	order := &pb.SubmitOrderRequest{Symbol: "ABC", Side: pb.Side_SELL, Type: pb.OrderType_LIMIT, Price: 100, Quantity: 1}
+	if _, err := c.SubmitOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SubmitOrder(ctx, order); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the second order over the limit, got %v", err)
+	}
+
+	// a bad key spends the address's tokens before it is looked at
+	bad := metadata.AppendToOutgoingContext(bg, "x-api-key", "nope:nope")
+	if _, err := c.GetOrder(bad, &pb.GetOrderRequest{Symbol: "ABC", OrderId: "x"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected the bad key refused, got %v", err)
+	}
+	if _, err := c.GetOrderBook(bg, &pb.GetOrderBookRequest{Symbol: "ABC"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrder(bad, &pb.GetOrderRequest{Symbol: "ABC", OrderId: "x"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the address over the limit, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package marketdata
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Candle is one OHLCV bar. Time is the bar's start (unix ms); prices are
+// integer cents.
+type Candle struct {
+	Time   int64 `json:"time"`
+	Open   int64 `json:"open"`
+	High   int64 `json:"high"`
+	Low    int64 `json:"low"`
+	Close  int64 `json:"close"`
+	Volume int64 `json:"volume"`
+	Trades int64 `json:"trades"`
+}
+
+// Intervals supported by the candle store, keyed by their API name.
+var Intervals = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// ErrUnknownInterval is returned for an interval not in Intervals.
+var ErrUnknownInterval = errors.New("unknown interval: use 1s, 1m, 5m, 1h or 1d")
+
+// DefaultCandleHistory is how many finished bars are kept per symbol and
+// interval.
+const DefaultCandleHistory = 1440
+
+// series is the bars of one symbol at one interval. Finished bars live in
+// a ring; the forming bar is kept aside until a trade lands in a later
+// bucket.
+type series struct {
+	ms       int64
+	max      int
+	done     []Candle // finished bars; a ring once len reaches max
+	next     int      // oldest bar once the ring is full
+	forming  Candle
+	hasOpen  bool
+	watchers map[chan Candle]struct{}
+}
+
+func (s *series) add(t model.Trade) {
+	start := t.Timestamp - t.Timestamp%s.ms
+	// a trade stamped before the forming bar (clock step) is folded into it
+	if s.hasOpen && start > s.forming.Time {
+		if len(s.done) < s.max {
+			s.done = append(s.done, s.forming)
+		} else {
+			s.done[s.next] = s.forming
+			s.next = (s.next + 1) % s.max
+		}
+		s.hasOpen = false
+	}
+	if !s.hasOpen {
+		s.forming = Candle{Time: start, Open: t.Price, High: t.Price, Low: t.Price}
+		s.hasOpen = true
+	}
+	c := &s.forming
+	if t.Price > c.High {
+		c.High = t.Price
+	}
+	if t.Price < c.Low {
+		c.Low = t.Price
+	}
+	c.Close = t.Price
+	c.Volume += t.Quantity
+	c.Trades++
+
+	for w := range s.watchers {
+		select {
+		case w <- *c:
+		default: // watcher is behind; the next update carries the full bar
+		}
+	}
+}
+
+// CandleStore builds OHLCV bars from the trades on the event bus.
+type CandleStore struct {
+	sub     *events.Subscription
+	history int
+
+	mu     sync.Mutex
+	series map[string]map[string]*series // symbol -> interval -> bars
+}
+
+// NewCandleStore subscribes to trades on bus and keeps up to history
+// finished bars per symbol and interval.
+func NewCandleStore(bus *events.Bus, history int) *CandleStore {
+	if history <= 0 {
+		history = DefaultCandleHistory
+	}
+	cs := &CandleStore{
+		sub: bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+			return ev.Type == events.TradeExecuted
+		}),
+		history: history,
+		series:  make(map[string]map[string]*series),
+	}
+	go cs.run()
+	return cs
+}
+
+// Close stops consuming trades.
+func (cs *CandleStore) Close() {
+	cs.sub.Close()
+}
+
+func (cs *CandleStore) run() {
+	for ev := range cs.sub.C() {
+		cs.AddTrade(*ev.Trade)
+	}
+}
+
+// AddTrade folds one trade into every interval of its symbol.
+func (cs *CandleStore) AddTrade(t model.Trade) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for name := range Intervals {
+		cs.seriesLocked(t.Symbol, name).add(t)
+	}
+}
+
+func (cs *CandleStore) seriesLocked(symbol, interval string) *series {
+	bySym, ok := cs.series[symbol]
+	if !ok {
+		bySym = make(map[string]*series)
+		cs.series[symbol] = bySym
+	}
+	s, ok := bySym[interval]
+	if !ok {
+		s = &series{
+			ms:       Intervals[interval].Milliseconds(),
+			max:      cs.history,
+			watchers: make(map[chan Candle]struct{}),
+		}
+		bySym[interval] = s
+	}
+	return s
+}
+
+// Query returns the bars of symbol whose start lies in [from, to], oldest
+// first, including the bar still forming. from/to of 0 are open-ended.
+func (cs *CandleStore) Query(symbol, interval string, from, to int64) ([]Candle, error) {
+	if _, ok := Intervals[interval]; !ok {
+		return nil, ErrUnknownInterval
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	out := []Candle{}
+	bySym, ok := cs.series[symbol]
+	if !ok {
+		return out, nil
+	}
+	s, ok := bySym[interval]
+	if !ok {
+		return out, nil
+	}
+	in := func(c Candle) bool {
+		return (from == 0 || c.Time >= from) && (to == 0 || c.Time <= to)
+	}
+	for i := range s.done {
+		c := s.done[(s.next+i)%len(s.done)]
+		if in(c) {
+			out = append(out, c)
+		}
+	}
+	if s.hasOpen && in(s.forming) {
+		out = append(out, s.forming)
+	}
+	return out, nil
+}
+
+// Watch streams the forming bar of symbol at interval after every trade.
+// Updates are dropped for a watcher that is behind; each one carries the
+// whole bar so nothing is lost but intermediate states. Call cancel when
+// done: a series that never traded is dropped with its last watcher, so
+// watching made-up symbols leaves nothing behind.
+func (cs *CandleStore) Watch(symbol, interval string) (updates <-chan Candle, cancel func(), err error) {
+	if _, ok := Intervals[interval]; !ok {
+		return nil, nil, ErrUnknownInterval
+	}
+	ch := make(chan Candle, 16)
+	cs.mu.Lock()
+	s := cs.seriesLocked(symbol, interval)
+	s.watchers[ch] = struct{}{}
+	cs.mu.Unlock()
+
+	cancel = func() {
+		cs.mu.Lock()
+		delete(s.watchers, ch)
+		if len(s.watchers) == 0 && !s.hasOpen {
+			cs.dropLocked(symbol, interval, s)
+		}
+		cs.mu.Unlock()
+	}
+	return ch, cancel, nil
+}
+
+// dropLocked removes s, the series of symbol at interval, and symbol once
+// it has no series left.
+func (cs *CandleStore) dropLocked(symbol, interval string, s *series) {
+	bySym := cs.series[symbol]
+	if bySym[interval] != s {
+		return
+	}
+	delete(bySym, interval)
+	if len(bySym) == 0 {
+		delete(cs.series, symbol)
+	}
+}
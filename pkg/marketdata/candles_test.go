@@ -0,0 +1,106 @@
+package marketdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func TestCandleAggregation(t *testing.T) {
+	cs := NewCandleStore(events.NewBus(), 2)
+	defer cs.Close()
+
+	base := int64(1_700_000_100_000) // a 5 minute boundary
+	trade := func(offsetMs, price, qty int64) {
+		cs.AddTrade(model.Trade{Symbol: "C", Price: price, Quantity: qty, Timestamp: base + offsetMs})
+	}
+	trade(0, 100, 1)
+	trade(10_000, 105, 2)
+	trade(50_000, 95, 3)
+	trade(60_000, 101, 1)  // second minute
+	trade(120_000, 102, 1) // third
+	trade(185_000, 103, 1) // fourth, evicts the first bar (history 2)
+
+	bars, err := cs.Query("C", "1m", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 3 {
+		t.Fatalf("expected 2 finished + 1 forming bar, got %d: %+v", len(bars), bars)
+	}
+	if bars[0].Time != base+60_000 || bars[2].Time != base+180_000 || bars[2].Close != 103 {
+		t.Fatalf("unexpected bars %+v", bars)
+	}
+
+	five, _ := cs.Query("C", "5m", 0, 0)
+	if len(five) != 1 {
+		t.Fatalf("expected one 5m bar, got %+v", five)
+	}
+	b := five[0]
+	if b.Open != 100 || b.High != 105 || b.Low != 95 || b.Close != 103 || b.Volume != 9 || b.Trades != 6 {
+		t.Fatalf("unexpected 5m bar %+v", b)
+	}
+
+	ranged, _ := cs.Query("C", "1m", base+100_000, base+150_000)
+	if len(ranged) != 1 || ranged[0].Time != base+120_000 {
+		t.Fatalf("expected only the third minute in range, got %+v", ranged)
+	}
+
+	if _, err := cs.Query("C", "7m", 0, 0); err != ErrUnknownInterval {
+		t.Fatalf("expected ErrUnknownInterval, got %v", err)
+	}
+}
+
+func TestCandleWatchFromEngineTrades(t *testing.T) {
+	bus := events.NewBus()
+	cs := NewCandleStore(bus, 0)
+	defer cs.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	updates, cancel, err := cs.Watch("W", "1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	r.SubmitOrder(&model.Order{ID: "s", Symbol: "W", Side: model.SELL, Type: model.LIMIT, Price: 200, Quantity: 5})
+	r.SubmitOrder(&model.Order{ID: "b", Symbol: "W", Side: model.BUY, Type: model.LIMIT, Price: 200, Quantity: 5})
+
+	select {
+	case c := <-updates:
+		if c.Close != 200 || c.Volume != 5 {
+			t.Fatalf("unexpected forming bar %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no candle update from engine trade")
+	}
+}
+
+func TestCandleWatchersOfUntradedSymbolsLeaveNothing(t *testing.T) {
+	bus := events.NewBus()
+	cs := NewCandleStore(bus, 0)
+	defer cs.Close()
+	cs.AddTrade(model.Trade{Symbol: "T", Price: 100, Quantity: 1, Timestamp: 1_000})
+
+	var cancels []func()
+	for _, sym := range []string{"NOPE", "NOPE", "T"} {
+		_, cancel, err := cs.Watch(sym, "1m")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancels = append(cancels, cancel)
+	}
+	cancels[0]()
+	if _, ok := cs.series["NOPE"]; !ok {
+		t.Fatal("expected NOPE kept while watched")
+	}
+	cancels[1]()
+	cancels[2]()
+	if _, ok := cs.series["NOPE"]; ok || len(cs.series["T"]) != len(Intervals) {
+		t.Fatalf("expected only T's bars left, got %v", cs.series)
+	}
+}
@@ -0,0 +1,164 @@
+package marketdata
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Client is a reference consumer of the L2 feed. It rebuilds each
+// subscribed book from snapshot + updates and resyncs on a seq gap.
+// It is used by tests and tools to check that a rebuilt book matches the
+// server's.
+type Client struct {
+	ws *websocket.Conn
+
+	wmu sync.Mutex // gorilla allows one concurrent writer
+
+	mu        sync.Mutex
+	books     map[string]*l2Book
+	resyncing map[string]bool // waiting for a fresh snapshot
+	resyncs   int
+	changed   chan struct{} // closed and replaced on every applied message
+
+	done chan struct{}
+	err  error
+}
+
+// Dial connects to a feed URL such as ws://host:8080/ws/v1/marketdata.
+func Dial(url string) (*Client, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		ws:        ws,
+		books:     make(map[string]*l2Book),
+		resyncing: make(map[string]bool),
+		changed:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Subscribe asks for symbol's snapshot and updates.
+func (c *Client) Subscribe(symbol string) error {
+	return c.send(Request{Op: "subscribe", Symbol: symbol})
+}
+
+// Close ends the connection.
+func (c *Client) Close() error {
+	return c.ws.Close()
+}
+
+// Err returns why the read loop stopped, once Done is closed.
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Done is closed when the connection ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Book returns the rebuilt book for symbol and the seq it reflects.
+func (c *Client) Book(symbol string) (bids, asks []Level, seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.books[symbol]
+	if !ok {
+		return nil, nil, 0
+	}
+	return sortedLevels(b.bids, false), sortedLevels(b.asks, true), b.seq
+}
+
+// Resyncs returns how many times the client had to resync after a gap.
+func (c *Client) Resyncs() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resyncs
+}
+
+// Changed returns a channel closed on the next applied message.
+func (c *Client) Changed() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.changed
+}
+
+func (c *Client) send(req Request) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.ws.WriteJSON(req)
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		var m Message
+		if err := c.ws.ReadJSON(&m); err != nil {
+			c.err = err
+			return
+		}
+		if m.Type == "error" {
+			c.err = errors.New(m.Error)
+			continue
+		}
+		if c.apply(m) {
+			if err := c.send(Request{Op: "resync", Symbol: m.Symbol}); err != nil {
+				c.err = err
+				return
+			}
+		}
+	}
+}
+
+// apply folds m into the local book. It returns true when m does not follow
+// the last applied seq and the book must be resynced.
+func (c *Client) apply(m Message) (gap bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer func() {
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}()
+
+	switch m.Type {
+	case "snapshot":
+		b := newL2Book()
+		for _, l := range m.Bids {
+			b.bids[l.Price] = l.Quantity
+		}
+		for _, l := range m.Asks {
+			b.asks[l.Price] = l.Quantity
+		}
+		b.seq = m.Seq
+		c.books[m.Symbol] = b
+		delete(c.resyncing, m.Symbol)
+	case "update":
+		if c.resyncing[m.Symbol] {
+			return false // stale, the snapshot on its way covers it
+		}
+		b, ok := c.books[m.Symbol]
+		if !ok || b.seq != m.PrevSeq {
+			// missed something: drop the book until the new snapshot lands
+			delete(c.books, m.Symbol)
+			c.resyncing[m.Symbol] = true
+			c.resyncs++
+			return true
+		}
+		for _, l := range m.Bids {
+			b.apply(model.BUY, l.Price, l.Quantity)
+		}
+		for _, l := range m.Asks {
+			b.apply(model.SELL, l.Price, l.Quantity)
+		}
+		b.seq = m.Seq
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package marketdata
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+const (
+	writeWait  = 5 * time.Second
+	pingPeriod = 30 * time.Second
+	pongWait   = 2 * pingPeriod
+)
+
+// Hub serves the L2 WebSocket feed.
+//
+// It keeps a price -> quantity mirror of every book, built from the
+// LevelChanged events on the bus, so snapshots and the incremental stream
+// come from one place and always line up by seq. The hub must subscribe
+// before orders start flowing; it uses the Block policy and never misses a
+// level change.
+//
+// Each connection conflates: level changes that arrive while the client is
+// still writing the previous message are merged per price, so a slow client
+// receives fewer, larger updates instead of an unbounded backlog.
+type Hub struct {
+	sub *events.Subscription
+
+	mu    sync.Mutex
+	books map[string]*l2Book            // symbol -> mirror
+	subs  map[string]map[*conn]struct{} // symbol -> subscribed connections
+
+	upgrader websocket.Upgrader
+}
+
+// NewHub subscribes to bus and starts applying level changes.
+func NewHub(bus *events.Bus) *Hub {
+	h := &Hub{
+		sub: bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+			return ev.Type == events.LevelChanged
+		}),
+		books: make(map[string]*l2Book),
+		subs:  make(map[string]map[*conn]struct{}),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+	go h.run()
+	return h
+}
+
+// Close stops consuming events. Open connections are left to time out.
+func (h *Hub) Close() {
+	h.sub.Close()
+}
+
+func (h *Hub) run() {
+	for ev := range h.sub.C() {
+		h.mu.Lock()
+		book := h.bookLocked(ev.Symbol)
+		book.apply(ev.Level.Side, ev.Level.Price, ev.Level.Quantity)
+		book.seq = ev.Seq
+		for c := range h.subs[ev.Symbol] {
+			c.queueChange(ev.Symbol, ev.Level, ev.Seq)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) bookLocked(symbol string) *l2Book {
+	b, ok := h.books[symbol]
+	if !ok {
+		b = newL2Book()
+		h.books[symbol] = b
+	}
+	return b
+}
+
+// Snapshot returns the hub's current view of symbol.
+func (h *Hub) Snapshot(symbol string) Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.snapshotLocked(symbol)
+}
+
+// snapshotLocked returns the mirror's snapshot of symbol. Only level changes
+// create mirrors: a symbol that has had none gets an empty snapshot, so
+// subscribers naming any symbol they like leave nothing behind.
+func (h *Hub) snapshotLocked(symbol string) Message {
+	if b, ok := h.books[symbol]; ok {
+		return b.snapshot(symbol)
+	}
+	return Message{Type: "snapshot", Symbol: symbol}
+}
+
+// subscribe (re)starts the stream for symbol on c with a fresh snapshot.
+// Pending updates for the symbol are discarded; the snapshot covers them.
+func (h *Hub) subscribe(c *conn, symbol string) {
+	h.mu.Lock()
+	snap := h.snapshotLocked(symbol)
+	c.reset(symbol, &snap)
+	if h.subs[symbol] == nil {
+		h.subs[symbol] = make(map[*conn]struct{})
+	}
+	h.subs[symbol][c] = struct{}{}
+	h.mu.Unlock()
+	c.signal()
+}
+
+func (h *Hub) unsubscribe(c *conn, symbol string) {
+	h.mu.Lock()
+	delete(h.subs[symbol], c)
+	if len(h.subs[symbol]) == 0 {
+		delete(h.subs, symbol)
+	}
+	c.reset(symbol, nil)
+	h.mu.Unlock()
+}
+
+func (h *Hub) remove(c *conn) {
+	h.mu.Lock()
+	for sym, set := range h.subs {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(h.subs, sym)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// ServeHTTP upgrades the request and serves one client.
+// GET /ws/v1/marketdata
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied
+	}
+	c := &conn{
+		ws:     ws,
+		syms:   make(map[string]*subState),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go c.writeLoop()
+	c.readLoop(h)
+	h.remove(c)
+}
+
+// subState is one symbol's stream state on one connection.
+type subState struct {
+	snapshot *Message        // queued snapshot, written before any change
+	bids     map[int64]int64 // conflated changes since the last write
+	asks     map[int64]int64
+	seq      uint64 // engine seq of the newest queued change
+	sentSeq  uint64 // seq of the last message written
+}
+
+type conn struct {
+	ws *websocket.Conn
+
+	mu   sync.Mutex
+	syms map[string]*subState
+	ctrl []Message // errors and other replies not tied to a stream
+
+	notify chan struct{} // cap 1: "something to write"
+	done   chan struct{}
+}
+
+func (c *conn) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// reset replaces the state for symbol; snap == nil unsubscribes.
+func (c *conn) reset(symbol string, snap *Message) {
+	c.mu.Lock()
+	if snap == nil {
+		delete(c.syms, symbol)
+	} else {
+		c.syms[symbol] = &subState{
+			snapshot: snap,
+			bids:     make(map[int64]int64),
+			asks:     make(map[int64]int64),
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *conn) queueChange(symbol string, lvl *events.Level, seq uint64) {
+	c.mu.Lock()
+	st, ok := c.syms[symbol]
+	if ok {
+		if lvl.Side == model.SELL {
+			st.asks[lvl.Price] = lvl.Quantity
+		} else {
+			st.bids[lvl.Price] = lvl.Quantity
+		}
+		st.seq = seq
+	}
+	c.mu.Unlock()
+	if ok {
+		c.signal()
+	}
+}
+
+// drain collects everything queued, in the order it must be written.
+func (c *conn) drain() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.ctrl
+	c.ctrl = nil
+	for sym, st := range c.syms {
+		if st.snapshot != nil {
+			out = append(out, *st.snapshot)
+			st.sentSeq = st.snapshot.Seq
+			st.snapshot = nil
+		}
+		if len(st.bids) == 0 && len(st.asks) == 0 {
+			continue
+		}
+		out = append(out, Message{
+			Type:    "update",
+			Symbol:  sym,
+			Seq:     st.seq,
+			PrevSeq: st.sentSeq,
+			Bids:    sortedLevels(st.bids, false),
+			Asks:    sortedLevels(st.asks, true),
+		})
+		st.sentSeq = st.seq
+		st.bids = make(map[int64]int64)
+		st.asks = make(map[int64]int64)
+	}
+	return out
+}
+
+func (c *conn) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.ws.Close()
+	for {
+		select {
+		case <-c.notify:
+			for _, m := range c.drain() {
+				c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := c.ws.WriteJSON(m); err != nil {
+					return
+				}
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *conn) readLoop(h *Hub) {
+	defer close(c.done)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		var req Request
+		if err := c.ws.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Println("marketdata: read:", err)
+			}
+			return
+		}
+		if req.Symbol == "" {
+			c.reply(Message{Type: "error", Error: "symbol is required"})
+			continue
+		}
+		switch req.Op {
+		case "subscribe", "resync":
+			h.subscribe(c, req.Symbol)
+		case "unsubscribe":
+			h.unsubscribe(c, req.Symbol)
+		default:
+			c.reply(Message{Type: "error", Symbol: req.Symbol, Error: "unknown op: " + req.Op})
+		}
+	}
+}
+
+// reply queues a message for the writer.
+func (c *conn) reply(m Message) {
+	c.mu.Lock()
+	c.ctrl = append(c.ctrl, m)
+	c.mu.Unlock()
+	c.signal()
+}
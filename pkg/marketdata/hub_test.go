@@ -0,0 +1,197 @@
+package marketdata
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func engineLevels(side []map[string]interface{}) []Level {
+	out := make([]Level, 0, len(side))
+	for _, m := range side {
+		out = append(out, Level{Price: m["price"].(int64), Quantity: m["quantity"].(int64)})
+	}
+	return out
+}
+
+func submitRandom(r *engine.Router, rnd *rand.Rand, symbol string, n int, ids *[]string) {
+	for i := 0; i < n; i++ {
+		side := model.BUY
+		price := int64(95 + rnd.Intn(8))
+		if rnd.Intn(2) == 0 {
+			side = model.SELL
+			price += 3
+		}
+		o := &model.Order{
+			ID:       fmt.Sprintf("%s-%d-%d", symbol, len(*ids), i),
+			Symbol:   symbol,
+			Side:     side,
+			Type:     model.LIMIT,
+			Price:    price,
+			Quantity: int64(1 + rnd.Intn(10)),
+		}
+		r.SubmitOrder(o)
+		*ids = append(*ids, o.ID)
+		if rnd.Intn(4) == 0 {
+			r.CancelOrder(symbol, (*ids)[rnd.Intn(len(*ids))])
+		}
+	}
+}
+
+func waitForBook(t *testing.T, c *Client, r *engine.Router, symbol string) {
+	t.Helper()
+	deadline := time.After(3 * time.Second)
+	for {
+		changed := c.Changed()
+		want := r.GetOrderBook(symbol, 1000)
+		bids, asks, _ := c.Book(symbol)
+		if reflect.DeepEqual(bids, engineLevels(want.Bids)) && reflect.DeepEqual(asks, engineLevels(want.Asks)) {
+			return
+		}
+		select {
+		case <-changed:
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("client book never matched engine\nclient bids=%v asks=%v\nengine bids=%v asks=%v",
+				bids, asks, engineLevels(want.Bids), engineLevels(want.Asks))
+		}
+	}
+}
+
+func TestL2FeedRebuildsEngineBook(t *testing.T) {
+	bus := events.NewBus()
+	hub := NewHub(bus)
+	defer hub.Close()
+	r := engine.NewRouter(2, 64, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	var ids []string
+	submitRandom(r, rnd, "L2", 200, &ids) // state before the client joins
+
+	c, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Subscribe("L2"); err != nil {
+		t.Fatal(err)
+	}
+
+	submitRandom(r, rnd, "L2", 500, &ids)
+	waitForBook(t, c, r, "L2")
+
+	if snap := hub.Snapshot("L2"); snap.Seq != r.GetOrderBook("L2", 1).Seq {
+		t.Fatalf("hub seq %d behind engine seq %d", snap.Seq, r.GetOrderBook("L2", 1).Seq)
+	}
+}
+
+func TestL2SubscribingKeepsNoBook(t *testing.T) {
+	bus := events.NewBus()
+	hub := NewHub(bus)
+	defer hub.Close()
+	r := engine.NewRouter(2, 64, engine.WithEventBus(bus))
+	defer r.Stop()
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	ws.WriteJSON(Request{Op: "subscribe", Symbol: "GHOST"})
+	var m Message
+	if err := ws.ReadJSON(&m); err != nil || m.Type != "snapshot" || len(m.Bids)+len(m.Asks) != 0 {
+		t.Fatalf("expected an empty snapshot, got %+v, %v", m, err)
+	}
+	ws.WriteJSON(Request{Op: "unsubscribe", Symbol: "GHOST"})
+	ws.WriteJSON(Request{Op: "subscribe", Symbol: "GHOST"})
+	if err := ws.ReadJSON(&m); err != nil || m.Type != "snapshot" {
+		t.Fatalf("expected a second snapshot, got %+v, %v", m, err)
+	}
+	hub.mu.Lock()
+	n := len(hub.books)
+	hub.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no book kept for a symbol without orders, have %d", n)
+	}
+
+	// once the symbol trades, the stream carries on from the empty snapshot
+	r.SubmitOrder(&model.Order{ID: "g1", Symbol: "GHOST", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 3})
+	if err := ws.ReadJSON(&m); err != nil || m.Type != "update" || m.PrevSeq != 0 || len(m.Bids) != 1 {
+		t.Fatalf("expected the first level as an update, got %+v, %v", m, err)
+	}
+}
+
+func TestL2ClientResyncsOnGap(t *testing.T) {
+	bus := events.NewBus()
+	hub := NewHub(bus)
+	defer hub.Close()
+	r := engine.NewRouter(1, 64, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Subscribe("GAP")
+
+	rnd := rand.New(rand.NewSource(2))
+	var ids []string
+	submitRandom(r, rnd, "GAP", 50, &ids)
+	waitForBook(t, c, r, "GAP")
+
+	// pretend an update was lost in transit
+	_, _, seq := c.Book("GAP")
+	if !c.apply(Message{Type: "update", Symbol: "GAP", Seq: seq + 10, PrevSeq: seq + 5}) {
+		t.Fatalf("expected gap to be detected")
+	}
+	c.send(Request{Op: "resync", Symbol: "GAP"})
+
+	submitRandom(r, rnd, "GAP", 50, &ids)
+	waitForBook(t, c, r, "GAP")
+	if c.Resyncs() != 1 {
+		t.Fatalf("expected 1 resync, got %d", c.Resyncs())
+	}
+}
+
+func TestConnConflatesPendingChanges(t *testing.T) {
+	c := &conn{syms: make(map[string]*subState), notify: make(chan struct{}, 1)}
+	snap := Message{Type: "snapshot", Symbol: "CF", Seq: 4}
+	c.reset("CF", &snap)
+
+	c.queueChange("CF", &events.Level{Side: model.BUY, Price: 100, Quantity: 5}, 5)
+	c.queueChange("CF", &events.Level{Side: model.BUY, Price: 100, Quantity: 7}, 6)
+	c.queueChange("CF", &events.Level{Side: model.SELL, Price: 101, Quantity: 0}, 7)
+
+	out := c.drain()
+	if len(out) != 2 || out[0].Type != "snapshot" {
+		t.Fatalf("expected snapshot + one conflated update, got %+v", out)
+	}
+	u := out[1]
+	if u.PrevSeq != 4 || u.Seq != 7 {
+		t.Fatalf("expected update covering (4,7], got (%d,%d]", u.PrevSeq, u.Seq)
+	}
+	if len(u.Bids) != 1 || u.Bids[0].Quantity != 7 || len(u.Asks) != 1 || u.Asks[0].Quantity != 0 {
+		t.Fatalf("unexpected conflated levels bids=%v asks=%v", u.Bids, u.Asks)
+	}
+}
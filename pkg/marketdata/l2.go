@@ -0,0 +1,85 @@
+package marketdata
+
+import (
+	"sort"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Level is one aggregated price level on the wire.
+// In updates Quantity 0 means the level was removed.
+type Level struct {
+	Price    int64 `json:"price"`
+	Quantity int64 `json:"quantity"`
+}
+
+// Message is what the L2 feed sends to clients.
+//
+//	snapshot: full book at Seq.
+//	update:   level changes between PrevSeq (exclusive) and Seq (inclusive).
+//	          If PrevSeq is not the last Seq the client applied, it missed
+//	          data and must send a resync.
+//	error:    Error explains a rejected request.
+type Message struct {
+	Type    string  `json:"type"`
+	Symbol  string  `json:"symbol,omitempty"`
+	Seq     uint64  `json:"seq,omitempty"`
+	PrevSeq uint64  `json:"prev_seq,omitempty"`
+	Bids    []Level `json:"bids,omitempty"`
+	Asks    []Level `json:"asks,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Request is what clients send to the L2 feed.
+// Op is one of subscribe, unsubscribe or resync.
+type Request struct {
+	Op     string `json:"op"`
+	Symbol string `json:"symbol"`
+}
+
+// l2Book is a price -> quantity mirror of one symbol's book.
+type l2Book struct {
+	bids map[int64]int64
+	asks map[int64]int64
+	seq  uint64 // engine seq of the last level change applied
+}
+
+func newL2Book() *l2Book {
+	return &l2Book{bids: make(map[int64]int64), asks: make(map[int64]int64)}
+}
+
+func (b *l2Book) apply(side model.Side, price, qty int64) {
+	m := b.bids
+	if side == model.SELL {
+		m = b.asks
+	}
+	if qty == 0 {
+		delete(m, price)
+		return
+	}
+	m[price] = qty
+}
+
+func (b *l2Book) snapshot(symbol string) Message {
+	return Message{
+		Type:   "snapshot",
+		Symbol: symbol,
+		Seq:    b.seq,
+		Bids:   sortedLevels(b.bids, false),
+		Asks:   sortedLevels(b.asks, true),
+	}
+}
+
+// sortedLevels flattens a side. Bids -> highest first, asks -> lowest first.
+func sortedLevels(m map[int64]int64, asc bool) []Level {
+	out := make([]Level, 0, len(m))
+	for p, q := range m {
+		out = append(out, Level{Price: p, Quantity: q})
+	}
+	if asc {
+		sort.Slice(out, func(i, j int) bool { return out[i].Price < out[j].Price })
+	} else {
+		sort.Slice(out, func(i, j int) bool { return out[i].Price > out[j].Price })
+	}
+	return out
+}
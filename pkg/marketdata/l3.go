@@ -0,0 +1,426 @@
+package marketdata
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// L3 message types.
+const (
+	L3Snapshot = "snapshot"
+	L3Add      = "add"     // order joined the back of its level
+	L3Modify   = "modify"  // resting size reduced at the same price, queue position kept
+	L3Execute  = "execute" // Quantity traded against the order at Price
+	L3Delete   = "delete"  // order left the book (cancelled or fully filled)
+)
+
+// L3Order is one resting order in an L3 snapshot, in queue order.
+// ID is an anonymised handle, stable for the life of the resting order.
+type L3Order struct {
+	ID       uint64 `json:"id"`
+	Quantity int64  `json:"quantity"`
+}
+
+// L3Level is one price level with its FIFO queue.
+type L3Level struct {
+	Price  int64     `json:"price"`
+	Orders []L3Order `json:"orders"`
+}
+
+// L3Message is what the L3 feed sends. PrevSeq works as in Message.
+type L3Message struct {
+	Type     string     `json:"type"`
+	Symbol   string     `json:"symbol,omitempty"`
+	Seq      uint64     `json:"seq,omitempty"`
+	PrevSeq  uint64     `json:"prev_seq,omitempty"`
+	OrderID  uint64     `json:"order_id,omitempty"`
+	Side     model.Side `json:"side,omitempty"`
+	Price    int64      `json:"price,omitempty"`
+	Quantity int64      `json:"quantity,omitempty"` // add/modify: resting size; execute: traded size
+	Bids     []L3Level  `json:"bids,omitempty"`
+	Asks     []L3Level  `json:"asks,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+type l3Entry struct {
+	id    uint64
+	side  model.Side
+	price int64
+	qty   int64
+}
+
+// L3Book is an order-by-order book rebuilt from L3 messages.
+// The hub uses it as its mirror; consumers can use it to apply the feed.
+type L3Book struct {
+	bids map[int64][]*l3Entry
+	asks map[int64][]*l3Entry
+	byID map[uint64]*l3Entry
+	seq  uint64
+}
+
+// NewL3Book creates an empty book.
+func NewL3Book() *L3Book {
+	return &L3Book{
+		bids: make(map[int64][]*l3Entry),
+		asks: make(map[int64][]*l3Entry),
+		byID: make(map[uint64]*l3Entry),
+	}
+}
+
+// ErrL3Gap is returned by Apply when a message does not follow the last one.
+var ErrL3Gap = errors.New("l3: sequence gap, resync required")
+
+// Seq returns the seq of the last applied message.
+func (b *L3Book) Seq() uint64 {
+	return b.seq
+}
+
+// Apply folds one feed message into the book.
+func (b *L3Book) Apply(m L3Message) error {
+	if m.Type == L3Snapshot {
+		*b = *NewL3Book()
+		for _, lvl := range m.Bids {
+			for _, o := range lvl.Orders {
+				b.add(o.ID, model.BUY, lvl.Price, o.Quantity)
+			}
+		}
+		for _, lvl := range m.Asks {
+			for _, o := range lvl.Orders {
+				b.add(o.ID, model.SELL, lvl.Price, o.Quantity)
+			}
+		}
+		b.seq = m.Seq
+		return nil
+	}
+	if m.PrevSeq != b.seq {
+		return ErrL3Gap
+	}
+	switch m.Type {
+	case L3Add:
+		b.add(m.OrderID, m.Side, m.Price, m.Quantity)
+	case L3Modify:
+		if e, ok := b.byID[m.OrderID]; ok {
+			e.qty = m.Quantity
+		}
+	case L3Execute:
+		if e, ok := b.byID[m.OrderID]; ok {
+			e.qty -= m.Quantity
+		}
+	case L3Delete:
+		b.remove(m.OrderID)
+	}
+	b.seq = m.Seq
+	return nil
+}
+
+// Levels returns one side in priority order with each level's FIFO queue.
+func (b *L3Book) Levels(side model.Side) []L3Level {
+	m, asc := b.bids, false
+	if side == model.SELL {
+		m, asc = b.asks, true
+	}
+	prices := make([]int64, 0, len(m))
+	for p := range m {
+		prices = append(prices, p)
+	}
+	if asc {
+		sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	} else {
+		sort.Slice(prices, func(i, j int) bool { return prices[i] > prices[j] })
+	}
+	out := make([]L3Level, 0, len(prices))
+	for _, p := range prices {
+		lvl := L3Level{Price: p, Orders: make([]L3Order, 0, len(m[p]))}
+		for _, e := range m[p] {
+			lvl.Orders = append(lvl.Orders, L3Order{ID: e.id, Quantity: e.qty})
+		}
+		out = append(out, lvl)
+	}
+	return out
+}
+
+func (b *L3Book) add(id uint64, side model.Side, price, qty int64) {
+	e := &l3Entry{id: id, side: side, price: price, qty: qty}
+	m := b.bids
+	if side == model.SELL {
+		m = b.asks
+	}
+	m[price] = append(m[price], e)
+	b.byID[id] = e
+}
+
+func (b *L3Book) remove(id uint64) {
+	e, ok := b.byID[id]
+	if !ok {
+		return
+	}
+	delete(b.byID, id)
+	m := b.bids
+	if e.side == model.SELL {
+		m = b.asks
+	}
+	q := m[e.price]
+	for i, x := range q {
+		if x == e {
+			q = append(q[:i], q[i+1:]...)
+			break
+		}
+	}
+	if len(q) == 0 {
+		delete(m, e.price)
+	} else {
+		m[e.price] = q
+	}
+}
+
+// l3Mirror is the hub's per-symbol state: the book plus the mapping from
+// engine order IDs to the anonymised IDs published on the feed.
+type l3Mirror struct {
+	book *L3Book
+	anon map[string]uint64 // engine order ID -> feed ID
+}
+
+// L3Hub serves the order-by-order WebSocket feed.
+//
+// Like Hub it mirrors the books from bus events so snapshots and the stream
+// line up by seq. Unlike L2, L3 messages cannot be conflated: a connection
+// whose queue overflows is closed and the client must reconnect and take a
+// fresh snapshot.
+type L3Hub struct {
+	sub *events.Subscription
+
+	mu     sync.Mutex
+	books  map[string]*l3Mirror
+	nextID uint64
+	subs   map[string]map[*l3Conn]struct{}
+
+	upgrader websocket.Upgrader
+}
+
+// NewL3Hub subscribes to bus and starts mirroring books.
+func NewL3Hub(bus *events.Bus) *L3Hub {
+	h := &L3Hub{
+		sub: bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+			switch ev.Type {
+			case events.OrderBooked, events.OrderAmended, events.OrderFilled, events.OrderCancelled:
+				return true
+			}
+			return false
+		}),
+		books: make(map[string]*l3Mirror),
+		subs:  make(map[string]map[*l3Conn]struct{}),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+	go h.run()
+	return h
+}
+
+// Close stops consuming events.
+func (h *L3Hub) Close() {
+	h.sub.Close()
+}
+
+func (h *L3Hub) mirrorLocked(symbol string) *l3Mirror {
+	m, ok := h.books[symbol]
+	if !ok {
+		m = &l3Mirror{book: NewL3Book(), anon: make(map[string]uint64)}
+		h.books[symbol] = m
+	}
+	return m
+}
+
+func (h *L3Hub) run() {
+	for ev := range h.sub.C() {
+		h.mu.Lock()
+		mir := h.mirrorLocked(ev.Symbol)
+		for _, m := range h.translate(mir, &ev) {
+			m.Symbol = ev.Symbol
+			m.Seq = ev.Seq
+			m.PrevSeq = mir.book.seq
+			mir.book.Apply(m)
+			for c := range h.subs[ev.Symbol] {
+				c.send(m)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// translate turns one engine event into L3 messages. Events about orders
+// that are not resting (the taker side of a fill) or are hidden produce
+// nothing.
+func (h *L3Hub) translate(mir *l3Mirror, ev *events.Event) []L3Message {
+	o := ev.Order
+	if ev.Type == events.OrderBooked {
+		if o.Hidden {
+			return nil
+		}
+		h.nextID++
+		mir.anon[o.ID] = h.nextID
+		return []L3Message{{Type: L3Add, OrderID: h.nextID, Side: o.Side, Price: o.Price, Quantity: o.Remaining()}}
+	}
+	id, ok := mir.anon[o.ID]
+	if !ok {
+		return nil
+	}
+	switch ev.Type {
+	case events.OrderAmended:
+		e := mir.book.byID[id]
+		if e.price == o.Price && o.Remaining() <= e.qty {
+			return []L3Message{{Type: L3Modify, OrderID: id, Side: o.Side, Price: o.Price, Quantity: o.Remaining()}}
+		}
+		// re-priced or grown: it lost its place and comes back as a new add
+		delete(mir.anon, o.ID)
+		return []L3Message{{Type: L3Delete, OrderID: id, Side: o.Side, Price: e.price}}
+	case events.OrderFilled:
+		out := []L3Message{{Type: L3Execute, OrderID: id, Side: o.Side, Price: ev.FillPrice, Quantity: ev.FillQty}}
+		if o.Remaining() == 0 {
+			delete(mir.anon, o.ID)
+			out = append(out, L3Message{Type: L3Delete, OrderID: id, Side: o.Side, Price: o.Price})
+		}
+		return out
+	case events.OrderCancelled:
+		delete(mir.anon, o.ID)
+		return []L3Message{{Type: L3Delete, OrderID: id, Side: o.Side, Price: o.Price}}
+	}
+	return nil
+}
+
+// Snapshot returns the hub's current L3 view of symbol.
+func (h *L3Hub) Snapshot(symbol string) L3Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.snapshotLocked(symbol)
+}
+
+// snapshotLocked returns the mirror's snapshot of symbol. As in Hub, only
+// events create mirrors: a symbol that has had none gets an empty snapshot.
+func (h *L3Hub) snapshotLocked(symbol string) L3Message {
+	m, ok := h.books[symbol]
+	if !ok {
+		return L3Message{Type: L3Snapshot, Symbol: symbol}
+	}
+	b := m.book
+	return L3Message{
+		Type:   L3Snapshot,
+		Symbol: symbol,
+		Seq:    b.seq,
+		Bids:   b.Levels(model.BUY),
+		Asks:   b.Levels(model.SELL),
+	}
+}
+
+// ServeHTTP upgrades the request and serves one client.
+// GET /ws/v1/marketdata/l3
+func (h *L3Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &l3Conn{ws: ws, out: make(chan L3Message, 8192), done: make(chan struct{})}
+	go c.writeLoop()
+	c.readLoop(h)
+
+	h.mu.Lock()
+	for sym, set := range h.subs {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(h.subs, sym)
+		}
+	}
+	h.mu.Unlock()
+}
+
+type l3Conn struct {
+	ws   *websocket.Conn
+	out  chan L3Message
+	done chan struct{}
+	once sync.Once
+}
+
+// send queues m; a full queue means the client cannot keep up and is cut off.
+func (c *l3Conn) send(m L3Message) {
+	select {
+	case c.out <- m:
+	default:
+		c.kill()
+	}
+}
+
+func (c *l3Conn) kill() {
+	c.once.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+func (c *l3Conn) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.kill()
+	for {
+		select {
+		case m := <-c.out:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteJSON(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *l3Conn) readLoop(h *L3Hub) {
+	defer c.kill()
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		var req Request
+		if err := c.ws.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Println("marketdata: l3 read:", err)
+			}
+			return
+		}
+		if req.Symbol == "" {
+			c.send(L3Message{Type: "error", Error: "symbol is required"})
+			continue
+		}
+		h.mu.Lock()
+		switch req.Op {
+		case "subscribe", "resync":
+			if h.subs[req.Symbol] == nil {
+				h.subs[req.Symbol] = make(map[*l3Conn]struct{})
+			}
+			h.subs[req.Symbol][c] = struct{}{}
+			c.send(h.snapshotLocked(req.Symbol))
+		case "unsubscribe":
+			delete(h.subs[req.Symbol], c)
+			if len(h.subs[req.Symbol]) == 0 {
+				delete(h.subs, req.Symbol)
+			}
+		default:
+			c.send(L3Message{Type: "error", Symbol: req.Symbol, Error: "unknown op: " + req.Op})
+		}
+		h.mu.Unlock()
+	}
+}
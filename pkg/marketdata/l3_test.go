@@ -0,0 +1,170 @@
+package marketdata
+
+import (
+	"math/rand"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func sumL3(lvls []L3Level) []Level {
+	out := make([]Level, 0, len(lvls))
+	for _, l := range lvls {
+		total := int64(0)
+		for _, o := range l.Orders {
+			total += o.Quantity
+		}
+		out = append(out, Level{Price: l.Price, Quantity: total})
+	}
+	return out
+}
+
+// waitL3 waits until the hub's L3 view adds up to the engine's book.
+func waitL3(t *testing.T, h *L3Hub, r *engine.Router, symbol string) L3Message {
+	t.Helper()
+	eng := r.GetOrderBook(symbol, 1000)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		snap := h.Snapshot(symbol)
+		if reflect.DeepEqual(sumL3(snap.Bids), engineLevels(eng.Bids)) && reflect.DeepEqual(sumL3(snap.Asks), engineLevels(eng.Asks)) {
+			return snap
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("L3 totals never matched engine book")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestL3PreservesQueueOrder(t *testing.T) {
+	bus := events.NewBus()
+	h := NewL3Hub(bus)
+	defer h.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	sell := func(id string, qty int64) {
+		r.SubmitOrder(&model.Order{ID: id, Symbol: "Q", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: qty})
+	}
+	sell("a", 5)
+	sell("b", 5)
+	sell("c", 5)
+	r.SubmitOrder(&model.Order{ID: "x", Symbol: "Q", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 2})
+	r.CancelOrder("Q", "b")
+	sell("d", 1)
+	r.SubmitOrder(&model.Order{ID: "y", Symbol: "Q", Side: model.BUY, Type: model.LIMIT, Price: 99, Quantity: 4})
+
+	snap := waitL3(t, h, r, "Q")
+	// anonymised IDs are handed out in booking order: a=1 b=2 c=3 d=4 y=5
+	wantAsks := []L3Level{{Price: 100, Orders: []L3Order{{ID: 1, Quantity: 3}, {ID: 3, Quantity: 5}, {ID: 4, Quantity: 1}}}}
+	wantBids := []L3Level{{Price: 99, Orders: []L3Order{{ID: 5, Quantity: 4}}}}
+	if !reflect.DeepEqual(snap.Asks, wantAsks) || !reflect.DeepEqual(snap.Bids, wantBids) {
+		t.Fatalf("unexpected L3 book\nasks=%+v\nbids=%+v", snap.Asks, snap.Bids)
+	}
+}
+
+func TestL3LeavesOutHiddenOrders(t *testing.T) {
+	bus := events.NewBus()
+	h := NewL3Hub(bus)
+	defer h.Close()
+	r := engine.NewRouter(1, 16, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	r.SubmitOrder(&model.Order{ID: "h", Symbol: "H", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 5, Hidden: true})
+	r.SubmitOrder(&model.Order{ID: "h2", Symbol: "H", Side: model.SELL, Type: model.LIMIT, Price: 99, Quantity: 5, Hidden: true})
+	r.SubmitOrder(&model.Order{ID: "d", Symbol: "H", Side: model.SELL, Type: model.LIMIT, Price: 100, Quantity: 2})
+	r.SubmitOrder(&model.Order{ID: "b", Symbol: "H", Side: model.BUY, Type: model.LIMIT, Price: 98, Quantity: 1})
+
+	snap := waitL3(t, h, r, "H")
+	wantAsks := []L3Level{{Price: 100, Orders: []L3Order{{ID: 1, Quantity: 2}}}}
+	if !reflect.DeepEqual(snap.Asks, wantAsks) {
+		t.Fatalf("expected only the displayed ask, got %+v", snap.Asks)
+	}
+}
+
+func TestL3StreamRebuildsSnapshot(t *testing.T) {
+	bus := events.NewBus()
+	h := NewL3Hub(bus)
+	defer h.Close()
+	r := engine.NewRouter(2, 64, engine.WithEventBus(bus))
+	defer r.Stop()
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	rnd := rand.New(rand.NewSource(3))
+	var ids []string
+	submitRandom(r, rnd, "L3", 100, &ids)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.WriteJSON(Request{Op: "subscribe", Symbol: "L3"})
+
+	submitRandom(r, rnd, "L3", 300, &ids)
+	want := waitL3(t, h, r, "L3")
+
+	book := NewL3Book()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	same := func() bool {
+		return book.Seq() >= want.Seq &&
+			reflect.DeepEqual(book.Levels(model.BUY), want.Bids) && reflect.DeepEqual(book.Levels(model.SELL), want.Asks)
+	}
+	for !same() {
+		var m L3Message
+		if err := ws.ReadJSON(&m); err != nil {
+			t.Fatalf("read: %v (at seq %d, want %d)", err, book.Seq(), want.Seq)
+		}
+		if err := book.Apply(m); err != nil {
+			t.Fatalf("seq %d: %v", m.Seq, err)
+		}
+	}
+}
+
+func TestL3SubscribingKeepsNoBook(t *testing.T) {
+	bus := events.NewBus()
+	h := NewL3Hub(bus)
+	defer h.Close()
+	r := engine.NewRouter(2, 64, engine.WithEventBus(bus))
+	defer r.Stop()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	ws.WriteJSON(Request{Op: "subscribe", Symbol: "GHOST"})
+	var m L3Message
+	if err := ws.ReadJSON(&m); err != nil || m.Type != L3Snapshot || len(m.Bids)+len(m.Asks) != 0 {
+		t.Fatalf("expected an empty snapshot, got %+v, %v", m, err)
+	}
+	h.mu.Lock()
+	n := len(h.books)
+	h.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no book kept for a symbol without orders, have %d", n)
+	}
+
+	book := NewL3Book()
+	book.Apply(m)
+	r.SubmitOrder(&model.Order{ID: "g1", Symbol: "GHOST", Side: model.BUY, Type: model.LIMIT, Price: 100, Quantity: 3})
+	if err := ws.ReadJSON(&m); err != nil || m.Type != L3Add {
+		t.Fatalf("expected the order added, got %+v, %v", m, err)
+	}
+	if err := book.Apply(m); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,9 @@
 package metrics
 
-import "sync/atomic"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Simple process-level metrics used by the engine & api.
 // Very small and intentionally minimal.
@@ -21,3 +24,29 @@ func AddOrdersProcessed(n int64) {
 func GetOrdersProcessed() int64 {
 	return atomic.LoadInt64(&ordersProcessed)
 }
+
+// sources are extra sections of the metrics endpoint, registered by the
+// components that own them.
+var sources = struct {
+	mu sync.RWMutex
+	m  map[string]func() interface{}
+}{m: make(map[string]func() interface{})}
+
+// Register adds a section named name whose value fn computes on every
+// scrape. Registering a name again replaces it.
+func Register(name string, fn func() interface{}) {
+	sources.mu.Lock()
+	sources.m[name] = fn
+	sources.mu.Unlock()
+}
+
+// Sections evaluates every registered section.
+func Sections() map[string]interface{} {
+	sources.mu.RLock()
+	defer sources.mu.RUnlock()
+	out := make(map[string]interface{}, len(sources.m))
+	for name, fn := range sources.m {
+		out[name] = fn()
+	}
+	return out
+}
@@ -1,6 +1,9 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 type Side string
 type OrderType string
@@ -11,6 +14,29 @@ const (
 
 	LIMIT  OrderType = "LIMIT"
 	MARKET OrderType = "MARKET"
+	PEGGED OrderType = "PEGGED" // price follows the book, see PegType
+
+	// TRAILING_STOP waits off the book until the last trade price reaches
+	// its StopPrice, then becomes its TriggerType.
+	TRAILING_STOP OrderType = "TRAILING_STOP"
+)
+
+// PegType is what a PEGGED order's price follows. References are the best
+// bid and ask of non-pegged orders.
+type PegType string
+
+const (
+	PegPrimary  PegType = "PRIMARY"  // own side: a buy pegs to the best bid
+	PegMarket   PegType = "MARKET"   // other side: a buy pegs to the best ask
+	PegMidpoint PegType = "MIDPOINT" // half way; trades only at the midpoint
+)
+
+// MinQtyScope is which executions an order's MinQty applies to.
+type MinQtyScope string
+
+const (
+	MinQtyEach  MinQtyScope = "EACH"  // every execution
+	MinQtyFirst MinQtyScope = "FIRST" // only the first; after it, any size
 )
 
 type Order struct {
@@ -22,6 +48,65 @@ type Order struct {
 	Quantity  int64     `json:"quantity"`
 	Filled    int64     `json:"filled_quantity,omitempty"`
 	Timestamp int64     `json:"timestamp,omitempty"` // unix ms
+	Account   string    `json:"account,omitempty"`   // owning account, carried on every order event
+	Hidden    bool      `json:"hidden,omitempty"`    // LIMIT only: never displayed, ranks behind displayed orders at its price
+
+	// ClientOrderID is the client's own name for the order, optional. The
+	// engine keeps it unique per account for a day.
+	ClientOrderID string `json:"client_order_id,omitempty"`
+
+	// LIMIT orders only: execution conditions. The order trades only in
+	// executions that meet them; resting, it is passed over, keeping its
+	// place, by orders it cannot trade with.
+	MinQty      int64       `json:"min_qty,omitempty"`       // smallest execution, or all that remains if less
+	MinQtyScope MinQtyScope `json:"min_qty_scope,omitempty"` // EACH (the default) or FIRST
+	AllOrNone   bool        `json:"all_or_none,omitempty"`   // everything that remains at once, or nothing
+
+	// PEGGED orders only. Price is then set by the engine: 0 while there
+	// is no reference to peg to.
+	PegType   PegType `json:"peg_type,omitempty"`
+	PegOffset int64   `json:"peg_offset,omitempty"` // cents from the reference, away from the other side
+	PegLimit  int64   `json:"peg_limit,omitempty"`  // a buy never pegs above it, a sell never below; 0: no cap
+
+	// TRAILING_STOP orders only. StopPrice is set by the engine: the best
+	// trade price since the order arrived (highest for a sell, lowest for a
+	// buy) less or plus the trail, 0 until a trade prints. Once triggered
+	// the order's Type is its TriggerType and Triggered is set.
+	TrailAmount  int64     `json:"trail_amount,omitempty"`  // cents
+	TrailPercent float64   `json:"trail_percent,omitempty"` // of the trade price
+	TriggerType  OrderType `json:"trigger_type,omitempty"`  // MARKET (the default) or LIMIT
+	LimitOffset  int64     `json:"limit_offset,omitempty"`  // a LIMIT trigger's price is this far past the stop price
+	StopPrice    int64     `json:"stop_price,omitempty"`
+	Triggered    bool      `json:"triggered,omitempty"`
+}
+
+// Remaining returns the quantity still open on the order.
+func (o *Order) Remaining() int64 {
+	return o.Quantity - o.Filled
+}
+
+// CanRest reports whether o's type rests on the book with what it could
+// not match.
+func (o *Order) CanRest() bool {
+	return o.Type != MARKET
+}
+
+// Trade is one execution between an incoming (taker) order and a resting
+// (maker) order. Price is always the maker's price.
+type Trade struct {
+	ID            string `json:"trade_id"`
+	Symbol        string `json:"symbol"`
+	Price         int64  `json:"price"` // integer cents
+	Quantity      int64  `json:"quantity"`
+	AggressorSide Side   `json:"aggressor_side"`
+	MakerOrderID  string `json:"maker_order_id,omitempty"`
+	TakerOrderID  string `json:"taker_order_id,omitempty"`
+	Timestamp     int64  `json:"timestamp"` // unix ms
+
+	// Fees in price units (cents); negative is a rebate. Only the
+	// participants' reports carry them, never the public tape.
+	MakerFee int64 `json:"maker_fee,omitempty"`
+	TakerFee int64 `json:"taker_fee,omitempty"`
 }
 
 // Validate checks basic syntactic correctness of the order.
@@ -36,17 +121,133 @@ func (o *Order) Validate() error {
 	if o.Side != BUY && o.Side != SELL {
 		return errors.New("invalid side: must be BUY or SELL")
 	}
-	if o.Type != LIMIT && o.Type != MARKET {
-		return errors.New("invalid type: must be LIMIT or MARKET")
+	if o.Type != LIMIT && o.Type != MARKET && o.Type != PEGGED && o.Type != TRAILING_STOP {
+		return errors.New("invalid type: must be LIMIT, MARKET, PEGGED or TRAILING_STOP")
 	}
 	if o.Quantity <= 0 {
 		return errors.New("quantity must be > 0")
 	}
+	if err := validClientOrderID(o.ClientOrderID); err != nil {
+		return err
+	}
 	if o.Type == LIMIT {
 		if o.Price <= 0 {
 			return errors.New("limit orders must have price > 0 (in cents)")
 		}
 	}
+	if o.Hidden && o.Type != LIMIT {
+		return errors.New("only limit orders can be hidden")
+	}
+	if err := o.validateConditions(); err != nil {
+		return err
+	}
+	if o.Type == PEGGED {
+		return o.validatePeg()
+	}
+	if o.Type == TRAILING_STOP {
+		return o.validateTrailingStop()
+	}
 	// For MARKET orders we do not require/validate price here (it will be ignored by matching logic)
 	return nil
 }
+
+// ValidateSpread checks an order for a spread instrument instead of
+// Validate. A spread's price combines its legs' prices and may be zero or
+// negative; its book takes plain limit orders only.
+func (o *Order) ValidateSpread() error {
+	if o == nil {
+		return errors.New("order is nil")
+	}
+	if o.Type != LIMIT || o.Hidden || o.MinQty != 0 || o.MinQtyScope != "" || o.AllOrNone {
+		return errors.New("spread orders must be plain limit orders")
+	}
+	cp := *o
+	cp.Price = 1 // any price will do
+	return cp.Validate()
+}
+
+// maxClientOrderID is the longest ClientOrderID accepted.
+const maxClientOrderID = 64
+
+// validClientOrderID accepts an empty ID, or up to maxClientOrderID letters,
+// digits and "-_.:", which are safe in a URL path.
+func validClientOrderID(id string) error {
+	if len(id) > maxClientOrderID {
+		return errors.New("client_order_id must be at most 64 characters")
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune("-_.:", c):
+		default:
+			return errors.New("client_order_id may hold only letters, digits and -_.:")
+		}
+	}
+	return nil
+}
+
+func (o *Order) validatePeg() error {
+	switch o.PegType {
+	case PegPrimary, PegMarket, PegMidpoint:
+	default:
+		return errors.New("invalid peg_type: must be PRIMARY, MARKET or MIDPOINT")
+	}
+	if o.Price != 0 {
+		return errors.New("pegged orders take no price; use peg_limit to cap it")
+	}
+	if o.PegOffset < 0 || o.PegLimit < 0 {
+		return errors.New("peg_offset and peg_limit must be >= 0")
+	}
+	if o.PegType == PegMidpoint && o.PegOffset != 0 {
+		return errors.New("midpoint pegs take no offset")
+	}
+	return nil
+}
+
+func (o *Order) validateTrailingStop() error {
+	if (o.TrailAmount > 0) == (o.TrailPercent > 0) {
+		return errors.New("trailing stops need exactly one of trail_amount or trail_percent > 0")
+	}
+	if o.TrailAmount < 0 || o.TrailPercent < 0 || o.TrailPercent >= 100 {
+		return errors.New("trail_amount must be > 0 and trail_percent between 0 and 100")
+	}
+	if o.Price != 0 || o.StopPrice != 0 || o.Triggered {
+		return errors.New("trailing stops take no price; the engine sets stop_price")
+	}
+	switch o.TriggerType {
+	case "", MARKET:
+		if o.LimitOffset != 0 {
+			return errors.New("limit_offset needs trigger_type LIMIT")
+		}
+	case LIMIT:
+		if o.LimitOffset < 0 {
+			return errors.New("limit_offset must be >= 0")
+		}
+	default:
+		return errors.New("invalid trigger_type: must be MARKET or LIMIT")
+	}
+	return nil
+}
+
+func (o *Order) validateConditions() error {
+	if o.MinQty == 0 && o.MinQtyScope == "" && !o.AllOrNone {
+		return nil
+	}
+	if o.Type != LIMIT {
+		return errors.New("min_qty and all_or_none apply to limit orders only")
+	}
+	if o.AllOrNone && (o.MinQty != 0 || o.MinQtyScope != "") {
+		return errors.New("all_or_none takes no min_qty")
+	}
+	if o.MinQty < 0 || o.MinQty > o.Quantity {
+		return errors.New("min_qty must be between 0 and quantity")
+	}
+	switch o.MinQtyScope {
+	case "", MinQtyEach, MinQtyFirst:
+	default:
+		return errors.New("invalid min_qty_scope: must be EACH or FIRST")
+	}
+	if o.MinQtyScope != "" && o.MinQty == 0 {
+		return errors.New("min_qty_scope needs a min_qty")
+	}
+	return nil
+}
@@ -43,6 +43,91 @@ func TestOrderValidate(t *testing.T) {
 			&Order{Symbol: "A", Side: SELL, Type: LIMIT, Price: 0, Quantity: 2},
 			false,
 		},
+		{
+			"valid primary peg",
+			&Order{Symbol: "A", Side: BUY, Type: PEGGED, PegType: PegPrimary, PegOffset: 1, PegLimit: 105, Quantity: 2},
+			true,
+		},
+		{
+			"peg without peg type",
+			&Order{Symbol: "A", Side: BUY, Type: PEGGED, Quantity: 2},
+			false,
+		},
+		{
+			"peg with a price",
+			&Order{Symbol: "A", Side: BUY, Type: PEGGED, PegType: PegMarket, Price: 100, Quantity: 2},
+			false,
+		},
+		{
+			"midpoint peg with offset",
+			&Order{Symbol: "A", Side: SELL, Type: PEGGED, PegType: PegMidpoint, PegOffset: 1, Quantity: 2},
+			false,
+		},
+		{
+			"client order id",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 2, ClientOrderID: "mm-42:bid.3"},
+			true,
+		},
+		{
+			"client order id with a slash",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 2, ClientOrderID: "a/b"},
+			false,
+		},
+		{
+			"hidden limit",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 2, Hidden: true},
+			true,
+		},
+		{
+			"hidden market",
+			&Order{Symbol: "A", Side: BUY, Type: MARKET, Quantity: 2, Hidden: true},
+			false,
+		},
+		{
+			"min qty on first fill",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 10, MinQty: 5, MinQtyScope: MinQtyFirst},
+			true,
+		},
+		{
+			"min qty above quantity",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 10, MinQty: 11},
+			false,
+		},
+		{
+			"all or none with min qty",
+			&Order{Symbol: "A", Side: BUY, Type: LIMIT, Price: 100, Quantity: 10, MinQty: 5, AllOrNone: true},
+			false,
+		},
+		{
+			"all or none market",
+			&Order{Symbol: "A", Side: BUY, Type: MARKET, Quantity: 10, AllOrNone: true},
+			false,
+		},
+		{
+			"valid trailing stop",
+			&Order{Symbol: "A", Side: SELL, Type: TRAILING_STOP, TrailPercent: 2.5, Quantity: 2},
+			true,
+		},
+		{
+			"trailing stop limit",
+			&Order{Symbol: "A", Side: BUY, Type: TRAILING_STOP, TrailAmount: 50, TriggerType: LIMIT, LimitOffset: 5, Quantity: 2},
+			true,
+		},
+		{
+			"trailing stop with both trails",
+			&Order{Symbol: "A", Side: SELL, Type: TRAILING_STOP, TrailAmount: 50, TrailPercent: 1, Quantity: 2},
+			false,
+		},
+		{
+			"trailing stop without a trail",
+			&Order{Symbol: "A", Side: SELL, Type: TRAILING_STOP, Quantity: 2},
+			false,
+		},
+		{
+			"market trailing stop with a limit offset",
+			&Order{Symbol: "A", Side: SELL, Type: TRAILING_STOP, TrailAmount: 50, LimitOffset: 5, Quantity: 2},
+			false,
+		},
 	}
 
 	for _, c := range cases {
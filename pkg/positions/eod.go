@@ -0,0 +1,48 @@
+package positions
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ParseEOD parses an end-of-day time of "HH:MM" (UTC) into an offset from
+// midnight.
+func ParseEOD(s string) (time.Duration, error) {
+	at, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("end of day %q: want HH:MM", s)
+	}
+	return time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute, nil
+}
+
+// nextEOD returns the first time after now that is at past midnight UTC.
+func nextEOD(now time.Time, at time.Duration) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(at)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// RunEOD marks every position to market once a day at offset at past
+// midnight UTC, handing each run's settlements to report, until Close.
+func (t *Tracker) RunEOD(at time.Duration, report func([]Settlement)) {
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextEOD(time.Now(), at)))
+			select {
+			case now := <-timer.C:
+				s := t.MarkToMarket(now)
+				log.Printf("positions: end of day mark-to-market settled %d positions\n", len(s))
+				if report != nil {
+					report(s)
+				}
+			case <-t.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
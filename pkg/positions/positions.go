@@ -0,0 +1,280 @@
+// Package positions tracks each account's net position and P&L per symbol
+// for a futures-style venue, where a trade opens or closes exposure rather
+// than exchanging assets.
+//
+// A position is signed: positive is long, negative short. Its cost basis
+// is the entry value of the open quantity (price units * quantity), so the
+// average entry price is cost / |net|. A fill on the same side adds to the
+// cost; a fill on the other side closes quantity at its average price and
+// realizes the difference, and whatever it does not close opens a new
+// position at the fill price. Unrealized P&L is the open quantity valued at
+// the symbol's last trade price, less its cost.
+//
+// The Tracker follows the bus like the other consumers: OrderFilled events
+// move positions, TradeExecuted events move the last price. Reads and
+// pushed updates trail the engine by a moment, never lead it.
+package positions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Position is one account's exposure in one symbol. Money is in price
+// units (cents) times quantity.
+type Position struct {
+	Account    string  `json:"account"`
+	Symbol     string  `json:"symbol"`
+	Net        int64   `json:"net"` // > 0 long, < 0 short
+	AvgPrice   float64 `json:"avg_price"`
+	Realized   int64   `json:"realized_pnl"`
+	Unrealized int64   `json:"unrealized_pnl"`
+	LastPrice  int64   `json:"last_price"`
+	MarkPrice  int64   `json:"mark_price,omitempty"` // price of the last mark-to-market
+	MarkedAt   int64   `json:"marked_at,omitempty"`  // unix ms
+	UpdatedAt  int64   `json:"updated_at"`           // unix ms
+}
+
+// position is the tracker's state behind a Position.
+type position struct {
+	net      int64
+	cost     int64 // entry value of |net|
+	realized int64
+	mark     int64
+	markedAt int64
+	updated  int64
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int64) int64 {
+	if n < 0 {
+		return -1
+	}
+	return 1
+}
+
+// fill applies qty at price, signed by side, and returns the P&L it
+// realized.
+func (p *position) fill(side model.Side, price, qty int64) int64 {
+	delta := qty
+	if side == model.SELL {
+		delta = -qty
+	}
+	if p.net == 0 || sign(p.net) == sign(delta) {
+		p.net += delta
+		p.cost += price * qty
+		return 0
+	}
+
+	closed := qty
+	if closed > abs(p.net) {
+		closed = abs(p.net)
+	}
+	closedCost := p.cost
+	if closed < abs(p.net) {
+		closedCost = p.cost * closed / abs(p.net)
+	}
+	pnl := (price*closed - closedCost) * sign(p.net)
+	p.realized += pnl
+	p.cost -= closedCost
+	p.net += delta
+	if opened := qty - closed; opened > 0 {
+		p.cost = price * opened // flipped: the rest opens at the fill price
+	}
+	return pnl
+}
+
+func (p *position) unrealized(last int64) int64 {
+	if p.net == 0 || last == 0 {
+		return 0
+	}
+	return (last*abs(p.net) - p.cost) * sign(p.net)
+}
+
+func (p *position) view(account, symbol string, last int64) Position {
+	v := Position{
+		Account: account, Symbol: symbol, Net: p.net,
+		Realized: p.realized, Unrealized: p.unrealized(last), LastPrice: last,
+		MarkPrice: p.mark, MarkedAt: p.markedAt, UpdatedAt: p.updated,
+	}
+	if p.net != 0 {
+		v.AvgPrice = float64(p.cost) / float64(abs(p.net))
+	}
+	return v
+}
+
+// Tracker keeps every account's positions from the bus.
+type Tracker struct {
+	sub *events.Subscription
+
+	mu        sync.Mutex
+	positions map[string]map[string]*position // account -> symbol
+	holders   map[string]map[string]struct{}  // symbol -> accounts with a position
+	last      map[string]int64                // symbol -> last trade price
+	watchers  map[string]map[*Watcher]struct{}
+	done      chan struct{}
+	stop      chan struct{} // ends the EOD job, if any
+	stopOnce  sync.Once
+}
+
+// NewTracker subscribes to bus and starts applying fills. It must
+// subscribe before orders start flowing.
+func NewTracker(bus *events.Bus) *Tracker {
+	t := &Tracker{
+		positions: make(map[string]map[string]*position),
+		holders:   make(map[string]map[string]struct{}),
+		last:      make(map[string]int64),
+		watchers:  make(map[string]map[*Watcher]struct{}),
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+	t.sub = bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.TradeExecuted:
+			return true
+		case events.OrderFilled:
+			return ev.Order != nil && ev.Order.Account != ""
+		}
+		return false
+	})
+	go t.run()
+	return t
+}
+
+// Close stops tracking and ends the EOD job.
+func (t *Tracker) Close() {
+	t.stopOnce.Do(func() { close(t.stop) })
+	t.sub.Close()
+	<-t.done
+}
+
+// Positions returns account's positions sorted by symbol, including flat
+// ones that still carry realized P&L.
+func (t *Tracker) Positions(account string) []Position {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Position, 0, len(t.positions[account]))
+	for sym, p := range t.positions[account] {
+		out = append(out, p.view(account, sym, t.last[sym]))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+// Position returns account's position in symbol.
+func (t *Tracker) Position(account, symbol string) (Position, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.positions[account][symbol]
+	if !ok {
+		return Position{}, false
+	}
+	return p.view(account, symbol, t.last[symbol]), true
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+	for ev := range t.sub.C() {
+		t.apply(&ev)
+	}
+}
+
+func (t *Tracker) apply(ev *events.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch ev.Type {
+	case events.TradeExecuted:
+		if ev.Trade == nil {
+			return
+		}
+		t.last[ev.Symbol] = ev.Trade.Price
+		// every open position in the symbol has a new unrealized P&L
+		for account := range t.holders[ev.Symbol] {
+			if p := t.positions[account][ev.Symbol]; p.net != 0 {
+				t.notifyLocked(account, ev.Symbol, p)
+			}
+		}
+	case events.OrderFilled:
+		account := ev.Order.Account
+		p := t.positionLocked(account, ev.Symbol)
+		p.fill(ev.Order.Side, ev.FillPrice, ev.FillQty)
+		p.updated = ev.Timestamp
+		if p.net == 0 {
+			delete(t.holders[ev.Symbol], account)
+		} else {
+			t.holders[ev.Symbol][account] = struct{}{}
+		}
+		t.notifyLocked(account, ev.Symbol, p)
+	}
+}
+
+func (t *Tracker) positionLocked(account, symbol string) *position {
+	ps := t.positions[account]
+	if ps == nil {
+		ps = make(map[string]*position)
+		t.positions[account] = ps
+	}
+	p := ps[symbol]
+	if p == nil {
+		p = &position{}
+		ps[symbol] = p
+	}
+	if t.holders[symbol] == nil {
+		t.holders[symbol] = make(map[string]struct{})
+	}
+	return p
+}
+
+// Settlement is one position's variation margin from a mark-to-market.
+type Settlement struct {
+	Account   string `json:"account"`
+	Symbol    string `json:"symbol"`
+	Net       int64  `json:"net"`
+	MarkPrice int64  `json:"mark_price"`
+	PnL       int64  `json:"pnl"` // unrealized P&L moved into realized
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MarkToMarket settles every open position at its symbol's last trade
+// price: the unrealized P&L is realized and the position is carried on at
+// the mark, so the next day's P&L starts from it. Positions in symbols
+// that have not traded yet are left alone. It returns the settlements in
+// account, symbol order.
+func (t *Tracker) MarkToMarket(now time.Time) []Settlement {
+	ts := now.UnixMilli()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Settlement
+	for sym, accounts := range t.holders {
+		mark := t.last[sym]
+		if mark == 0 {
+			continue
+		}
+		for account := range accounts {
+			p := t.positions[account][sym]
+			pnl := p.unrealized(mark)
+			p.realized += pnl
+			p.cost = mark * abs(p.net)
+			p.mark, p.markedAt, p.updated = mark, ts, ts
+			out = append(out, Settlement{Account: account, Symbol: sym, Net: p.net, MarkPrice: mark, PnL: pnl, Timestamp: ts})
+			t.notifyLocked(account, sym, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Account != out[j].Account {
+			return out[i].Account < out[j].Account
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
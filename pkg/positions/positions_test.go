@@ -0,0 +1,199 @@
+package positions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func setup(t *testing.T) (*engine.Router, *Tracker) {
+	t.Helper()
+	b := events.NewBus()
+	tr := NewTracker(b)
+	r := engine.NewRouter(2, 16, engine.WithEventBus(b))
+	t.Cleanup(func() {
+		r.Stop()
+		tr.Close()
+	})
+	return r, tr
+}
+
+func limit(id, account, symbol string, side model.Side, price, qty int64) *model.Order {
+	return &model.Order{ID: id, Account: account, Symbol: symbol, Side: side, Type: model.LIMIT, Price: price, Quantity: qty}
+}
+
+// waitPosition waits for the bus to bring account's symbol position to
+// net with realized P&L realized.
+func waitPosition(t *testing.T, tr *Tracker, account, symbol string, net, realized int64) Position {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p, _ := tr.Position(account, symbol)
+		if p.Net == net && p.Realized == realized {
+			return p
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s %s: expected net %d realized %d, got %+v", account, symbol, net, realized, p)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFillArithmetic(t *testing.T) {
+	var p position
+	p.fill(model.BUY, 100, 10)
+	p.fill(model.BUY, 130, 5) // 15 long at 110
+	if v := p.view("a", "X", 120); v.AvgPrice != 110 || v.Unrealized != 150 {
+		t.Fatalf("expected avg 110 and +150 unrealized, got %+v", v)
+	}
+	if pnl := p.fill(model.SELL, 120, 5); pnl != 50 || p.net != 10 || p.cost != 1_100 {
+		t.Fatalf("closing 5 at 120 should realize 50, got %d net %d cost %d", pnl, p.net, p.cost)
+	}
+	// sells 10 to close and 4 more to go short at 90
+	if pnl := p.fill(model.SELL, 90, 14); pnl != -200 || p.net != -4 || p.cost != 360 {
+		t.Fatalf("flip: expected -200 realized, short 4 at 90, got %d net %d cost %d", pnl, p.net, p.cost)
+	}
+	if v := p.view("a", "X", 80); v.Unrealized != 40 || v.Realized != -150 {
+		t.Fatalf("short 4 from 90 at 80 is +40, got %+v", v)
+	}
+	if pnl := p.fill(model.BUY, 95, 4); pnl != -20 || p.net != 0 || p.cost != 0 {
+		t.Fatalf("expected flat after -20, got %d net %d cost %d", pnl, p.net, p.cost)
+	}
+}
+
+func TestTracksFillsAndLastPrice(t *testing.T) {
+	r, tr := setup(t)
+
+	r.SubmitOrder(limit("s1", "seller", "ES", model.SELL, 100, 10))
+	r.SubmitOrder(limit("b1", "buyer", "ES", model.BUY, 100, 6))
+	waitPosition(t, tr, "buyer", "ES", 6, 0)
+	waitPosition(t, tr, "seller", "ES", -6, 0)
+
+	// a trade between others moves the last price and both P&Ls
+	r.SubmitOrder(limit("s2", "other2", "ES", model.SELL, 90, 1))
+	r.SubmitOrder(limit("b3", "other", "ES", model.BUY, 90, 1))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p, _ := tr.Position("buyer", "ES")
+		if p.LastPrice == 90 && p.Unrealized == -60 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected buyer marked at 90 for -60, got %+v", p)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if p, _ := tr.Position("seller", "ES"); p.Unrealized != 60 || p.AvgPrice != 100 {
+		t.Fatalf("expected seller +60 short from 100, got %+v", p)
+	}
+
+	// the buyer sells out at 90 and keeps the loss as realized
+	r.SubmitOrder(limit("b4", "other", "ES", model.BUY, 90, 6))
+	r.SubmitOrder(limit("s3", "buyer", "ES", model.SELL, 90, 6))
+	p := waitPosition(t, tr, "buyer", "ES", 0, -60)
+	if p.Unrealized != 0 || p.AvgPrice != 0 {
+		t.Fatalf("flat position carries no unrealized P&L: %+v", p)
+	}
+}
+
+func TestMarkToMarket(t *testing.T) {
+	r, tr := setup(t)
+	r.SubmitOrder(limit("s", "short", "ES", model.SELL, 100, 3))
+	r.SubmitOrder(limit("b", "long", "ES", model.BUY, 100, 3))
+	r.SubmitOrder(limit("s2", "x", "ES", model.SELL, 110, 1))
+	r.SubmitOrder(limit("b2", "y", "ES", model.BUY, 110, 1))
+	r.SubmitOrder(limit("untraded", "long", "NQ", model.BUY, 50, 1))
+	waitPosition(t, tr, "y", "ES", 1, 0)
+
+	now := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	got := tr.MarkToMarket(now)
+	want := []Settlement{
+		{Account: "long", Symbol: "ES", Net: 3, MarkPrice: 110, PnL: 30},
+		{Account: "short", Symbol: "ES", Net: -3, MarkPrice: 110, PnL: -30},
+		{Account: "x", Symbol: "ES", Net: -1, MarkPrice: 110},
+		{Account: "y", Symbol: "ES", Net: 1, MarkPrice: 110},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d settlements, got %+v", len(want), got)
+	}
+	for i := range want {
+		want[i].Timestamp = now.UnixMilli()
+		if got[i] != want[i] {
+			t.Fatalf("settlement %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	p, _ := tr.Position("long", "ES")
+	if p.Realized != 30 || p.Unrealized != 0 || p.AvgPrice != 110 || p.MarkPrice != 110 {
+		t.Fatalf("expected the long carried at 110 with 30 realized, got %+v", p)
+	}
+}
+
+func TestNextEOD(t *testing.T) {
+	at, err := ParseEOD("21:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	if got := nextEOD(before, at); !got.Equal(time.Date(2024, 5, 1, 21, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected today, got %v", got)
+	}
+	on := time.Date(2024, 5, 1, 21, 30, 0, 0, time.UTC)
+	if got := nextEOD(on, at); !got.Equal(time.Date(2024, 5, 2, 21, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected tomorrow, got %v", got)
+	}
+	if _, err := ParseEOD("9pm"); err == nil {
+		t.Fatal("expected a bad time to be refused")
+	}
+}
+
+func TestStreamPushesOwnPositions(t *testing.T) {
+	r, tr := setup(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		account := req.URL.Query().Get("as") // stands in for auth.Authenticate
+		tr.ServeHTTP(w, req.WithContext(auth.WithKey(req.Context(), auth.Key{Account: account})))
+	}))
+	defer srv.Close()
+
+	r.SubmitOrder(limit("s", "a", "ES", model.SELL, 100, 5))
+	r.SubmitOrder(limit("b", "b", "ES", model.BUY, 100, 2))
+	waitPosition(t, tr, "a", "ES", -2, 0)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?as=a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var m Message
+	if err := ws.ReadJSON(&m); err != nil || m.Type != "snapshot" || len(m.Positions) != 1 || m.Positions[0].Net != -2 {
+		t.Fatalf("expected a snapshot short 2, got %+v (%v)", m, err)
+	}
+
+	// another account's trade is not pushed; a's own is
+	r.SubmitOrder(limit("s2", "c", "NQ", model.SELL, 10, 1))
+	r.SubmitOrder(limit("b2", "d", "NQ", model.BUY, 10, 1))
+	r.SubmitOrder(limit("b3", "b", "ES", model.BUY, 100, 3))
+	for {
+		if err := ws.ReadJSON(&m); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.Positions {
+			if p.Account != "a" || p.Symbol != "ES" {
+				t.Fatalf("pushed someone else's position: %+v", p)
+			}
+		}
+		if n := len(m.Positions); m.Type == "update" && n > 0 && m.Positions[n-1].Net == -5 {
+			return
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package positions
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/auth"
+)
+
+const (
+	writeWait  = 5 * time.Second
+	pingPeriod = 30 * time.Second
+	pongWait   = 2 * pingPeriod
+)
+
+// Watcher receives one account's position changes. Changes that arrive
+// while the previous batch is still being written are merged per symbol,
+// so a slow reader sees fewer, fresher updates instead of a backlog.
+type Watcher struct {
+	t       *Tracker
+	account string
+
+	mu      sync.Mutex
+	pending map[string]Position
+	notify  chan struct{} // cap 1: "something to read"
+}
+
+// Watch starts collecting account's position changes. Call Stop when done.
+func (t *Tracker) Watch(account string) *Watcher {
+	w := &Watcher{t: t, account: account, pending: make(map[string]Position), notify: make(chan struct{}, 1)}
+	t.mu.Lock()
+	if t.watchers[account] == nil {
+		t.watchers[account] = make(map[*Watcher]struct{})
+	}
+	t.watchers[account][w] = struct{}{}
+	t.mu.Unlock()
+	return w
+}
+
+// Stop unregisters w.
+func (w *Watcher) Stop() {
+	w.t.mu.Lock()
+	delete(w.t.watchers[w.account], w)
+	if len(w.t.watchers[w.account]) == 0 {
+		delete(w.t.watchers, w.account)
+	}
+	w.t.mu.Unlock()
+}
+
+// C is signalled when Drain has something to return.
+func (w *Watcher) C() <-chan struct{} { return w.notify }
+
+// Drain returns the changed positions since the last call, by symbol.
+func (w *Watcher) Drain() []Position {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]Position, 0, len(w.pending))
+	for _, p := range w.pending {
+		out = append(out, p)
+	}
+	w.pending = make(map[string]Position)
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+func (w *Watcher) queue(p Position) {
+	w.mu.Lock()
+	w.pending[p.Symbol] = p
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// notifyLocked queues p's new state to account's watchers.
+func (t *Tracker) notifyLocked(account, symbol string, p *position) {
+	ws := t.watchers[account]
+	if len(ws) == 0 {
+		return
+	}
+	v := p.view(account, symbol, t.last[symbol])
+	for w := range ws {
+		w.queue(v)
+	}
+}
+
+// Message is what the position stream sends: a snapshot of every position
+// on connect, then updates carrying only the positions that changed.
+type Message struct {
+	Type      string     `json:"type"` // "snapshot" or "update"
+	Account   string     `json:"account"`
+	Positions []Position `json:"positions"`
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ServeHTTP streams the authenticated account's positions. Serve behind
+// auth.KeyStore.Authenticate.
+// GET /ws/v1/positions
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	account := auth.Account(r.Context())
+	if account == "" {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied
+	}
+	defer ws.Close()
+
+	// watch before the snapshot so no change falls between them; one that
+	// lands in both is sent again, which is harmless
+	watch := t.Watch(account)
+	defer watch.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		// the stream is one-way; reading handles pongs and notices the close
+		defer close(done)
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+					log.Println("positions: read:", err)
+				}
+				return
+			}
+		}
+	}()
+
+	write := func(m Message) bool {
+		ws.SetWriteDeadline(time.Now().Add(writeWait))
+		return ws.WriteJSON(m) == nil
+	}
+	if !write(Message{Type: "snapshot", Account: account, Positions: t.Positions(account)}) {
+		return
+	}
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-watch.C():
+			if ps := watch.Drain(); len(ps) > 0 && !write(Message{Type: "update", Account: account, Positions: ps}) {
+				return
+			}
+		case <-ticker.C:
+			ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Package ratelimit throttles clients with token buckets, one per account
+// and one per IP address for each class of request, so a single runaway
+// client cannot fill the shard channels. HTTP requests go through
+// Middleware or Guard; the FIX, gRPC and binary gateways call Allow
+// themselves with the logged on account and the connection's address.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class is a kind of request with its own budget.
+type Class int
+
+const (
+	Order  Class = iota // new orders and amends
+	Cancel              // cancels
+	Query               // reads
+	numClasses
+)
+
+var classNames = [numClasses]string{"order", "cancel", "query"}
+
+func (c Class) String() string { return classNames[c] }
+
+// ClassOf classifies a request by method: POST and PATCH place or change
+// orders, DELETE cancels, everything else is a query.
+func ClassOf(r *http.Request) Class {
+	switch r.Method {
+	case http.MethodPost, http.MethodPatch:
+		return Order
+	case http.MethodDelete:
+		return Cancel
+	}
+	return Query
+}
+
+// Budget is a sustained rate and the burst allowed on top of it. A zero
+// Rate means unlimited.
+type Budget struct {
+	Rate  float64 // tokens per second
+	Burst float64
+}
+
+// Budgets holds one budget per class.
+type Budgets [numClasses]Budget
+
+// ParseBudgets parses "order=50,cancel=100:200,query=200" where each value
+// is rate[:burst] per second; the burst defaults to the rate. Classes not
+// listed, and the empty string, are unlimited.
+func ParseBudgets(s string) (Budgets, error) {
+	var b Budgets
+	if strings.TrimSpace(s) == "" {
+		return b, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return b, fmt.Errorf("budget %q: want class=rate[:burst]", part)
+		}
+		c := -1
+		for i, n := range classNames {
+			if n == name {
+				c = i
+			}
+		}
+		if c < 0 {
+			return b, fmt.Errorf("budget %q: unknown class %q", part, name)
+		}
+		rs, bs, hasBurst := strings.Cut(val, ":")
+		rate, err := strconv.ParseFloat(rs, 64)
+		if err != nil || rate < 0 {
+			return b, fmt.Errorf("budget %q: bad rate", part)
+		}
+		burst := rate
+		if hasBurst {
+			if burst, err = strconv.ParseFloat(bs, 64); err != nil || burst < 1 {
+				return b, fmt.Errorf("budget %q: bad burst", part)
+			}
+		}
+		b[c] = Budget{Rate: rate, Burst: math.Max(burst, 1)}
+	}
+	return b, nil
+}
+
+// bucket is a token bucket; callers hold Limiter.mu.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// level returns the tokens available at now.
+func (b *bucket) level(bud Budget, now time.Time) float64 {
+	return math.Min(bud.Burst, b.tokens+now.Sub(b.last).Seconds()*bud.Rate)
+}
+
+type scope int
+
+const (
+	byAccount scope = iota
+	byIP
+)
+
+type bucketKey struct {
+	scope scope
+	class Class
+	id    string
+}
+
+// sweepEvery is how often buckets that have refilled completely, and so
+// carry no state, are dropped.
+const sweepEvery = time.Minute
+
+// Limiter holds the buckets.
+type Limiter struct {
+	account, ip Budgets
+	now         func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	allowed   [numClasses]uint64
+	limited   [numClasses][2]uint64 // by scope
+}
+
+// New creates a limiter with per-account and per-IP budgets.
+func New(account, ip Budgets) *Limiter {
+	for c := range account {
+		account[c].Burst = math.Max(account[c].Burst, 1)
+		ip[c].Burst = math.Max(ip[c].Burst, 1)
+	}
+	return &Limiter{
+		account: account,
+		ip:      ip,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Allow takes a token for class from both the account's and the IP's
+// bucket. account "" (an unauthenticated request) is only limited by IP,
+// and ip "" only by account. When refused it returns how long to wait. A
+// nil Limiter allows everything.
+func (l *Limiter) Allow(c Class, account, ip string) (bool, time.Duration) {
+	return l.AllowN(c, 1, account, ip)
+}
+
+// AllowN is Allow for n tokens at once. More than a bucket's burst is never
+// allowed.
+func (l *Limiter) AllowN(c Class, n int, account, ip string) (bool, time.Duration) {
+	return l.take(c, n, account, ip, true)
+}
+
+// Screen takes a token for class from the IP's bucket alone, before the
+// client is authenticated, so clients with bad credentials are throttled
+// too. Once it is, Allow with its account and no IP finishes the request,
+// which is counted as allowed only then.
+func (l *Limiter) Screen(c Class, ip string) (bool, time.Duration) {
+	return l.take(c, 1, "", ip, false)
+}
+
+// take is AllowN, counting the tokens as allowed only if count is set.
+func (l *Limiter) take(c Class, n int, account, ip string, count bool) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > sweepEvery {
+		l.sweepLocked(now)
+	}
+
+	// check both before taking from either, so a refusal costs nothing
+	var keys [2]bucketKey
+	var buds [2]Budget
+	nb := 0
+	if bud := l.ip[c]; bud.Rate > 0 && ip != "" {
+		keys[nb], buds[nb] = bucketKey{byIP, c, ip}, bud
+		nb++
+	}
+	if bud := l.account[c]; bud.Rate > 0 && account != "" {
+		keys[nb], buds[nb] = bucketKey{byAccount, c, account}, bud
+		nb++
+	}
+	var bs [2]*bucket
+	for i := 0; i < nb; i++ {
+		b := l.bucketLocked(keys[i], buds[i], now)
+		b.tokens, b.last = b.level(buds[i], now), now
+		bs[i] = b
+		if b.tokens < float64(n) {
+			l.limited[c][keys[i].scope]++
+			return false, time.Duration((float64(n) - b.tokens) / buds[i].Rate * float64(time.Second))
+		}
+	}
+	for i := 0; i < nb; i++ {
+		bs[i].tokens -= float64(n)
+	}
+	if count {
+		l.allowed[c] += uint64(n)
+	}
+	return true, 0
+}
+
+func (l *Limiter) bucketLocked(k bucketKey, bud Budget, now time.Time) *bucket {
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: bud.Burst, last: now}
+		l.buckets[k] = b
+	}
+	return b
+}
+
+func (l *Limiter) budget(k bucketKey) Budget {
+	if k.scope == byIP {
+		return l.ip[k.class]
+	}
+	return l.account[k.class]
+}
+
+func (l *Limiter) sweepLocked(now time.Time) {
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		bud := l.budget(k)
+		if b.level(bud, now) >= bud.Burst {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// ClassUsage is the counters of one class.
+type ClassUsage struct {
+	Allowed        uint64 `json:"allowed"`
+	LimitedAccount uint64 `json:"limited_account"`
+	LimitedIP      uint64 `json:"limited_ip"`
+}
+
+// AccountUsage is how much of its budgets an account is using right now,
+// as the fraction of the burst spent (0 idle, 1 throttled).
+type AccountUsage struct {
+	Account string             `json:"account"`
+	Used    map[string]float64 `json:"used"`
+}
+
+// Usage is the limiter state reported on the metrics endpoint.
+type Usage struct {
+	Classes   map[string]ClassUsage `json:"classes"`
+	Accounts  []AccountUsage        `json:"accounts"`   // accounts with a partly spent bucket
+	ActiveIPs int                   `json:"active_ips"` // addresses with a partly spent bucket
+}
+
+// Usage returns the counters and current bucket levels.
+func (l *Limiter) Usage() Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	u := Usage{Classes: make(map[string]ClassUsage, numClasses), Accounts: []AccountUsage{}}
+	for c := Class(0); c < numClasses; c++ {
+		u.Classes[c.String()] = ClassUsage{
+			Allowed:        l.allowed[c],
+			LimitedAccount: l.limited[c][byAccount],
+			LimitedIP:      l.limited[c][byIP],
+		}
+	}
+
+	accounts := make(map[string]map[string]float64)
+	ips := make(map[string]struct{})
+	for k, b := range l.buckets {
+		bud := l.budget(k)
+		level := b.level(bud, now)
+		if level >= bud.Burst {
+			continue
+		}
+		if k.scope == byIP {
+			ips[k.id] = struct{}{}
+			continue
+		}
+		if accounts[k.id] == nil {
+			accounts[k.id] = make(map[string]float64)
+		}
+		accounts[k.id][k.class.String()] = math.Round((1-level/bud.Burst)*1000) / 1000
+	}
+	for a, used := range accounts {
+		u.Accounts = append(u.Accounts, AccountUsage{Account: a, Used: used})
+	}
+	sort.Slice(u.Accounts, func(i, j int) bool { return u.Accounts[i].Account < u.Accounts[j].Account })
+	u.ActiveIPs = len(ips)
+	return u
+}
+
+type chargeKey struct{}
+
+// Middleware limits requests by ClassOf, the account returned by accountOf
+// and the remote address. Refused requests get 429 with Retry-After. A
+// request that stands for several, such as a batch, pays for the rest with
+// Charge.
+func (l *Limiter) Middleware(accountOf func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, account, ip := ClassOf(r), accountOf(r), remoteIP(r)
+		if ok, wait := l.Allow(c, account, ip); !ok {
+			refuse(w, wait)
+			return
+		}
+		charge := func(n int) (bool, time.Duration) { return l.AllowN(c, n, account, ip) }
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargeKey{}, charge)))
+	})
+}
+
+// Guard is Middleware for requests that authenticate: the IP's bucket is
+// charged before authenticate runs, so requests with bad credentials are
+// throttled too, and the account's after it.
+func (l *Limiter) Guard(authenticate func(http.Handler) http.Handler, accountOf func(*http.Request) string, next http.Handler) http.Handler {
+	authed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, account, ip := ClassOf(r), accountOf(r), remoteIP(r)
+		if ok, wait := l.Allow(c, account, ""); !ok {
+			refuse(w, wait)
+			return
+		}
+		charge := func(n int) (bool, time.Duration) { return l.AllowN(c, n, account, ip) }
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargeKey{}, charge)))
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Screen(ClassOf(r), remoteIP(r)); !ok {
+			refuse(w, wait)
+			return
+		}
+		authenticate(authed).ServeHTTP(w, r)
+	})
+}
+
+// Charge takes n more tokens for a request that passed Middleware. When
+// they are not there it answers 429 as Middleware does and returns false.
+// Requests that did not go through Middleware are not charged.
+func Charge(w http.ResponseWriter, r *http.Request, n int) bool {
+	charge, ok := r.Context().Value(chargeKey{}).(func(int) (bool, time.Duration))
+	if !ok || n <= 0 {
+		return true
+	}
+	if ok, wait := charge(n); !ok {
+		refuse(w, wait)
+		return false
+	}
+	return true
+}
+
+func refuse(w http.ResponseWriter, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprintf(w, "{\"error\":\"rate limit exceeded\",\"retry_after_ms\":%d}\n", wait.Milliseconds())
+}
+
+func remoteIP(r *http.Request) string {
+	return host(r.RemoteAddr)
+}
+
+// AddrIP returns the IP of a connection's remote address, for Allow.
+func AddrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return host(addr.String())
+}
+
+func host(addr string) string {
+	h, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return h
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, account, ip string) (*Limiter, *clock) {
+	t.Helper()
+	a, err := ParseBudgets(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := ParseBudgets(ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(a, i)
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	l.now = c.now
+	return l, c
+}
+
+func TestBucketRefill(t *testing.T) {
+	l, c := newTestLimiter(t, "order=2:3", "")
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow(Order, "a", "1.1.1.1"); !ok {
+			t.Fatalf("request %d within burst refused", i)
+		}
+	}
+	ok, wait := l.Allow(Order, "a", "1.1.1.1")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected refusal with 500ms wait, got %v %v", ok, wait)
+	}
+	if ok, _ := l.Allow(Order, "b", "1.1.1.1"); !ok {
+		t.Fatal("another account has its own bucket")
+	}
+	if ok, _ := l.Allow(Cancel, "a", "1.1.1.1"); !ok {
+		t.Fatal("cancels have their own budget")
+	}
+
+	c.advance(500 * time.Millisecond)
+	if ok, _ := l.Allow(Order, "a", "1.1.1.1"); !ok {
+		t.Fatal("expected a token after refill")
+	}
+	if ok, _ := l.Allow(Order, "a", "1.1.1.1"); ok {
+		t.Fatal("expected only one token after 500ms")
+	}
+}
+
+func TestIPAndAccountBothApply(t *testing.T) {
+	l, _ := newTestLimiter(t, "order=10", "order=1:2")
+
+	l.Allow(Order, "a", "10.0.0.1")
+	l.Allow(Order, "b", "10.0.0.1")
+	if ok, _ := l.Allow(Order, "c", "10.0.0.1"); ok {
+		t.Fatal("expected the IP budget to cap all accounts behind it")
+	}
+	if ok, _ := l.Allow(Order, "c", "10.0.0.2"); !ok {
+		t.Fatal("refusal by IP must not have spent the account's token")
+	}
+
+	u := l.Usage()
+	if o := u.Classes["order"]; o.Allowed != 3 || o.LimitedIP != 1 || o.LimitedAccount != 0 {
+		t.Fatalf("unexpected counters %+v", o)
+	}
+	if u.ActiveIPs != 2 || len(u.Accounts) != 3 || u.Accounts[0].Used["order"] != 0.1 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+}
+
+func TestSweepDropsIdleBuckets(t *testing.T) {
+	l, c := newTestLimiter(t, "query=10", "")
+	l.Allow(Query, "a", "")
+	c.advance(2 * sweepEvery)
+	l.Allow(Query, "b", "")
+	if len(l.buckets) != 1 {
+		t.Fatalf("expected the refilled bucket to be dropped, have %d", len(l.buckets))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(t, "", "cancel=1")
+	h := l.Middleware(func(*http.Request) string { return "" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/api/v1/orders/x", nil))
+		return w
+	}
+	if w := do("DELETE"); w.Code != http.StatusOK {
+		t.Fatalf("expected first cancel through, got %d", w.Code)
+	}
+	w := do("DELETE")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After 1, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("GET"); w.Code != http.StatusOK {
+		t.Fatalf("queries are unlimited here, got %d", w.Code)
+	}
+}
+
+func TestGuardChargesTheIPBeforeAuthenticating(t *testing.T) {
+	l, _ := newTestLimiter(t, "query=1", "query=2")
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "good" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := l.Guard(authenticate, func(*http.Request) string { return "a" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(key string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/orders/x", nil)
+		r.Header.Set("X-API-Key", key)
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if do("guess") != http.StatusUnauthorized || do("good") != http.StatusOK {
+		t.Fatal("expected the guess refused and the good key through")
+	}
+	if code := do("guess"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the address out of tokens after two requests, got %d", code)
+	}
+	if u := l.Usage().Classes["query"]; u.Allowed != 1 || u.LimitedIP != 1 {
+		t.Fatalf("expected one request counted allowed and one limited by IP, got %+v", u)
+	}
+}
+
+func TestChargeTakesTheRestOfABatch(t *testing.T) {
+	l, _ := newTestLimiter(t, "order=1:10", "")
+	items := 4
+	h := l.Middleware(func(*http.Request) string { return "a" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Charge(w, r, items-1) {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	do := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/orders/batch", nil))
+		return w.Code
+	}
+
+	// 10 tokens pay for two batches of 4; the third has only 2 left
+	if do() != http.StatusCreated || do() != http.StatusCreated {
+		t.Fatal("expected two batches of 4 within a burst of 10")
+	}
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the third batch refused, got %d", code)
+	}
+	if u := l.Usage().Classes["order"]; u.Allowed != 9 {
+		t.Fatalf("expected 9 tokens taken, got %+v", u)
+	}
+
+	items = 1
+	if do() != http.StatusCreated {
+		t.Fatal("expected a batch of 1 to cost only the request's token")
+	}
+}
+
+func TestParseBudgets(t *testing.T) {
+	b, err := ParseBudgets("order=50, cancel=100:200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[Order] != (Budget{50, 50}) || b[Cancel] != (Budget{100, 200}) || b[Query].Rate != 0 {
+		t.Fatalf("unexpected budgets %+v", b)
+	}
+	for _, bad := range []string{"order", "fills=1", "order=x", "order=1:0"} {
+		if _, err := ParseBudgets(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+}
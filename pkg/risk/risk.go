@@ -0,0 +1,349 @@
+// Package risk runs pre-trade checks on orders before they reach a shard.
+//
+// Per-order limits (size, notional, price band) look only at the order.
+// Per-account limits (open orders, open notional per symbol) need the
+// account's resting exposure across every shard, which the Checker keeps
+// itself: an accepted limit order is reserved at check time, so two orders
+// racing through different shards cannot both slip under a limit, and the
+// reservation shrinks as fill, amend and cancel events arrive from the bus.
+// Releases lag the engine slightly, which only makes the checks stricter.
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+// Limit names, as reported in Violation.Limit.
+const (
+	LimitOrderQty       = "max_order_qty"
+	LimitOrderNotional  = "max_order_notional"
+	LimitOpenOrders     = "max_open_orders"
+	LimitOpenNotional   = "max_open_notional"
+	LimitPriceDeviation = "max_price_deviation_bps"
+)
+
+// Limits for one account. Zero disables a limit. Notional is price in cents
+// times quantity, taken as positive: a spread's price can be negative.
+type Limits struct {
+	MaxOrderQty          int64 `json:"max_order_qty,omitempty"`
+	MaxOrderNotional     int64 `json:"max_order_notional,omitempty"`
+	MaxOpenOrders        int64 `json:"max_open_orders,omitempty"`         // resting orders across all symbols
+	MaxOpenNotional      int64 `json:"max_open_notional,omitempty"`       // resting notional in one symbol
+	MaxPriceDeviationBps int64 `json:"max_price_deviation_bps,omitempty"` // limit price vs last trade
+}
+
+// Config is the default limits and per-account overrides. An account with
+// an override uses it instead of the default, not merged with it.
+type Config struct {
+	Default  Limits            `json:"default"`
+	Accounts map[string]Limits `json:"accounts,omitempty"`
+}
+
+// LoadConfig reads a Config from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(b, &cfg)
+	return cfg, err
+}
+
+// Violation is a refused order: which limit, and by how much.
+type Violation struct {
+	Limit   string `json:"limit"`
+	Account string `json:"account,omitempty"`
+	Symbol  string `json:"symbol"`
+	Value   int64  `json:"value"` // what the order would make it
+	Max     int64  `json:"max"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("risk limit %s exceeded: %d > %d", v.Limit, v.Value, v.Max)
+}
+
+// open is a reserved resting order.
+type open struct {
+	account, symbol string
+	price           int64
+	quantity        int64 // total, so filled = quantity - remaining
+	remaining       int64
+	pegged          bool // price is the reserved estimate, not the order's
+	stop            bool // a trailing stop not yet triggered, priced like a peg
+}
+
+type exposure struct {
+	orders   int64
+	notional map[string]int64 // by symbol
+}
+
+// Checker implements engine.PreTrade.
+type Checker struct {
+	sub *events.Subscription
+
+	mu       sync.Mutex
+	cfg      Config
+	orders   map[string]*open // by order ID
+	accounts map[string]*exposure
+	last     map[string]int64 // last trade price by symbol
+	rejected map[string]uint64
+	done     chan struct{}
+}
+
+// NewChecker creates a checker with cfg, following bus for fills, amends,
+// cancels and trade prices. Pass it to engine.WithPreTrade.
+func NewChecker(bus *events.Bus, cfg Config) *Checker {
+	c := &Checker{
+		cfg:      cfg,
+		orders:   make(map[string]*open),
+		accounts: make(map[string]*exposure),
+		last:     make(map[string]int64),
+		rejected: make(map[string]uint64),
+		done:     make(chan struct{}),
+	}
+	c.sub = bus.Subscribe(4096, events.Block, func(ev *events.Event) bool {
+		switch ev.Type {
+		case events.OrderFilled, events.OrderAmended, events.OrderCancelled,
+			events.OrderRejected, events.TradeExecuted:
+			return true
+		}
+		return false
+	})
+	go c.run()
+	return c
+}
+
+// Close stops following the bus.
+func (c *Checker) Close() {
+	c.sub.Close()
+	<-c.done
+}
+
+// SetConfig replaces the limits. Exposure already reserved is kept.
+func (c *Checker) SetConfig(cfg Config) {
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+}
+
+func (c *Checker) limitsLocked(account string) Limits {
+	if l, ok := c.cfg.Accounts[account]; ok {
+		return l
+	}
+	return c.cfg.Default
+}
+
+// CheckOrder vets a new order and, for an order that can rest, reserves its
+// exposure. A pegged order is priced at its cap, or the last trade if it has
+// none, and a trailing stop at the last trade. Per-account limits apply only
+// to orders that carry an account.
+func (c *Checker) CheckOrder(o *model.Order) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lim := c.limitsLocked(o.Account)
+
+	price := o.Price
+	switch {
+	case o.Type == model.MARKET || o.Type == model.TRAILING_STOP:
+		price = c.last[o.Symbol] // best guess; 0 skips the notional check
+	case o.Type == model.PEGGED && o.PegLimit > 0:
+		price = o.PegLimit
+	case o.Type == model.PEGGED:
+		price = c.last[o.Symbol]
+	}
+	if v := c.checkOrderLocked(lim, o.Account, o.Symbol, o.Type, price, o.Quantity); v != nil {
+		return c.refuseLocked(v)
+	}
+	if !o.CanRest() || o.Account == "" {
+		return nil
+	}
+
+	exp := c.accounts[o.Account]
+	if exp == nil {
+		exp = &exposure{notional: make(map[string]int64)}
+	}
+	if lim.MaxOpenOrders > 0 && exp.orders+1 > lim.MaxOpenOrders {
+		return c.refuseLocked(&Violation{Limit: LimitOpenOrders, Account: o.Account, Symbol: o.Symbol, Value: exp.orders + 1, Max: lim.MaxOpenOrders})
+	}
+	if n := exp.notional[o.Symbol] + notional(price, o.Quantity); lim.MaxOpenNotional > 0 && n > lim.MaxOpenNotional {
+		return c.refuseLocked(&Violation{Limit: LimitOpenNotional, Account: o.Account, Symbol: o.Symbol, Value: n, Max: lim.MaxOpenNotional})
+	}
+
+	if o.ID != "" {
+		c.orders[o.ID] = &open{account: o.Account, symbol: o.Symbol, price: price, quantity: o.Quantity, remaining: o.Quantity, pegged: o.Type == model.PEGGED, stop: o.Type == model.TRAILING_STOP}
+		exp.orders++
+		exp.notional[o.Symbol] += notional(price, o.Quantity)
+		c.accounts[o.Account] = exp
+	}
+	return nil
+}
+
+// Release drops the reservation CheckOrder made for o.
+func (c *Checker) Release(o *model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if od, ok := c.orders[o.ID]; ok {
+		c.accounts[od.account].notional[od.symbol] -= notional(od.price, od.remaining)
+		c.dropLocked(o.ID, od)
+	}
+}
+
+// dropLocked forgets a reserved order whose notional is already released.
+func (c *Checker) dropLocked(id string, od *open) {
+	delete(c.orders, id)
+	exp := c.accounts[od.account]
+	exp.orders--
+	if exp.notional[od.symbol] == 0 {
+		delete(exp.notional, od.symbol)
+	}
+	if exp.orders == 0 {
+		delete(c.accounts, od.account)
+	}
+}
+
+// CheckAmend vets a new price and total quantity for a resting order. It
+// does not reserve: the amend event moves the reservation once it applies.
+func (c *Checker) CheckAmend(symbol, orderID string, price, qty int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	od, ok := c.orders[orderID]
+	if !ok {
+		return nil // not ours to judge; the shard answers for unknown orders
+	}
+	symbol = od.symbol // the caller may route by order ID alone
+	lim := c.limitsLocked(od.account)
+	typ := model.LIMIT
+	switch {
+	case od.pegged:
+		typ, price = model.PEGGED, od.price // the peg, not the caller, sets the price
+	case od.stop:
+		typ, price = model.TRAILING_STOP, od.price // it has no price until it triggers
+	}
+	if v := c.checkOrderLocked(lim, od.account, symbol, typ, price, qty); v != nil {
+		return c.refuseLocked(v)
+	}
+	remaining := qty - (od.quantity - od.remaining)
+	if remaining <= 0 {
+		return nil // the shard rejects it
+	}
+	exp := c.accounts[od.account]
+	n := exp.notional[symbol] - notional(od.price, od.remaining) + notional(price, remaining)
+	if lim.MaxOpenNotional > 0 && n > lim.MaxOpenNotional {
+		return c.refuseLocked(&Violation{Limit: LimitOpenNotional, Account: od.account, Symbol: symbol, Value: n, Max: lim.MaxOpenNotional})
+	}
+	return nil
+}
+
+// checkOrderLocked applies the limits that need only the order itself.
+func (c *Checker) checkOrderLocked(lim Limits, account, symbol string, typ model.OrderType, price, qty int64) *Violation {
+	v := &Violation{Account: account, Symbol: symbol}
+	switch {
+	case lim.MaxOrderQty > 0 && qty > lim.MaxOrderQty:
+		v.Limit, v.Value, v.Max = LimitOrderQty, qty, lim.MaxOrderQty
+	case lim.MaxOrderNotional > 0 && notional(price, qty) > lim.MaxOrderNotional:
+		v.Limit, v.Value, v.Max = LimitOrderNotional, notional(price, qty), lim.MaxOrderNotional
+	default:
+		last := c.last[symbol]
+		if typ != model.LIMIT || lim.MaxPriceDeviationBps <= 0 || last <= 0 {
+			return nil
+		}
+		diff := price - last
+		if diff < 0 {
+			diff = -diff
+		}
+		bps := diff * 10_000 / last
+		if bps <= lim.MaxPriceDeviationBps {
+			return nil
+		}
+		v.Limit, v.Value, v.Max = LimitPriceDeviation, bps, lim.MaxPriceDeviationBps
+	}
+	return v
+}
+
+// notional is the size of qty at price, which is negative for some spreads.
+func notional(price, qty int64) int64 {
+	if n := price * qty; n < 0 {
+		return -n
+	}
+	return price * qty
+}
+
+func (c *Checker) refuseLocked(v *Violation) error {
+	c.rejected[v.Limit]++
+	return v
+}
+
+// Rejections returns how many orders each limit has refused.
+func (c *Checker) Rejections() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]uint64, len(c.rejected))
+	for k, n := range c.rejected {
+		out[k] = n
+	}
+	return out
+}
+
+// Exposure returns an account's reserved open order count and open
+// notional by symbol.
+func (c *Checker) Exposure(account string) (orders int64, notional map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	notional = make(map[string]int64)
+	exp := c.accounts[account]
+	if exp == nil {
+		return 0, notional
+	}
+	for s, n := range exp.notional {
+		notional[s] = n
+	}
+	return exp.orders, notional
+}
+
+func (c *Checker) run() {
+	defer close(c.done)
+	for ev := range c.sub.C() {
+		c.apply(&ev)
+	}
+}
+
+func (c *Checker) apply(ev *events.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ev.Type == events.TradeExecuted {
+		c.last[ev.Symbol] = ev.Trade.Price
+		return
+	}
+	od, ok := c.orders[ev.Order.ID]
+	if !ok {
+		return
+	}
+	exp := c.accounts[od.account]
+	exp.notional[od.symbol] -= notional(od.price, od.remaining)
+
+	switch ev.Type {
+	case events.OrderFilled, events.OrderAmended:
+		if od.stop && ev.Order.Triggered {
+			od.stop = false // now an order at its own price
+		}
+		if !od.pegged && !od.stop {
+			od.price = ev.Order.Price
+		}
+		od.quantity, od.remaining = ev.Order.Quantity, ev.Order.Remaining()
+	default: // cancelled or rejected
+		od.remaining = 0
+	}
+
+	if od.remaining > 0 {
+		exp.notional[od.symbol] += notional(od.price, od.remaining)
+		return
+	}
+	c.dropLocked(ev.Order.ID, od)
+}
@@ -0,0 +1,198 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/engine"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/events"
+	"github.com/2019UGEC100/order-matching-engine-go/pkg/model"
+)
+
+func setup(t *testing.T, cfg Config) (*engine.Router, *Checker) {
+	t.Helper()
+	b := events.NewBus()
+	c := NewChecker(b, cfg)
+	r := engine.NewRouter(2, 16, engine.WithEventBus(b), engine.WithPreTrade(c))
+	t.Cleanup(func() {
+		r.Stop()
+		c.Close()
+	})
+	return r, c
+}
+
+func limit(id, account, symbol string, side model.Side, price, qty int64) *model.Order {
+	return &model.Order{ID: id, Account: account, Symbol: symbol, Side: side, Type: model.LIMIT, Price: price, Quantity: qty}
+}
+
+func violation(t *testing.T, res engine.SubmitResult, want string) *Violation {
+	t.Helper()
+	var v *Violation
+	if !errors.As(res.Reject, &v) || v.Limit != want {
+		t.Fatalf("expected %s violation, got %v", want, res.Reject)
+	}
+	if res.Err != v.Error() {
+		t.Fatalf("expected Err to carry the violation, got %q", res.Err)
+	}
+	return v
+}
+
+// waitExposure waits for the bus to catch the checker up.
+func waitExposure(t *testing.T, c *Checker, account string, orders int64) map[string]int64 {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, notional := c.Exposure(account)
+		if n == orders {
+			return notional
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d open orders for %s, have %d", orders, account, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPerOrderLimits(t *testing.T) {
+	r, _ := setup(t, Config{
+		Default:  Limits{MaxOrderQty: 100, MaxOrderNotional: 50_000},
+		Accounts: map[string]Limits{"big": {MaxOrderQty: 1000}},
+	})
+
+	violation(t, r.SubmitOrder(limit("1", "a", "X", model.BUY, 100, 101)), LimitOrderQty)
+	v := violation(t, r.SubmitOrder(limit("2", "a", "X", model.BUY, 1000, 60)), LimitOrderNotional)
+	if v.Value != 60_000 || v.Max != 50_000 || v.Account != "a" || v.Symbol != "X" {
+		t.Fatalf("unexpected violation %+v", v)
+	}
+	if res := r.SubmitOrder(limit("3", "big", "X", model.BUY, 1000, 500)); res.Err != "" {
+		t.Fatalf("override should replace the default limits: %s", res.Err)
+	}
+}
+
+func TestNegativeSpreadPricesCountAsNotional(t *testing.T) {
+	b := events.NewBus()
+	c := NewChecker(b, Config{Default: Limits{MaxOrderNotional: 5_000, MaxOpenNotional: 8_000}})
+	r := engine.NewRouter(2, 16, engine.WithEventBus(b), engine.WithPreTrade(c),
+		engine.WithSpreads(engine.Spread{Symbol: "A-B", Legs: [2]engine.Leg{{Symbol: "A", Ratio: 1}, {Symbol: "B", Ratio: -1}}}))
+	t.Cleanup(func() {
+		r.Stop()
+		c.Close()
+	})
+
+	v := violation(t, r.SubmitOrder(limit("1", "a", "A-B", model.BUY, -100, 60)), LimitOrderNotional)
+	if v.Value != 6_000 {
+		t.Fatalf("expected the notional taken as 6000, got %+v", v)
+	}
+	if res := r.SubmitOrder(limit("2", "a", "A-B", model.BUY, -100, 50)); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if n := waitExposure(t, c, "a", 1)["A-B"]; n != 5_000 {
+		t.Fatalf("expected 5000 reserved, got %d", n)
+	}
+	violation(t, r.SubmitOrder(limit("3", "a", "A-B", model.BUY, -100, 40)), LimitOpenNotional)
+}
+
+func TestOpenOrdersAcrossShards(t *testing.T) {
+	r, c := setup(t, Config{Default: Limits{MaxOpenOrders: 2, MaxOpenNotional: 1_000}})
+
+	// symbols chosen to land on different shards or not, it must not matter
+	r.SubmitOrder(limit("a1", "a", "AAA", model.BUY, 100, 5))
+	r.SubmitOrder(limit("a2", "a", "ZZZ", model.BUY, 100, 5))
+	violation(t, r.SubmitOrder(limit("a3", "a", "MMM", model.BUY, 100, 1)), LimitOpenOrders)
+	if res := r.SubmitOrder(limit("b1", "b", "AAA", model.BUY, 100, 10)); res.Err != "" {
+		t.Fatalf("another account has its own limits: %s", res.Err)
+	}
+	violation(t, r.SubmitOrder(limit("b2", "b", "AAA", model.BUY, 1, 1)), LimitOpenNotional)
+
+	// a cancel and a full fill free a's two slots
+	r.CancelOrder("AAA", "a1")
+	r.SubmitOrder(limit("s", "c", "ZZZ", model.SELL, 100, 5))
+	waitExposure(t, c, "a", 0)
+	if res := r.SubmitOrder(limit("a4", "a", "MMM", model.BUY, 100, 1)); res.Err != "" {
+		t.Fatalf("expected room after cancel and fill: %s", res.Err)
+	}
+}
+
+func TestPartialFillAndAmendMoveNotional(t *testing.T) {
+	r, c := setup(t, Config{Default: Limits{MaxOpenNotional: 1_000}})
+
+	r.SubmitOrder(limit("a1", "a", "X", model.BUY, 100, 10))
+	r.SubmitOrder(limit("s", "b", "X", model.SELL, 100, 4))
+	if n := waitNotional(t, c, "a", "X", 600); n != 600 {
+		t.Fatalf("expected 600 open after a fill of 4, got %d", n)
+	}
+
+	if res := r.AmendOrder("X", "a1", 200, 10); !errors.As(res.Reject, new(*Violation)) {
+		t.Fatalf("expected the amend to 200 x 6 left to be refused, got %+v", res)
+	}
+	if res := r.AmendOrder("X", "a1", 150, 10); res.Err != "" {
+		t.Fatalf("amend within limit refused: %s", res.Err)
+	}
+	if n := waitNotional(t, c, "a", "X", 900); n != 900 {
+		t.Fatalf("expected 150 x 6 open, got %d", n)
+	}
+}
+
+func waitNotional(t *testing.T, c *Checker, account, symbol string, want int64) int64 {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, n := c.Exposure(account)
+		if n[symbol] == want || time.Now().After(deadline) {
+			return n[symbol]
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPriceDeviation(t *testing.T) {
+	r, c := setup(t, Config{Default: Limits{MaxPriceDeviationBps: 1_000}})
+
+	// no reference price yet
+	if res := r.SubmitOrder(limit("s", "a", "X", model.SELL, 100, 1)); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	r.SubmitOrder(limit("b", "b", "X", model.BUY, 100, 1))
+	waitExposure(t, c, "a", 0)
+
+	if res := r.SubmitOrder(limit("ok", "a", "X", model.BUY, 110, 1)); res.Err != "" {
+		t.Fatalf("10%% away is within the band: %s", res.Err)
+	}
+	v := violation(t, r.SubmitOrder(limit("far", "a", "X", model.SELL, 80, 1)), LimitPriceDeviation)
+	if v.Value != 2_000 {
+		t.Fatalf("expected 2000 bps, got %d", v.Value)
+	}
+
+	rej := c.Rejections()
+	if rej[LimitPriceDeviation] != 1 {
+		t.Fatalf("expected one deviation rejection counted, got %v", rej)
+	}
+}
+
+func TestAmendOfWaitingStopKeepsItsReservation(t *testing.T) {
+	r, c := setup(t, Config{Default: Limits{MaxPriceDeviationBps: 1_000, MaxOpenNotional: 5_000}})
+	r.SubmitOrder(limit("s", "b", "X", model.SELL, 100, 1))
+	r.SubmitOrder(limit("b", "c", "X", model.BUY, 100, 1))
+	waitExposure(t, c, "b", 0)
+
+	stop := &model.Order{ID: "stop", Account: "a", Symbol: "X", Side: model.SELL, Type: model.TRAILING_STOP, Quantity: 10, TrailAmount: 5}
+	if res := r.SubmitOrder(stop); res.Err != "" {
+		t.Fatal(res.Err)
+	}
+	if n := waitNotional(t, c, "a", "X", 1_000); n != 1_000 {
+		t.Fatalf("expected the stop reserved at the last trade, got %d", n)
+	}
+
+	// the REST API fills a missing price with the stop's, which is 0
+	if res := r.AmendOrder("X", "stop", 0, 20); res.Err != "" {
+		t.Fatalf("expected a quantity-only amend of the stop to pass, got %s", res.Err)
+	}
+	if n := waitNotional(t, c, "a", "X", 2_000); n != 2_000 {
+		t.Fatalf("expected 100 x 20 reserved after the amend, got %d", n)
+	}
+	var v *Violation
+	if res := r.AmendOrder("X", "stop", 0, 60); !errors.As(res.Reject, &v) || v.Limit != LimitOpenNotional || v.Value != 6_000 {
+		t.Fatalf("expected 100 x 60 over the open notional, got %+v", res)
+	}
+}